@@ -0,0 +1,176 @@
+package dbperf
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// AnomalyKind is a kind of latency anomaly
+type AnomalyKind string
+
+const (
+	AnomalySpike AnomalyKind = "spike" // the p99 stayed well above its median for a while
+	AnomalyStep  AnomalyKind = "step"  // the median latency changed for good
+)
+
+// AnomalyConfig configures the detection of latency anomalies, the zero value detecting them with the defaults
+type AnomalyConfig struct {
+	Factor      float64       // seconds with a p99 over Factor times the median p99 are spikes, 2 if 0
+	MinDuration time.Duration // spikes must last at least this long to be reported, 3s if 0
+	Window      time.Duration // the median latency is compared over this long before and after a step, 30s if 0
+	Change      float64       // steps must change the median latency by this fraction, 0.5 if 0
+}
+
+func (cfg AnomalyConfig) withDefaults() AnomalyConfig {
+	if cfg.Factor <= 0 {
+		cfg.Factor = 2
+	}
+	if cfg.MinDuration <= 0 {
+		cfg.MinDuration = 3 * time.Second
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 30 * time.Second
+	}
+	if cfg.Change <= 0 {
+		cfg.Change = 0.5
+	}
+	return cfg
+}
+
+// Anomaly is a latency anomaly found in the timeline of a run
+type Anomaly struct {
+	Kind     AnomalyKind   `json:"kind"`
+	Start    time.Duration `json:"start"`    // offset of the anomaly from the start of the run
+	End      time.Duration `json:"end"`      // end of a spike, the same as start for a step
+	Baseline time.Duration `json:"baseline"` // median p99 of the run for a spike, mean median latency before a step
+	Value    time.Duration `json:"value"`    // peak p99 of a spike, mean median latency after a step
+}
+
+func (a Anomaly) String() string {
+	if a.Kind == AnomalyStep {
+		return fmt.Sprintf("step change at %s: median %s -> %s", a.Start, a.Baseline, a.Value)
+	}
+	return fmt.Sprintf("sustained spike from %s to %s: p99 up to %s (median p99 %s)", a.Start, a.End, a.Value, a.Baseline)
+}
+
+// Anomalies analyzes the latency timeline of the run for sustained spikes of the p99 and step changes of the median
+// latency, in the order they started
+func (r *Report) Anomalies(cfg AnomalyConfig) []Anomaly {
+	cfg = cfg.withDefaults()
+	spikes := timelineSpikes(r.Timeline, cfg)
+	steps := timelineSteps(r.Timeline, cfg)
+
+	// merge the two, both being in order
+	var anomalies []Anomaly
+	for len(spikes) > 0 || len(steps) > 0 {
+		if len(steps) == 0 || len(spikes) > 0 && spikes[0].Start <= steps[0].Start {
+			anomalies = append(anomalies, spikes[0])
+			spikes = spikes[1:]
+		} else {
+			anomalies = append(anomalies, steps[0])
+			steps = steps[1:]
+		}
+	}
+	return anomalies
+}
+
+// timelineSpikes finds the runs of consecutive seconds with a p99 over cfg.Factor times the median p99 lasting at
+// least cfg.MinDuration
+func timelineSpikes(timeline []TimelineBucket, cfg AnomalyConfig) []Anomaly {
+	var p99s []time.Duration
+	for _, b := range timeline {
+		if b.Processed > 0 {
+			p99s = append(p99s, b.P99)
+		}
+	}
+	baseline := medianDuration(p99s)
+	threshold := time.Duration(float64(baseline) * cfg.Factor)
+
+	var spikes []Anomaly
+	start := -1
+	var peak time.Duration
+	end := func(i int) {
+		if start >= 0 && time.Duration(i-start)*timelineBucket >= cfg.MinDuration {
+			spikes = append(spikes, Anomaly{
+				Kind:     AnomalySpike,
+				Start:    time.Duration(start) * timelineBucket,
+				End:      time.Duration(i) * timelineBucket,
+				Baseline: baseline,
+				Value:    peak,
+			})
+		}
+		start, peak = -1, 0
+	}
+
+	for i, b := range timeline {
+		if b.Processed == 0 || b.P99 <= threshold {
+			end(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		if b.P99 > peak {
+			peak = b.P99
+		}
+	}
+	end(len(timeline))
+
+	return spikes
+}
+
+// timelineSteps finds the seconds the median latency over the cfg.Window after differs from the cfg.Window before
+// by more than cfg.Change, reporting the largest change of every run of such seconds
+func timelineSteps(timeline []TimelineBucket, cfg AnomalyConfig) []Anomaly {
+	w := int(cfg.Window / timelineBucket)
+	if w < 1 || len(timeline) < 2*w {
+		return nil
+	}
+
+	// mean of the medians of the non-empty seconds of a window, peaking at the second of a step unlike the median
+	mean := func(buckets []TimelineBucket) time.Duration {
+		var sum time.Duration
+		var n int
+		for _, b := range buckets {
+			if b.Processed > 0 {
+				sum += b.Median
+				n++
+			}
+		}
+		if n == 0 {
+			return 0
+		}
+		return sum / time.Duration(n)
+	}
+
+	limit := math.Log(1 + cfg.Change)
+	var steps []Anomaly
+	var best *Anomaly
+	var bestChange float64
+	for i := w; i <= len(timeline)-w; i++ {
+		before, after := mean(timeline[i-w:i]), mean(timeline[i:i+w])
+		change := 0.0
+		if before > 0 && after > 0 {
+			change = math.Abs(math.Log(float64(after) / float64(before)))
+		}
+
+		if change <= limit {
+			if best != nil {
+				steps = append(steps, *best)
+				best = nil
+			}
+			continue
+		}
+		if best == nil || change > bestChange {
+			at := time.Duration(i) * timelineBucket
+			best = &Anomaly{Kind: AnomalyStep, Start: at, End: at, Baseline: before, Value: after}
+			bestChange = change
+		}
+	}
+	if best != nil {
+		steps = append(steps, *best)
+	}
+
+	return steps
+}
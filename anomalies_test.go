@@ -0,0 +1,48 @@
+package dbperf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnomalies(t *testing.T) {
+	ms := time.Millisecond
+	second := func(median, p99 time.Duration) TimelineBucket {
+		return TimelineBucket{Processed: 10, Median: median, P99: p99}
+	}
+
+	// 100s at a 10ms p99 with a 4s spike at 20s, a 2s blip at 60s and the median doubling at 70s
+	var timeline []TimelineBucket
+	for i := 0; i < 100; i++ {
+		b := second(2*ms, 10*ms)
+		switch {
+		case i >= 20 && i < 24:
+			b.P99 = time.Duration(30+i) * ms
+		case i >= 60 && i < 62:
+			b.P99 = 50 * ms
+		case i == 40:
+			b = TimelineBucket{}
+		}
+		if i >= 70 {
+			b.Median = 4 * ms
+		}
+		b.Start = time.Duration(i) * time.Second
+		timeline = append(timeline, b)
+	}
+
+	r := &Report{QueryStats: &QueryStats{}, Timeline: timeline}
+	assert.Equal(t, []Anomaly{
+		{Kind: AnomalySpike, Start: 20 * time.Second, End: 24 * time.Second, Baseline: 10 * ms, Value: 53 * ms},
+		{Kind: AnomalyStep, Start: 70 * time.Second, End: 70 * time.Second, Baseline: 2 * ms, Value: 4 * ms},
+	}, r.Anomalies(AnomalyConfig{}))
+
+	// shorter spikes and smaller steps with a lower bar
+	anomalies := r.Anomalies(AnomalyConfig{MinDuration: 2 * time.Second, Change: 0.2})
+	assert.Len(t, anomalies, 3)
+	assert.Equal(t, 60*time.Second, anomalies[1].Start)
+
+	assert.Empty(t, (&Report{QueryStats: &QueryStats{}}).Anomalies(AnomalyConfig{}))
+	assert.Equal(t, "step change at 1m10s: median 2ms -> 4ms", anomalies[2].String())
+}
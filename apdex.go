@@ -0,0 +1,43 @@
+package dbperf
+
+import (
+	"sort"
+	"time"
+)
+
+// Apdex returns the Apdex score (0-1) of the queries of the run for the target latency T: queries completing within T
+// are satisfied, within 4T tolerating and the rest, like those that failed, frustrated. The score is the satisfied
+// plus half the tolerating queries over all of them, 1 if there were none. It's exact when the stats have every
+// latency, otherwise within the precision of the histogram.
+func (s *QueryStats) Apdex(target time.Duration) float64 {
+	total := s.Processed + s.Errors
+	if total == 0 {
+		return 1
+	}
+
+	var satisfied, tolerating int64
+	switch {
+	case int64(len(s.Latencies)) == s.Processed:
+		satisfied = int64(sort.Search(len(s.Latencies), func(i int) bool { return s.Latencies[i] > target }))
+		tolerating = int64(sort.Search(len(s.Latencies), func(i int) bool { return s.Latencies[i] > 4*target })) - satisfied
+	case s.Histogram != nil:
+		satisfied = s.Histogram.countAtMost(target)
+		tolerating = s.Histogram.countAtMost(4*target) - satisfied
+	default:
+		return 0
+	}
+
+	return (float64(satisfied) + float64(tolerating)/2) / float64(total)
+}
+
+// countAtMost returns the # of latencies counted at most d, within the histogram precision
+func (h *Histogram) countAtMost(d time.Duration) int64 {
+	last := histogramIndex(d)
+	var n int64
+	for idx, count := range h.Counts {
+		if idx <= last {
+			n += count
+		}
+	}
+	return n
+}
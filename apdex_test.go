@@ -0,0 +1,29 @@
+package dbperf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApdex(t *testing.T) {
+	ms := time.Millisecond
+	latencies := []time.Duration{5 * ms, 10 * ms, 20 * ms, 30 * ms, 50 * ms, 100 * ms}
+
+	// 2 satisfied, 2 tolerating and 2 frustrated
+	stats := calculateStats(latencies)
+	stats.Latencies = latencies
+	assert.InDelta(t, 0.5, stats.Apdex(10*ms), 1e-9)
+
+	// failed queries are frustrated
+	stats.Errors = 2
+	assert.InDelta(t, 0.375, stats.Apdex(10*ms), 1e-9)
+
+	// from the histogram when the latencies weren't kept
+	streamed := NewHistogram(latencies).stats()
+	assert.InDelta(t, 0.5, streamed.Apdex(10*ms), 1e-9)
+	assert.InDelta(t, 1.0, streamed.Apdex(time.Second), 1e-9)
+
+	assert.Equal(t, 1.0, (&QueryStats{}).Apdex(10*ms))
+}
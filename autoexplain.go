@@ -0,0 +1,153 @@
+package dbperf
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// serverLogQuery returns the log file the server currently writes to, relative to its data directory, and its size
+	serverLogQuery = `SELECT f, (pg_stat_file(f)).size FROM pg_current_logfile() f;`
+
+	// readServerLogQuery reads $3 bytes of the server log file $1 from offset $2
+	readServerLogQuery = `SELECT pg_read_file($1, $2, $3);`
+)
+
+// AutoExplainConfig configures the auto_explain module on the sessions of a run (see WithAutoExplain)
+type AutoExplainConfig struct {
+	Threshold time.Duration // the plans of the statements taking at least this long are logged
+	Analyze   bool          // log the actual row counts and times of the plans (EXPLAIN ANALYZE), at an overhead
+}
+
+// settings returns the statements configuring auto_explain on a session
+func (cfg AutoExplainConfig) settings() []string {
+	threshold := strconv.FormatInt(cfg.Threshold.Milliseconds(), 10)
+	return []string{
+		"LOAD 'auto_explain'",
+		"SET auto_explain.log_min_duration = " + threshold,
+		"SET auto_explain.log_analyze = " + strconv.FormatBool(cfg.Analyze),
+	}
+}
+
+// WithAutoExplain returns a ConnectFunc that loads auto_explain on every connection opened by connect, for the server
+// to log the plans of the statements slower than the threshold of cfg. Loading auto_explain requires superuser unless
+// it's installed in the plugins directory of the server. The settings are reset when the connection is closed.
+func WithAutoExplain(connect ConnectFunc, cfg AutoExplainConfig) ConnectFunc {
+	return func(ctx context.Context) (Conn, error) {
+		conn, err := connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range cfg.settings() {
+			if _, err := conn.ExecContext(ctx, s); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("failed to enable auto_explain: %s", err)
+			}
+		}
+
+		return &autoExplainConn{conn}, nil
+	}
+}
+
+// autoExplainConn disables auto_explain on the connection when closed
+type autoExplainConn struct {
+	Conn
+}
+
+func (c *autoExplainConn) Close() error {
+	_, err := c.ExecContext(context.Background(), "SET auto_explain.log_min_duration = -1")
+	if cerr := c.Conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// LogPosition is a position in the log of the server
+type LogPosition struct {
+	File   string // log file relative to the data directory of the server
+	Offset int64
+}
+
+// ServerLogPosition returns the end of the log file the server currently writes to. The server must run the logging
+// collector and reading its log requires superuser or the pg_read_server_files role.
+func ServerLogPosition(ctx context.Context, db Queryable) (LogPosition, error) {
+	var p LogPosition
+	err := db.QueryRowContext(ctx, serverLogQuery).Scan(&p.File, &p.Offset)
+	if err == nil && p.File == "" {
+		err = fmt.Errorf("the server doesn't run the logging collector")
+	}
+	return p, err
+}
+
+// ReadServerLog reads the log the server wrote since the position, from the start of the current log file if it
+// rotated to another since
+func ReadServerLog(ctx context.Context, db Queryable, since LogPosition) (string, error) {
+	end, err := ServerLogPosition(ctx, db)
+	if err != nil {
+		return "", err
+	}
+
+	offset := since.Offset
+	if end.File != since.File || end.Offset < offset {
+		offset = 0
+	}
+	if end.Offset == offset {
+		return "", nil
+	}
+
+	var log string
+	err = db.QueryRowContext(ctx, readServerLogQuery, end.File, offset, end.Offset-offset).Scan(&log)
+	return log, err
+}
+
+// LoggedPlan is the plan of a statement logged by auto_explain
+type LoggedPlan struct {
+	Duration time.Duration `json:"duration"` // time taken by the statement
+	Query    string        `json:"query"`
+	Plan     string        `json:"plan"`
+}
+
+// ParsePlans parses the plans auto_explain logged in text format from a server log in the default stderr format, the
+// lines of a plan following the line of the statement indented by a tab
+func ParsePlans(log string) []LoggedPlan {
+	var plans []LoggedPlan
+	var plan *LoggedPlan
+	var lines []string
+	flush := func() {
+		if plan != nil {
+			plan.Plan = strings.Join(lines, "\n")
+			plans = append(plans, *plan)
+		}
+		plan, lines = nil, nil
+	}
+
+	for _, line := range strings.Split(log, "\n") {
+		if plan != nil && strings.HasPrefix(line, "\t") {
+			line = line[1:]
+			if plan.Query == "" && strings.HasPrefix(line, "Query Text: ") {
+				plan.Query = strings.TrimPrefix(line, "Query Text: ")
+				continue
+			}
+			lines = append(lines, line)
+			continue
+		}
+		flush()
+
+		i := strings.Index(line, "duration: ")
+		if i < 0 || !strings.HasSuffix(strings.TrimSpace(line), "plan:") {
+			continue
+		}
+		var ms float64
+		if _, err := fmt.Sscanf(line[i:], "duration: %f ms", &ms); err != nil {
+			continue
+		}
+		plan = &LoggedPlan{Duration: time.Duration(ms * float64(time.Millisecond))}
+	}
+	flush()
+
+	return plans
+}
@@ -0,0 +1,91 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlans(t *testing.T) {
+	log := `2024-01-01 00:00:00.000 UTC [42] LOG:  duration: 152.250 ms  plan:
+	Query Text: SELECT max(usage) FROM cpu_usage WHERE host = $1
+	Aggregate  (cost=10.00..10.01 rows=1 width=8)
+	  ->  Seq Scan on cpu_usage  (cost=0.00..9.00 rows=400 width=8)
+2024-01-01 00:00:01.000 UTC [43] LOG:  checkpoint starting: time
+2024-01-01 00:00:02.000 UTC [42] LOG:  duration: 101.5 ms  plan:
+	Query Text: SELECT 1
+	Result  (cost=0.00..0.01 rows=1 width=4)
+`
+
+	plans := ParsePlans(log)
+	require.Len(t, plans, 2)
+	assert.Equal(t, LoggedPlan{
+		Duration: 152250 * time.Microsecond,
+		Query:    "SELECT max(usage) FROM cpu_usage WHERE host = $1",
+		Plan:     "Aggregate  (cost=10.00..10.01 rows=1 width=8)\n  ->  Seq Scan on cpu_usage  (cost=0.00..9.00 rows=400 width=8)",
+	}, plans[0])
+	assert.Equal(t, 101500*time.Microsecond, plans[1].Duration)
+	assert.Equal(t, "SELECT 1", plans[1].Query)
+	assert.Empty(t, ParsePlans("LOG:  duration: 1.0 ms  statement: SELECT 1\n"))
+}
+
+func TestWithAutoExplain(t *testing.T) {
+	var executed []string
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		executed = append(executed, query)
+		return nil, nil
+	})
+	defer db.Close()
+
+	connect := WithAutoExplain(SQLConnector(db), AutoExplainConfig{Threshold: 250 * time.Millisecond, Analyze: true})
+	conn, err := connect(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	assert.Equal(t, []string{
+		"LOAD 'auto_explain'",
+		"SET auto_explain.log_min_duration = 250",
+		"SET auto_explain.log_analyze = true",
+		"SET auto_explain.log_min_duration = -1",
+	}, executed)
+}
+
+func TestReadServerLog(t *testing.T) {
+	file, size := "log/postgresql.log", int64(100)
+	var read []driver.Value
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		if strings.Contains(query, "pg_read_file") {
+			read = args
+			return []string{"log"}, [][]driver.Value{{"LOG:  ..."}}
+		}
+		return []string{"f", "size"}, [][]driver.Value{{file, size}}
+	})
+	defer db.Close()
+
+	ctx := context.Background()
+	pos, err := ServerLogPosition(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, LogPosition{File: file, Offset: 100}, pos)
+
+	// nothing logged since
+	log, err := ReadServerLog(ctx, db, pos)
+	require.NoError(t, err)
+	assert.Empty(t, log)
+
+	size = 150
+	log, err = ReadServerLog(ctx, db, pos)
+	require.NoError(t, err)
+	assert.Equal(t, "LOG:  ...", log)
+	assert.Equal(t, []driver.Value{file, int64(100), int64(50)}, read)
+
+	// rotated to another file
+	file = "log/postgresql.1.log"
+	_, err = ReadServerLog(ctx, db, pos)
+	require.NoError(t, err)
+	assert.Equal(t, []driver.Value{file, int64(0), int64(150)}, read)
+}
@@ -0,0 +1,50 @@
+package dbperf
+
+// background is a task running alongside the queries of a run until the run ends, e.g. sampling the server or
+// injecting faults, collecting stats of type S
+type background[S any] struct {
+	stop    chan struct{}
+	done    chan S
+	stopped bool
+	stats   *S
+}
+
+// backgroundTask is a background task of any stats, for RunTest to stop all of them in one place however it ends
+type backgroundTask interface {
+	halt()
+}
+
+// startBackground starts run in the background, adding it to the tasks to stop. run returns when stop is closed,
+// sending its stats on done.
+func startBackground[S any](tasks *[]backgroundTask, run func(stop <-chan struct{}, done chan<- S)) *background[S] {
+	b := &background[S]{stop: make(chan struct{}), done: make(chan S, 1)}
+	go run(b.stop, b.done)
+	*tasks = append(*tasks, b)
+	return b
+}
+
+// halt stops the task and waits for its stats, stopping a task already stopped does nothing
+func (b *background[S]) halt() {
+	if b.stopped {
+		return
+	}
+	b.stopped = true
+	close(b.stop)
+	s := <-b.done
+	b.stats = &s
+}
+
+// result returns the stats of the task once halted, nil for a task that was never started
+func (b *background[S]) result() *S {
+	if b == nil {
+		return nil
+	}
+	return b.stats
+}
+
+// haltAll stops the tasks, in the order they were started
+func haltAll(tasks []backgroundTask) {
+	for _, t := range tasks {
+		t.halt()
+	}
+}
@@ -0,0 +1,43 @@
+package dbperf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackground(t *testing.T) {
+	var tasks []backgroundTask
+	runs := 0
+	b := startBackground(&tasks, func(stop <-chan struct{}, done chan<- int) {
+		runs++
+		<-stop
+		done <- 42
+	})
+	assert.Len(t, tasks, 1)
+	assert.Nil(t, b.result())
+
+	haltAll(tasks)
+	haltAll(tasks)
+	assert.Equal(t, 42, *b.result())
+	assert.Equal(t, 1, runs)
+
+	var never *background[int]
+	assert.Nil(t, never.result())
+}
+
+// runInBackground runs SELECT 1 at 100 qps on a single worker against db for d with the controller configured by
+// configure, for the tests of the tasks running in the background of runs
+func runInBackground(t *testing.T, db Queryable, d time.Duration, configure func(c *Controller)) *Report {
+	c := NewController(WithPoolSize(1))
+	c.SetRateLimit(100)
+	c.SetDuration(d)
+	configure(c)
+
+	report, err := c.RunTest(context.Background(), db, &repeatGenerator{Query{Query: "SELECT 1"}})
+	require.NoError(t, err)
+	return report
+}
@@ -0,0 +1,70 @@
+package dbperf
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+// Benchmark runs the queries of gen against db as a Go benchmark, for database performance tests to live in regular
+// go test -bench suites. b.N queries are executed by a pool of poolSize workers, replaying the queries of gen from the
+// start as often as needed. The reported ns/op is the wall clock time per query, the inverse of the throughput, and
+// the median, p95 and p99 latency and the throughput are reported as additional metrics.
+//
+// gen is read in full on every call, create it within the benchmark function:
+//
+//	func BenchmarkMinMax(b *testing.B) {
+//		f, err := os.Open("queries.csv")
+//		if err != nil {
+//			b.Fatal(err)
+//		}
+//		defer f.Close()
+//		dbperf.Benchmark(b, db, dbperf.NewCPUTestGenerator(f), 8)
+//	}
+func Benchmark(b *testing.B, db Queryable, gen QueryGenerator, poolSize int) {
+	b.Helper()
+
+	var queries []*Query
+	for {
+		q, err := gen.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			b.Fatalf("failed to read queries: %s", err)
+		}
+		queries = append(queries, q)
+	}
+	if len(queries) == 0 {
+		b.Fatal("no queries to benchmark")
+	}
+
+	b.ResetTimer()
+	stats, err := NewController(WithPoolSize(poolSize)).RunTest(context.Background(), db, &replayGenerator{queries: queries, n: b.N})
+	b.StopTimer()
+	if err != nil {
+		b.Fatalf("test run failed: %s", err)
+	}
+
+	b.ReportMetric(float64(stats.Median), "median-ns")
+	b.ReportMetric(float64(stats.P95), "p95-ns")
+	b.ReportMetric(float64(stats.P99), "p99-ns")
+	b.ReportMetric(stats.Throughput(), "qps")
+}
+
+// replayGenerator generates n queries cycling through a fixed set of them
+type replayGenerator struct {
+	queries []*Query
+	n, i    int
+}
+
+func (g *replayGenerator) Next() (*Query, error) {
+	if g.i >= g.n {
+		return nil, io.EOF
+	}
+
+	// a copy, as wrapping generators may modify the query while a worker still executes the last one generated
+	q := *g.queries[g.i%len(g.queries)]
+	g.i++
+	return &q, nil
+}
@@ -0,0 +1,32 @@
+package dbperf
+
+import (
+	"strings"
+	"testing"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBenchmark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var executed int
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Do(func(...interface{}) {
+		executed++
+	}).Return(nil, nil).AnyTimes()
+
+	res := testing.Benchmark(func(b *testing.B) {
+		executed = 0
+		Benchmark(b, mdb, NewCPUTestGenerator(strings.NewReader(testQueries)), 1)
+	})
+
+	// the 10 queries are replayed for as many queries as the benchmark asked for
+	assert.True(t, res.N > 10)
+	assert.Equal(t, res.N, executed)
+	assert.Contains(t, res.Extra, "p99-ns")
+	assert.Contains(t, res.Extra, "qps")
+}
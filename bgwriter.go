@@ -0,0 +1,174 @@
+package dbperf
+
+import (
+	"context"
+	"time"
+)
+
+// checkpointerActiveQuery returns whether the checkpointer is busy with a checkpoint, an idle checkpointer waiting
+// in its main loop
+const checkpointerActiveQuery = `SELECT count(*) FILTER (WHERE wait_event IS DISTINCT FROM 'CheckpointerMain') > 0
+	FROM pg_stat_activity WHERE backend_type = 'checkpointer';`
+
+// hasCheckpointerQuery returns whether the server has pg_stat_checkpointer (PostgreSQL 17+), which took the checkpoint
+// counters over from pg_stat_bgwriter
+const hasCheckpointerQuery = `SELECT to_regclass('pg_catalog.pg_stat_checkpointer') IS NOT NULL;`
+
+// checkpointCountsQuery and bgwriterCountsQuery return the # checkpoints the server performed on schedule and on
+// request since the statistics were reset
+const (
+	checkpointCountsQuery = `SELECT num_timed, num_requested FROM pg_stat_checkpointer;`
+	bgwriterCountsQuery   = `SELECT checkpoints_timed, checkpoints_req FROM pg_stat_bgwriter;`
+)
+
+// defaultBgWriterInterval is how often checkpoint activity is sampled by default, the resolution of the timeline
+const defaultBgWriterInterval = timelineBucket
+
+// BgWriterConfig configures the sampling of checkpoint activity during a run
+type BgWriterConfig struct {
+	Interval time.Duration // how often to sample, every second if 0
+}
+
+func (cfg BgWriterConfig) interval() time.Duration {
+	if cfg.Interval > 0 {
+		return cfg.Interval
+	}
+	return defaultBgWriterInterval
+}
+
+// BgWriterSpan is a checkpoint observed in progress during a run
+type BgWriterSpan struct {
+	Start time.Duration // offset of the first sample that found the checkpoint in progress from the start of the run
+	End   time.Duration // offset of the last sample that found it in progress
+}
+
+// BgWriterStats reports the checkpoint activity sampled during a run (see SetBgWriterSampling)
+type BgWriterStats struct {
+	Interval  time.Duration  // time between samples
+	Samples   int64          // # samples taken
+	Failures  int64          // # samples that failed
+	Timed     int64          // # checkpoints the server performed on schedule (checkpoint_timeout) during the run
+	Requested int64          // # checkpoints requested, e.g. by max_wal_size being reached or a CHECKPOINT command
+	Spans     []BgWriterSpan // the checkpoints observed in progress
+}
+
+// bgWriterSampler samples the checkpointer of the server until stopped
+type bgWriterSampler struct {
+	cfg    BgWriterConfig
+	db     Queryable
+	start  time.Time
+	stats  BgWriterStats
+	counts string // query of the checkpoint counters of the server, empty if they couldn't be read
+	active bool   // whether the last sample found a checkpoint in progress
+}
+
+func newBgWriterSampler(cfg BgWriterConfig, db Queryable, start time.Time) *bgWriterSampler {
+	return &bgWriterSampler{
+		cfg:   cfg,
+		db:    db,
+		start: start,
+		stats: BgWriterStats{Interval: cfg.interval()},
+	}
+}
+
+// run samples checkpoint activity until stop is closed, the stats are sent on done when finished
+func (cs *bgWriterSampler) run(stop <-chan struct{}, done chan<- BgWriterStats) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	timed, requested, countsErr := cs.readCounts(ctx)
+	cs.sample(ctx, time.Now())
+
+	ticker := time.NewTicker(cs.stats.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			cs.sample(ctx, now)
+		case <-stop:
+			if countsErr == nil {
+				if t, r, err := cs.readCounts(ctx); err == nil {
+					cs.stats.Timed, cs.stats.Requested = t-timed, r-requested
+				}
+			}
+			done <- cs.stats
+			return
+		}
+	}
+}
+
+// readCounts reads the # checkpoints the server performed on schedule and on request
+func (cs *bgWriterSampler) readCounts(ctx context.Context) (timed, requested int64, err error) {
+	if cs.counts == "" {
+		var checkpointer bool
+		if err := cs.db.QueryRowContext(ctx, hasCheckpointerQuery).Scan(&checkpointer); err != nil {
+			return 0, 0, err
+		}
+		cs.counts = bgwriterCountsQuery
+		if checkpointer {
+			cs.counts = checkpointCountsQuery
+		}
+	}
+	err = cs.db.QueryRowContext(ctx, cs.counts).Scan(&timed, &requested)
+	return timed, requested, err
+}
+
+// sample checks whether a checkpoint is in progress, extending the current span or starting a new one
+func (cs *bgWriterSampler) sample(ctx context.Context, now time.Time) {
+	cs.stats.Samples++
+	var active bool
+	if err := cs.db.QueryRowContext(ctx, checkpointerActiveQuery).Scan(&active); err != nil {
+		cs.stats.Failures++
+		return
+	}
+
+	at := now.Sub(cs.start)
+	switch {
+	case active && cs.active:
+		cs.stats.Spans[len(cs.stats.Spans)-1].End = at
+	case active:
+		cs.stats.Spans = append(cs.stats.Spans, BgWriterSpan{Start: at, End: at})
+	}
+	cs.active = active
+}
+
+// markBgWriter marks the buckets of the timeline a checkpoint was observed in progress during
+func markBgWriter(timeline []TimelineBucket, spans []BgWriterSpan) {
+	for _, s := range spans {
+		for i := int(s.Start / timelineBucket); i <= int(s.End/timelineBucket) && i < len(timeline); i++ {
+			if i >= 0 {
+				timeline[i].Checkpointing = true
+			}
+		}
+	}
+}
+
+// BgWriterCorrelation compares the latency of the seconds of a run a checkpoint was in progress during to the
+// rest, to tell whether checkpoints explain periodic latency spikes
+type BgWriterCorrelation struct {
+	Seconds          int           // # seconds of the timeline of the run
+	Checkpointing    int           // # seconds with a checkpoint in progress
+	CheckpointingP99 time.Duration // median p99 of the seconds with a checkpoint in progress
+	OtherP99         time.Duration // median p99 of the seconds without
+	Spikes           int           // # seconds whose p99 was over twice the median p99 of all seconds
+	CheckpointSpikes int           // # spikes in seconds with a checkpoint in progress
+}
+
+// BgWriterCorrelation correlates the checkpoints sampled during the run with its latency timeline, nil if
+// checkpoint activity wasn't sampled
+func (r *Report) BgWriterCorrelation() *BgWriterCorrelation {
+	if r.BgWriter == nil {
+		return nil
+	}
+
+	c := correlate(r.Timeline, func(i int) bool { return r.Timeline[i].Checkpointing })
+	return &BgWriterCorrelation{
+		Seconds:          c.seconds,
+		Checkpointing:    c.marked,
+		CheckpointingP99: c.markedP99,
+		OtherP99:         c.otherP99,
+		Spikes:           c.spikes,
+		CheckpointSpikes: c.markedSpikes,
+	}
+}
@@ -0,0 +1,95 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBgWriterSample(t *testing.T) {
+	active := []bool{false, true, true, false, true}
+	var n int
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		rows := [][]driver.Value{{active[n]}}
+		n++
+		return []string{"active"}, rows
+	})
+	defer db.Close()
+
+	start := time.Now()
+	cs := newBgWriterSampler(BgWriterConfig{}, db, start)
+	for i := range active {
+		cs.sample(context.Background(), start.Add(time.Duration(i)*time.Second))
+	}
+
+	s := cs.stats
+	assert.Equal(t, defaultBgWriterInterval, s.Interval)
+	assert.Equal(t, int64(5), s.Samples)
+	assert.Equal(t, []BgWriterSpan{{Start: time.Second, End: 2 * time.Second}, {Start: 4 * time.Second, End: 4 * time.Second}}, s.Spans)
+
+	timeline := make([]TimelineBucket, 4)
+	markBgWriter(timeline, s.Spans)
+	var marked []bool
+	for _, b := range timeline {
+		marked = append(marked, b.Checkpointing)
+	}
+	assert.Equal(t, []bool{false, true, true, false}, marked)
+}
+
+func TestCheckpointCorrelation(t *testing.T) {
+	r := &Report{QueryStats: &QueryStats{}}
+	assert.Nil(t, r.BgWriterCorrelation())
+
+	ms := time.Millisecond
+	r.BgWriter = &BgWriterStats{}
+	r.Timeline = []TimelineBucket{
+		{Processed: 10, P99: 10 * ms},
+		{Processed: 10, P99: 50 * ms, Checkpointing: true},
+		{Processed: 10, P99: 14 * ms, Checkpointing: true},
+		{Checkpointing: true},
+		{Processed: 10, P99: 11 * ms},
+		{Processed: 10, P99: 30 * ms},
+	}
+
+	assert.Equal(t, &BgWriterCorrelation{
+		Seconds:          5,
+		Checkpointing:    2,
+		CheckpointingP99: 14 * ms,
+		OtherP99:         11 * ms,
+		Spikes:           2,
+		CheckpointSpikes: 1,
+	}, r.BgWriterCorrelation())
+}
+
+func TestRunTestBgWriter(t *testing.T) {
+	var timed int64
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		switch {
+		case strings.Contains(query, "to_regclass"):
+			return []string{"exists"}, [][]driver.Value{{false}}
+		case strings.Contains(query, "pg_stat_bgwriter"):
+			timed += 2
+			return []string{"timed", "requested"}, [][]driver.Value{{timed, int64(1)}}
+		case strings.Contains(query, "checkpointer"):
+			return []string{"active"}, [][]driver.Value{{true}}
+		}
+		return nil, nil
+	})
+	defer db.Close()
+
+	report := runInBackground(t, db, 100*time.Millisecond, func(c *Controller) {
+		c.SetBgWriterSampling(BgWriterConfig{Interval: 10 * time.Millisecond})
+	})
+	require.NotNil(t, report.BgWriter)
+	assert.Equal(t, int64(2), report.BgWriter.Timed)
+	assert.Equal(t, int64(0), report.BgWriter.Requested)
+	require.Len(t, report.BgWriter.Spans, 1)
+	require.NotEmpty(t, report.Timeline)
+	assert.True(t, report.Timeline[0].Checkpointing)
+	assert.NotNil(t, report.BgWriterCorrelation())
+}
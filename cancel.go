@@ -0,0 +1,102 @@
+package dbperf
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CancelConfig configures the cancellation of in-flight queries
+type CancelConfig struct {
+	Fraction float64       // fraction (0-1) of queries to cancel
+	After    time.Duration // how long after a query starts it is cancelled
+}
+
+// CancelStats reports how the server responded to cancelled queries
+type CancelStats struct {
+	Attempted int64 // # queries selected for cancellation
+	Cancelled int64 // # queries that were cancelled
+	Completed int64 // # queries that completed before they could be cancelled
+
+	// Latency is the time between cancelling a query and the server releasing it, i.e. the query returning
+	Latency *QueryStats
+}
+
+// cancellation is the outcome of cancelling a single query
+type cancellation struct {
+	completed bool          // the query completed before it was cancelled
+	latency   time.Duration // time between the cancel and the query returning
+}
+
+// canceller selects queries for cancellation at random
+type canceller struct {
+	cfg CancelConfig
+	rnd *rand.Rand
+}
+
+func newCanceller(cfg CancelConfig, seed int64) *canceller {
+	return &canceller{
+		cfg: cfg,
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// selected returns true if the next query should be cancelled
+func (c *canceller) selected() bool {
+	return c.rnd.Float64() < c.cfg.Fraction
+}
+
+// execCancelled executes the query on db cancelling its context after the configured delay. The query error is
+// returned unless the query was cancelled as intended.
+func (c *canceller) execCancelled(ctx context.Context, db Queryable, q *Query) (*cancellation, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cancelled := make(chan time.Time, 1)
+	timer := time.AfterFunc(c.cfg.After, func() {
+		cancelled <- time.Now()
+		cancel()
+	})
+
+	_, err := db.ExecContext(ctx, q.Query, q.Args...)
+	end := time.Now()
+	timer.Stop()
+
+	select {
+	case at := <-cancelled:
+		if at.Before(end) && isCancelError(err) {
+			return &cancellation{latency: end.Sub(at)}, nil
+		}
+	default:
+	}
+
+	return &cancellation{completed: true}, err
+}
+
+// isCancelError returns true if err is the result of a query being cancelled
+func isCancelError(err error) bool {
+	if err == context.Canceled {
+		return true
+	}
+
+	// query_canceled
+	if e, ok := err.(*pq.Error); ok && e.Code == "57014" {
+		return true
+	}
+
+	return false
+}
+
+// activeSessionsQuery counts the benchmark's sessions, other than the one running it, still executing a query
+const activeSessionsQuery = `SELECT count(*) FROM pg_stat_activity
+	WHERE application_name = $1 AND datname = current_database() AND pid <> pg_backend_pid() AND state = 'active';`
+
+// ActiveSessions returns the number of sessions with the given application name that are still executing a query,
+// e.g. to verify the server released every cancelled query once a run has finished
+func ActiveSessions(ctx context.Context, db Queryable, applicationName string) (int, error) {
+	var n int
+	err := db.QueryRowContext(ctx, activeSessionsQuery, applicationName).Scan(&n)
+	return n, err
+}
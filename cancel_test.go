@@ -0,0 +1,80 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingExec blocks until the query is cancelled and returns the error the server would
+func blockingExec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	<-ctx.Done()
+	time.Sleep(time.Millisecond)
+	return nil, &pq.Error{Code: "57014", Message: "canceling statement due to user request"}
+}
+
+func TestExecCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	query := &Query{Query: "SELECT pg_sleep(10)"}
+	c := newCanceller(CancelConfig{Fraction: 1, After: time.Millisecond * 5}, 1)
+
+	t.Run("cancelled", func(t *testing.T) {
+		mdb := mock_dbperf.NewMockQueryable(ctrl)
+		mdb.EXPECT().ExecContext(gomock.Any(), query.Query).DoAndReturn(blockingExec)
+
+		cancel, err := c.execCancelled(context.Background(), mdb, query)
+		assert.NoError(t, err)
+		assert.False(t, cancel.completed)
+		assert.True(t, cancel.latency >= time.Millisecond)
+	})
+
+	t.Run("completed", func(t *testing.T) {
+		mdb := mock_dbperf.NewMockQueryable(ctrl)
+		mdb.EXPECT().ExecContext(gomock.Any(), query.Query).Return(nil, nil)
+
+		cancel, err := c.execCancelled(context.Background(), mdb, query)
+		assert.NoError(t, err)
+		assert.True(t, cancel.completed)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		queryErr := errors.New("relation does not exist")
+
+		mdb := mock_dbperf.NewMockQueryable(ctrl)
+		mdb.EXPECT().ExecContext(gomock.Any(), query.Query).Return(nil, queryErr)
+
+		cancel, err := c.execCancelled(context.Background(), mdb, query)
+		assert.Equal(t, queryErr, err)
+		assert.True(t, cancel.completed)
+	})
+}
+
+func TestRunTestCancellation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(blockingExec).Times(10)
+
+	c := NewController(WithPoolSize(4))
+	c.SetCancellation(CancelConfig{Fraction: 1, After: time.Millisecond})
+
+	stats, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Processed)
+	if assert.NotNil(t, stats.Cancellations) {
+		assert.Equal(t, int64(10), stats.Cancellations.Attempted)
+		assert.Equal(t, int64(10), stats.Cancellations.Cancelled)
+		assert.Equal(t, int64(10), stats.Cancellations.Latency.Processed)
+	}
+}
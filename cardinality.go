@@ -0,0 +1,61 @@
+package dbperf
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// NewCardinalityGenerator returns a generator of the queries of gen retargeted at hosts distinct hosts, every query
+// targeting one of them at random instead of its own host (its first argument and key), named host_000000 and up as
+// GenerateQueryParams names them. Running the same workload with a growing # hosts tells how the latency of the
+// queries grows with the cardinality of the keys. The results expected of the queries are dropped, they no longer
+// apply.
+func NewCardinalityGenerator(gen QueryGenerator, hosts int, seed int64) QueryGenerator {
+	return &cardinalityGenerator{gen: gen, hosts: hosts, rnd: rand.New(rand.NewSource(seed))}
+}
+
+type cardinalityGenerator struct {
+	gen   QueryGenerator
+	hosts int
+	rnd   *rand.Rand
+}
+
+func (g *cardinalityGenerator) Next() (*Query, error) {
+	q, err := g.gen.Next()
+	if err != nil {
+		return nil, err
+	}
+	if len(q.Args) == 0 {
+		return nil, fmt.Errorf("query has no host argument: %s", q.Query)
+	}
+
+	host := fmt.Sprintf("host_%06d", g.rnd.Intn(g.hosts))
+	args := append([]interface{}{host}, q.Args[1:]...)
+	q.Args = args
+	q.Space = host
+	q.key = host
+	q.Expect = nil
+	return q, nil
+}
+
+// CardinalityStep is a run of a workload against a # distinct hosts (see NewCardinalityGenerator)
+type CardinalityStep struct {
+	Hosts int
+	Stats *QueryStats
+}
+
+// CardinalityBreakpoint returns the index of the first step, by increasing # hosts, whose p99 is more than factor times
+// the p99 of the first step, where the queries fall over, -1 if none is
+func CardinalityBreakpoint(steps []CardinalityStep, factor float64) int {
+	if len(steps) == 0 {
+		return -1
+	}
+
+	base := float64(steps[0].Stats.P99)
+	for i, s := range steps[1:] {
+		if float64(s.Stats.P99) > factor*base {
+			return i + 1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,50 @@
+package dbperf
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCardinalityGenerator(t *testing.T) {
+	input := "hostname,start_time,end_time,expected\n"
+	for i := 0; i < 100; i++ {
+		input += "host_000001,2017-01-01 08:00:00,2017-01-01 09:00:00,1\n"
+	}
+
+	g := NewCardinalityGenerator(NewCPUTestGenerator(strings.NewReader(input)), 5, 1)
+	hosts := make(map[string]int)
+	for {
+		q, err := g.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		host := q.Args[0].(string)
+		hosts[host]++
+		assert.Equal(t, host, q.Space)
+		assert.Equal(t, host, q.key)
+		assert.Equal(t, []interface{}{host, "2017-01-01 08:00:00", "2017-01-01 09:00:00"}, q.Args)
+		assert.Nil(t, q.Expect)
+	}
+	assert.Len(t, hosts, 5)
+	for host := range hosts {
+		assert.Contains(t, []string{"host_000000", "host_000001", "host_000002", "host_000003", "host_000004"}, host)
+	}
+}
+
+func TestCardinalityBreakpoint(t *testing.T) {
+	step := func(hosts int, p99 time.Duration) CardinalityStep {
+		return CardinalityStep{Hosts: hosts, Stats: &QueryStats{P99: p99}}
+	}
+
+	assert.Equal(t, -1, CardinalityBreakpoint(nil, 2))
+	steps := []CardinalityStep{step(10, 10*time.Millisecond), step(1000, 15*time.Millisecond), step(100000, 40*time.Millisecond)}
+	assert.Equal(t, 2, CardinalityBreakpoint(steps, 2))
+	assert.Equal(t, -1, CardinalityBreakpoint(steps, 5))
+}
@@ -0,0 +1,86 @@
+package dbperf
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// terminateQuery terminates a random session of the benchmark other than the one running it
+const terminateQuery = `SELECT pg_terminate_backend(pid) FROM pg_stat_activity
+	WHERE application_name = $1 AND datname = current_database() AND pid <> pg_backend_pid()
+	ORDER BY random() LIMIT 1;`
+
+// ChaosConfig configures the chaos injected during a run
+type ChaosConfig struct {
+	Rate            float64 // average # of sessions terminated per second, at exponentially distributed intervals
+	ApplicationName string  // only sessions with this application_name are terminated, i.e. the benchmark's own
+}
+
+// ChaosStats reports the chaos injected during a run
+type ChaosStats struct {
+	Kills    int64 // # sessions terminated
+	Misses   int64 // # attempts that found no session to terminate
+	Failures int64 // # attempts that failed themselves
+}
+
+// chaos terminates the benchmark's own sessions at random until stopped
+type chaos struct {
+	cfg   ChaosConfig
+	db    Queryable
+	rnd   *rand.Rand
+	stats ChaosStats
+}
+
+func newChaos(cfg ChaosConfig, db Queryable, seed int64) *chaos {
+	return &chaos{
+		cfg: cfg,
+		db:  db,
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// next returns the random interval until the next kill
+func (ch *chaos) next() time.Duration {
+	return time.Duration(ch.rnd.ExpFloat64() / ch.cfg.Rate * float64(time.Second))
+}
+
+// run terminates sessions until stop is closed, the stats are sent on done when finished
+func (ch *chaos) run(stop <-chan struct{}, done chan<- ChaosStats) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	timer := time.NewTimer(ch.next())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			ch.kill(ctx)
+			timer.Reset(ch.next())
+		case <-stop:
+			done <- ch.stats
+			return
+		}
+	}
+}
+
+// kill terminates a single random session
+func (ch *chaos) kill(ctx context.Context) {
+	res, err := ch.db.ExecContext(ctx, terminateQuery, ch.cfg.ApplicationName)
+	if err != nil {
+		ch.stats.Failures++
+		return
+	}
+
+	// SELECT reports the # rows, i.e. whether a session was found
+	n, err := res.RowsAffected()
+	switch {
+	case err != nil:
+		ch.stats.Failures++
+	case n == 0:
+		ch.stats.Misses++
+	default:
+		ch.stats.Kills += n
+	}
+}
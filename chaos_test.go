@@ -0,0 +1,48 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaosKill(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	gomock.InOrder(
+		mdb.EXPECT().ExecContext(gomock.Any(), terminateQuery, "dbperf").Return(driver.RowsAffected(1), nil),
+		mdb.EXPECT().ExecContext(gomock.Any(), terminateQuery, "dbperf").Return(driver.RowsAffected(0), nil),
+		mdb.EXPECT().ExecContext(gomock.Any(), terminateQuery, "dbperf").Return(nil, errors.New("permission denied")),
+	)
+
+	ch := newChaos(ChaosConfig{Rate: 1, ApplicationName: "dbperf"}, mdb, 1)
+	for i := 0; i < 3; i++ {
+		ch.kill(context.Background())
+	}
+
+	assert.Equal(t, ChaosStats{Kills: 1, Misses: 1, Failures: 1}, ch.stats)
+}
+
+func TestRunTestChaos(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), terminateQuery, "dbperf").Return(driver.RowsAffected(1), nil).MinTimes(1)
+	mdb.EXPECT().ExecContext(gomock.Any(), "SELECT 1").Return(nil, nil).AnyTimes()
+
+	stats := runInBackground(t, mdb, time.Millisecond*100, func(c *Controller) {
+		c.SetChaos(ChaosConfig{Rate: 200, ApplicationName: "dbperf"})
+	})
+	if assert.NotNil(t, stats.Chaos) {
+		assert.True(t, stats.Chaos.Kills > 0)
+	}
+}
@@ -0,0 +1,50 @@
+package dbperf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checkpoint is a snapshot of the stats of a run in progress
+type Checkpoint struct {
+	Seq      int           `json:"seq"`      // checkpoint sequence number starting at 1
+	Start    time.Time     `json:"start"`    // when the run started
+	Time     time.Time     `json:"time"`     // when the checkpoint was taken
+	Elapsed  time.Duration `json:"elapsed"`  // time since the run started
+	Total    *QueryStats   `json:"total"`    // stats of the run so far
+	Interval *QueryStats   `json:"interval"` // stats since the previous checkpoint
+}
+
+// checkpointRunFormat formats the start of a run in the names of its checkpoint files
+const checkpointRunFormat = "20060102T150405.000"
+
+// CheckpointFunc is called with every checkpoint taken during a run, returning an error aborts the run
+type CheckpointFunc func(cp *Checkpoint) error
+
+// CheckpointDir returns a CheckpointFunc that writes every checkpoint as a JSON file to dir, named after the start of
+// its run so the checkpoints of successive runs don't overwrite each other. Files are written atomically so a crash
+// never leaves a partially written checkpoint behind.
+func CheckpointDir(dir string) CheckpointFunc {
+	return func(cp *Checkpoint) error {
+		data, err := json.MarshalIndent(cp, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		return writeFileAtomic(filepath.Join(dir, fmt.Sprintf("checkpoint-%s-%04d.json", cp.Start.UTC().Format(checkpointRunFormat), cp.Seq)), data)
+	}
+}
+
+// writeFileAtomic writes the file via a temporary file renamed into place, so readers never see it partially written
+func writeFileAtomic(name string, data []byte) error {
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
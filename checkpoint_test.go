@@ -0,0 +1,80 @@
+package dbperf
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTakeCheckpoint(t *testing.T) {
+	c := NewController(WithPoolSize(1))
+	c.SetCheckpoint(time.Minute, func(*Checkpoint) error { return nil })
+	col := c.newCollector()
+	start := time.Now()
+
+	col.record(result{elapsed: time.Millisecond * 30})
+	col.record(result{elapsed: time.Millisecond * 10})
+
+	cp := col.takeCheckpoint(start, start.Add(time.Minute))
+	assert.Equal(t, 1, cp.Seq)
+	assert.Equal(t, start, cp.Start)
+	assert.Equal(t, time.Minute, cp.Elapsed)
+	assert.Equal(t, int64(2), cp.Total.Processed)
+	assert.Equal(t, int64(2), cp.Interval.Processed)
+	assert.Equal(t, time.Minute, cp.Interval.Duration)
+
+	// the collected results are left untouched
+	assert.Equal(t, []time.Duration{time.Millisecond * 30, time.Millisecond * 10}, col.latencies)
+
+	col.record(result{elapsed: time.Millisecond * 50})
+
+	cp = col.takeCheckpoint(start, start.Add(time.Minute*3))
+	assert.Equal(t, 2, cp.Seq)
+	assert.Equal(t, int64(3), cp.Total.Processed)
+	assert.Equal(t, time.Millisecond*10, cp.Total.Min)
+	assert.Equal(t, int64(1), cp.Interval.Processed)
+	assert.Equal(t, time.Millisecond*50, cp.Interval.Min)
+	assert.Equal(t, time.Minute*2, cp.Interval.Duration)
+}
+
+func TestCheckpointDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbperf")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cp := &Checkpoint{
+		Seq:      3,
+		Start:    time.Date(2020, 3, 4, 5, 6, 7, 8e6, time.UTC),
+		Elapsed:  time.Minute,
+		Total:    &QueryStats{Processed: 10},
+		Interval: &QueryStats{Processed: 2},
+	}
+
+	require.NoError(t, CheckpointDir(dir)(cp))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "checkpoint-20200304T050607.008-0003.json"))
+	require.NoError(t, err)
+
+	var actual Checkpoint
+	require.NoError(t, json.Unmarshal(data, &actual))
+	assert.Equal(t, cp.Seq, actual.Seq)
+	assert.True(t, cp.Start.Equal(actual.Start))
+	assert.Equal(t, cp.Total.Processed, actual.Total.Processed)
+	assert.Equal(t, cp.Interval.Processed, actual.Interval.Processed)
+
+	// the checkpoints of a later run don't overwrite those of earlier ones
+	next := *cp
+	next.Start = cp.Start.Add(time.Hour)
+	require.NoError(t, CheckpointDir(dir)(&next))
+
+	// no temporary files are left behind
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
@@ -0,0 +1,191 @@
+package dbperf
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// chunkStatsQuery returns the # chunks of the hypertable $1, how many are compressed and their total size
+const chunkStatsQuery = `SELECT count(*), count(*) FILTER (WHERE c.is_compressed), COALESCE(sum(s.total_bytes), 0)::bigint
+	FROM timescaledb_information.chunks c
+	LEFT JOIN chunks_detailed_size($1::regclass) s ON s.chunk_schema = c.chunk_schema AND s.chunk_name = c.chunk_name
+	WHERE format('%I.%I', c.hypertable_schema, c.hypertable_name)::regclass = $1::regclass;`
+
+// chunkRangesQuery returns the time range of every chunk of the hypertable $1 in the session time zone, the zone time
+// ranges given as text are interpreted in
+const chunkRangesQuery = `SELECT chunk_name, range_start::timestamp, range_end::timestamp
+	FROM timescaledb_information.chunks
+	WHERE format('%I.%I', hypertable_schema, hypertable_name)::regclass = $1::regclass AND range_start IS NOT NULL
+	ORDER BY range_start;`
+
+// hasChunksQuery returns whether the TimescaleDB chunks view exists
+const hasChunksQuery = `SELECT to_regclass('timescaledb_information.chunks') IS NOT NULL;`
+
+// chunkParentsQuery returns the hypertable of every chunk
+const chunkParentsQuery = `SELECT format('%I.%I', chunk_schema, chunk_name), chunk_name, format('%I.%I', hypertable_schema, hypertable_name)
+	FROM timescaledb_information.chunks;`
+
+// chunkParent is the hypertable a chunk belongs to
+type chunkParent struct {
+	name       string // name of the chunk, which the names of its indexes start with
+	hypertable string // schema qualified name of the hypertable
+}
+
+// readChunkParents reads the hypertable of every chunk by its schema qualified name to roll the statistics of chunks
+// up into their hypertable, none if TimescaleDB isn't installed
+func readChunkParents(ctx context.Context, db Queryable) (map[string]chunkParent, error) {
+	var hasChunks bool
+	if err := db.QueryRowContext(ctx, hasChunksQuery).Scan(&hasChunks); err != nil {
+		return nil, err
+	}
+
+	chunks := make(map[string]chunkParent)
+	if !hasChunks {
+		return chunks, nil
+	}
+
+	rows, err := db.QueryContext(ctx, chunkParentsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table string
+		var c chunkParent
+		if err := rows.Scan(&table, &c.name, &c.hypertable); err != nil {
+			return nil, err
+		}
+		chunks[table] = c
+	}
+	return chunks, rows.Err()
+}
+
+// ChunkStats summarizes the chunks of a hypertable at a point in time
+type ChunkStats struct {
+	Chunks     int64 `json:"chunks"`
+	Compressed int64 `json:"compressed"` // # compressed chunks
+	Bytes      int64 `json:"bytes"`      // total size of the chunks, indexes and TOAST included
+}
+
+// ReadChunkStats reads the stats of the chunks of the hypertable
+func ReadChunkStats(ctx context.Context, db Queryable, hypertable string) (ChunkStats, error) {
+	var s ChunkStats
+	err := db.QueryRowContext(ctx, chunkStatsQuery, hypertable).Scan(&s.Chunks, &s.Compressed, &s.Bytes)
+	return s, err
+}
+
+// ChunkRange is the time range [Start, End) of the rows stored in a chunk
+type ChunkRange struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// ReadChunkRanges reads the time ranges of the chunks of the hypertable in ascending order
+func ReadChunkRanges(ctx context.Context, db Queryable, hypertable string) ([]ChunkRange, error) {
+	rows, err := db.QueryContext(ctx, chunkRangesQuery, hypertable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ranges []ChunkRange
+	for rows.Next() {
+		var r ChunkRange
+		if err := rows.Scan(&r.Name, &r.Start, &r.End); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, rows.Err()
+}
+
+// ChunkReport reports how the chunks of a hypertable changed during a run and how well the time ranges of the queries
+// of the run exclude chunks, the fewer chunks a query touches the fewer the planner has to scan
+type ChunkReport struct {
+	Hypertable string     `json:"hypertable"`
+	Before     ChunkStats `json:"before"`
+	After      ChunkStats `json:"after"`
+
+	Chunks   int     `json:"chunks"`   // # chunks the time ranges were matched against, as of the start of the run
+	Queries  int64   `json:"queries"`  // # queries with a known time range (see Query.Start)
+	Touched  float64 `json:"touched"`  // avg # chunks the time range of a query overlaps
+	Distinct int     `json:"distinct"` // # chunks overlapped by the time range of any query
+}
+
+// Excluded returns the fraction of the chunks excluded by the time range of the average query
+func (r *ChunkReport) Excluded() float64 {
+	if r.Chunks == 0 {
+		return 0
+	}
+	return 1 - r.Touched/float64(r.Chunks)
+}
+
+// ChunkExclusion counts the chunks of a hypertable the time ranges of queries overlap, as an upper bound of the chunks
+// the planner can't exclude for them. It isn't safe for concurrent use.
+type ChunkExclusion struct {
+	ranges   []ChunkRange // by start
+	queries  int64
+	touched  int64
+	distinct map[int]bool
+}
+
+// NewChunkExclusion creates a chunk exclusion counter for the chunk time ranges
+func NewChunkExclusion(ranges []ChunkRange) *ChunkExclusion {
+	sorted := append([]ChunkRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+	return &ChunkExclusion{ranges: sorted, distinct: make(map[int]bool)}
+}
+
+// Observe counts the chunks the time range of the query overlaps, queries without one are skipped. The range is
+// inclusive of its end, as with BETWEEN.
+func (e *ChunkExclusion) Observe(q *Query) {
+	if q.Start.IsZero() || q.End.IsZero() {
+		return
+	}
+
+	e.queries++
+	// the first chunk ending after the start of the range, up to the last starting before its end
+	i := sort.Search(len(e.ranges), func(i int) bool { return e.ranges[i].End.After(q.Start) })
+	for ; i < len(e.ranges) && !e.ranges[i].Start.After(q.End); i++ {
+		e.touched++
+		e.distinct[i] = true
+	}
+}
+
+// Generator wraps the generator to observe every query it generates
+func (e *ChunkExclusion) Generator(gen QueryGenerator) QueryGenerator {
+	return &chunkExclusionGenerator{gen: gen, e: e}
+}
+
+// Report reports the chunk exclusion of the queries observed with the stats of the chunks of the hypertable before
+// and after the run
+func (e *ChunkExclusion) Report(hypertable string, before, after ChunkStats) *ChunkReport {
+	r := &ChunkReport{
+		Hypertable: hypertable,
+		Before:     before,
+		After:      after,
+		Chunks:     len(e.ranges),
+		Queries:    e.queries,
+		Distinct:   len(e.distinct),
+	}
+	if e.queries > 0 {
+		r.Touched = float64(e.touched) / float64(e.queries)
+	}
+	return r
+}
+
+type chunkExclusionGenerator struct {
+	gen QueryGenerator
+	e   *ChunkExclusion
+}
+
+func (g *chunkExclusionGenerator) Next() (*Query, error) {
+	q, err := g.gen.Next()
+	if err != nil {
+		return nil, err
+	}
+	g.e.Observe(q)
+	return q, nil
+}
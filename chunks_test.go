@@ -0,0 +1,75 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadChunks(t *testing.T) {
+	day := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		assert.Equal(t, "cpu_usage", args[0])
+		if strings.Contains(query, "range_start") {
+			return []string{"chunk_name", "range_start", "range_end"}, [][]driver.Value{
+				{"_hyper_1_1_chunk", day, day.Add(24 * time.Hour)},
+				{"_hyper_1_2_chunk", day.Add(24 * time.Hour), day.Add(48 * time.Hour)},
+			}
+		}
+		return []string{"count", "count", "bytes"}, [][]driver.Value{{int64(2), int64(1), int64(1 << 20)}}
+	})
+	defer db.Close()
+
+	s, err := ReadChunkStats(context.Background(), db, "cpu_usage")
+	require.NoError(t, err)
+	assert.Equal(t, ChunkStats{Chunks: 2, Compressed: 1, Bytes: 1 << 20}, s)
+
+	ranges, err := ReadChunkRanges(context.Background(), db, "cpu_usage")
+	require.NoError(t, err)
+	require.Len(t, ranges, 2)
+	assert.Equal(t, ChunkRange{Name: "_hyper_1_2_chunk", Start: day.Add(24 * time.Hour), End: day.Add(48 * time.Hour)}, ranges[1])
+}
+
+func TestChunkExclusion(t *testing.T) {
+	day := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	var ranges []ChunkRange
+	for i := 3; i >= 0; i-- {
+		ranges = append(ranges, ChunkRange{Start: day.Add(time.Duration(i) * 24 * time.Hour), End: day.Add(time.Duration(i+1) * 24 * time.Hour)})
+	}
+
+	input := `hostname,start_time,end_time
+host_000001,2017-01-01 08:59:22,2017-01-01 09:59:22
+host_000002,2017-01-01 23:00:00,2017-01-02 01:00:00
+host_000003,2017-01-02 00:00:00,2017-01-02 00:00:00
+host_000004,2017-02-01 00:00:00,2017-02-01 01:00:00`
+
+	e := NewChunkExclusion(ranges)
+	g := e.Generator(NewCPUTestGenerator(strings.NewReader(input)))
+	for {
+		_, err := g.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	// queries without a time range are skipped
+	e.Observe(&Query{Query: "SELECT 1"})
+
+	before := ChunkStats{Chunks: 4}
+	after := ChunkStats{Chunks: 4, Compressed: 2}
+	r := e.Report("cpu_usage", before, after)
+	assert.Equal(t, before, r.Before)
+	assert.Equal(t, after, r.After)
+	assert.Equal(t, 4, r.Chunks)
+	assert.Equal(t, int64(4), r.Queries)
+	// the queries touch 1, 2, 1 and 0 chunks, the first two of the chunks
+	assert.Equal(t, 1.0, r.Touched)
+	assert.Equal(t, 2, r.Distinct)
+	assert.Equal(t, 0.75, r.Excluded())
+}
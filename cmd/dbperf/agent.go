@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"timescale/dbperf/rpc"
+)
+
+// agentCommand serves the gRPC control API for a coordinator to run its share of a workload
+func agentCommand(args []string) {
+	var cli CliArgs
+	var addr string
+	fs := newFlagSet("agent", "", "Runs the share of a workload a coordinator sends it, see dbperf coordinator")
+	cli.RegisterConn(fs)
+	fs.IntVar(&cli.nworkers, "n", 8, "default number of concurrent workers")
+	fs.StringVar(&addr, "addr", ":9090", "address to listen on for the coordinator")
+	parseFlags(fs, &cli, args)
+
+	db, _ := openDB(context.Background(), &cli)
+
+	fatalf("agent stopped: %s", serveGRPC(addr, db, rpc.ServerConfig{Generators: generators, Workers: cli.nworkers}))
+}
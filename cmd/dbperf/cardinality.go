@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"timescale/dbperf"
+)
+
+// cardinalityBreakpoint is how many times the p99 of the fewest hosts the p99 of a run must be for the queries to be
+// reported as falling over
+const cardinalityBreakpoint = 2
+
+// parseCardinalities parses a comma separated list of # hosts, sorted
+func parseCardinalities(s string) ([]int, error) {
+	var hosts []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid # hosts: %q", f)
+		}
+		hosts = append(hosts, n)
+	}
+	sort.Ints(hosts)
+	return hosts, nil
+}
+
+// runCardinality runs the workload once for every # distinct hosts given on the command line, its queries retargeted
+// at that many hosts, and reports the latency as a function of the cardinality of the hosts and where it falls over
+func runCardinality(ctx context.Context, cli *CliArgs, db dbperf.Queryable, newGenerator func() (dbperf.QueryGenerator, error), configure func(c *dbperf.Controller)) {
+	cardinalities, err := parseCardinalities(cli.hosts)
+	if err != nil {
+		fatalf("%s", err)
+	}
+
+	steps := make([]dbperf.CardinalityStep, len(cardinalities))
+	for i, hosts := range cardinalities {
+		g, err := newGenerator()
+		if err != nil {
+			fatalf("failed to read input: %s", err)
+		}
+
+		slog.Info("running workload", "hosts", hosts)
+		c := dbperf.NewController(dbperf.WithPoolSize(cli.nworkers))
+		configure(c)
+		report, err := c.RunTest(ctx, db, dbperf.NewCardinalityGenerator(g, hosts, cli.seed))
+		if err != nil {
+			fatalf("run with %d hosts failed: %s", hosts, err)
+		}
+		steps[i] = dbperf.CardinalityStep{Hosts: hosts, Stats: report.QueryStats}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "hosts\tqueries\tmedian\tp95\tp99\tp99 vs %d hosts\tthroughput\n", steps[0].Hosts)
+	for _, step := range steps {
+		s := step.Stats
+		ratio := 0.0
+		if base := steps[0].Stats.P99; base > 0 {
+			ratio = float64(s.P99) / float64(base)
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%.2fx\t%.2f\n", step.Hosts, s.Processed, s.Median, s.P95, s.P99, ratio, s.Throughput())
+	}
+	w.Flush()
+
+	if i := dbperf.CardinalityBreakpoint(steps, cardinalityBreakpoint); i >= 0 {
+		fmt.Printf("queries fall over at %d hosts: p99 %s is over %dx the p99 of %d hosts\n",
+			steps[i].Hosts, steps[i].Stats.P99, cardinalityBreakpoint, steps[0].Hosts)
+	}
+}
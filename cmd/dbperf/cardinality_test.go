@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCardinalities(t *testing.T) {
+	hosts, err := parseCardinalities("1000, 10,1000000,100")
+	require.NoError(t, err)
+	assert.Equal(t, []int{10, 100, 1000, 1000000}, hosts)
+
+	hosts, err = parseCardinalities("42")
+	require.NoError(t, err)
+	assert.Equal(t, []int{42}, hosts)
+
+	for _, s := range []string{"", "10,", "10,,100", "ten", "10,0", "-5", "1.5", "hosts=10"} {
+		t.Run(s, func(t *testing.T) {
+			_, err := parseCardinalities(s)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -2,17 +2,272 @@ package main
 
 import (
 	"flag"
+	"os"
 	"runtime"
+	"strconv"
+	"time"
+	"timescale/dbperf"
 )
 
 // CliArgs holds the command line interface arguments that were given
 type CliArgs struct {
-	nworkers int
-	filename string
+	config string
+	// logging, see parseFlags
+	logLevel  string
+	logFormat string
+	nworkers  int
+	filename  string
+
+	// connection
+	host   string
+	port   string
+	user   string
+	dbName string
+
+	passwordFile    string
+	passwordCommand string
+
+	// TLS
+	sslMode     string
+	sslRootCert string
+	sslCert     string
+	sslKey      string
+
+	out        string
+	jtl        string
+	record     string
+	golden     string
+	goldenOut  string
+	plans      string
+	ddl        string
+	webhook    string
+	resultsDir string
+	iterations int
+	tags       dbperf.Tags
+	tui        bool
+	progress   time.Duration
+	window     time.Duration
+	logEvery   time.Duration
+	prescan    bool
+	dedupe     bool
+	balance    bool
+	preload    bool
+	summary    string
+	streaming  bool
+	fetch      string
+	fetchSize  int
+	fetchSizes string
+	hosts      string
+	protocol   string
+	format     string
+	seed       int64
+	sample     int
+	warmup     float64
+	trim       float64
+	outliers   string
+	apdex      time.Duration
+	slowest    int
+	slowestBy  string
+
+	// share of the workload run by this process, see -shards
+	shards     int
+	shardIndex int
+
+	multiNode bool
+	query     string
+	space     string
+	chunks    string
+	indexes   bool
+	sizes     bool
+	wal       bool
+	analyze   string
+	vacuum    string
+	rate      float64
+	duration  time.Duration
+	schedule  string
+	shape     string
+	churn     int
+	tenants   string
+	pooler    string
+	reconnect time.Duration
+	chaosRate float64
+	fuzz      float64
+	locks     bool
+	backends  bool
+	notify    bool
+
+	// log the plans of slow statements with auto_explain
+	autoExplain        time.Duration
+	autoExplainAnalyze bool
+
+	// heavy queries run in the background, see -interference
+	interference  string
+	interferenceN int
+
+	// zone of the timestamps of the input without zone information
+	timezone string
+
+	// validation and handling of the invalid rows of the input, see -invalid-rows
+	validRanges bool
+	maxWindow   time.Duration
+	invalidRows string
+	rejects     string
+
+	// vacuum a table during the run, see -vacuum-during
+	vacuumDuring string
+	vacuumMode   string
+
+	// sample the checkpointer and wait events
+	checkpointActivity bool
+	waitEvents         bool
+
+	// stall watchdog
+	stall      time.Duration
+	stallAbort bool
+
+	// query cancellation
+	cancelFraction float64
+	cancelAfter    time.Duration
+
+	// spike test
+	spikeEvery  time.Duration
+	spikeSize   int
+	spikeWindow time.Duration
+
+	// soak test checkpoints
+	checkpointDir      string
+	checkpointInterval time.Duration
+
+	// profiles of dbperf itself
+	profileDir      string
+	profileInterval time.Duration
+
+	// max throughput search
+	searchSLO        time.Duration
+	searchPercentile float64
+	searchStart      float64
+	searchMax        float64
+	searchStep       time.Duration
+}
+
+// RegisterConn registers the database connection flags with the given flagset
+func (cli *CliArgs) RegisterConn(fs *flag.FlagSet) {
+	fs.StringVar(&cli.config, "config", "", "path to a YAML config file of flag settings, flags given on the command line take precedence")
+	fs.StringVar(&cli.host, "host", getenv("DB_HOST", "localhost"), "database host to connect to")
+	fs.StringVar(&cli.port, "port", getenv("DB_PORT", "5432"), "database port")
+	fs.StringVar(&cli.user, "user", getenv("DB_USER", "postgres"), "database username")
+	fs.StringVar(&cli.dbName, "dbname", getenv("DB_NAME", "homework"), "database name")
+	fs.StringVar(&cli.passwordFile, "password-file", "", "read the database password from this file")
+	fs.StringVar(&cli.passwordCommand, "password-command", "", "read the database password from the output of this shell command (e.g. a secret manager CLI)")
+	fs.StringVar(&cli.sslMode, "sslmode", getenv("DB_SSLMODE", "disable"), "TLS mode: disable, require, verify-ca or verify-full")
+	fs.StringVar(&cli.sslRootCert, "sslrootcert", getenv("DB_SSLROOTCERT", ""), "path to the root CA certificate used to verify the server")
+	fs.StringVar(&cli.sslCert, "sslcert", getenv("DB_SSLCERT", ""), "path to the client certificate")
+	fs.StringVar(&cli.sslKey, "sslkey", getenv("DB_SSLKEY", ""), "path to the client certificate's private key")
+}
+
+// RegisterLogging registers the logging flags every subcommand takes with the given flagset
+func (cli *CliArgs) RegisterLogging(fs *flag.FlagSet) {
+	fs.StringVar(&cli.logLevel, "log-level", "info", "log level: debug, info, warn or error")
+	fs.StringVar(&cli.logFormat, "log-format", "text", "log format: text, or json for one object per line")
 }
 
 // Register the flags with the given flagset
 func (cli *CliArgs) Register(fs *flag.FlagSet) {
+	cli.RegisterConn(fs)
 	fs.IntVar(&cli.nworkers, "n", runtime.NumCPU(), "number of concurrent workers")
-	fs.StringVar(&cli.filename, "f", "", "path to input file containing queries to execute")
+	fs.StringVar(&cli.filename, "f", "", "path (or http(s) object store URL) to input file containing queries to execute")
+	fs.IntVar(&cli.iterations, "iterations", 1, "repeat the whole run this many times and report every iteration, the pooled stats and the mean of every statistic with its 95% confidence interval")
+	fs.StringVar(&cli.timezone, "timezone", "", "zone the timestamps of the input without zone information are in, a name (UTC, Europe/Berlin) or an offset (+02:00), to bind them with their offset whatever the server's TimeZone (timestamps with zone information keep it)")
+	fs.BoolVar(&cli.dedupe, "dedupe", false, "skip the exact duplicates of the rows of the input already run (same query and arguments) and report how many were skipped")
+	fs.BoolVar(&cli.validRanges, "validate-ranges", false, "treat the rows whose time range doesn't start before it ends, which match no rows and skew the latency low, as invalid (see -invalid-rows)")
+	fs.DurationVar(&cli.maxWindow, "max-window", 0, "treat the rows whose time range is longer than this as invalid as well (implies -validate-ranges, 0 for no max)")
+	fs.StringVar(&cli.invalidRows, "invalid-rows", "fail", "how invalid rows of the input are handled: fail to abort the run, skip to skip and count them, or reject to skip them and write them to -rejects")
+	fs.StringVar(&cli.rejects, "rejects", "rejects.csv", "file the invalid rows of the input are written to with -invalid-rows reject")
+	fs.StringVar(&cli.out, "out", "", "save the full results (summary, stats by key, errors and manifest) to this JSON file (or http(s) object store URL) for the compare and report commands")
+	fs.StringVar(&cli.jtl, "jtl", "", "write the result of every query to this file in JMeter's CSV results (JTL) format")
+	fs.StringVar(&cli.record, "record", "", "record every query dispatched, with its worker and when, to this file for the replay command")
+	fs.StringVar(&cli.goldenOut, "write-golden", "", "record the full result set of every distinct query to this file, for later runs to be verified against with -golden")
+	fs.StringVar(&cli.golden, "golden", "", "verify the queries return the result sets recorded in this file by a run with -write-golden")
+	fs.StringVar(&cli.plans, "expect-plans", "", "check the plans of the query templates in this YAML file meet their expectations (use_index, no_seq_scan, max_chunks) and fail the run if they don't")
+	fs.StringVar(&cli.webhook, "webhook", "", "POST the run summary as JSON to this URL when the run finishes or fails, e.g. for Slack or alerting integrations")
+	fs.StringVar(&cli.resultsDir, "results-dir", "", "also keep the results in this directory for the history command")
+	fs.Var(&cli.tags, "tag", "label the run with key=value to group and filter runs by later, may be repeated")
+	fs.IntVar(&cli.shards, "shards", 1, "only run the share of the input keyed to -shard-index when the input is split into this many shards by the first column")
+	fs.IntVar(&cli.shardIndex, "shard-index", jobCompletionIndex(), "shard of the input to run when -shards is set, defaults to the pod's index in an indexed Kubernetes job")
+	fs.StringVar(&cli.query, "query", "minmax", "built-in query template to run: minmax, lastfirst")
+	fs.StringVar(&cli.space, "space", "", "break latency down by the space partitions of the given hypertable (e.g. cpu_usage)")
+	fs.StringVar(&cli.chunks, "chunks", "", "report the chunks of the given hypertable (e.g. cpu_usage) before and after the run and how many of them the time ranges of the queries touch")
+	fs.BoolVar(&cli.indexes, "index-usage", false, "report the scans of the tables and indexes made during the run, the indexes of the tables scanned left unused and the tables scanned sequentially")
+	fs.BoolVar(&cli.sizes, "table-sizes", false, "report the growth of the tables and their indexes during the run, the bytes per row written and the estimated bloat, for write workloads")
+	fs.BoolVar(&cli.wal, "wal", false, "report the WAL the server generated during the run per query and per row affected, for write workloads")
+	fs.StringVar(&cli.analyze, "analyze", "", "ANALYZE these comma separated tables (e.g. cpu_usage) before the run so runs compared to each other are planned from equally fresh statistics")
+	fs.StringVar(&cli.vacuum, "vacuum", "", "VACUUM (ANALYZE) these comma separated tables before the run, see -analyze")
+	fs.Float64Var(&cli.rate, "rate", 0, "dispatch queries at a fixed rate (queries per second) instead of as fast as possible")
+	fs.DurationVar(&cli.duration, "duration", 0, "stop dispatching queries after the run has lasted this long")
+	fs.StringVar(&cli.schedule, "schedule", "", "path to a load schedule file of DURATION RATE [WORKERS] phases to follow")
+	fs.StringVar(&cli.shape, "shape", "", "dispatch following a load shape, e.g. square:base=100,peak=1000,period=1m,duty=0.1 or sine:mean=500,amplitude=250,period=5m")
+	fs.IntVar(&cli.churn, "churn", 0, "open a fresh connection every N queries per worker to measure connection cost (0 disables)")
+	fs.StringVar(&cli.tenants, "tenants", "", "path to a tenant file of ROLE WORKERS [login] lines assigning database roles to workers (overrides -n)")
+	fs.StringVar(&cli.pooler, "pooler", "", "also run the workload through the pooler at HOST:PORT and compare it to the direct connection")
+	fs.DurationVar(&cli.reconnect, "reconnect", 0, "reconnect with backoff for up to this long when connections are lost instead of aborting (0 disables)")
+	fs.DurationVar(&cli.stall, "stall", 0, "log the stuck queries and dbperf's sessions when no query completed for this long while queries are in flight (0 disables)")
+	fs.BoolVar(&cli.stallAbort, "stall-abort", false, "abort the run when it stalls, see -stall")
+	fs.Float64Var(&cli.chaosRate, "chaos-rate", 0, "terminate dbperf's own database sessions at random at this average rate per second (use with -reconnect)")
+	fs.Float64Var(&cli.fuzz, "fuzz", 0, "perturb the arguments of this fraction (0-1) of the queries, widening, narrowing or swapping their time ranges or targeting unknown hosts, and report them by the label fuzz:PERTURBATION")
+	fs.BoolVar(&cli.locks, "locks", false, "sample the lock waits of dbperf's sessions during the run and report them by lock type, correlated with the latency spikes of the run")
+	fs.DurationVar(&cli.autoExplain, "auto-explain", 0, "load auto_explain on the workers' sessions to log the plans of statements taking at least this long and collect them from the server log into the results (0 disables)")
+	fs.BoolVar(&cli.autoExplainAnalyze, "auto-explain-analyze", false, "log the plans with their actual row counts and times, see -auto-explain")
+	fs.BoolVar(&cli.backends, "backend-pids", false, "give every worker a connection of its own and record its backend pid in the logs and results, to correlate workers with server logs and pg_stat_activity")
+	fs.BoolVar(&cli.notify, "notify", false, "send a notification every 100ms during the run and report the latency of their delivery to a listener under the load of the run")
+	fs.BoolVar(&cli.waitEvents, "wait-events", false, "sample the wait events of dbperf's sessions during the run and break the server time of the run down by them")
+	fs.StringVar(&cli.vacuumDuring, "vacuum-during", "", "vacuum this table (e.g. cpu_usage) during the run and report the latency of the workload while it was being vacuumed against the rest of the run")
+	fs.StringVar(&cli.vacuumMode, "vacuum-mode", "manual", "how -vacuum-during vacuums the table: manual to VACUUM it over and over, or autovacuum to make autovacuum process it aggressively for the duration of the run")
+	fs.BoolVar(&cli.checkpointActivity, "checkpoint-activity", false, "sample the server's checkpoints during the run and mark them on its latency timeline, correlated with the latency spikes of the run")
+	fs.Float64Var(&cli.cancelFraction, "cancel-fraction", 0, "fraction (0-1) of queries to cancel while in flight")
+	fs.DurationVar(&cli.cancelAfter, "cancel-after", 100*time.Millisecond, "how long after starting a query it is cancelled when -cancel-fraction is set")
+	fs.DurationVar(&cli.spikeEvery, "spike-every", 0, "inject a spike of concurrent queries on top of the regular load this often")
+	fs.IntVar(&cli.spikeSize, "spike-size", 100, "# of queries injected at once by every spike")
+	fs.DurationVar(&cli.spikeWindow, "spike-window", time.Second, "window p99 latency is tracked over to measure spike recovery")
+	fs.StringVar(&cli.checkpointDir, "checkpoint-dir", "", "write a JSON snapshot of the stats to this directory periodically during the run (for soak tests)")
+	fs.DurationVar(&cli.checkpointInterval, "checkpoint-interval", 5*time.Minute, "how often to write checkpoints when -checkpoint-dir is set")
+	fs.StringVar(&cli.profileDir, "profile-dir", "", "capture CPU and heap profiles of dbperf itself to this directory during the run")
+	fs.DurationVar(&cli.profileInterval, "profile-interval", 0, "capture profiles this often when -profile-dir is set, only once at the end of the run if 0")
+	fs.DurationVar(&cli.searchSLO, "search-slo", 0, "search for the max throughput that keeps the latency percentile within this SLO")
+	fs.Float64Var(&cli.searchPercentile, "search-percentile", 99, "latency percentile the search SLO applies to: 50, 95, 99 or 100")
+	fs.Float64Var(&cli.searchStart, "search-start", 10, "rate (queries per second) the search starts at")
+	fs.Float64Var(&cli.searchMax, "search-max", 100000, "max rate (queries per second) the search will try")
+	fs.DurationVar(&cli.searchStep, "search-step", 30*time.Second, "how long each search step runs")
+	fs.StringVar(&cli.summary, "summary", "dbperf", "format of the summary printed after the run: dbperf, or pgbench for tools parsing pgbench's output")
+	fs.Int64Var(&cli.seed, "seed", 0, "seed of the random choices of the run (cancellation, chaos and sampling) so runs with the same seed and input make the same ones, 0 picks one based on the current time")
+	fs.BoolVar(&cli.streaming, "streaming", false, "summarize latencies in histograms instead of keeping every one to bound memory on long runs (percentiles are estimated, raw latencies aren't saved)")
+	fs.StringVar(&cli.fetch, "fetch", "none", "how workers read the rows of queries: none, rows to iterate over them, scan to decode them into typed values as applications do, or cursor to fetch them from a server side cursor in batches of -fetch-size")
+	fs.IntVar(&cli.fetchSize, "fetch-size", 1000, "# rows fetched from the cursor of a query at a time with -fetch cursor")
+	fs.StringVar(&cli.fetchSizes, "fetch-sizes", "", "run the workload fetching from cursors with every one of these comma separated fetch sizes and compare the time to the first row against the total time")
+	fs.StringVar(&cli.hosts, "cardinality", "", "run the workload once for every one of these comma separated # distinct hosts (e.g. 10,1000,100000,1000000), its queries retargeted at that many hosts, and report the latency as a function of the # hosts")
+	fs.StringVar(&cli.interference, "interference", "", "run the workload without and then with the heavy queries of this SQL file (statements ending with ; at the end of a line) running in the background, and compare them")
+	fs.IntVar(&cli.interferenceN, "interference-n", 1, "# connections running the heavy queries of -interference at the same time")
+	fs.StringVar(&cli.ddl, "ddl", "", "execute the DDL operations of this YAML file (at, statement) at their offsets of the run and report their impact on the latency of the workload, to validate online migrations")
+	fs.StringVar(&cli.protocol, "protocol", "extended", "how queries are executed: extended to bind their arguments (parse/bind/execute), simple to inline them as literals as through poolers without prepared statements, or compare to run the workload both ways and compare them")
+	fs.StringVar(&cli.format, "result-format", "text", "format the rows fetched from cursors (-fetch cursor) are transferred in: text, binary (every row fetched as the bytea of its binary encoding) or compare to run the workload both ways and compare them")
+	fs.IntVar(&cli.sample, "sample", 0, "keep a random sample of this many latencies for plots and compare instead of every one, summarizing the stats as with -streaming (0 keeps every one)")
+	fs.Float64Var(&cli.warmup, "warmup", 0, "fraction (0-1) of the queries at the start of the run to report separately as cold, while the caches warm up, from the rest")
+	fs.Float64Var(&cli.trim, "trim", 0, "also report the stats without this fraction (0-0.5) of both the fastest and the slowest queries")
+	fs.StringVar(&cli.outliers, "outliers", "", "count the outlier queries by this rule: iqr (beyond 1.5 IQR from the quartiles) or mad (modified z-score beyond 3.5)")
+	fs.DurationVar(&cli.apdex, "apdex", 0, "report the Apdex score of the run for this target latency, queries within 4x of it count as tolerating (0 disables)")
+	fs.IntVar(&cli.slowest, "slowest-keys", 0, "report the stats of this many keys (e.g. hosts) with the worst -slowest-by, 0 disables")
+	fs.StringVar(&cli.slowestBy, "slowest-by", "p99", "rank the keys of -slowest-keys by p99 or total time")
+	fs.DurationVar(&cli.progress, "progress", 0, "log the # of queries completed this often during the run (0 disables)")
+	fs.DurationVar(&cli.window, "window", 10*time.Second, "sliding window (up to 1m) of the live throughput and percentiles of -progress and the recent expvar")
+	fs.DurationVar(&cli.logEvery, "log-interval", 0, "log a summary line of the queries completed within every interval (completed, errors, qps, p50, p95, p99) like pgbench -P (0 disables)")
+	fs.BoolVar(&cli.balance, "balance", false, "count the queries of every key (host) of the input before the run and assign the keys to the workers by bin packing their counts, instead of as they're first seen, to balance the load of the workers")
+	fs.BoolVar(&cli.preload, "preload", false, "parse and validate the whole input into memory before starting any workers, so an invalid row can't abort the run halfway through and -progress knows the exact total (the input must fit in memory)")
+	fs.BoolVar(&cli.prescan, "prescan", false, "count the queries of the input before the run to log the percent complete and ETA with -progress")
+	fs.BoolVar(&cli.tui, "tui", false, "show a live dashboard of throughput, latency, worker queues and errors during the run")
+	fs.BoolVar(&cli.multiNode, "multinode", false, "target is a multi-node access node; tolerate and report data node errors")
+}
+
+// jobCompletionIndex returns the index of the pod in an indexed Kubernetes job, 0 outside of one
+func jobCompletionIndex() int {
+	index, _ := strconv.Atoi(os.Getenv("JOB_COMPLETION_INDEX"))
+	return index
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"timescale/dbperf"
+)
+
+// compareCommand prints a statistical comparison of two saved results files
+func compareCommand(args []string) {
+	var cli CliArgs
+	var alpha float64
+	fs := newFlagSet("compare", "BASE.json OTHER.json", "Compares results saved with run -out, latency changes that aren't significant are shown as ~")
+	fs.StringVar(&cli.config, "config", "", "path to a YAML config file of flag settings, flags given on the command line take precedence")
+	fs.Float64Var(&alpha, "alpha", 0.05, "significance level below which latency differences are reported")
+	parseFlags(fs, &cli, args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	base := mustReadResults(fs.Arg(0))
+	other := mustReadResults(fs.Arg(1))
+
+	// results of -streaming runs without -sample have no latencies to test
+	var untested []string
+	for i, res := range []*dbperf.Results{base, other} {
+		if len(res.Latencies) == 0 {
+			untested = append(untested, filepath.Base(fs.Arg(i)))
+		}
+	}
+	p := dbperf.MannWhitney(base.Latencies, other.Latencies)
+	significant := p < alpha
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "\t%s\t%s\tdelta\n", filepath.Base(fs.Arg(0)), filepath.Base(fs.Arg(1)))
+	for _, d := range dbperf.Compare(base.Stats, other.Stats) {
+		delta := fmt.Sprintf("%+.2f%%", d.Change())
+		if d.Duration && !significant && len(untested) == 0 {
+			delta = "~"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Name, formatStat(d, d.Base), formatStat(d, d.Other), delta)
+	}
+	w.Flush()
+
+	if len(untested) > 0 {
+		fmt.Printf("\nsignificance of the latency difference not tested: no latencies in %s (save the results of streaming runs with -sample)\n",
+			strings.Join(untested, " and "))
+		return
+	}
+
+	verdict := "latency distributions differ significantly"
+	if !significant {
+		verdict = "no significant latency difference"
+	}
+	fmt.Printf("\n%s (p=%.3f n=%d+%d, Mann-Whitney U)\n", verdict, p, len(base.Latencies), len(other.Latencies))
+}
+
+// formatStat formats a value of the compared statistic
+func formatStat(d dbperf.StatDelta, v float64) string {
+	if d.Duration {
+		return time.Duration(v).String()
+	}
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+// mustReadResults reads a results file or exits
+func mustReadResults(filename string) *dbperf.Results {
+	f, err := os.Open(filename)
+	if err != nil {
+		fatalf("open %s: %s", filename, err)
+	}
+	defer f.Close()
+
+	res, err := dbperf.ReadResults(f)
+	if err != nil {
+		fatalf("failed to read results %s: %s", filename, err)
+	}
+	return res
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// loadConfig applies the settings of a YAML config file to the flags that weren't given on the command line. Keys
+// are flag names, nested keys are joined with a dash so the related flags can be grouped, e.g.
+//
+//	host: db1.example.com
+//	n: 16
+//	duration: 10m
+//	search:
+//	  slo: 50ms
+//	  percentile: 95
+//
+// sets -host, -n, -duration, -search-slo and -search-percentile.
+func loadConfig(fs *flag.FlagSet, filename string) error {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+
+	// the runs of the matrix command, see readMatrix
+	delete(doc, "matrix")
+
+	settings := make(map[string]string)
+	if err := flattenConfig("", doc, settings); err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	// apply in a stable order so errors are reproducible
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// the same config may be shared by the subcommands so only settings neither this subcommand nor a run knows are
+	// rejected, the flags of the subcommand itself are looked up on fs
+	var all CliArgs
+	known := flag.NewFlagSet("", flag.ContinueOnError)
+	all.Register(known)
+	all.RegisterLogging(known)
+
+	for _, key := range keys {
+		if key == "config" || (fs.Lookup(key) == nil && known.Lookup(key) == nil) {
+			return fmt.Errorf("%s: unknown setting: %s", filename, key)
+		}
+		if fs.Lookup(key) == nil || given[key] {
+			continue
+		}
+		if err := fs.Set(key, settings[key]); err != nil {
+			return fmt.Errorf("%s: invalid value for %s: %s", filename, key, err)
+		}
+	}
+
+	return nil
+}
+
+// flattenConfig flattens the nested maps of a config document into flag names and values
+func flattenConfig(prefix string, doc map[string]interface{}, settings map[string]string) error {
+	for key, v := range doc {
+		if prefix != "" {
+			key = prefix + "-" + key
+		}
+
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if err := flattenConfig(key, v, settings); err != nil {
+				return err
+			}
+		case []interface{}:
+			return fmt.Errorf("%s: lists are not supported", key)
+		case nil:
+			return fmt.Errorf("%s: missing value", key)
+		default:
+			settings[key] = fmt.Sprint(v)
+		}
+	}
+
+	return nil
+}
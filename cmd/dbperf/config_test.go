@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a config file to a temporary directory and returns its name
+func writeConfig(t *testing.T, doc string) string {
+	dir, err := ioutil.TempDir("", "dbperf")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	name := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(name, []byte(doc), 0644))
+	return name
+}
+
+func TestLoadConfigSubcommandFlags(t *testing.T) {
+	var addr string
+	var drop bool
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.StringVar(&addr, "addr", ":8080", "")
+	fs.BoolVar(&drop, "drop", false, "")
+	require.NoError(t, fs.Parse(nil))
+
+	// settings of the runs are accepted too so the config can be shared with them
+	require.NoError(t, loadConfig(fs, writeConfig(t, "addr: \":9999\"\ndrop: true\nhost: db1\n")))
+	assert.Equal(t, ":9999", addr)
+	assert.True(t, drop)
+}
+
+func TestFlattenConfig(t *testing.T) {
+	settings := make(map[string]string)
+	require.NoError(t, flattenConfig("", map[string]interface{}{
+		"host": "db1",
+		"n":    16,
+		"search": map[string]interface{}{
+			"slo":        "50ms",
+			"percentile": 95.5,
+		},
+	}, settings))
+	assert.Equal(t, map[string]string{"host": "db1", "n": "16", "search-slo": "50ms", "search-percentile": "95.5"}, settings)
+
+	err := flattenConfig("", map[string]interface{}{"search": map[string]interface{}{"slo": []interface{}{"50ms"}}}, make(map[string]string))
+	assert.EqualError(t, err, "search-slo: lists are not supported")
+
+	err = flattenConfig("", map[string]interface{}{"host": nil}, make(map[string]string))
+	assert.EqualError(t, err, "host: missing value")
+}
+
+func TestLoadConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		doc  string
+		args []string
+		host string
+		n    int
+		slo  string
+		err  string
+	}{
+		{name: "flat", doc: "host: db1\nn: 16\n", host: "db1", n: 16, slo: "0s"},
+		{name: "nested", doc: "search:\n  slo: 50ms\n", host: "localhost", n: 4, slo: "50ms"},
+		{name: "command line first", doc: "host: db1\nn: 16\n", args: []string{"-n", "2"}, host: "db1", n: 2, slo: "0s"},
+		{name: "matrix ignored", doc: "host: db1\nmatrix:\n  n: [1, 2]\n", host: "db1", n: 4, slo: "0s"},
+		{name: "list", doc: "host:\n  - db1\n  - db2\n", err: "lists are not supported"},
+		{name: "unknown", doc: "hots: db1\n", err: "unknown setting: hots"},
+		{name: "config", doc: "config: other.yaml\n", err: "unknown setting: config"},
+		{name: "invalid", doc: "n: many\n", err: "invalid value for n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var host, slo string
+			var n int
+			fs := flag.NewFlagSet("", flag.ContinueOnError)
+			fs.StringVar(&host, "host", "localhost", "")
+			fs.IntVar(&n, "n", 4, "")
+			fs.StringVar(&slo, "search-slo", "0s", "")
+			require.NoError(t, fs.Parse(tc.args))
+
+			err := loadConfig(fs, writeConfig(t, tc.doc))
+			if tc.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.host, host)
+			assert.Equal(t, tc.n, n)
+			assert.Equal(t, tc.slo, slo)
+		})
+	}
+}
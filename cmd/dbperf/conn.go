@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// connConfig holds the settings used to connect to the database
+type connConfig struct {
+	host     string
+	port     string
+	user     string
+	password string
+	dbName   string
+
+	// TLS
+	sslMode     string
+	sslRootCert string
+	sslCert     string
+	sslKey      string
+}
+
+// String returns the connection string in the key=value format understood by lib/pq
+func (cfg connConfig) String() string {
+	params := map[string]string{
+		"host":             cfg.host,
+		"port":             cfg.port,
+		"user":             cfg.user,
+		"password":         cfg.password,
+		"dbname":           cfg.dbName,
+		"sslmode":          cfg.sslMode,
+		"sslrootcert":      cfg.sslRootCert,
+		"sslcert":          cfg.sslCert,
+		"sslkey":           cfg.sslKey,
+		"application_name": applicationName,
+	}
+
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+quoteConnValue(params[k]))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// quoteConnValue quotes a connection string value if needed, escaping backslashes and single quotes
+func quoteConnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `'`, `\'`, -1)
+	return "'" + v + "'"
+}
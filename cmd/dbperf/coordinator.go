@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"timescale/dbperf"
+	"timescale/dbperf/rpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// coordinatorCommand shards a workload across agents, starts them at the same time and merges their results
+func coordinatorCommand(args []string) {
+	var cli CliArgs
+	var agents string
+	var startDelay time.Duration
+	fs := newFlagSet("coordinator", "FILENAME", `Shards the workload across the agents by the first column (e.g. hostname) so every key is queried by a
+single agent, starts all agents at the same time and merges their results. Agent clocks are assumed to be in sync.
+The stats are merged from the agents' latency histograms, the raw latencies are only collected when saving with -out.`)
+	fs.StringVar(&cli.config, "config", "", "path to a YAML config file of flag settings, flags given on the command line take precedence")
+	fs.StringVar(&agents, "agents", "", "comma separated HOST:PORT list of the agents to run the workload on")
+	fs.IntVar(&cli.nworkers, "n", 0, "number of concurrent workers per agent, the agent's default if 0")
+	fs.StringVar(&cli.filename, "f", "", "path to input file containing queries to execute")
+	fs.StringVar(&cli.query, "query", "minmax", "built-in query template to run: minmax, lastfirst")
+	fs.Float64Var(&cli.rate, "rate", 0, "total rate (queries per second) split evenly across the agents, as fast as possible if 0")
+	fs.DurationVar(&cli.duration, "duration", 0, "stop dispatching queries after the run has lasted this long")
+	fs.StringVar(&cli.out, "out", "", "save the merged results to this JSON file for the compare and report commands")
+	fs.Var(&cli.tags, "tag", "label the run with key=value to group and filter runs by later, may be repeated")
+	fs.DurationVar(&startDelay, "start-delay", 2*time.Second, "how far ahead the synchronized start is scheduled to give every agent time to receive its share")
+	parseFlags(fs, &cli, args)
+	filename := inputFilename(fs, &cli)
+
+	addrs := strings.Split(agents, ",")
+	if agents == "" {
+		fatalf("no agents given, see -agents")
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fatalf("open %s: %s", filename, err)
+	}
+	shards, err := shardQueries(bytes.NewReader(data), len(addrs))
+	if err != nil {
+		fatalf("failed to read %s: %s", filename, err)
+	}
+
+	// the agents don't report the server they ran against, only the coordinator's side of the run is recorded
+	manifest := newManifest(fs)
+	manifest.SetInput(filename, bytes.NewReader(data))
+
+	ctx := context.Background()
+
+	clients := make([]rpc.DbperfClient, len(addrs))
+	for i, addr := range addrs {
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			fatalf("failed to connect to agent %s: %s", addr, err)
+		}
+		defer conn.Close()
+		clients[i] = rpc.NewDbperfClient(conn)
+	}
+
+	startAt := time.Now().Add(startDelay)
+	ids := make([]string, len(addrs))
+	for i, client := range clients {
+		req := &rpc.StartRunRequest{
+			Query:   cli.query,
+			Input:   &rpc.StartRunRequest_Queries{Queries: shards[i]},
+			Workers: int32(cli.nworkers),
+			Rate:    cli.rate / float64(len(addrs)),
+			StartAt: timestamppb.New(startAt),
+		}
+		if cli.duration > 0 {
+			req.Duration = durationpb.New(cli.duration)
+		}
+
+		run, err := client.StartRun(ctx, req)
+		if err != nil {
+			// don't leave the agents that did start running
+			for j := 0; j < i; j++ {
+				clients[j].StopRun(ctx, &rpc.RunRequest{Id: ids[j]})
+			}
+			fatalf("failed to start run on agent %s: %s", addrs[i], err)
+		}
+		ids[i] = run.Id
+	}
+	slog.Info("started agents", "agents", len(addrs), "start", startAt.Format(time.RFC3339Nano))
+
+	results := make([]*dbperf.Results, len(addrs))
+	errs := make([]error, len(addrs))
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = collectAgentResults(ctx, clients[i], ids[i], cli.out != "")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			fatalf("agent %s: %s", addrs[i], err)
+		}
+		slog.Info("agent done", "agent", addrs[i], "queries", results[i].Stats.Processed, "median", results[i].Stats.Median, "p99", results[i].Stats.P99)
+	}
+
+	merged, err := dbperf.MergeResults(results...)
+	if err != nil {
+		fatalf("failed to merge results: %s", err)
+	}
+	merged.ID = dbperf.NewRunID()
+	merged.Tags = cli.tags
+	merged.Manifest = manifest
+	if cli.out != "" {
+		if err := saveResults(cli.out, merged); err != nil {
+			fatalf("failed to save results: %s", err)
+		}
+	}
+
+	stats := merged.Stats
+	fmt.Printf("%d queries processed by %d agents after %s\n", stats.Processed, len(addrs), stats.TotalElapsed)
+	fmt.Printf("min: %s; max: %s; avg: %s; median: %s\n", stats.Min, stats.Max, stats.Avg, stats.Median)
+	fmt.Printf("p95: %s; p99: %s; throughput: %.1f qps\n", stats.P95, stats.P99, stats.Throughput())
+}
+
+// collectAgentResults waits for the run on the agent to finish and fetches its results, the raw latencies are only
+// transferred if asked for as the histogram of the stats is enough to merge them
+func collectAgentResults(ctx context.Context, client rpc.DbperfClient, id string, raw bool) (*dbperf.Results, error) {
+	for {
+		run, err := client.GetRun(ctx, &rpc.RunRequest{Id: id})
+		if err != nil {
+			return nil, err
+		}
+
+		switch run.Status {
+		case rpc.Status_STATUS_RUNNING:
+			time.Sleep(time.Second)
+			continue
+		case rpc.Status_STATUS_DONE:
+		default:
+			return nil, fmt.Errorf("run %s %s: %s", id, run.Status, run.Error)
+		}
+
+		stats := run.Stats.QueryStats()
+		if raw {
+			if stats.Latencies, err = rpc.FetchLatencies(ctx, client, id); err != nil {
+				return nil, err
+			}
+		}
+
+		return dbperf.NewResults(stats), nil
+	}
+}
+
+// shardQueries splits the CSV input into n CSV inputs by the hash of the first column so every key ends up in the
+// same shard, every shard keeps the header
+func shardQueries(r io.Reader, n int) ([]string, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	bufs := make([]bytes.Buffer, n)
+	writers := make([]*csv.Writer, n)
+	for i := range bufs {
+		writers[i] = csv.NewWriter(&bufs[i])
+		writers[i].Write(header)
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		h := fnv.New32a()
+		h.Write([]byte(record[0]))
+		writers[h.Sum32()%uint32(n)].Write(record)
+	}
+
+	shards := make([]string, n)
+	for i, w := range writers {
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, err
+		}
+		shards[i] = bufs[i].String()
+	}
+
+	return shards, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readShard returns the rows of a shard after checking it starts with the header
+func readShard(t *testing.T, shard string) [][]string {
+	records, err := csv.NewReader(strings.NewReader(shard)).ReadAll()
+	require.NoError(t, err)
+	require.NotEmpty(t, records)
+	assert.Equal(t, []string{"hostname", "start", "end"}, records[0])
+	return records[1:]
+}
+
+func TestShardQueries(t *testing.T) {
+	input := "hostname,start,end\n"
+	for i := 0; i < 50; i++ {
+		input += fmt.Sprintf("host_%d,2017-01-01 08:00:00,2017-01-01 09:00:00\n", i%7)
+	}
+
+	shards, err := shardQueries(strings.NewReader(input), 3)
+	require.NoError(t, err)
+	require.Len(t, shards, 3)
+
+	// the split is uneven by key but every row ends up in exactly one shard with all the others of its key
+	var rows int
+	shardOf := make(map[string]int)
+	for i, shard := range shards {
+		for _, row := range readShard(t, shard) {
+			if s, ok := shardOf[row[0]]; ok {
+				assert.Equal(t, s, i, row[0])
+			}
+			shardOf[row[0]] = i
+			rows++
+		}
+	}
+	assert.Equal(t, 50, rows)
+	assert.Len(t, shardOf, 7)
+
+	// the split is deterministic
+	again, err := shardQueries(strings.NewReader(input), 3)
+	require.NoError(t, err)
+	assert.Equal(t, shards, again)
+}
+
+func TestShardQueriesMoreShardsThanQueries(t *testing.T) {
+	input := "hostname,start,end\nhost_1,2017-01-01 08:00:00,2017-01-01 09:00:00\nhost_2,2017-01-01 08:00:00,2017-01-01 09:00:00\n"
+
+	shards, err := shardQueries(strings.NewReader(input), 8)
+	require.NoError(t, err)
+	require.Len(t, shards, 8)
+
+	// the shards without queries still have the header
+	var rows int
+	for _, shard := range shards {
+		rows += len(readShard(t, shard))
+	}
+	assert.Equal(t, 2, rows)
+}
+
+func TestShardQueriesInvalid(t *testing.T) {
+	_, err := shardQueries(strings.NewReader(""), 2)
+	assert.Error(t, err)
+
+	_, err = shardQueries(strings.NewReader("hostname,start,end\nhost_1,2017-01-01 08:00:00\n"), 2)
+	assert.Error(t, err)
+}
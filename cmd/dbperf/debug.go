@@ -1,23 +1,34 @@
 package main
 
 import (
+	"expvar"
+	"fmt"
 	"os"
+	"runtime/trace"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"timescale/dbperf"
 )
 
 type dbgVar struct {
 	name  string
 	value *int32
+	str   *string
 }
 
 // Holds variables parsed from DBPERFDEBUG env var, variables can be string or int32
 var debug struct {
-	pprof int32
+	pprof      int32
+	trace      string
+	schedtrace int32
 }
 
 var dbgvars = []dbgVar{
-	{"pprof", &debug.pprof},
+	{name: "pprof", value: &debug.pprof},
+	{name: "trace", str: &debug.trace},
+	{name: "schedtrace", value: &debug.schedtrace},
 }
 
 func init() {
@@ -36,11 +47,86 @@ func init() {
 		key, value := field[:i], field[i+1:]
 
 		for _, v := range dbgvars {
-			if v.name == key {
-				if n, err := strconv.Atoi(value); err == nil {
-					*v.value = int32(n)
-				}
+			if v.name != key {
+				continue
 			}
+			if v.str != nil {
+				*v.str = value
+			} else if n, err := strconv.Atoi(value); err == nil {
+				*v.value = int32(n)
+			}
+		}
+	}
+}
+
+// dispatching is the controller whose dispatch stats are published on /debug/vars, the last one configured
+var dispatching struct {
+	sync.Mutex
+	c      *dbperf.Controller
+	window time.Duration // of the recent stats
+}
+
+var publishOnce sync.Once
+
+// publishDispatchStats publishes the live dispatch stats of the controller as the "dispatch" expvar, served by the
+// pprof server (see DBPERFDEBUG) to observe scheduler imbalance during a run, and the stats of the queries completed
+// within the last window as the "recent" expvar to observe degradation
+func publishDispatchStats(c *dbperf.Controller, window time.Duration) {
+	dispatching.Lock()
+	dispatching.c = c
+	dispatching.window = window
+	dispatching.Unlock()
+
+	publishOnce.Do(func() {
+		expvar.Publish("dispatch", expvar.Func(func() interface{} {
+			dispatching.Lock()
+			defer dispatching.Unlock()
+			return dispatching.c.DispatchStats()
+		}))
+		expvar.Publish("recent", expvar.Func(func() interface{} {
+			dispatching.Lock()
+			defer dispatching.Unlock()
+			return dispatching.c.RecentStats(dispatching.window)
+		}))
+	})
+}
+
+// startTrace starts capturing a runtime execution trace of the run to the file, the returned func stops it. See
+// https://golang.org/pkg/runtime/trace
+func startTrace(filename string) (func(), error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := trace.Start(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		trace.Stop()
+		f.Close()
+	}, nil
+}
+
+// schedTrace writes a line summarizing the dispatch state of the run every interval, like GODEBUG=schedtrace does
+// for the Go scheduler: the queries dispatched, completed and in flight followed by the queue depth of every worker
+func schedTrace(interval time.Duration) {
+	start := time.Now()
+	for range time.Tick(interval) {
+		dispatching.Lock()
+		c := dispatching.c
+		dispatching.Unlock()
+		if c == nil {
+			continue
+		}
+
+		ds := c.DispatchStats()
+		queues := make([]string, len(ds.Workers))
+		for i, ws := range ds.Workers {
+			queues[i] = strconv.Itoa(ws.QueueDepth)
 		}
+		fmt.Fprintf(logOutput, "SCHED %dms: workers=%d dispatched=%d completed=%d inflight=%d queues=[%s]\n",
+			time.Since(start).Milliseconds(), len(ds.Workers), ds.Dispatched, ds.Completed, ds.Inflight, strings.Join(queues, " "))
 	}
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"timescale/dbperf"
+)
+
+// parseFetchSizes parses a comma separated list of fetch sizes
+func parseFetchSizes(s string) ([]int, error) {
+	var sizes []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid fetch size: %q", f)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
+
+// runFetchSizes runs the workload once for every fetch size given on the command line, fetching the rows of its
+// queries from cursors in batches of that size, and reports how the batch size trades the time to the first row for
+// the total time of the queries
+func runFetchSizes(ctx context.Context, cli *CliArgs, db dbperf.Queryable, newGenerator func() (dbperf.QueryGenerator, error), configure func(c *dbperf.Controller)) {
+	sizes, err := parseFetchSizes(cli.fetchSizes)
+	if err != nil {
+		fatalf("%s", err)
+	}
+
+	runs := make([]*dbperf.QueryStats, len(sizes))
+	for i, size := range sizes {
+		g, err := newGenerator()
+		if err != nil {
+			fatalf("failed to read input: %s", err)
+		}
+
+		slog.Info("running workload", "fetch size", size)
+		c := dbperf.NewController(dbperf.WithPoolSize(cli.nworkers))
+		configure(c)
+		c.SetFetch(dbperf.FetchCursor)
+		c.SetFetchSize(size)
+		report, err := c.RunTest(ctx, db, g)
+		if err != nil {
+			fatalf("run with fetch size %d failed: %s", size, err)
+		}
+		runs[i] = report.QueryStats
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "fetch size\tfirst row median\tfirst row p99\ttotal median\ttotal p99\tfetches\tfetch median\tthroughput\n")
+	for i, s := range runs {
+		fr, fs := s.FirstRow, s.Fetches
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\t%.2f\n", sizes[i], fr.Median, fr.P99, s.Median, s.P99, fs.Processed, fs.Median, s.Throughput())
+	}
+	w.Flush()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"timescale/dbperf"
+)
+
+// runFormatComparison runs the identical workload fetching the rows of its queries from cursors as text and as
+// binary and reports the difference in their decode and transfer cost
+func runFormatComparison(ctx context.Context, cli *CliArgs, db dbperf.Queryable, newGenerator func() (dbperf.QueryGenerator, error), configure func(c *dbperf.Controller)) {
+	formats := []dbperf.ResultFormat{dbperf.FormatText, dbperf.FormatBinary}
+	stats := make([]*dbperf.QueryStats, len(formats))
+
+	for i, f := range formats {
+		g, err := newGenerator()
+		if err != nil {
+			fatalf("failed to read input: %s", err)
+		}
+
+		slog.Info("running workload", "result format", f)
+		c := dbperf.NewController(dbperf.WithPoolSize(cli.nworkers))
+		configure(c)
+		c.SetFetch(dbperf.FetchCursor)
+		c.SetResultFormat(f)
+
+		report, err := c.RunTest(ctx, db, g)
+		if err != nil {
+			fatalf("%s result format test run failed: %s", f, err)
+		}
+		stats[i] = report.QueryStats
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "format\tqueries\trows\tmedian\tp99\tfetch median\tfetch p99\trows/s\n")
+	for i, f := range formats {
+		s := stats[i]
+		fs := s.Fetches
+		if fs == nil {
+			fs = &dbperf.QueryStats{}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%.1f\n", f, s.Processed, s.Rows, s.Median, s.P99, fs.Median, fs.P99, float64(s.Rows)/s.Duration.Seconds())
+	}
+	w.Flush()
+
+	fmt.Println("binary vs text:")
+	for _, d := range dbperf.Compare(stats[0], stats[1]) {
+		fmt.Printf("  %s\n", d)
+	}
+}
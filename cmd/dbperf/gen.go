@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"time"
+	"timescale/dbperf"
+)
+
+// genCommand writes synthetic query parameters for the cpu usage workload
+func genCommand(args []string) {
+	var cli CliArgs
+	var start, end, out string
+	cfg := dbperf.QueryParamsConfig{}
+	fs := newFlagSet("gen", "", "Generates synthetic query parameters for the cpu usage workload")
+	fs.StringVar(&cli.config, "config", "", "path to a YAML config file of flag settings, flags given on the command line take precedence")
+	fs.IntVar(&cfg.Hosts, "hosts", 10, "# distinct hosts to query")
+	fs.IntVar(&cfg.Count, "count", 200, "# queries to generate")
+	fs.StringVar(&start, "start", "2017-01-01 00:00:00", "earliest time a query range may start")
+	fs.StringVar(&end, "end", "2017-01-02 23:59:59", "latest time a query range may end")
+	fs.DurationVar(&cfg.Window, "window", time.Hour, "length of every query's time range")
+	fs.DurationVar(&cfg.Recency, "recency", 0, "skew the query ranges towards the end of the time range, their distance from it being exponentially distributed with this mean (0 spreads them uniformly)")
+	fs.Int64Var(&cfg.Seed, "seed", 0, "random seed, 0 picks one based on the current time")
+	fs.StringVar(&out, "o", "", "write the queries to this file instead of stdout")
+	parseFlags(fs, &cli, args)
+
+	var err error
+	if cfg.Start, err = time.Parse("2006-01-02 15:04:05", start); err != nil {
+		fatalf("invalid start time: %s", err)
+	}
+	if cfg.End, err = time.Parse("2006-01-02 15:04:05", end); err != nil {
+		fatalf("invalid end time: %s", err)
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
+	f := os.Stdout
+	if out != "" {
+		if f, err = os.Create(out); err != nil {
+			fatalf("failed to create %s: %s", out, err)
+		}
+	}
+
+	w := bufio.NewWriter(f)
+	if err := dbperf.GenerateQueryParams(w, cfg); err != nil {
+		fatalf("failed to generate queries: %s", err)
+	}
+	if err := w.Flush(); err != nil {
+		fatalf("failed to write queries: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		fatalf("failed to write queries: %s", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"timescale/dbperf"
+)
+
+// historyCommand reports the p99 trend of a workload over the runs kept in a results store, flagging drift
+func historyCommand(args []string) {
+	var dir string
+	var n int
+	var threshold float64
+	var filter dbperf.Tags
+	var cli CliArgs
+	var fail bool
+	fs := newFlagSet("history", "[WORKLOAD]", `Shows the p99 trend of the last -n runs of the workload (QUERY FILE, e.g. "minmax queries.csv") and
+flags drift from the baseline of the runs before the latest. Without a workload the stored workloads are listed.`)
+	fs.StringVar(&cli.config, "config", "", "path to a YAML config file of flag settings, flags given on the command line take precedence")
+	fs.StringVar(&dir, "results-dir", "", "results store directory the runs were kept in with run or serve -results-dir")
+	fs.IntVar(&n, "n", 30, "number of most recent runs to analyze, all of them if 0")
+	fs.Float64Var(&threshold, "threshold", 0.1, "relative p99 change (0.1 for 10%) beyond which the runs drifted")
+	fs.Var(&filter, "tag", "only consider runs labeled key=value, may be repeated")
+	fs.BoolVar(&fail, "fail", false, "exit with status 1 when the runs drifted, e.g. to fail a CI job")
+	parseFlags(fs, &cli, args)
+
+	if dir == "" || fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	store, err := dbperf.OpenResultsStore(dir)
+	if err != nil {
+		fatalf("failed to open results store: %s", err)
+	}
+	records, err := store.List(filter)
+	if err != nil {
+		fatalf("failed to list runs: %s", err)
+	}
+
+	if fs.NArg() == 0 {
+		listWorkloads(records)
+		return
+	}
+
+	name := fs.Arg(0)
+	var runs []*dbperf.RunRecord
+	for _, rec := range records {
+		if rec.Name == name {
+			runs = append(runs, rec)
+		}
+	}
+	if len(runs) == 0 {
+		fatalf("no runs of workload %q, run dbperf history -results-dir %s to list the workloads", name, dir)
+	}
+
+	trend := dbperf.AnalyzeTrend(runs, n, threshold)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "id\tstarted\ttags\tqueries\tmedian\tp99\tvs baseline\n")
+	for _, rec := range trend.Runs {
+		change := "-"
+		if trend.Baseline > 0 {
+			change = fmt.Sprintf("%+.2f%%", 100*float64(rec.Stats.P99-trend.Baseline)/float64(trend.Baseline))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", rec.ID, rec.Started.Format("2006-01-02 15:04:05"), rec.Tags,
+			rec.Stats.Processed, rec.Stats.Median, rec.Stats.P99, change)
+	}
+	w.Flush()
+
+	if len(trend.Runs) < 2 {
+		fmt.Printf("\nonly one run of %s, nothing to compare to yet\n", name)
+		return
+	}
+
+	verdict := "no drift"
+	if trend.Drift {
+		verdict = "DRIFT"
+	}
+	fmt.Printf("\n%s: latest p99 %s is %+.2f%% from the baseline %s (median of %d runs), trend %+.2f%% per run (threshold %.0f%%)\n",
+		verdict, trend.Latest, 100*trend.Change, trend.Baseline, len(trend.Runs)-1, 100*trend.Slope, 100*threshold)
+	if trend.Drift && fail {
+		os.Exit(1)
+	}
+}
+
+// listWorkloads prints the workloads with runs in the store, with their # of runs and the latest of them
+func listWorkloads(records []*dbperf.RunRecord) {
+	latest := make(map[string]*dbperf.RunRecord)
+	count := make(map[string]int)
+	for _, rec := range records {
+		latest[rec.Name] = rec // records are ordered oldest first
+		count[rec.Name]++
+	}
+
+	names := make([]string, 0, len(latest))
+	for name := range latest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "workload\truns\tlatest\tp99\n")
+	for _, name := range names {
+		rec := latest[name]
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", name, count[name], rec.Started.Format("2006-01-02 15:04:05"), rec.Stats.P99)
+	}
+	w.Flush()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"strings"
+	"timescale/dbperf"
+)
+
+// runInterferenceComparison runs the identical workload on its own and then with the heavy queries of -interference
+// running in the background, and reports how much they slow the workload down
+func runInterferenceComparison(ctx context.Context, cli *CliArgs, db dbperf.Queryable, newGenerator func() (dbperf.QueryGenerator, error), configure func(c *dbperf.Controller)) {
+	queries, err := readStatements(cli.interference)
+	if err != nil {
+		fatalf("failed to read %s: %s", cli.interference, err)
+	}
+	if len(queries) == 0 {
+		fatalf("%s has no queries", cli.interference)
+	}
+
+	runs := []string{"without interference", "with interference"}
+	stats := make([]*dbperf.QueryStats, len(runs))
+	for i, name := range runs {
+		g, err := newGenerator()
+		if err != nil {
+			fatalf("failed to read input: %s", err)
+		}
+
+		slog.Info("running workload", "run", name)
+		c := dbperf.NewController(dbperf.WithPoolSize(cli.nworkers))
+		configure(c)
+		if i == 1 {
+			c.SetInterference(dbperf.InterferenceConfig{Queries: queries, Concurrency: cli.interferenceN})
+		}
+
+		report, err := c.RunTest(ctx, db, g)
+		if err != nil {
+			fatalf("test run %s failed: %s", name, err)
+		}
+		stats[i] = report.QueryStats
+	}
+
+	for i, name := range runs {
+		s := stats[i]
+		fmt.Printf("%s: %d queries; %.1f qps; min: %s; max: %s; avg: %s; median: %s; p95: %s; p99: %s\n",
+			name, s.Processed, s.Throughput(), s.Min, s.Max, s.Avg, s.Median, s.P95, s.P99)
+	}
+
+	if is := stats[1].Interference; is != nil {
+		fmt.Printf("heavy queries: %d completed; %d failed; %d cancelled at the end", is.Completed, is.Errors, is.Cancelled)
+		if is.Latency.Processed > 0 {
+			fmt.Printf("; avg: %s; max: %s", is.Latency.Avg, is.Latency.Max)
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("with interference vs without:")
+	for _, d := range dbperf.Compare(stats[0], stats[1]) {
+		fmt.Printf("  %s\n", d)
+	}
+}
+
+// readStatements reads the SQL statements of a file, every statement ending with a semicolon at the end of a line
+func readStatements(filename string) ([]string, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []string
+	var b strings.Builder
+	for _, line := range strings.Split(string(buf), "\n") {
+		b.WriteString(line)
+		b.WriteString("\n")
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			if s := strings.TrimSpace(b.String()); s != ";" {
+				statements = append(statements, s)
+			}
+			b.Reset()
+		}
+	}
+	if s := strings.TrimSpace(b.String()); s != "" {
+		statements = append(statements, s)
+	}
+
+	return statements, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"timescale/dbperf"
+)
+
+// runIterations repeats the run the # of iterations given on the command line and prints the stats of every iteration,
+// of all of them pooled and the mean of every statistic with its confidence interval and spread across the iterations,
+// single runs being too noisy for tuning decisions
+func runIterations(ctx context.Context, cli *CliArgs, db dbperf.Queryable, newGenerator func() (dbperf.QueryGenerator, error), configure func(c *dbperf.Controller)) {
+	runs := make([]*dbperf.QueryStats, cli.iterations)
+	for i := range runs {
+		g, err := newGenerator()
+		if err != nil {
+			fatalf("failed to read input: %s", err)
+		}
+
+		c := dbperf.NewController(dbperf.WithPoolSize(cli.nworkers))
+		configure(c)
+		report, err := c.RunTest(ctx, db, g)
+		if err != nil {
+			fatalf("iteration %d failed: %s", i+1, err)
+		}
+		runs[i] = report.QueryStats
+		slog.Info("iteration done", "iteration", i+1, "of", cli.iterations, "queries", runs[i].Processed,
+			"median", runs[i].Median, "p99", runs[i].P99, "throughput", runs[i].Throughput())
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "iteration\tqueries\terrors\tmedian\tp95\tp99\tthroughput\n")
+	for i, s := range runs {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%s\t%s\t%.2f\n", i+1, s.Processed, s.Errors, s.Median, s.P95, s.P99, s.Throughput())
+	}
+	if pooled, err := dbperf.PoolRuns(runs); err != nil {
+		slog.Warn("failed to pool the iterations", "err", err)
+	} else {
+		fmt.Fprintf(w, "pooled\t%d\t%d\t%s\t%s\t%s\t%.2f\n", pooled.Processed, pooled.Errors, pooled.Median, pooled.P95, pooled.P99, pooled.Throughput())
+	}
+	w.Flush()
+	fmt.Println()
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "\tmean\t±95%% CI\trelative\tstddev\n")
+	for _, s := range dbperf.SummarizeRuns(runs) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t±%.2f%%\t%s\n", s.Name, formatInterval(s, s.Mean), formatInterval(s, s.Margin), s.RelativeMargin(),
+			formatInterval(s, s.StdDev))
+	}
+	w.Flush()
+	fmt.Printf("\n%d iterations\n", cli.iterations)
+}
+
+// formatInterval formats a value of the summarized statistic
+func formatInterval(s dbperf.StatInterval, v float64) string {
+	return formatStat(dbperf.StatDelta{Duration: s.Duration}, v)
+}
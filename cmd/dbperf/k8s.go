@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"timescale/dbperf"
+)
+
+// k8sPollInterval is how often the status of the job is checked while waiting for it to finish
+const k8sPollInterval = 5 * time.Second
+
+// k8sJob is the data the job manifest is rendered from
+type k8sJob struct {
+	Name      string
+	Namespace string
+	Image     string
+	Pods      int
+	Secret    string
+	Args      []string
+}
+
+var k8sManifest = template.Must(template.New("job").Funcs(template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}).Parse(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: {{.Name}}
+{{- if .Namespace}}
+  namespace: {{.Namespace}}
+{{- end}}
+  labels:
+    app: dbperf
+spec:
+  completionMode: Indexed
+  completions: {{.Pods}}
+  parallelism: {{.Pods}}
+  backoffLimit: 0
+  template:
+    metadata:
+      labels:
+        app: dbperf
+        dbperf-job: {{.Name}}
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: dbperf
+        image: {{json .Image}}
+        args: {{json .Args}}
+        env:
+        - name: JOB_COMPLETION_INDEX
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.annotations['batch.kubernetes.io/job-completion-index']
+{{- if .Secret}}
+        envFrom:
+        - secretRef:
+            name: {{.Secret}}
+{{- end}}
+`))
+
+// k8sCommand runs a workload on a Kubernetes indexed job and merges the results of its pods
+func k8sCommand(args []string) {
+	var cli CliArgs
+	var job k8sJob
+	var store, kubectl string
+	var dryRun bool
+	var timeout time.Duration
+	fs := newFlagSet("k8s", "FILENAME [-- RUN FLAGS]", `Runs the workload on an indexed Kubernetes job of -pods pods for more load than a single machine can generate.
+The input is uploaded to the object store and every pod runs the shard of it keyed to its index (see run -shards),
+saving its results to the store. Once the job completes the results are merged like the coordinator's.
+
+The store is any object store accepting HTTP PUT and GET of objects below the -store URL, e.g. a MinIO or S3
+compatible bucket. Set DBPERF_STORE_TOKEN to send a bearer token with the requests, the pods get it (and
+DB_PASSWORD) from the -secret. Flags after -- are passed on to run in every pod. Pods start as they're scheduled,
+use -duration so they all stop dispatching after the same time.`)
+	cli.RegisterConn(fs)
+	fs.StringVar(&cli.filename, "f", "", "path to input file containing queries to execute")
+	fs.StringVar(&cli.out, "out", "", "save the merged results to this JSON file for the compare and report commands")
+	fs.Var(&cli.tags, "tag", "label the run, and the results of every pod, with key=value to group and filter runs by later, may be repeated")
+	fs.IntVar(&job.Pods, "pods", 4, "number of pods to run the workload on")
+	fs.StringVar(&job.Image, "image", "", "container image with the dbperf binary as its entrypoint")
+	fs.StringVar(&job.Name, "job", "", "name of the job, dbperf-TIMESTAMP if not set")
+	fs.StringVar(&job.Namespace, "namespace", "", "namespace of the job, kubectl's current namespace if not set")
+	fs.StringVar(&job.Secret, "secret", "", "secret exposed to the pods as environment variables, e.g. DB_PASSWORD and DBPERF_STORE_TOKEN")
+	fs.StringVar(&store, "store", "", "base http(s) URL of the object store the input and results are exchanged through")
+	fs.StringVar(&kubectl, "kubectl", "kubectl", "path to kubectl")
+	fs.DurationVar(&timeout, "timeout", time.Hour, "give up waiting for the job after this long")
+	fs.BoolVar(&dryRun, "dry-run", false, "print the job manifest instead of starting the job")
+	parseFlags(fs, &cli, args)
+
+	// flags after the filename (or after -- when given with -f) are passed on to run
+	rest := fs.Args()
+	filename := cli.filename
+	if filename == "" {
+		if len(rest) == 0 {
+			fs.Usage()
+			os.Exit(1)
+		}
+		filename, rest = rest[0], rest[1:]
+	}
+	if len(rest) > 0 && rest[0] == "--" {
+		rest = rest[1:]
+	}
+
+	if job.Image == "" {
+		fatalf("no image given, see -image")
+	}
+	if !isURL(store) {
+		fatalf("-store must be an http(s) URL")
+	}
+	if job.Pods < 1 {
+		fatalf("-pods must be positive")
+	}
+	if job.Name == "" {
+		job.Name = "dbperf-" + time.Now().Format("20060102-150405")
+	}
+
+	prefix := strings.TrimSuffix(store, "/") + "/" + job.Name
+	input := prefix + "/input.csv"
+	job.Args = append([]string{
+		"run",
+		"-f", input,
+		"-shards", strconv.Itoa(job.Pods),
+		"-out", prefix + "/results-$(JOB_COMPLETION_INDEX).json",
+		"-host", cli.host,
+		"-port", cli.port,
+		"-user", cli.user,
+		"-dbname", cli.dbName,
+		"-sslmode", cli.sslMode,
+	}, rest...)
+	if len(cli.tags) > 0 {
+		job.Args = append(job.Args, "-tag", cli.tags.String())
+	}
+
+	var spec bytes.Buffer
+	if err := k8sManifest.Execute(&spec, &job); err != nil {
+		fatalf("failed to render job manifest: %s", err)
+	}
+	if dryRun {
+		os.Stdout.Write(spec.Bytes())
+		return
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fatalf("open %s: %s", filename, err)
+	}
+	manifest := newManifest(fs)
+	manifest.SetInput(filename, bytes.NewReader(data))
+
+	if err := putObject(input, data); err != nil {
+		fatalf("failed to upload the input: %s", err)
+	}
+
+	apply := kubectlCommand(kubectl, job.Namespace, "apply", "-f", "-")
+	apply.Stdin = &spec
+	if out, err := apply.CombinedOutput(); err != nil {
+		fatalf("failed to create job %s: %s: %s", job.Name, err, out)
+	}
+	slog.Info("started job", "job", job.Name, "pods", job.Pods)
+
+	if err := waitForJob(kubectl, &job, timeout); err != nil {
+		fatalf("job %s: %s", job.Name, err)
+	}
+
+	results := make([]*dbperf.Results, job.Pods)
+	for i := range results {
+		url := fmt.Sprintf("%s/results-%d.json", prefix, i)
+		data, err := fetchObject(url)
+		if err != nil {
+			fatalf("failed to fetch the results of pod %d: %s", i, err)
+		}
+		if results[i], err = dbperf.ReadResults(bytes.NewReader(data)); err != nil {
+			fatalf("failed to read the results of pod %d: %s", i, err)
+		}
+		slog.Info("pod done", "pod", i, "queries", results[i].Stats.Processed, "median", results[i].Stats.Median, "p99", results[i].Stats.P99)
+	}
+
+	merged, err := dbperf.MergeResults(results...)
+	if err != nil {
+		fatalf("failed to merge results: %s", err)
+	}
+
+	// the pods ran against the same server, record its versions as they saw them
+	if pm := results[0].Manifest; pm != nil {
+		manifest.Server = pm.Server
+		manifest.ServerVersion = pm.ServerVersion
+		manifest.TimescaleDBVersion = pm.TimescaleDBVersion
+		manifest.ServerSettings = pm.ServerSettings
+		manifest.Features = pm.Features
+	}
+	merged.ID = dbperf.NewRunID()
+	merged.Tags = cli.tags
+	merged.Manifest = manifest
+	if cli.out != "" {
+		if err := saveResults(cli.out, merged); err != nil {
+			fatalf("failed to save results: %s", err)
+		}
+	}
+
+	stats := merged.Stats
+	fmt.Printf("%d queries processed by %d pods after %s\n", stats.Processed, job.Pods, stats.TotalElapsed)
+	fmt.Printf("min: %s; max: %s; avg: %s; median: %s\n", stats.Min, stats.Max, stats.Avg, stats.Median)
+	fmt.Printf("p95: %s; p99: %s; throughput: %.1f qps\n", stats.P95, stats.P99, stats.Throughput())
+}
+
+// kubectlCommand returns a kubectl command in the namespace, kubectl's current one if empty
+func kubectlCommand(kubectl, namespace string, args ...string) *exec.Cmd {
+	if namespace != "" {
+		args = append([]string{"-n", namespace}, args...)
+	}
+	return exec.Command(kubectl, args...)
+}
+
+// waitForJob polls the job until all of its pods succeeded, failing as soon as one of them does
+func waitForJob(kubectl string, job *k8sJob, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		out, err := kubectlCommand(kubectl, job.Namespace, "get", "job", job.Name,
+			"-o", "jsonpath={.status.succeeded},{.status.failed}").Output()
+		if err != nil {
+			return fmt.Errorf("failed to get the job status: %s", err)
+		}
+
+		counts := strings.Split(strings.TrimSpace(string(out)), ",")
+		succeeded, _ := strconv.Atoi(counts[0])
+		var failed int
+		if len(counts) > 1 {
+			failed, _ = strconv.Atoi(counts[1])
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d pods failed, see kubectl logs -l dbperf-job=%s", failed, job.Name)
+		}
+		if succeeded >= job.Pods {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d of %d pods done after %s", succeeded, job.Pods, timeout)
+		}
+
+		time.Sleep(k8sPollInterval)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// logOutput is where the logs are written, the dashboard captures them while it's shown
+var logOutput = &switchWriter{w: os.Stderr}
+
+// switchWriter writes to a writer that may be switched while in use
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// Set switches the writer written to
+func (s *switchWriter) Set(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w = w
+}
+
+// logLevels are the levels of -log-level by name
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// setupLogging sets the default logger, which the library is given as well, to log at the level in the format, text
+// or json (one object per line)
+func setupLogging(level, format string) error {
+	lvl, ok := logLevels[level]
+	if !ok {
+		return fmt.Errorf("unknown log level: %s", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var h slog.Handler
+	switch format {
+	case "text":
+		h = slog.NewTextHandler(logOutput, opts)
+	case "json":
+		h = slog.NewJSONHandler(logOutput, opts)
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// atFatal is called with the error before fatalf exits when set, e.g. to notify the webhook of the failed run
+var atFatal func(msg string)
+
+// fatalf logs the error and exits
+func fatalf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	slog.Error(msg)
+	if atFatal != nil {
+		atFatal(msg)
+	}
+	os.Exit(1)
+}
@@ -1,16 +1,44 @@
 // dbperf is a command line utility for testing SELECT performance of a TimescaleDB / postgres database.
 //
+// Commands
+//
+// run: Run a workload against the database and report the latency (the default without a command)
+// validate: Check a workload and its schedule, load shape and tenant files without running it
+// seed: Create the cpu_usage hypertable and load it from a CSV file
+// gen: Generate synthetic query parameters for the cpu usage workload
+// compare: Compare the results of two runs saved with run -out
+// report: Render results saved with run -out as html, md or csv
+// serve: Serve an HTTP API to submit runs and fetch their results remotely
+// agent: Run the share of a workload sent by a coordinator
+// coordinator: Shard a workload across agents, start them at once and merge their results
+// k8s: Run a workload on a Kubernetes indexed job and merge the results of its pods
+// history: Show the p99 trend of a workload across stored runs and flag drift
+// replay: Re-execute a run recorded with run -record with its original pacing
+// matrix: Run a workload for every combination of the settings of a config file matrix
+//
 // Environment Variables
 //
+// The connection settings below may be overridden with the equivalent command line flags (except the password).
+// The password may instead be read from a file (-password-file), the output of a command such as a secret manager
+// CLI (-password-command) or, when DB_PASSWORD isn't set either, a matching ~/.pgpass (or PGPASSFILE) entry.
+//
 // DB_HOST: The database host to connect to (default: localhost)
 // DB_PORT: The database port (default: 5432)
 // DB_USER: The database username to use (default: postgres)
-// DB_PASSWORD: The database username to use (default: password)
+// DB_PASSWORD: The database password to use, DB_PW is also accepted (default: password)
 // DB_NAME: The database name to use (default: homework)
+// DB_SSLMODE: The TLS mode, one of disable, require, verify-ca or verify-full (default: disable)
+// DB_SSLROOTCERT: Path to the root CA certificate to verify the server with
+// DB_SSLCERT: Path to the client certificate to present to the server
+// DB_SSLKEY: Path to the client certificate's private key
 //
 // The DBPERFDEBUG variable controls debugging variables within the runtime. It is a comma-separated list of name=val pairs setting these named variables:
 //
-// pprof: Setting pprof=X causes an HTTP server listening on port X to serve the profiling data expected by the pprof tool. See https://golang.org/pkg/net/http/pprof
+// pprof: Setting pprof=X causes an HTTP server listening on port X to serve the profiling data expected by the pprof tool. See https://golang.org/pkg/net/http/pprof. It also serves the dispatch stats of the run in progress, the queue depth and queries dispatched, in flight and completed by worker, under "dispatch" on /debug/vars, and the stats of the queries completed within the last -window under "recent". See https://golang.org/pkg/expvar
+//
+// trace: Setting trace=FILE captures a Go runtime execution trace of the run to FILE for go tool trace. See https://golang.org/pkg/runtime/trace
+//
+// schedtrace: Setting schedtrace=X causes a line summarizing the dispatch state of the run, the queries dispatched, completed and in flight and the queue depth of every worker, to be written to stderr every X milliseconds, like GODEBUG=schedtrace
 //
 package main
 
@@ -19,23 +47,96 @@ import (
 	"database/sql"
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"path"
 	"timescale/dbperf"
 
-	"net/http"
 	_ "net/http/pprof"
 
 	_ "github.com/lib/pq"
 )
 
-var (
-	host     = getenv("DB_HOST", "localhost")
-	port     = getenv("DB_PORT", "5432")
-	user     = getenv("DB_USER", "postgres")
-	password = getenv("DB_PW", "password")
-	dbName   = getenv("DB_NAME", "homework")
-)
+// applicationName identifies dbperf's own sessions on the server
+const applicationName = "dbperf"
+
+// generators maps the built-in query template names to their generator
+var generators = map[string]func(io.Reader) dbperf.QueryGenerator{
+	"minmax":    dbperf.NewCPUTestGenerator,
+	"lastfirst": dbperf.NewLastFirstTestGenerator,
+}
+
+// connString returns the connection string to connect to the database as the given user
+func connString(cli *CliArgs, user, password string) string {
+	return connStringTo(cli, cli.host, cli.port, user, password)
+}
+
+// connStringTo returns the connection string to connect to the database at the given host and port
+func connStringTo(cli *CliArgs, host, port, user, password string) string {
+	cfg := connConfig{
+		host:        host,
+		port:        port,
+		user:        user,
+		password:    password,
+		dbName:      cli.dbName,
+		sslMode:     cli.sslMode,
+		sslRootCert: cli.sslRootCert,
+		sslCert:     cli.sslCert,
+		sslKey:      cli.sslKey,
+	}
+
+	return cfg.String()
+}
+
+// readTenants reads a tenant file
+func readTenants(filename string) (dbperf.Tenants, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return dbperf.ParseTenants(f)
+}
+
+// tenantConns returns the dedicated connection of every worker according to its tenant, tenants that log in as
+// their role get a database handle of their own, with the password of db unless they have their own
+func tenantConns(cli *CliArgs, tenants dbperf.Tenants, db *sql.DB, password string) (func(id int) dbperf.WorkerConn, error) {
+	connects := make(map[string]dbperf.ConnectFunc, len(tenants))
+	for _, t := range tenants {
+		if !t.Login {
+			connects[t.Role] = dbperf.WithRole(dbperf.SQLConnector(db), t.Role)
+			continue
+		}
+
+		pw := t.Password
+		if pw == "" {
+			pw = password
+		}
+
+		tdb, err := sql.Open("postgres", connString(cli, t.Role, pw))
+		if err != nil {
+			return nil, err
+		}
+		connects[t.Role] = dbperf.SQLConnector(tdb)
+	}
+
+	return func(id int) dbperf.WorkerConn {
+		t := tenants.Assign(id)
+		return dbperf.WorkerConn{Connect: connects[t.Role], Tenant: t.Role}
+	}, nil
+}
+
+// readSchedule reads a load schedule file
+func readSchedule(filename string) (dbperf.Schedule, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return dbperf.ParseSchedule(f)
+}
 
 // getenv is a utility function to get a value from the environment or return the default if not found
 func getenv(key, def string) string {
@@ -47,75 +148,153 @@ func getenv(key, def string) string {
 	return val
 }
 
-func usage(fs *flag.FlagSet) func() {
-	return func() {
-		fmt.Fprintf(os.Stdout, "usage: dbperf [FLAGS] FILENAME\n\n")
-		fmt.Fprintf(os.Stdout, "Filename may be specified as either an argument or via the -f flag\n\n")
-		fs.PrintDefaults()
+// command is a dbperf subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+// commands lists the subcommands in the order they're shown in the usage
+var commands = []command{
+	{"run", "run a workload against the database and report the latency", runCommand},
+	{"validate", "check a workload and its schedule, load shape and tenant files without running it", validateCommand},
+	{"seed", "create the cpu_usage hypertable and load it from a CSV file", seedCommand},
+	{"gen", "generate synthetic query parameters for the cpu usage workload", genCommand},
+	{"compare", "compare the results of two runs saved with run -out", compareCommand},
+	{"report", "render results saved with run -out as html, md or csv", reportCommand},
+	{"serve", "serve an HTTP API to submit runs and fetch their results remotely", serveCommand},
+	{"agent", "run the share of a workload sent by a coordinator", agentCommand},
+	{"coordinator", "shard a workload across agents, start them at once and merge their results", coordinatorCommand},
+	{"k8s", "run a workload on a Kubernetes indexed job and merge the results of its pods", k8sCommand},
+	{"history", "show the p99 trend of a workload across stored runs and flag drift", historyCommand},
+	{"replay", "re-execute a run recorded with run -record with its original pacing", replayCommand},
+	{"matrix", "run a workload for every combination of the settings of a config file matrix", matrixCommand},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stdout, "usage: dbperf COMMAND [FLAGS] [ARGS]\n\n")
+	fmt.Fprintf(os.Stdout, "Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stdout, "  %-12s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintf(os.Stdout, "\nRun dbperf COMMAND -h for the flags of a command. Without a command the arguments are passed to run.\n")
 }
 
-func main() {
-	var cli CliArgs
-	fs := flag.NewFlagSet("dbperf", flag.ExitOnError)
-	fs.Usage = usage(fs)
-	cli.Register(fs)
+// newFlagSet creates the flagset of a subcommand with a usage describing its arguments
+func newFlagSet(name, args, help string) *flag.FlagSet {
+	fs := flag.NewFlagSet("dbperf "+name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stdout, "usage: dbperf %s [FLAGS] %s\n\n", name, args)
+		if help != "" {
+			fmt.Fprintf(os.Stdout, "%s\n", help)
+		}
+		fmt.Fprintf(os.Stdout, "Any flag may also be set in a YAML config file given with -config\n\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
 
-	if err := fs.Parse(os.Args[1:]); err != nil {
+// parseFlags parses the arguments of a subcommand and applies the config file if one was given
+func parseFlags(fs *flag.FlagSet, cli *CliArgs, args []string) {
+	cli.RegisterLogging(fs)
+	if err := fs.Parse(args); err != nil {
 		fs.Usage()
 		os.Exit(1)
 	}
+	applyFlags(fs, cli)
+}
 
-	args := fs.Args()
-	if cli.filename == "" && len(args) != 1 {
-		fs.Usage()
-		os.Exit(1)
+// applyFlags applies the config file if one was given and sets up the logging once the arguments are parsed, for
+// subcommands parsing them themselves
+func applyFlags(fs *flag.FlagSet, cli *CliArgs) {
+	if cli.config != "" {
+		if err := loadConfig(fs, cli.config); err != nil {
+			fatalf("failed to load config: %s", err)
+		}
+	}
+
+	if err := setupLogging(cli.logLevel, cli.logFormat); err != nil {
+		fatalf("%s", err)
 	}
+}
 
-	filename := cli.filename
+// workloadName identifies the workload of a run in the results store, the history command follows the runs of a
+// workload by it
+func workloadName(query, filename string) string {
 	if filename == "" {
-		filename = args[0]
+		return query + " (inline)"
 	}
+	return query + " " + path.Base(filename)
+}
 
-	f, err := os.Open(filename)
-	if err != nil {
-		log.Fatalf("open %s: %s\n", filename, err)
+// newManifest starts the manifest of a run with the command line and the value of every flag of the command
+func newManifest(fs *flag.FlagSet) *dbperf.Manifest {
+	m := dbperf.NewManifest()
+	m.Args = os.Args[1:]
+	m.Flags = make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		m.Flags[f.Name] = f.Value.String()
+	})
+	return m
+}
+
+// inputFilename returns the input file given either with -f or as the only argument
+func inputFilename(fs *flag.FlagSet, cli *CliArgs) string {
+	if cli.filename != "" {
+		return cli.filename
 	}
-	defer f.Close()
 
-	log.SetFlags(log.Ldate | log.Lmicroseconds)
-	log.Println("starting dbperf...")
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	return fs.Arg(0)
+}
 
-	// run pprof monitor if asked
-	if debug.pprof > 0 {
-		go func() {
-			laddr := fmt.Sprintf(":%d", debug.pprof)
-			log.Printf("DEBUG: starting pprof server: http://%s/debug/pprof\n", laddr)
-			log.Fatal(http.ListenAndServe(laddr, nil))
-		}()
+// openDB connects to the database and verifies the connection is good, returning the password it connected with for
+// the other connections of the command. The password is never taken from a flag directly so it doesn't show up in the
+// process list (see resolvePassword).
+func openDB(ctx context.Context, cli *CliArgs) (*sql.DB, string) {
+	password, err := resolvePassword(cli)
+	if err != nil {
+		fatalf("failed to get database password: %s", err)
 	}
 
-	connStr := fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%s sslmode=disable", user, password, dbName, host, port)
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", connString(cli, cli.user, password))
 	if err != nil {
-		log.Fatalf("failed to connect to database: %s\n", err)
+		fatalf("failed to connect to database: %s", err)
 	}
 
-	ctx := context.Background()
 	if err := db.PingContext(ctx); err != nil {
-		log.Fatalf("failed to ping database: %s\n", err)
+		fatalf("failed to ping database: %s", err)
 	}
-	log.Println("database connection good...starting test")
 
-	controller := dbperf.NewController(cli.nworkers)
-	generator := dbperf.NewCPUTestGenerator(f)
+	return db, password
+}
 
-	stats, err := controller.RunTest(ctx, db, generator)
-	if err != nil {
-		log.Fatalf("test run failed: %s\n", err)
+func main() {
+	setupLogging("info", "text")
+
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
 	}
 
-	fmt.Printf("%d queries processed after %s\n", stats.Processed, stats.TotalElapsed)
-	fmt.Printf("min: %s; max: %s; avg: %s; median: %s\n", stats.Min, stats.Max, stats.Avg, stats.Median)
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			cmd.run(args[1:])
+			return
+		}
+	}
 
+	// dbperf [FLAGS] FILENAME predates the subcommands and is still supported
+	runCommand(args)
 }
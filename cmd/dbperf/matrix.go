@@ -0,0 +1,302 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"timescale/dbperf"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// matrixSetting is a setting of the matrix: section of a config file with the values runs are made with
+type matrixSetting struct {
+	name   string
+	values []string
+}
+
+// matrixConnParams are the parameters of a dsn in a matrix and the flags they set
+var matrixConnParams = []string{"sslmode", "sslrootcert", "sslcert", "sslkey"}
+
+// matrixCommand runs a workload for every combination of the settings of a matrix and prints a table of the results
+func matrixCommand(args []string) {
+	var cli CliArgs
+	var outDir string
+	fs := newFlagSet("matrix", "FILENAME [-- RUN FLAGS]", `Runs the workload once for every combination of the settings of the matrix: section of the -config file and
+prints a table of the results of all of them, e.g.
+
+  matrix:
+    n: [4, 16, 64]
+    query: [minmax, lastfirst]
+    dsn:
+      - postgres://postgres@db1:5432/homework
+      - postgres://postgres@db2:5432/homework?sslmode=require
+
+makes 12 runs. Settings are run flags, or dsn for the database to connect to as a postgres:// URL, the rest of the
+config applies to every run. Flags after -- are passed on to every run. Failed runs are marked in the table and the
+command exits with an error after all of them.`)
+	fs.StringVar(&cli.config, "config", "", "path to the YAML config file declaring the matrix")
+	fs.StringVar(&cli.filename, "f", "", "path (or http(s) object store URL) to input file containing queries to execute")
+	fs.StringVar(&outDir, "out-dir", "", "save the results of every run to this directory as run-N.json for the compare and report commands")
+	parseFlags(fs, &cli, args)
+
+	// flags after the filename (or after -- when given with -f) are passed on to run
+	rest := fs.Args()
+	filename := cli.filename
+	if filename == "" {
+		if len(rest) == 0 {
+			fs.Usage()
+			os.Exit(1)
+		}
+		filename, rest = rest[0], rest[1:]
+	}
+	if len(rest) > 0 && rest[0] == "--" {
+		rest = rest[1:]
+	}
+
+	if cli.config == "" {
+		fatalf("no config given, see -config")
+	}
+	settings, err := readMatrix(cli.config)
+	if err != nil {
+		fatalf("failed to read matrix: %s", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fatalf("failed to find the dbperf executable: %s", err)
+	}
+	if outDir == "" {
+		if outDir, err = ioutil.TempDir("", "dbperf-matrix"); err != nil {
+			fatalf("failed to create results directory: %s", err)
+		}
+		defer os.RemoveAll(outDir)
+	} else if err := os.MkdirAll(outDir, 0755); err != nil {
+		fatalf("failed to create results directory: %s", err)
+	}
+
+	runs := matrixCombinations(settings)
+	slog.Info("running matrix", "runs", len(runs))
+
+	results := make([]*dbperf.Results, len(runs))
+	failed := 0
+	for i, values := range runs {
+		runArgs, password, err := matrixRunArgs(settings, values)
+		if err != nil {
+			fatalf("run %d: %s", i+1, err)
+		}
+
+		out := filepath.Join(outDir, fmt.Sprintf("run-%d.json", i+1))
+		// settings of the matrix take precedence over the flags passed on to every run
+		cmdArgs := append([]string{"run", "-config", cli.config}, rest...)
+		cmdArgs = append(cmdArgs, runArgs...)
+		cmdArgs = append(cmdArgs, "-out", out, "-f", filename)
+
+		slog.Info("matrix run", "run", i+1, "of", len(runs), "args", strings.Join(runArgs, " "))
+		cmd := exec.Command(exe, cmdArgs...)
+		// keep stdout for the table, the summary of every run goes with the logs
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		cmd.Env = os.Environ()
+		if password != "" {
+			cmd.Env = append(cmd.Env, "DB_PASSWORD="+password)
+		}
+		if err := cmd.Run(); err != nil {
+			slog.Error("matrix run failed", "run", i+1, "err", err)
+			failed++
+			continue
+		}
+
+		f, err := os.Open(out)
+		if err != nil {
+			fatalf("open %s: %s", out, err)
+		}
+		results[i], err = dbperf.ReadResults(f)
+		f.Close()
+		if err != nil {
+			fatalf("failed to read results %s: %s", out, err)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, s := range settings {
+		fmt.Fprintf(w, "%s\t", s.name)
+	}
+	fmt.Fprintf(w, "queries\tqps\tavg\tmedian\tp95\tp99\n")
+	for i, values := range runs {
+		for j, v := range values {
+			fmt.Fprintf(w, "%s\t", matrixLabel(settings[j].name, v))
+		}
+		if results[i] == nil {
+			fmt.Fprintf(w, "failed\t\t\t\t\t\n")
+			continue
+		}
+		stats := results[i].Stats
+		fmt.Fprintf(w, "%d\t%.1f\t%s\t%s\t%s\t%s\n", stats.Processed, stats.Throughput(), stats.Avg, stats.Median, stats.P95, stats.P99)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		fatalf("%d of %d runs failed", failed, len(runs))
+	}
+}
+
+// readMatrix reads the settings of the matrix: section of a config file in the order they're declared. A setting
+// maps a run flag, or dsn, to a list of values or a single one.
+func readMatrix(filename string) ([]matrixSetting, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Matrix yaml.Node `yaml:"matrix"`
+	}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if doc.Matrix.Kind == 0 {
+		return nil, fmt.Errorf("%s: no matrix declared", filename)
+	}
+	if doc.Matrix.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: matrix must map settings to lists of values", filename)
+	}
+
+	var known CliArgs
+	run := flag.NewFlagSet("", flag.ContinueOnError)
+	known.Register(run)
+
+	var settings []matrixSetting
+	nodes := doc.Matrix.Content
+	for i := 0; i+1 < len(nodes); i += 2 {
+		s := matrixSetting{name: nodes[i].Value}
+		switch s.name {
+		case "dsn":
+		case "config", "out", "f":
+			return nil, fmt.Errorf("%s: %s can't be a matrix setting", filename, s.name)
+		default:
+			if run.Lookup(s.name) == nil {
+				return nil, fmt.Errorf("%s: unknown matrix setting: %s", filename, s.name)
+			}
+		}
+
+		switch v := nodes[i+1]; v.Kind {
+		case yaml.ScalarNode:
+			s.values = []string{v.Value}
+		case yaml.SequenceNode:
+			for _, item := range v.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("%s: matrix setting %s: values must be scalars", filename, s.name)
+				}
+				s.values = append(s.values, item.Value)
+			}
+		default:
+			return nil, fmt.Errorf("%s: matrix setting %s: values must be a list", filename, s.name)
+		}
+		if len(s.values) == 0 {
+			return nil, fmt.Errorf("%s: matrix setting %s: no values", filename, s.name)
+		}
+
+		settings = append(settings, s)
+	}
+	if len(settings) == 0 {
+		return nil, fmt.Errorf("%s: no matrix settings", filename)
+	}
+
+	return settings, nil
+}
+
+// matrixCombinations returns the values of every combination of the settings, the last setting varying fastest
+func matrixCombinations(settings []matrixSetting) [][]string {
+	runs := [][]string{nil}
+	for _, s := range settings {
+		next := make([][]string, 0, len(runs)*len(s.values))
+		for _, run := range runs {
+			for _, v := range s.values {
+				values := append(append([]string(nil), run...), v)
+				next = append(next, values)
+			}
+		}
+		runs = next
+	}
+	return runs
+}
+
+// matrixRunArgs returns the run flags setting the values of a combination of the settings, and the password of its
+// dsn if it has one
+func matrixRunArgs(settings []matrixSetting, values []string) ([]string, string, error) {
+	var args []string
+	var password string
+	for i, s := range settings {
+		if s.name != "dsn" {
+			args = append(args, "-"+s.name+"="+values[i])
+			continue
+		}
+
+		dsnArgs, pw, err := parseDSN(values[i])
+		if err != nil {
+			return nil, "", err
+		}
+		args = append(args, dsnArgs...)
+		password = pw
+	}
+	return args, password, nil
+}
+
+// parseDSN returns the connection flags set by a postgres:// URL and its password if it has one
+func parseDSN(dsn string) ([]string, string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return nil, "", fmt.Errorf("%s: not a postgres:// URL", u.Redacted())
+	}
+
+	var args []string
+	var password string
+	if host := u.Hostname(); host != "" {
+		args = append(args, "-host="+host)
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-port="+port)
+	}
+	if u.User != nil {
+		args = append(args, "-user="+u.User.Username())
+		password, _ = u.User.Password()
+	}
+	if dbName := strings.TrimPrefix(u.Path, "/"); dbName != "" {
+		args = append(args, "-dbname="+dbName)
+	}
+
+	params := u.Query()
+	for _, p := range matrixConnParams {
+		if v := params.Get(p); v != "" {
+			args = append(args, "-"+p+"="+v)
+		}
+		delete(params, p)
+	}
+	for p := range params {
+		return nil, "", fmt.Errorf("%s: unsupported parameter: %s", u.Redacted(), p)
+	}
+
+	return args, password, nil
+}
+
+// matrixLabel returns the value of a setting as shown in the results table, without the password of a dsn
+func matrixLabel(name, value string) string {
+	if name != "dsn" {
+		return value
+	}
+	if u, err := url.Parse(value); err == nil {
+		return u.Redacted()
+	}
+	return value
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// defaultPassword is used when no other password source is configured
+const defaultPassword = "password"
+
+// resolvePassword determines the database password from, in order of precedence, the -password-file flag, the
+// -password-command flag, the DB_PASSWORD (or DB_PW) environment variable and a matching ~/.pgpass entry
+func resolvePassword(cli *CliArgs) (string, error) {
+	if cli.passwordFile != "" {
+		buf, err := ioutil.ReadFile(cli.passwordFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(buf), "\r\n"), nil
+	}
+
+	if cli.passwordCommand != "" {
+		cmd := exec.Command("sh", "-c", cli.passwordCommand)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("password command: %s", err)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+
+	if pw := getenv("DB_PASSWORD", os.Getenv("DB_PW")); pw != "" {
+		return pw, nil
+	}
+
+	pw, ok, err := pgpassLookup(pgpassFile(), cli.host, cli.port, cli.dbName, cli.user)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return pw, nil
+	}
+
+	return defaultPassword, nil
+}
+
+// pgpassFile returns the path of the password file, PGPASSFILE or ~/.pgpass the same as libpq
+func pgpassFile() string {
+	if path := os.Getenv("PGPASSFILE"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pgpass")
+}
+
+// pgpassLookup returns the password of the first entry of the password file matching the connection. A missing file
+// isn't an error and, like libpq, a file readable by group or others is ignored.
+// See https://www.postgresql.org/docs/current/libpq-pgpass.html
+func pgpassLookup(path, host, port, dbName, user string) (string, bool, error) {
+	if path == "" {
+		return "", false, nil
+	}
+
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		slog.Warn("password file has group or world access, ignoring it; permissions should be 0600 or less", "file", path)
+		return "", false, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	want := []string{host, port, dbName, user}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := splitPgpass(line)
+		if len(fields) != 5 {
+			continue
+		}
+
+		match := true
+		for i, w := range want {
+			if fields[i] != "*" && fields[i] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return fields[4], true, nil
+		}
+	}
+
+	return "", false, scanner.Err()
+}
+
+// splitPgpass splits a password file line on unescaped colons, unescaping \: and \\
+func splitPgpass(line string) []string {
+	var fields []string
+	var field strings.Builder
+
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			field.WriteByte(line[i])
+		case c == ':' && len(fields) < 4:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(c)
+		}
+	}
+
+	return append(fields, field.String())
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitPgpass(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		expected []string
+	}{
+		{"localhost:5432:postgres:user:secret", []string{"localhost", "5432", "postgres", "user", "secret"}},
+		{`db\:1:5432:postgres:user:secret`, []string{"db:1", "5432", "postgres", "user", "secret"}},
+		{`localhost:5432:postgres:user:back\\slash`, []string{"localhost", "5432", "postgres", "user", `back\slash`}},
+		// the password is everything after the fourth colon
+		{"localhost:5432:postgres:user:se:cret", []string{"localhost", "5432", "postgres", "user", "se:cret"}},
+		{`localhost:5432:postgres:user:secret\`, []string{"localhost", "5432", "postgres", "user", `secret\`}},
+		{"localhost:5432", []string{"localhost", "5432"}},
+	} {
+		t.Run(tc.line, func(t *testing.T) {
+			assert.Equal(t, tc.expected, splitPgpass(tc.line))
+		})
+	}
+}
+
+// writePgpass writes a password file with the given permissions to a temporary directory and returns its path
+func writePgpass(t *testing.T, content string, perm os.FileMode) string {
+	path := filepath.Join(t.TempDir(), ".pgpass")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	require.NoError(t, os.Chmod(path, perm))
+	return path
+}
+
+func TestPgpassLookup(t *testing.T) {
+	path := writePgpass(t, `#db4:5432:postgres:user:commented
+db1:5432:postgres:user:first
+db1:5432:postgres:user:second
+*:6432:*:user:wildcard
+db\:2:5432:postgres:user:escaped
+malformed:line
+db3:5432:postgres:admin:admin
+`, 0600)
+
+	for _, tc := range []struct {
+		name                     string
+		host, port, dbName, user string
+		password                 string
+		ok                       bool
+	}{
+		{name: "first match wins", host: "db1", port: "5432", dbName: "postgres", user: "user", password: "first", ok: true},
+		{name: "wildcards", host: "db9", port: "6432", dbName: "other", user: "user", password: "wildcard", ok: true},
+		{name: "escaped", host: "db:2", port: "5432", dbName: "postgres", user: "user", password: "escaped", ok: true},
+		{name: "user", host: "db3", port: "5432", dbName: "postgres", user: "admin", password: "admin", ok: true},
+		{name: "no match", host: "db3", port: "5432", dbName: "postgres", user: "user"},
+		{name: "comment", host: "#db4", port: "5432", dbName: "postgres", user: "user"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pw, ok, err := pgpassLookup(path, tc.host, tc.port, tc.dbName, tc.user)
+			require.NoError(t, err)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.password, pw)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, ok, err := pgpassLookup(filepath.Join(t.TempDir(), ".pgpass"), "db1", "5432", "postgres", "user")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("readable by others", func(t *testing.T) {
+		_, ok, err := pgpassLookup(writePgpass(t, "*:*:*:*:secret\n", 0644), "db1", "5432", "postgres", "user")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestResolvePassword(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, ioutil.WriteFile(file, []byte("from-file\n"), 0600))
+	pgpass := writePgpass(t, "*:*:*:*:from-pgpass\n", 0600)
+
+	for _, tc := range []struct {
+		name     string
+		cli      CliArgs
+		env      map[string]string
+		expected string
+	}{
+		{name: "file", cli: CliArgs{passwordFile: file, passwordCommand: "echo from-command"}, env: map[string]string{"DB_PASSWORD": "from-env", "PGPASSFILE": pgpass}, expected: "from-file"},
+		{name: "command", cli: CliArgs{passwordCommand: "echo from-command"}, env: map[string]string{"DB_PASSWORD": "from-env", "PGPASSFILE": pgpass}, expected: "from-command"},
+		{name: "env", env: map[string]string{"DB_PASSWORD": "from-env", "DB_PW": "from-pw", "PGPASSFILE": pgpass}, expected: "from-env"},
+		{name: "legacy env", env: map[string]string{"DB_PW": "from-pw", "PGPASSFILE": pgpass}, expected: "from-pw"},
+		{name: "pgpass", env: map[string]string{"PGPASSFILE": pgpass}, expected: "from-pgpass"},
+		{name: "default", env: map[string]string{"PGPASSFILE": filepath.Join(t.TempDir(), ".pgpass")}, expected: defaultPassword},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, key := range []string{"DB_PASSWORD", "DB_PW", "PGPASSFILE"} {
+				t.Setenv(key, tc.env[key])
+			}
+
+			pw, err := resolvePassword(&tc.cli)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, pw)
+		})
+	}
+
+	_, err := resolvePassword(&CliArgs{passwordCommand: "exit 1"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+	"timescale/dbperf"
+)
+
+// summaryFormats lists the formats the summary of a run can be printed in, see -summary
+var summaryFormats = map[string]bool{"dbperf": true, "pgbench": true}
+
+// writePgbenchSummary writes the summary of a run in the format of pgbench's, every query counting as a transaction,
+// for dashboards and parsers built around pgbench. Connect is the time taken to establish the initial connection,
+// which the tps including connections establishing accounts for. The queries of every label are reported like the
+// scripts of a pgbench run of several.
+func writePgbenchSummary(w io.Writer, query string, clients int, duration time.Duration, stats *dbperf.QueryStats, connect time.Duration) error {
+	tps := func(n int64, elapsed time.Duration) float64 {
+		if elapsed <= 0 {
+			return 0
+		}
+		return float64(n) / elapsed.Seconds()
+	}
+
+	fmt.Fprintf(w, "transaction type: %s\n", query)
+	fmt.Fprintf(w, "query mode: extended\n")
+	fmt.Fprintf(w, "number of clients: %d\n", clients)
+	fmt.Fprintf(w, "number of threads: %d\n", clients)
+	if duration > 0 {
+		fmt.Fprintf(w, "duration: %.0f s\n", duration.Seconds())
+	}
+	fmt.Fprintf(w, "number of transactions actually processed: %d\n", stats.Processed)
+	fmt.Fprintf(w, "latency average = %.3f ms\n", pgbenchMillis(stats.Avg))
+	fmt.Fprintf(w, "latency stddev = %.3f ms\n", pgbenchMillis(stats.StdDev))
+	fmt.Fprintf(w, "initial connection time = %.3f ms\n", pgbenchMillis(connect))
+	fmt.Fprintf(w, "tps = %f (including connections establishing)\n", tps(stats.Processed, stats.Duration+connect))
+	_, err := fmt.Fprintf(w, "tps = %f (excluding connections establishing)\n", tps(stats.Processed, stats.Duration))
+
+	labels := make([]string, 0, len(stats.Labels))
+	for l := range stats.Labels {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	for i, l := range labels {
+		ls := stats.Labels[l]
+		fmt.Fprintf(w, "SQL script %d: %s\n", i+1, l)
+		fmt.Fprintf(w, " - %d transactions (%.1f%% of total, tps = %f)\n", ls.Processed, percentOf(ls.Processed, stats.Processed),
+			tps(ls.Processed, stats.Duration))
+		fmt.Fprintf(w, " - latency average = %.3f ms\n", pgbenchMillis(ls.Avg))
+		_, err = fmt.Fprintf(w, " - latency stddev = %.3f ms\n", pgbenchMillis(ls.StdDev))
+	}
+	return err
+}
+
+// percentOf returns n as a percentage of total, 0 if total is 0
+func percentOf(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// pgbenchMillis converts a latency to the milliseconds pgbench reports
+func pgbenchMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net"
+	"timescale/dbperf"
+)
+
+// endpoint is a database endpoint the workload is run against
+type endpoint struct {
+	name string
+	db   *sql.DB
+}
+
+// runPoolerComparison runs the identical workload directly against the database and through the pooler at addr
+// (host:port), connecting to it with password, and reports the overhead of the pooler and the differences in session
+// behavior
+func runPoolerComparison(ctx context.Context, cli *CliArgs, direct *sql.DB, password, addr string, newGenerator func() (dbperf.QueryGenerator, error), configure func(c *dbperf.Controller)) {
+	poolerHost, poolerPort, err := net.SplitHostPort(addr)
+	if err != nil {
+		fatalf("invalid pooler address %s: %s", addr, err)
+	}
+
+	pooler, err := sql.Open("postgres", connStringTo(cli, poolerHost, poolerPort, cli.user, password))
+	if err != nil {
+		fatalf("failed to connect to pooler: %s", err)
+	}
+	defer pooler.Close()
+
+	if err := pooler.PingContext(ctx); err != nil {
+		fatalf("failed to ping pooler: %s", err)
+	}
+
+	endpoints := []endpoint{{"direct", direct}, {"pooler", pooler}}
+	stats := make([]*dbperf.QueryStats, len(endpoints))
+	behavior := make([]*dbperf.SessionBehavior, len(endpoints))
+
+	for i, ep := range endpoints {
+		behavior[i], err = probeSession(ctx, ep.db)
+		if err != nil {
+			fatalf("failed to probe %s session behavior: %s", ep.name, err)
+		}
+
+		g, err := newGenerator()
+		if err != nil {
+			fatalf("failed to read input: %s", err)
+		}
+
+		slog.Info("running workload", "endpoint", ep.name)
+		c := dbperf.NewController(dbperf.WithPoolSize(cli.nworkers))
+		configure(c)
+
+		report, err := c.RunTest(ctx, ep.db, g)
+		if err != nil {
+			fatalf("%s test run failed: %s", ep.name, err)
+		}
+		stats[i] = report.QueryStats
+	}
+
+	for i, ep := range endpoints {
+		s := stats[i]
+		fmt.Printf("%s: %d queries; %.1f qps; min: %s; max: %s; avg: %s; median: %s; p95: %s; p99: %s\n",
+			ep.name, s.Processed, s.Throughput(), s.Min, s.Max, s.Avg, s.Median, s.P95, s.P99)
+	}
+
+	fmt.Println("pooler overhead:")
+	for _, d := range dbperf.Compare(stats[0], stats[1]) {
+		fmt.Printf("  %s\n", d)
+	}
+
+	fmt.Printf("%-22s %-8s %s\n", "session behavior", "direct", "pooler")
+	fmt.Printf("%-22s %-8t %t\n", "prepared statements", behavior[0].PreparedStatements, behavior[1].PreparedStatements)
+	fmt.Printf("%-22s %-8t %t\n", "session settings", behavior[0].SessionSettings, behavior[1].SessionSettings)
+	fmt.Printf("%-22s %-8t %t\n", "stable backend", behavior[0].StableBackend, behavior[1].StableBackend)
+}
+
+// probeSession probes the session behavior on a dedicated connection of db
+func probeSession(ctx context.Context, db *sql.DB) (*dbperf.SessionBehavior, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return dbperf.ProbeSession(ctx, conn)
+}
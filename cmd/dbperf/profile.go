@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// profiler captures CPU and heap profiles of dbperf itself to a directory while a run progresses, see -profile-dir
+type profiler struct {
+	dir string
+	seq int
+	cpu *os.File // CPU profile being captured
+}
+
+// startProfiling captures a CPU profile of every interval of the run and a heap profile at the end of it as
+// cpu-SEQ.pprof and heap-SEQ.pprof files in dir, only one of each for the whole run if interval is 0. The returned
+// func captures the last profiles and stops.
+func startProfiling(dir string, interval time.Duration) (func(), error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	p := &profiler{dir: dir}
+	if err := p.startCPU(); err != nil {
+		return nil, err
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case <-tick:
+				p.capture()
+				if err := p.startCPU(); err != nil {
+					slog.Warn("failed to start CPU profile", "err", err)
+				}
+			case <-stop:
+				p.capture()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}, nil
+}
+
+// startCPU starts capturing the CPU profile of the next interval
+func (p *profiler) startCPU() error {
+	p.seq++
+	f, err := os.Create(p.path("cpu"))
+	if err != nil {
+		return err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	p.cpu = f
+	return nil
+}
+
+// capture finishes the CPU profile of the current interval and writes a heap profile
+func (p *profiler) capture() {
+	if p.cpu != nil {
+		pprof.StopCPUProfile()
+		p.cpu.Close()
+		p.cpu = nil
+	}
+
+	f, err := os.Create(p.path("heap"))
+	if err != nil {
+		slog.Warn("failed to write heap profile", "err", err)
+		return
+	}
+	defer f.Close()
+
+	// up to date statistics of the memory in use
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		slog.Warn("failed to write heap profile", "err", err)
+		return
+	}
+	slog.Debug("captured profiles", "seq", p.seq, "dir", p.dir)
+}
+
+func (p *profiler) path(kind string) string {
+	return filepath.Join(p.dir, fmt.Sprintf("%s-%04d.pprof", kind, p.seq))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"time"
+	"timescale/dbperf"
+)
+
+// prescanQueries counts the queries the run will dispatch from the input, rewinding it afterwards
+func prescanQueries(f io.ReadSeeker, newGenerator func(io.Reader) dbperf.QueryGenerator) (int64, error) {
+	n, err := countQueries(f, newGenerator)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	return int64(n), err
+}
+
+// logProgress logs the # of queries the controller completed every interval until stop is closed, with the throughput
+// and percentiles of the last window. The percent complete and ETA are logged as well when the total # of queries is
+// known (> 0), the ETA is capped by the deadline of a run limited to a duration.
+func logProgress(c *dbperf.Controller, interval, window time.Duration, total int64, duration time.Duration, stop <-chan struct{}) {
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		completed := c.DispatchStats().Completed
+		elapsed := time.Since(start)
+		recent := c.RecentStats(window)
+		attrs := []interface{}{"completed", completed, "elapsed", elapsed.Round(time.Second),
+			"window", window, "qps", recent.Throughput(), "p50", recent.Median, "p99", recent.P99}
+		if total > 0 && completed > 0 {
+			eta := time.Duration(float64(elapsed) * float64(total-completed) / float64(completed))
+			if duration > 0 && duration-elapsed < eta {
+				eta = duration - elapsed
+			}
+			attrs = append(attrs, "total", total, "percent", float64(completed)*100/float64(total), "eta", eta.Round(time.Second))
+		}
+		slog.Info("progress", attrs...)
+	}
+}
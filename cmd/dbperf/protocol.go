@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"timescale/dbperf"
+)
+
+// runProtocolComparison runs the identical workload with the extended protocol, binding the arguments of queries, and
+// with the simple protocol, inlining them, and reports the cost of the simple protocol poolers without prepared
+// statement support force on applications
+func runProtocolComparison(ctx context.Context, cli *CliArgs, db dbperf.Queryable, newGenerator func() (dbperf.QueryGenerator, error), configure func(c *dbperf.Controller)) {
+	protocols := []dbperf.Protocol{dbperf.ProtocolExtended, dbperf.ProtocolSimple}
+	stats := make([]*dbperf.QueryStats, len(protocols))
+
+	for i, p := range protocols {
+		g, err := newGenerator()
+		if err != nil {
+			fatalf("failed to read input: %s", err)
+		}
+
+		slog.Info("running workload", "protocol", p)
+		c := dbperf.NewController(dbperf.WithPoolSize(cli.nworkers))
+		configure(c)
+		c.SetProtocol(p)
+
+		report, err := c.RunTest(ctx, db, g)
+		if err != nil {
+			fatalf("%s protocol test run failed: %s", p, err)
+		}
+		stats[i] = report.QueryStats
+	}
+
+	for i, p := range protocols {
+		s := stats[i]
+		fmt.Printf("%s: %d queries; %.1f qps; min: %s; max: %s; avg: %s; median: %s; p95: %s; p99: %s\n",
+			p, s.Processed, s.Throughput(), s.Min, s.Max, s.Avg, s.Median, s.P95, s.P99)
+	}
+
+	fmt.Println("simple protocol vs extended:")
+	for _, d := range dbperf.Compare(stats[0], stats[1]) {
+		fmt.Printf("  %s\n", d)
+	}
+}
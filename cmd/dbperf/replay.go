@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"timescale/dbperf"
+)
+
+// replayCommand re-executes a run recorded with run -record with the original pacing
+func replayCommand(args []string) {
+	var cli CliArgs
+	fs := newFlagSet("replay", "RECORDING", `Re-executes the queries of a run recorded with run -record in the same order, on the same workers and
+with the same pacing, e.g. to reproduce a latency anomaly`)
+	cli.RegisterConn(fs)
+	fs.StringVar(&cli.out, "out", "", "save the results to this JSON file for the compare and report commands")
+	fs.Var(&cli.tags, "tag", "label the run with key=value to group and filter runs by later, may be repeated")
+	parseFlags(fs, &cli, args)
+	filename := inputFilename(fs, &cli)
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fatalf("open %s: %s", filename, err)
+	}
+	rec, err := dbperf.ReadRecording(bytes.NewReader(data))
+	if err != nil {
+		fatalf("failed to read %s: %s", filename, err)
+	}
+	if rec.Len() == 0 {
+		fatalf("no queries recorded in %s", filename)
+	}
+
+	manifest := newManifest(fs)
+	manifest.SetInput(filename, bytes.NewReader(data))
+
+	ctx := context.Background()
+	db, _ := openDB(ctx, &cli)
+	if err := manifest.ReadServerVersions(ctx, db); err != nil {
+		slog.Warn("failed to read the server version", "err", err)
+	}
+	if err := manifest.ReadServerSettings(ctx, db); err != nil {
+		slog.Warn("failed to read the server settings", "err", err)
+	}
+
+	runID := dbperf.NewRunID()
+	slog.Info("replaying", "run", runID, "queries", rec.Len(), "workers", rec.Workers())
+
+	c := dbperf.NewController(dbperf.WithPoolSize(rec.Workers()), dbperf.WithScheduler(rec.Scheduler()), dbperf.WithLogger(slog.Default()))
+	c.SetLoadShape(rec.Shape())
+	report, err := c.RunTest(ctx, db, rec.Generator())
+	if err != nil {
+		fatalf("replay failed: %s", err)
+	}
+	stats := report.QueryStats
+
+	if cli.out != "" {
+		res := dbperf.NewResults(stats)
+		res.ID = runID
+		res.Tags = cli.tags
+		res.Manifest = manifest
+		if err := saveResults(cli.out, res); err != nil {
+			fatalf("failed to save results: %s", err)
+		}
+	}
+
+	fmt.Printf("%d queries replayed after %s\n", stats.Processed, stats.TotalElapsed)
+	fmt.Printf("min: %s; max: %s; avg: %s; median: %s\n", stats.Min, stats.Max, stats.Avg, stats.Median)
+	fmt.Printf("p95: %s; p99: %s; throughput: %.1f qps\n", stats.P95, stats.P99, stats.Throughput())
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"timescale/dbperf"
+)
+
+// reportBuckets is the # of latency histogram buckets in a report
+const reportBuckets = 10
+
+// reportRow is the summary of a group of queries, the whole run or a breakdown of it
+type reportRow struct {
+	Name  string
+	Stats *dbperf.QueryStats
+}
+
+// histogramBucket counts the queries with a latency in [From, To)
+type histogramBucket struct {
+	From, To time.Duration
+	Count    int
+	Percent  float64
+}
+
+// manifestField is a setting of the run shown in the manifest section of a report
+type manifestField struct {
+	Name, Value string
+}
+
+// report is the data rendered by every report format
+type report struct {
+	Title     string
+	Manifest  []manifestField
+	Rows      []reportRow
+	Histogram []histogramBucket
+	Outages   []dbperf.Outage
+}
+
+// reportFormats renders a report in each supported format
+var reportFormats = map[string]func(w io.Writer, r *report) error{
+	"md":    writeMarkdownReport,
+	"csv":   writeCSVReport,
+	"html":  writeHTMLReport,
+	"bench": writeBenchReport,
+}
+
+// reportCommand renders saved results into a report
+func reportCommand(args []string) {
+	var cli CliArgs
+	var format, out string
+	fs := newFlagSet("report", "RESULTS.json", "Renders results saved with run -out")
+	fs.StringVar(&cli.config, "config", "", "path to a YAML config file of flag settings, flags given on the command line take precedence")
+	fs.StringVar(&format, "format", "md", "report format: html, md, csv or bench (for benchstat)")
+	fs.StringVar(&out, "o", "", "write the report to this file instead of stdout")
+	cli.RegisterLogging(fs)
+	fs.Parse(args)
+
+	// allow the flags after the filename as well
+	var filenames []string
+	for fs.NArg() > 0 {
+		filenames = append(filenames, fs.Arg(0))
+		fs.Parse(fs.Args()[1:])
+	}
+	applyFlags(fs, &cli)
+
+	if len(filenames) != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	write, ok := reportFormats[format]
+	if !ok {
+		fatalf("unknown report format: %s", format)
+	}
+
+	res := mustReadResults(filenames[0])
+	r := newReport(filenames[0], res)
+
+	w := os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			fatalf("failed to create %s: %s", out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := write(w, r); err != nil {
+		fatalf("failed to write report: %s", err)
+	}
+}
+
+// newReport gathers the summary rows and latency histogram of the results
+func newReport(title string, res *dbperf.Results) *report {
+	stats := res.Stats
+	r := &report{
+		Title:     title,
+		Rows:      []reportRow{{"all", stats}},
+		Histogram: histogram(res.Latencies, reportBuckets),
+		Outages:   stats.Outages,
+		Manifest:  manifestFields(res.Manifest),
+	}
+
+	// the run's identity goes first with its manifest
+	var identity []manifestField
+	if res.ID != "" {
+		identity = append(identity, manifestField{"run id", res.ID})
+	}
+	if len(res.Tags) > 0 {
+		identity = append(identity, manifestField{"tags", res.Tags.String()})
+	}
+	r.Manifest = append(identity, r.Manifest...)
+
+	for i, ps := range stats.Phases {
+		r.Rows = append(r.Rows, reportRow{fmt.Sprintf("phase %d", i+1), ps})
+	}
+	r.Rows = append(r.Rows, sortedRows("tenant ", stats.Tenants)...)
+	r.Rows = append(r.Rows, sortedRows("label ", stats.Labels)...)
+	r.Rows = append(r.Rows, sortedRows("template ", stats.Templates)...)
+	r.Rows = append(r.Rows, sortedRows("", stats.Partitions)...)
+	r.Rows = append(r.Rows, sortedRows("key ", res.Keys)...)
+
+	return r
+}
+
+// manifestFields lists the settings of the manifest that are known, flags in name order
+func manifestFields(m *dbperf.Manifest) []manifestField {
+	if m == nil {
+		return nil
+	}
+
+	var fields []manifestField
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, manifestField{name, value})
+		}
+	}
+	add("dbperf version", m.Version)
+	add("commit", m.Commit)
+	add("go version", m.GoVersion)
+	add("server version", m.ServerVersion)
+	add("timescaledb version", m.TimescaleDBVersion)
+	add("server", m.Server)
+	if m.Features != nil {
+		add("timescaledb features", m.Features.String())
+	}
+	for _, name := range dbperf.ServerSettings {
+		add(name, m.ServerSettings[name])
+	}
+	add("input", m.Input)
+	add("input sha256", m.InputSHA256)
+	add("args", strings.Join(m.Args, " "))
+
+	names := make([]string, 0, len(m.Flags))
+	for name := range m.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add("-"+name, m.Flags[name])
+	}
+
+	return fields
+}
+
+// sortedRows returns a row per named breakdown ordered by name
+func sortedRows(prefix string, breakdown map[string]*dbperf.QueryStats) []reportRow {
+	names := make([]string, 0, len(breakdown))
+	for name := range breakdown {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([]reportRow, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, reportRow{prefix + name, breakdown[name]})
+	}
+	return rows
+}
+
+// histogram buckets the sorted latencies into n buckets of equal width between the min and max
+func histogram(latencies []time.Duration, n int) []histogramBucket {
+	if len(latencies) == 0 {
+		return nil
+	}
+
+	min, max := latencies[0], latencies[len(latencies)-1]
+	width := (max - min) / time.Duration(n)
+	if width <= 0 {
+		return []histogramBucket{{From: min, To: max, Count: len(latencies), Percent: 100}}
+	}
+
+	buckets := make([]histogramBucket, n)
+	for i := range buckets {
+		buckets[i].From = min + time.Duration(i)*width
+		buckets[i].To = buckets[i].From + width
+	}
+	buckets[n-1].To = max
+
+	for _, l := range latencies {
+		i := int((l - min) / width)
+		if i >= n {
+			i = n - 1
+		}
+		buckets[i].Count++
+	}
+
+	for i := range buckets {
+		buckets[i].Percent = float64(buckets[i].Count) / float64(len(latencies)) * 100
+	}
+
+	return buckets
+}
+
+// ms formats a latency in milliseconds for the machine readable formats
+func ms(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+var reportHeader = []string{"group", "processed", "throughput_qps", "min_ms", "max_ms", "avg_ms", "median_ms", "p95_ms", "p99_ms"}
+
+func (row reportRow) fields() []string {
+	s := row.Stats
+	return []string{
+		row.Name,
+		strconv.FormatInt(s.Processed, 10),
+		strconv.FormatFloat(s.Throughput(), 'f', 1, 64),
+		ms(s.Min), ms(s.Max), ms(s.Avg), ms(s.Median), ms(s.P95), ms(s.P99),
+	}
+}
+
+func writeCSVReport(w io.Writer, r *report) error {
+	// the manifest goes first as comment lines, e.g. skipped by readers with a comment character of #
+	for _, f := range r.Manifest {
+		if _, err := fmt.Fprintf(w, "# %s: %s\n", f.Name, f.Value); err != nil {
+			return err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(reportHeader)
+	for _, row := range r.Rows {
+		cw.Write(row.fields())
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeBenchReport writes the report in the Go benchmark format for benchstat, a benchmark per row with the average
+// latency as ns/op and the percentiles and throughput as extra units. The manifest goes first as configuration lines,
+// benchstat splits the results on them to compare runs of e.g. different server versions.
+func writeBenchReport(w io.Writer, r *report) error {
+	var b strings.Builder
+	for _, f := range r.Manifest {
+		// unique per run, it would keep benchstat from comparing the runs of a configuration
+		if f.Name == "run id" {
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\n", benchKey(f.Name), strings.Replace(f.Value, "\n", " ", -1))
+	}
+	for _, row := range r.Rows {
+		s := row.Stats
+		fmt.Fprintf(&b, "BenchmarkDbperf/group=%s %d %d ns/op %d median-ns %d p95-ns %d p99-ns %.1f qps\n",
+			strings.Replace(row.Name, " ", "_", -1), s.Processed, s.Avg.Nanoseconds(), s.Median.Nanoseconds(),
+			s.P95.Nanoseconds(), s.P99.Nanoseconds(), s.Throughput())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// benchKey turns a manifest setting into a configuration key, which must be lower case without spaces
+func benchKey(name string) string {
+	if strings.HasPrefix(name, "-") {
+		name = "flag-" + name[1:]
+	}
+	return strings.Replace(strings.ToLower(name), " ", "-", -1)
+}
+
+func writeMarkdownReport(w io.Writer, r *report) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# dbperf report: %s\n\n", r.Title)
+	fmt.Fprintf(&b, "| %s |\n", strings.Join(reportHeader, " | "))
+	fmt.Fprintf(&b, "|%s\n", strings.Repeat(" --- |", len(reportHeader)))
+	for _, row := range r.Rows {
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(row.fields(), " | "))
+	}
+
+	if len(r.Histogram) > 0 {
+		fmt.Fprintf(&b, "\n## Latency distribution\n\n")
+		fmt.Fprintf(&b, "| latency | queries | %% |\n| --- | --- | --- |\n")
+		for _, bucket := range r.Histogram {
+			fmt.Fprintf(&b, "| %s - %s | %d | %.1f |\n", bucket.From, bucket.To, bucket.Count, bucket.Percent)
+		}
+	}
+
+	if len(r.Outages) > 0 {
+		fmt.Fprintf(&b, "\n## Outages\n\n")
+		fmt.Fprintf(&b, "| start | duration | failed attempts |\n| --- | --- | --- |\n")
+		for _, o := range r.Outages {
+			fmt.Fprintf(&b, "| %s | %s | %d |\n", o.Start.Format(time.RFC3339Nano), o.Duration, o.Errors)
+		}
+	}
+
+	if len(r.Manifest) > 0 {
+		fmt.Fprintf(&b, "\n## Manifest\n\n")
+		fmt.Fprintf(&b, "| setting | value |\n| --- | --- |\n")
+		for _, f := range r.Manifest {
+			fmt.Fprintf(&b, "| %s | `%s` |\n", f.Name, strings.Replace(f.Value, "|", "\\|", -1))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"fields": reportRow.fields,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>dbperf report: {{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.bar { background: #4a90d9; height: 1em; }
+</style>
+</head>
+<body>
+<h1>dbperf report: {{.Title}}</h1>
+<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range fields .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{if .Histogram}}<h2>Latency distribution</h2>
+<table>
+<tr><th>latency</th><th>queries</th><th>%</th><th></th></tr>
+{{range .Histogram}}<tr><td>{{.From}} - {{.To}}</td><td>{{.Count}}</td><td>{{printf "%.1f" .Percent}}</td><td style="width: 20em; text-align: left"><div class="bar" style="width: {{printf "%.1f" .Percent}}%"></div></td></tr>
+{{end}}</table>
+{{end}}{{if .Outages}}<h2>Outages</h2>
+<table>
+<tr><th>start</th><th>duration</th><th>failed attempts</th></tr>
+{{range .Outages}}<tr><td>{{.Start}}</td><td>{{.Duration}}</td><td>{{.Errors}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Manifest}}<h2>Manifest</h2>
+<table>
+<tr><th>setting</th><th>value</th></tr>
+{{range .Manifest}}<tr><td>{{.Name}}</td><td style="text-align: left"><code>{{.Value}}</code></td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+func writeHTMLReport(w io.Writer, r *report) error {
+	return htmlReport.Execute(w, struct {
+		*report
+		Header []string
+	}{r, reportHeader})
+}
@@ -0,0 +1,1233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"timescale/dbperf"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// runCommand runs a workload against the database and reports the latency
+func runCommand(args []string) {
+	var cli CliArgs
+	fs := newFlagSet("run", "FILENAME", "Filename may be specified as either an argument or via the -f flag")
+	cli.Register(fs)
+	parseFlags(fs, &cli, args)
+	filename := inputFilename(fs, &cli)
+
+	runID := dbperf.NewRunID()
+	if cli.webhook != "" {
+		begun := time.Now()
+		atFatal = func(msg string) {
+			notifyWebhook(cli.webhook, &webhookEvent{ID: runID, Status: statusFailed, Error: msg, Workload: workloadName(cli.query, filename),
+				Tags: cli.tags, Started: begun, Finished: time.Now()})
+		}
+	}
+	// notifyDone notifies the webhook of the success of the run, the modes running the workload several times have no
+	// stats of their own
+	notifyDone := func(started, finished time.Time, stats *dbperf.QueryStats) {
+		if cli.webhook != "" {
+			notifyWebhook(cli.webhook, &webhookEvent{ID: runID, Status: statusDone, Workload: workloadName(cli.query, filename),
+				Tags: cli.tags, Started: started, Finished: finished, Stats: stats})
+		}
+	}
+
+	newGenerator, ok := generators[cli.query]
+	if !ok {
+		fatalf("unknown query template: %s", cli.query)
+	}
+	if cli.timezone != "" {
+		loc, err := parseTimezone(cli.timezone)
+		if err != nil {
+			fatalf("invalid timezone: %s", err)
+		}
+		generate := newGenerator
+		newGenerator = func(r io.Reader) dbperf.QueryGenerator {
+			g := generate(r)
+			if ls, ok := g.(dbperf.LocationSetter); ok {
+				ls.SetLocation(loc)
+			}
+			return g
+		}
+	}
+	if cli.golden != "" {
+		if cli.goldenOut != "" {
+			fatalf("-golden can't be combined with -write-golden")
+		}
+		golden, err := readGolden(cli.golden)
+		if err != nil {
+			fatalf("failed to read %s: %s", cli.golden, err)
+		}
+		generate := newGenerator
+		newGenerator = func(r io.Reader) dbperf.QueryGenerator { return golden.Generator(generate(r)) }
+	}
+	newGenerator = validateRanges(&cli, newGenerator)
+	var invalidRows *dbperf.InvalidRows
+	if policy := dbperf.RowPolicy(cli.invalidRows); policy != dbperf.RowFail {
+		var rejects io.Writer
+		if policy == dbperf.RowReject {
+			rf, err := os.Create(cli.rejects)
+			if err != nil {
+				fatalf("failed to create %s: %s", cli.rejects, err)
+			}
+			defer rf.Close()
+			rejects = rf
+		}
+		var err error
+		if invalidRows, err = dbperf.NewInvalidRows(policy, rejects); err != nil {
+			fatalf("%s", err)
+		}
+		generate := newGenerator
+		newGenerator = func(r io.Reader) dbperf.QueryGenerator { return invalidRows.Generator(generate(r)) }
+	}
+	var dedupe *dbperf.Deduplicator
+	if cli.dedupe {
+		dedupe = &dbperf.Deduplicator{}
+		generate := newGenerator
+		newGenerator = func(r io.Reader) dbperf.QueryGenerator { return dedupe.Generator(generate(r)) }
+	}
+	if !summaryFormats[cli.summary] {
+		fatalf("unknown summary format: %s", cli.summary)
+	}
+	if rule := dbperf.OutlierRule(cli.outliers); rule != "" && rule != dbperf.OutlierIQR && rule != dbperf.OutlierMAD {
+		fatalf("unknown outlier rule: %s", cli.outliers)
+	}
+	if mode := dbperf.FetchMode(cli.fetch); mode != dbperf.FetchNone && mode != dbperf.FetchRows && mode != dbperf.FetchScan && mode != dbperf.FetchCursor {
+		fatalf("unknown fetch mode: %s", cli.fetch)
+	}
+	if p := dbperf.Protocol(cli.protocol); p != dbperf.ProtocolExtended && p != dbperf.ProtocolSimple && cli.protocol != "compare" {
+		fatalf("unknown protocol: %s", cli.protocol)
+	}
+	if f := dbperf.ResultFormat(cli.format); f != dbperf.FormatText && f != dbperf.FormatBinary && cli.format != "compare" {
+		fatalf("unknown result format: %s", cli.format)
+	}
+	if dbperf.ResultFormat(cli.format) == dbperf.FormatBinary && dbperf.FetchMode(cli.fetch) != dbperf.FetchCursor {
+		fatalf("-result-format binary only applies to the rows fetched from cursors with -fetch cursor")
+	}
+	if rank := dbperf.KeyRank(cli.slowestBy); rank != dbperf.RankByP99 && rank != dbperf.RankByTotal {
+		fatalf("unknown key rank: %s", cli.slowestBy)
+	}
+	if cli.trim < 0 || cli.trim >= 0.5 {
+		fatalf("-trim must be in [0, 0.5)")
+	}
+	if cli.searchSLO > 0 || cli.pooler != "" || cli.iterations > 1 || cli.fetchSizes != "" || cli.protocol == "compare" || cli.format == "compare" || cli.interference != "" || cli.hosts != "" {
+		// the modes running the workload several times only report their comparison of the runs
+		singleRun := []struct {
+			flag string
+			set  bool
+		}{
+			{"-tui", cli.tui},
+			{"-out", cli.out != ""},
+			{"-results-dir", cli.resultsDir != ""},
+			{"-jtl", cli.jtl != ""},
+			{"-record", cli.record != ""},
+			{"-write-golden", cli.goldenOut != ""},
+			{"-chunks", cli.chunks != ""},
+			{"-index-usage", cli.indexes},
+			{"-table-sizes", cli.sizes},
+			{"-wal", cli.wal},
+			{"-auto-explain", cli.autoExplain > 0},
+		}
+		for _, o := range singleRun {
+			if o.set {
+				fatalf("%s can't be combined with -search-slo, -pooler, -iterations, -fetch-sizes, -protocol compare, -result-format compare, -interference or -cardinality", o.flag)
+			}
+		}
+	}
+
+	f, err := openInput(filename, cli.shards, cli.shardIndex)
+	if err != nil {
+		fatalf("open %s: %s", filename, err)
+	}
+	if c, ok := f.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	if cli.seed == 0 {
+		cli.seed = time.Now().UnixNano()
+	}
+	if cli.fuzz > 0 {
+		generate := newGenerator
+		newGenerator = func(r io.Reader) dbperf.QueryGenerator {
+			return dbperf.NewFuzzGenerator(generate(r), dbperf.FuzzConfig{Rate: cli.fuzz, Seed: cli.seed})
+		}
+	}
+	// the manifest records the seed picked to reproduce the run
+	manifest := newManifest(fs)
+	if err := manifest.SetInput(filename, f); err != nil {
+		fatalf("failed to read %s: %s", filename, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		fatalf("failed to read %s: %s", filename, err)
+	}
+
+	var schedule dbperf.Schedule
+	if cli.schedule != "" {
+		schedule, err = readSchedule(cli.schedule)
+		if err != nil {
+			fatalf("failed to read schedule %s: %s", cli.schedule, err)
+		}
+	}
+
+	var ddl []dbperf.DDLOperation
+	if cli.ddl != "" {
+		ddl, err = readDDL(cli.ddl)
+		if err != nil {
+			fatalf("failed to read %s: %s", cli.ddl, err)
+		}
+	}
+
+	var shape dbperf.LoadShape
+	if cli.shape != "" {
+		shape, err = dbperf.ParseLoadShape(cli.shape)
+		if err != nil {
+			fatalf("invalid load shape: %s", err)
+		}
+	}
+
+	var tenants dbperf.Tenants
+	if cli.tenants != "" {
+		tenants, err = readTenants(cli.tenants)
+		if err != nil {
+			fatalf("failed to read tenants %s: %s", cli.tenants, err)
+		}
+		cli.nworkers = tenants.Workers()
+	}
+
+	if cli.checkpointDir != "" {
+		if err := os.MkdirAll(cli.checkpointDir, 0755); err != nil {
+			fatalf("failed to create checkpoint directory: %s", err)
+		}
+	}
+
+	slog.Info("starting dbperf")
+
+	// run pprof monitor if asked
+	if debug.pprof > 0 {
+		go func() {
+			laddr := fmt.Sprintf(":%d", debug.pprof)
+			slog.Debug("starting pprof server", "url", "http://"+laddr+"/debug/pprof")
+			fatalf("pprof server stopped: %s", http.ListenAndServe(laddr, nil))
+		}()
+	}
+
+	if debug.trace != "" {
+		stop, err := startTrace(debug.trace)
+		if err != nil {
+			fatalf("failed to start trace: %s", err)
+		}
+		defer stop()
+	}
+
+	if debug.schedtrace > 0 {
+		go schedTrace(time.Duration(debug.schedtrace) * time.Millisecond)
+	}
+
+	if cli.profileDir != "" {
+		stop, err := startProfiling(cli.profileDir, cli.profileInterval)
+		if err != nil {
+			fatalf("failed to start profiling: %s", err)
+		}
+		defer stop()
+	}
+
+	var store *dbperf.ResultsStore
+	if cli.resultsDir != "" {
+		if store, err = dbperf.OpenResultsStore(cli.resultsDir); err != nil {
+			fatalf("failed to open results store: %s", err)
+		}
+	}
+
+	ctx := context.Background()
+	connecting := time.Now()
+	db, password := openDB(ctx, &cli)
+	connect := time.Since(connecting)
+
+	if cli.churn > 0 {
+		// released connections must be closed rather than reused for every checkout to connect anew
+		db.SetMaxIdleConns(0)
+	}
+
+	if err := manifest.ReadServerVersions(ctx, db); err != nil {
+		slog.Warn("failed to read the server version", "err", err)
+	}
+	if err := manifest.ReadServerSettings(ctx, db); err != nil {
+		slog.Warn("failed to read the server settings", "err", err)
+	}
+	if manifest.Features, err = dbperf.DetectFeatures(ctx, db); err != nil {
+		slog.Warn("failed to detect the timescaledb features", "err", err)
+	} else {
+		warnUnavailableFeatures(&cli, manifest.Features)
+	}
+	slog.Info("versions", "dbperf", manifest.Version, "commit", manifest.Commit, "go", manifest.GoVersion,
+		"server", manifest.ServerVersion, "timescaledb", manifest.TimescaleDBVersion, "input_sha256", manifest.InputSHA256)
+	slog.Info("server", "version", manifest.Server, "shared_buffers", manifest.ServerSettings["shared_buffers"],
+		"max_connections", manifest.ServerSettings["max_connections"], "features", manifest.Features)
+
+	prepareTables(ctx, db, cli.vacuum, true)
+	prepareTables(ctx, db, cli.analyze, false)
+
+	slog.Info("database connection good, starting test run", "run", runID, "seed", cli.seed)
+
+	var dataNodes []string
+	if cli.multiNode {
+		dataNodes, err = dbperf.DataNodes(ctx, db)
+		if err != nil {
+			fatalf("failed to list data nodes: %s", err)
+		}
+		slog.Info("multi-node", "data_nodes", dataNodes)
+	}
+
+	var partitioner dbperf.Partitioner
+	if cli.space != "" {
+		partitioner, err = dbperf.NewSpacePartitioner(ctx, db, cli.space)
+		if err != nil {
+			fatalf("failed to load space partitioning of %s: %s", cli.space, err)
+		}
+	}
+
+	var chunksBefore dbperf.ChunkStats
+	var exclusion *dbperf.ChunkExclusion
+	if cli.chunks != "" {
+		if chunksBefore, err = dbperf.ReadChunkStats(ctx, db, cli.chunks); err != nil {
+			fatalf("failed to read the chunks of %s: %s", cli.chunks, err)
+		}
+		ranges, err := dbperf.ReadChunkRanges(ctx, db, cli.chunks)
+		if err != nil {
+			fatalf("failed to read the chunk ranges of %s: %s", cli.chunks, err)
+		}
+		exclusion = dbperf.NewChunkExclusion(ranges)
+	}
+
+	var usageBefore *dbperf.UsageSnapshot
+	if cli.indexes {
+		if usageBefore, err = dbperf.TakeUsageSnapshot(ctx, db); err != nil {
+			fatalf("failed to read the index usage: %s", err)
+		}
+	}
+
+	var sizesBefore dbperf.SizeSnapshot
+	if cli.sizes {
+		if sizesBefore, err = dbperf.TakeSizeSnapshot(ctx, db); err != nil {
+			fatalf("failed to read the table sizes: %s", err)
+		}
+	}
+
+	var walBefore *dbperf.WALSnapshot
+	if cli.wal {
+		if walBefore, err = dbperf.TakeWALSnapshot(ctx, db); err != nil {
+			fatalf("failed to read the WAL location: %s", err)
+		}
+	}
+
+	var workerConns func(id int) dbperf.WorkerConn
+	if tenants != nil {
+		workerConns, err = tenantConns(&cli, tenants, db, password)
+		if err != nil {
+			fatalf("failed to connect tenants: %s", err)
+		}
+	}
+
+	var logBefore *dbperf.LogPosition
+	if cli.autoExplain > 0 {
+		pos, err := dbperf.ServerLogPosition(ctx, db)
+		if err != nil {
+			fatalf("failed to find the server log for auto_explain: %s", err)
+		}
+		logBefore = &pos
+		workerConns = autoExplainConns(workerConns, db, dbperf.AutoExplainConfig{Threshold: cli.autoExplain, Analyze: cli.autoExplainAnalyze})
+	}
+
+	var preloaded *dbperf.PreloadedQueries
+	if cli.preload {
+		begun := time.Now()
+		preloaded, err = dbperf.Preload(newGenerator(f))
+		if err != nil {
+			fatalf("failed to preload %s: %s", filename, err)
+		}
+		slog.Info("preloaded the input", "queries", preloaded.Len(), "elapsed", time.Since(begun).Round(time.Millisecond))
+		newGenerator = func(io.Reader) dbperf.QueryGenerator { return preloaded.Generator() }
+	}
+
+	var keyCounts map[string]int64
+	if cli.balance {
+		// a streamed input that can't be rewound can't be scanned twice
+		keyCounts, err = dbperf.CountKeys(newGenerator(f))
+		if err != nil {
+			fatalf("failed to count the keys of %s: %s", filename, err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			fatalf("failed to rewind %s after counting its keys: %s", filename, err)
+		}
+	}
+
+	// the plans of every template are checked once, in whichever run first executes it
+	var planChecker *dbperf.PlanChecker
+	if cli.plans != "" {
+		expectations, err := readPlanExpectations(cli.plans)
+		if err != nil {
+			fatalf("failed to read %s: %s", cli.plans, err)
+		}
+		planChecker = dbperf.NewPlanChecker(expectations)
+	}
+
+	// configure applies the command line options to a controller
+	configure := func(c *dbperf.Controller) {
+		c.SetLogger(slog.Default())
+		publishDispatchStats(c, cli.window)
+		c.SetMultiNode(cli.multiNode)
+		c.SetStreaming(cli.streaming)
+		c.SetFetch(dbperf.FetchMode(cli.fetch))
+		c.SetFetchSize(cli.fetchSize)
+		if cli.protocol != "compare" {
+			c.SetProtocol(dbperf.Protocol(cli.protocol))
+		}
+		if cli.format != "compare" {
+			c.SetResultFormat(dbperf.ResultFormat(cli.format))
+		}
+		c.SetSeed(cli.seed)
+		c.SetSampleSize(cli.sample)
+		if keyCounts != nil {
+			c.SetScheduler(dbperf.NewBalancedScheduler(keyCounts))
+		}
+		if partitioner != nil {
+			c.SetPartitioner(partitioner)
+		}
+		c.SetRateLimit(cli.rate)
+		if shape != nil {
+			c.SetLoadShape(shape)
+		}
+		c.SetDuration(cli.duration)
+		if cli.checkpointDir != "" {
+			writeCheckpoint := dbperf.CheckpointDir(cli.checkpointDir)
+			c.SetCheckpoint(cli.checkpointInterval, func(cp *dbperf.Checkpoint) error {
+				slog.Info("checkpoint", "seq", cp.Seq, "queries", cp.Total.Processed, "elapsed", cp.Elapsed.Round(time.Second),
+					"interval_median", cp.Interval.Median, "interval_p99", cp.Interval.P99)
+				return writeCheckpoint(cp)
+			})
+		}
+		if schedule != nil {
+			c.SetSchedule(schedule)
+		}
+		if len(ddl) > 0 {
+			c.SetDDL(ddl)
+		}
+		if cli.churn > 0 {
+			c.SetConnectionChurn(dbperf.SQLConnector(db), cli.churn)
+		}
+		if workerConns != nil {
+			c.SetWorkerConns(workerConns)
+		}
+		if planChecker != nil {
+			c.SetPlanChecker(planChecker)
+		}
+		if cli.reconnect > 0 {
+			c.SetReconnect(dbperf.ReconnectConfig{MaxOutage: cli.reconnect})
+		}
+		if cli.stall > 0 {
+			c.SetWatchdog(dbperf.WatchdogConfig{Stall: cli.stall, Abort: cli.stallAbort, ApplicationName: applicationName})
+		}
+		if cli.chaosRate > 0 {
+			c.SetChaos(dbperf.ChaosConfig{Rate: cli.chaosRate, ApplicationName: applicationName})
+		}
+		if cli.locks {
+			c.SetLockSampling(dbperf.LockConfig{ApplicationName: applicationName})
+		}
+		if cli.backends {
+			c.SetBackendPIDs(true)
+		}
+		if cli.notify {
+			c.SetNotifyLatency(dbperf.NotifyConfig{Listener: dbperf.PQListener(connString(&cli, cli.user, password))})
+		}
+		if cli.waitEvents {
+			c.SetWaitSampling(dbperf.WaitConfig{ApplicationName: applicationName})
+		}
+		if cli.checkpointActivity {
+			c.SetBgWriterSampling(dbperf.BgWriterConfig{})
+		}
+		if cli.vacuumDuring != "" {
+			c.SetVacuum(dbperf.VacuumConfig{Table: cli.vacuumDuring, Mode: dbperf.VacuumMode(cli.vacuumMode)})
+		}
+		if cli.warmup > 0 {
+			c.SetWarmup(cli.warmup)
+		}
+		if cli.logEvery > 0 {
+			c.SetLogInterval(cli.logEvery)
+		}
+		if cli.trim > 0 || cli.outliers != "" {
+			c.SetOutliers(dbperf.OutlierConfig{Trim: cli.trim, Rule: dbperf.OutlierRule(cli.outliers)})
+		}
+		if cli.cancelFraction > 0 {
+			c.SetCancellation(dbperf.CancelConfig{Fraction: cli.cancelFraction, After: cli.cancelAfter})
+		}
+		if cli.spikeEvery > 0 {
+			c.SetSpikes(dbperf.SpikeConfig{Every: cli.spikeEvery, Size: cli.spikeSize, Window: cli.spikeWindow})
+		}
+	}
+
+	// reopen replays the input from the start for modes that run the workload multiple times
+	reopen := func() (dbperf.QueryGenerator, error) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return newGenerator(f), nil
+	}
+
+	started := time.Now()
+	multiRun := true
+	switch {
+	case cli.searchSLO > 0:
+		runSearch(ctx, &cli, db, reopen, configure)
+	case cli.pooler != "":
+		runPoolerComparison(ctx, &cli, db, password, cli.pooler, reopen, configure)
+	case cli.iterations > 1:
+		runIterations(ctx, &cli, db, reopen, configure)
+	case cli.fetchSizes != "":
+		runFetchSizes(ctx, &cli, db, reopen, configure)
+	case cli.protocol == "compare":
+		runProtocolComparison(ctx, &cli, db, reopen, configure)
+	case cli.format == "compare":
+		runFormatComparison(ctx, &cli, db, reopen, configure)
+	case cli.interference != "":
+		runInterferenceComparison(ctx, &cli, db, reopen, configure)
+	case cli.hosts != "":
+		runCardinality(ctx, &cli, db, reopen, configure)
+	default:
+		multiRun = false
+	}
+	if multiRun {
+		notifyDone(started, time.Now(), nil)
+		reportPlanViolations(planChecker)
+		return
+	}
+
+	controller := dbperf.NewController(dbperf.WithPoolSize(cli.nworkers))
+	configure(controller)
+
+	stopProgress := make(chan struct{})
+	if cli.progress > 0 {
+		var total int64
+		if preloaded != nil {
+			total = int64(preloaded.Len())
+		} else if cli.prescan {
+			// a streamed input that can't be rewound only reports the queries completed
+			if total, err = prescanQueries(f, newGenerator); err != nil {
+				slog.Warn("failed to pre-scan the input, the percent complete and ETA won't be logged", "err", err)
+				total = 0
+			}
+		}
+		go logProgress(controller, cli.progress, cli.window, total, cli.duration, stopProgress)
+	}
+	generator := newGenerator(f)
+	if exclusion != nil {
+		generator = exclusion.Generator(generator)
+	}
+
+	var jtl *dbperf.JTLWriter
+	if cli.jtl != "" {
+		jf, err := os.Create(cli.jtl)
+		if err != nil {
+			fatalf("failed to create %s: %s", cli.jtl, err)
+		}
+		defer jf.Close()
+		jtl = dbperf.NewJTLWriter(jf, cli.query, cli.nworkers)
+		controller.SetResultFunc(jtl.Record)
+	}
+
+	var recorder *dbperf.Recorder
+	if cli.record != "" {
+		rf, err := os.Create(cli.record)
+		if err != nil {
+			fatalf("failed to create %s: %s", cli.record, err)
+		}
+		defer rf.Close()
+		recorder = dbperf.NewRecorder(rf)
+		controller.SetDispatchFunc(recorder.Record)
+	}
+
+	var goldenRecorder *dbperf.GoldenRecorder
+	if cli.goldenOut != "" {
+		gf, err := os.Create(cli.goldenOut)
+		if err != nil {
+			fatalf("failed to create %s: %s", cli.goldenOut, err)
+		}
+		defer gf.Close()
+		goldenRecorder = dbperf.NewGoldenRecorder(gf)
+		controller.SetGoldenRecorder(goldenRecorder)
+	}
+
+	var dash *dashboard
+	if cli.tui {
+		dash = newDashboard(os.Stdout, controller)
+		if jtl != nil {
+			controller.SetResultFunc(func(r dbperf.QueryResult) {
+				dash.record(r)
+				jtl.Record(r)
+			})
+		}
+		dash.Start()
+	}
+
+	started = time.Now()
+	report, err := controller.RunTest(ctx, db, generator)
+	finished := time.Now()
+	close(stopProgress)
+	if dash != nil {
+		dash.Stop()
+	}
+	if err != nil {
+		fatalf("test run failed: %s", err)
+	}
+	stats := report.QueryStats
+	if jtl != nil {
+		if err := jtl.Flush(); err != nil {
+			fatalf("failed to write %s: %s", cli.jtl, err)
+		}
+	}
+	if recorder != nil {
+		if err := recorder.Flush(); err != nil {
+			fatalf("failed to write %s: %s", cli.record, err)
+		}
+	}
+	if goldenRecorder != nil {
+		if err := goldenRecorder.Flush(); err != nil {
+			fatalf("failed to write %s: %s", cli.goldenOut, err)
+		}
+	}
+	if invalidRows != nil && invalidRows.Count() > 0 {
+		slog.Warn("skipped invalid rows of the input", "rows", invalidRows.Count(), "first", invalidRows.First().Error())
+	}
+
+	res := report.Results()
+	res.ID = runID
+	res.Tags = cli.tags
+	res.Manifest = manifest
+	if exclusion != nil {
+		chunksAfter, err := dbperf.ReadChunkStats(ctx, db, cli.chunks)
+		if err != nil {
+			slog.Warn("failed to read the chunks after the run", "hypertable", cli.chunks, "err", err)
+		}
+		res.Chunks = exclusion.Report(cli.chunks, chunksBefore, chunksAfter)
+	}
+	if usageBefore != nil || sizesBefore != nil || walBefore != nil && walBefore.Stats {
+		flushSessionStats(db)
+	}
+	if usageBefore != nil {
+		if usageAfter, err := dbperf.TakeUsageSnapshot(ctx, db); err != nil {
+			slog.Warn("failed to read the index usage after the run", "err", err)
+		} else {
+			res.Usage = dbperf.UsageDelta(usageBefore, usageAfter)
+		}
+	}
+	if logBefore != nil {
+		if log, err := dbperf.ReadServerLog(ctx, db, *logBefore); err != nil {
+			slog.Warn("failed to read the plans logged by auto_explain", "err", err)
+		} else {
+			res.Plans = dbperf.ParsePlans(log)
+		}
+	}
+	if walBefore != nil {
+		if walAfter, err := dbperf.TakeWALSnapshot(ctx, db); err != nil {
+			slog.Warn("failed to read the WAL location after the run", "err", err)
+		} else {
+			res.WAL = dbperf.WALDelta(walBefore, walAfter, stats)
+		}
+	}
+	if sizesBefore != nil {
+		if sizesAfter, err := dbperf.TakeSizeSnapshot(ctx, db); err != nil {
+			slog.Warn("failed to read the table sizes after the run", "err", err)
+		} else {
+			res.Sizes = dbperf.SizeDelta(sizesBefore, sizesAfter)
+		}
+	}
+	if cli.out != "" {
+		if err := saveResults(cli.out, res); err != nil {
+			fatalf("failed to save results: %s", err)
+		}
+	}
+	if store != nil {
+		rec := &dbperf.RunRecord{ID: runID, Name: workloadName(cli.query, filename), Tags: cli.tags, Started: started, Finished: finished, Stats: stats}
+		if err := store.Save(rec, res); err != nil {
+			fatalf("failed to store results: %s", err)
+		}
+	}
+
+	notifyDone(started, finished, stats)
+
+	if cli.summary == "pgbench" {
+		writePgbenchSummary(os.Stdout, cli.query, cli.nworkers, cli.duration, stats, connect)
+		reportPlanViolations(planChecker)
+		reportVerification(stats.Verification)
+		return
+	}
+
+	fmt.Printf("%d queries processed after %s\n", stats.Processed, stats.TotalElapsed)
+	if dedupe != nil {
+		fmt.Printf("%d duplicate rows of the input skipped\n", dedupe.Duplicates())
+	}
+	fmt.Printf("min: %s; max: %s; avg: %s; median: %s\n", stats.Min, stats.Max, stats.Avg, stats.Median)
+	fmt.Printf("p95: %s; p99: %s; throughput: %.1f qps\n", stats.P95, stats.P99, stats.Throughput())
+	fmt.Printf("geometric mean: %s; harmonic mean: %s\n", stats.GeoMean, stats.HarmonicMean)
+	if stats.Rows > 0 {
+		fmt.Printf("%d rows read; %.1f rows/s\n", stats.Rows, float64(stats.Rows)/stats.Duration.Seconds())
+	}
+	if fr := stats.FirstRow; fr != nil && fr.Processed > 0 {
+		fmt.Printf("time to first row avg: %s; median: %s; p99: %s\n", fr.Avg, fr.Median, fr.P99)
+	}
+	if cli.apdex > 0 {
+		fmt.Printf("apdex (T=%s): %.2f\n", cli.apdex, stats.Apdex(cli.apdex))
+	}
+	if qw := stats.QueueWait; qw != nil && qw.Max > 0 {
+		// the query times above are the database's alone, any client side queuing is reported separately
+		fmt.Printf("queue wait avg: %s; median: %s; p99: %s; max: %s\n", qw.Avg, qw.Median, qw.P99, qw.Max)
+	}
+	if cu := report.Client; cu != nil {
+		fmt.Printf("client cpu avg: %.2f; peak: %.2f of %d; max rss: %.1f MB; max goroutines: %d; gc: %d cycles, %s paused\n",
+			cu.CPU, cu.PeakCPU, cu.NumCPU, float64(cu.MaxRSS)/(1<<20), cu.MaxGoroutines, cu.GCCycles, cu.GCPause)
+		if cu.PeakCPU >= 0.9*float64(cu.NumCPU) {
+			fmt.Printf("WARNING: dbperf saturated its CPUs, the load generator may have been the bottleneck\n")
+		}
+	}
+
+	if ts := stats.Trimmed; ts != nil {
+		fmt.Printf("trimmed (%g%% each end): %d queries; avg: %s; median: %s; p95: %s; p99: %s\n", cli.trim*100, ts.Processed, ts.Avg, ts.Median, ts.P95, ts.P99)
+	}
+	if cli.outliers != "" && !cli.streaming && cli.sample <= 0 {
+		fmt.Printf("%d outliers (%s)\n", stats.Outliers, cli.outliers)
+	}
+	if stats.Cold != nil {
+		fmt.Printf("cold (first %g%%): %d queries; avg: %s; median: %s; p95: %s; p99: %s\n", cli.warmup*100, stats.Cold.Processed, stats.Cold.Avg, stats.Cold.Median, stats.Cold.P95, stats.Cold.P99)
+		fmt.Printf("warm: %d queries; avg: %s; median: %s; p95: %s; p99: %s\n", stats.Warm.Processed, stats.Warm.Avg, stats.Warm.Median, stats.Warm.P95, stats.Warm.P99)
+	}
+
+	for i, ps := range stats.Phases {
+		phase := schedule[i]
+		fmt.Printf("phase %d (%s @ %.0f qps, %d workers): %d queries; %.1f qps; median: %s; p95: %s; p99: %s\n",
+			i+1, phase.Duration, phase.Rate, phase.Workers, ps.Processed, ps.Throughput(), ps.Median, ps.P95, ps.P99)
+	}
+
+	if len(stats.Outages) > 0 {
+		var downtime time.Duration
+		for _, o := range stats.Outages {
+			downtime += o.Duration
+		}
+
+		fmt.Printf("%d outages; total downtime: %s\n", len(stats.Outages), downtime)
+		for _, o := range stats.Outages {
+			fmt.Printf("  %s - %s: %s; %d failed attempts\n", o.Start.Format(time.RFC3339Nano), o.End.Format(time.RFC3339Nano), o.Duration, o.Errors)
+		}
+	}
+
+	if cs := stats.Cancellations; cs != nil {
+		fmt.Printf("%d queries selected for cancellation: %d cancelled; %d completed first\n", cs.Attempted, cs.Cancelled, cs.Completed)
+		fmt.Printf("cancellation latency min: %s; max: %s; avg: %s; median: %s; p99: %s\n", cs.Latency.Min, cs.Latency.Max, cs.Latency.Avg, cs.Latency.Median, cs.Latency.P99)
+
+		active, err := dbperf.ActiveSessions(ctx, db, applicationName)
+		if err != nil {
+			slog.Warn("failed to verify cancelled queries were released", "err", err)
+		} else if active > 0 {
+			fmt.Printf("WARNING: %d sessions still executing queries after the run\n", active)
+		}
+	}
+
+	if stats.Chaos != nil {
+		fmt.Printf("chaos: %d sessions terminated; %d attempts found no session; %d attempts failed\n", stats.Chaos.Kills, stats.Chaos.Misses, stats.Chaos.Failures)
+	}
+
+	if ls := stats.Locks; ls != nil {
+		fmt.Printf("lock waits (sampled every %s, %d samples failed):", ls.Interval, ls.Failures)
+		for _, t := range ls.Types() {
+			fmt.Printf(" %s: %d (~%s);", t, ls.Waits[t], ls.WaitTime(t))
+		}
+		fmt.Println()
+		if lc := report.LockCorrelation(); lc != nil {
+			fmt.Printf("seconds with lock waits: %d of %d; median p99 with: %s; without: %s; %d of %d latency spikes had lock waits\n",
+				lc.Contended, lc.Seconds, lc.ContendedP99, lc.UncontendedP99, lc.ContendedSpikes, lc.Spikes)
+		}
+	}
+
+	if ws := stats.Waits; ws != nil {
+		printWaits(ws, stats.TotalElapsed)
+	}
+	if len(stats.Backends) > 0 {
+		printBackends(stats.Backends)
+	}
+	if ns := stats.Notify; ns != nil {
+		fmt.Printf("notifications: %d sent; %d received; %d lost; %d failed\n", ns.Sent, ns.Received, ns.Lost, ns.Failures)
+		if l := ns.Latency; l.Processed > 0 {
+			fmt.Printf("notification latency min: %s; max: %s; avg: %s; median: %s; p99: %s\n", l.Min, l.Max, l.Avg, l.Median, l.P99)
+		}
+	}
+
+	if cs := stats.BgWriter; cs != nil {
+		fmt.Printf("checkpoints: %d timed; %d requested; %d observed in progress (sampled every %s, %d samples failed)\n",
+			cs.Timed, cs.Requested, len(cs.Spans), cs.Interval, cs.Failures)
+		for _, s := range cs.Spans {
+			fmt.Printf("  checkpoint in progress from %s to %s\n", s.Start.Truncate(time.Second), s.End.Truncate(time.Second))
+		}
+		if cc := report.BgWriterCorrelation(); cc != nil {
+			fmt.Printf("seconds with a checkpoint in progress: %d of %d; median p99 with: %s; without: %s; %d of %d latency spikes during checkpoints\n",
+				cc.Checkpointing, cc.Seconds, cc.CheckpointingP99, cc.OtherP99, cc.CheckpointSpikes, cc.Spikes)
+		}
+	}
+
+	if vs := stats.Vacuum; vs != nil {
+		fmt.Printf("vacuum of %s (%s): %d vacuums; %s vacuuming; %d failed\n", vs.Table, vs.Mode, vs.Vacuums, vs.Time().Truncate(time.Millisecond), vs.Failures)
+		if vc := report.VacuumCorrelation(); vc != nil {
+			fmt.Printf("seconds vacuuming: %d of %d; median p99 with: %s; without: %s; %d of %d latency spikes while vacuuming\n",
+				vc.Vacuuming, vc.Seconds, vc.VacuumingP99, vc.OtherP99, vc.VacuumSpikes, vc.Spikes)
+		}
+	}
+
+	if ds := stats.DDL; ds != nil {
+		printDDL(report, ds)
+	}
+
+	if cr := res.Chunks; cr != nil {
+		fmt.Printf("chunks of %s before: %d (%d compressed, %.1f MB); after: %d (%d compressed, %.1f MB)\n", cr.Hypertable,
+			cr.Before.Chunks, cr.Before.Compressed, float64(cr.Before.Bytes)/(1<<20), cr.After.Chunks, cr.After.Compressed, float64(cr.After.Bytes)/(1<<20))
+		fmt.Printf("chunk exclusion: %d queries touched %.1f of %d chunks on average (%.1f%% excluded); %d distinct chunks touched\n",
+			cr.Queries, cr.Touched, cr.Chunks, cr.Excluded()*100, cr.Distinct)
+	}
+
+	if res.Usage != nil {
+		printUsage(res.Usage)
+	}
+	if res.Sizes != nil {
+		printSizes(res.Sizes)
+	}
+	for _, a := range res.Anomalies {
+		fmt.Printf("latency anomaly: %s\n", a)
+	}
+	if len(res.Plans) > 0 {
+		slowest := res.Plans[0]
+		for _, p := range res.Plans {
+			if p.Duration > slowest.Duration {
+				slowest = p
+			}
+		}
+		fmt.Printf("auto_explain: %d plans logged; slowest: %s %s\n", len(res.Plans), slowest.Duration, slowest.Query)
+	}
+	if wal := res.WAL; wal != nil {
+		fmt.Printf("WAL: %s written (%d records, %d full page images); %.1f bytes/query; %.1f bytes/row of %d rows affected\n",
+			formatBytes(wal.Bytes), wal.Records, wal.FPI, wal.BytesPerQuery(), wal.BytesPerRow(), wal.Rows)
+	}
+
+	if fs := stats.Fetches; fs != nil {
+		fmt.Printf("%d fetches of %d rows; min: %s; max: %s; avg: %s; median: %s; p99: %s\n", fs.Processed, cli.fetchSize, fs.Min, fs.Max, fs.Avg, fs.Median, fs.P99)
+	}
+
+	if stats.Connects != nil {
+		cs := stats.Connects
+		fmt.Printf("%d connections opened; min: %s; max: %s; avg: %s; median: %s; p99: %s\n", cs.Processed, cs.Min, cs.Max, cs.Avg, cs.Median, cs.P99)
+	}
+
+	if stats.Spikes != nil {
+		fmt.Printf("spikes (baseline p99: %s):\n", stats.Spikes.BaselineP99)
+		for _, spike := range stats.Spikes.Spikes {
+			recovery := "not recovered"
+			if spike.Recovered {
+				recovery = fmt.Sprintf("recovered after %s", spike.Recovery)
+			}
+			fmt.Printf("  @%s: %d queries; peak p99: %s; %s\n", spike.At.Round(time.Millisecond), spike.Size, spike.PeakP99, recovery)
+		}
+	}
+
+	for _, t := range tenants {
+		if ts, ok := stats.Tenants[t.Role]; ok {
+			fmt.Printf("tenant %s (%d workers): %d queries; min: %s; max: %s; avg: %s; median: %s; p99: %s\n", t.Role, t.Workers, ts.Processed, ts.Min, ts.Max, ts.Avg, ts.Median, ts.P99)
+		}
+	}
+
+	if len(stats.Labels) > 0 {
+		labels := make([]string, 0, len(stats.Labels))
+		for l := range stats.Labels {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+
+		fmt.Println("labels:")
+		for _, l := range labels {
+			ls := stats.Labels[l]
+			fmt.Printf("  %s: %d queries; min: %s; max: %s; avg: %s; median: %s; p99: %s\n", l, ls.Processed, ls.Min, ls.Max, ls.Avg, ls.Median, ls.P99)
+		}
+	}
+
+	if len(stats.Templates) > 0 {
+		templates := make([]string, 0, len(stats.Templates))
+		for t := range stats.Templates {
+			templates = append(templates, t)
+		}
+		sort.Strings(templates)
+
+		fmt.Println("query templates:")
+		for _, t := range templates {
+			ts := stats.Templates[t]
+			fmt.Printf("  %s: %d queries; min: %s; max: %s; avg: %s; median: %s; p99: %s\n", t, ts.Processed, ts.Min, ts.Max, ts.Avg, ts.Median, ts.P99)
+		}
+	}
+
+	if len(stats.Partitions) > 0 {
+		partitions := make([]string, 0, len(stats.Partitions))
+		for p := range stats.Partitions {
+			partitions = append(partitions, p)
+		}
+		sort.Strings(partitions)
+
+		fmt.Printf("space partitions of %s:\n", cli.space)
+		for _, p := range partitions {
+			ps := stats.Partitions[p]
+			fmt.Printf("  %s: %d queries; min: %s; max: %s; avg: %s; median: %s\n", p, ps.Processed, ps.Min, ps.Max, ps.Avg, ps.Median)
+		}
+	}
+
+	if cli.slowest > 0 {
+		fmt.Printf("slowest keys by %s:\n", cli.slowestBy)
+		for _, ks := range report.SlowestKeys(cli.slowest, dbperf.KeyRank(cli.slowestBy)) {
+			fmt.Printf("  %s: %d queries; total: %s; avg: %s; median: %s; p99: %s\n", ks.Key, ks.Processed, ks.TotalElapsed, ks.Avg, ks.Median, ks.P99)
+		}
+	}
+
+	if cli.multiNode {
+		fmt.Printf("%d data node errors\n", stats.Errors)
+		for _, node := range dataNodes {
+			fmt.Printf("  %s: %d errors\n", node, stats.NodeErrors[node])
+		}
+	}
+
+	reportPlanViolations(planChecker)
+	reportVerification(stats.Verification)
+}
+
+// readPlanExpectations reads the plans expected of query templates from a YAML list of their expectations, keyed by
+// the yaml tags of dbperf.PlanExpectation
+func readPlanExpectations(filename string) ([]dbperf.PlanExpectation, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var expectations []dbperf.PlanExpectation
+	if err := yaml.Unmarshal(buf, &expectations); err != nil {
+		return nil, err
+	}
+	for i, e := range expectations {
+		if e.Template == "" {
+			return nil, fmt.Errorf("expectation %d has no template", i+1)
+		}
+	}
+	return expectations, nil
+}
+
+// readDDL reads the DDL operations to execute during the run from a YAML list of them, keyed by the yaml tags of
+// dbperf.DDLOperation
+func readDDL(filename string) ([]dbperf.DDLOperation, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []dbperf.DDLOperation
+	if err := yaml.Unmarshal(buf, &ops); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		if strings.TrimSpace(op.Statement) == "" {
+			return nil, fmt.Errorf("operation %d has no statement", i+1)
+		}
+	}
+	return ops, nil
+}
+
+// printDDL prints the DDL operations executed during the run and their impact on its latency
+func printDDL(report *dbperf.Report, ds *dbperf.DDLStats) {
+	fmt.Printf("DDL: %d operations executed; %d not reached by the end of the run\n", len(ds.Operations), ds.Skipped)
+	for _, impact := range report.DDLImpact() {
+		op := impact.Operation
+		status := "ok"
+		if op.Err != "" {
+			status = op.Err
+		}
+		fmt.Printf("  %s at %s took %s (%s): median p99 during: %s (max %s); without DDL: %s; %d errors\n",
+			op.Statement, op.Start.Truncate(time.Millisecond), op.Elapsed().Truncate(time.Millisecond), status,
+			impact.P99, impact.MaxP99, impact.BaselineP99, impact.Errors)
+	}
+}
+
+// parseTimezone parses a time zone given by name, e.g. UTC or Europe/Berlin, or by its UTC offset, e.g. +02:00
+func parseTimezone(s string) (*time.Location, error) {
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		t, err := time.Parse("-07:00", s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset %q, expected +HH:MM or -HH:MM", s)
+		}
+		_, offset := t.Zone()
+		return time.FixedZone(s, offset), nil
+	}
+	return time.LoadLocation(s)
+}
+
+// validateRanges wraps the generators of newGenerator in the validation of the time ranges of their queries with
+// -validate-ranges or -max-window
+func validateRanges(cli *CliArgs, newGenerator func(io.Reader) dbperf.QueryGenerator) func(io.Reader) dbperf.QueryGenerator {
+	if !cli.validRanges && cli.maxWindow <= 0 {
+		return newGenerator
+	}
+	return func(r io.Reader) dbperf.QueryGenerator {
+		return dbperf.NewTimeRangeValidator(newGenerator(r), cli.maxWindow)
+	}
+}
+
+// reportPlanViolations prints the plans that violated the expectations of their query template, exiting with status 1
+// if any did
+func reportPlanViolations(pc *dbperf.PlanChecker) {
+	if pc == nil {
+		return
+	}
+
+	violations := pc.Violations()
+	for _, v := range violations {
+		fmt.Printf("plan violation: %s %v: %s\n", v.Template, v.Args, strings.Join(v.Reasons, "; "))
+	}
+	if len(violations) > 0 {
+		slog.Error("plans violated their expectations", "violations", len(violations))
+		os.Exit(1)
+	}
+}
+
+// readGolden reads the result sets recorded by a golden run
+func readGolden(filename string) (dbperf.Golden, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return dbperf.ReadGolden(f)
+}
+
+// reportVerification prints the results of the queries verified against the results expected by the input or golden
+// run, exiting with status 1 if any of them returned a different result
+func reportVerification(v *dbperf.VerifyStats) {
+	if v == nil {
+		return
+	}
+
+	fmt.Printf("verified: %d queries; %d mismatches\n", v.Verified, v.Mismatches)
+	for _, m := range v.Samples {
+		fmt.Printf("  %v: expected %s; got %s\n", m.Args, m.Expected, m.Actual)
+	}
+	if v.Mismatches > 0 {
+		slog.Error("queries returned unexpected results", "mismatches", v.Mismatches)
+		os.Exit(1)
+	}
+}
+
+// openInput opens the input of a run, a local file or an object in an object store, keeping only the given shard of
+// it if it's split into more than one. Local files are streamed rather than read in full unless sharded, the caller
+// closes the input if it's an io.Closer.
+func openInput(filename string, shards, index int) (io.ReadSeeker, error) {
+	if shards < 1 || index < 0 || index >= shards {
+		return nil, fmt.Errorf("invalid shard %d of %d", index, shards)
+	}
+	if shards == 1 && !isURL(filename) {
+		return os.Open(filename)
+	}
+
+	var data []byte
+	var err error
+	if isURL(filename) {
+		data, err = fetchObject(filename)
+	} else {
+		data, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if shards == 1 {
+		return bytes.NewReader(data), nil
+	}
+
+	parts, err := shardQueries(bytes.NewReader(data), shards)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(parts[index]), nil
+}
+
+// saveResults writes the results to a file, or an object store if given a URL, for later comparison
+func saveResults(filename string, res *dbperf.Results) error {
+	if isURL(filename) {
+		var buf bytes.Buffer
+		if err := dbperf.WriteResults(&buf, res); err != nil {
+			return err
+		}
+		return putObject(filename, buf.Bytes())
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := dbperf.WriteResults(f, res); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// warnUnavailableFeatures warns about the modes of the run needing TimescaleDB features the server doesn't have
+func warnUnavailableFeatures(cli *CliArgs, f *dbperf.Features) {
+	if cli.multiNode && !f.MultiNode {
+		slog.Warn("-multinode needs a multi-node access node but multi-node isn't available on the server", "features", f)
+	}
+	if cli.space != "" && !f.TimescaleDB {
+		slog.Warn("-space needs a hypertable but the timescaledb extension isn't installed", "features", f)
+	}
+}
+
+// statsFlushDelay is how long to wait for the statistics of closed sessions to be visible to other sessions
+const statsFlushDelay = time.Second
+
+// flushSessionStats closes the idle connections of the run for their backends to report their pending statistics as
+// they exit, which since PostgreSQL 15 idle backends may otherwise hold on to for up to 10s, and waits for them to be
+// visible. Queries after it open new connections.
+func flushSessionStats(db *sql.DB) {
+	db.SetMaxIdleConns(0)
+	time.Sleep(statsFlushDelay)
+}
+
+// printUsage prints the scans of the tables and indexes made during the run
+func printUsage(u *dbperf.UsageReport) {
+	fmt.Println("index usage:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "  table\tindex\tscans\ttuples read\ttuples fetched\n")
+	for _, i := range u.Indexes {
+		fmt.Fprintf(w, "  %s\t%s\t%d\t%d\t%d\n", i.Table, i.Index, i.Scans, i.TuplesRead, i.TuplesFetched)
+	}
+	w.Flush()
+
+	for _, i := range u.Unused() {
+		fmt.Printf("unused index %s of %s\n", i.Index, i.Table)
+	}
+	for _, table := range u.SeqScanned() {
+		t := u.Tables[table]
+		fmt.Printf("WARNING: %d sequential scans of %s read %d rows\n", t.SeqScans, table, t.SeqTuplesRead)
+	}
+}
+
+// printSizes prints how the sizes of the tables changed during the run
+func printSizes(changes []dbperf.SizeChange) {
+	fmt.Println("table sizes:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "  table\tbefore\tafter\tgrowth\tindexes\tbytes/row\test. bloat before\test. bloat after\n")
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%.1f\t%s\t%s\n", c.Table, formatBytes(c.Before.TotalBytes), formatBytes(c.After.TotalBytes),
+			formatBytes(c.Growth()), formatBytes(c.After.IndexBytes-c.Before.IndexBytes), c.BytesPerRow(), formatBytes(c.Before.Bloat()),
+			formatBytes(c.After.Bloat()))
+	}
+	w.Flush()
+}
+
+// autoExplainConns wraps the connections of the workers, their own per conns or checked out from db, to load
+// auto_explain with cfg
+func autoExplainConns(conns func(id int) dbperf.WorkerConn, db *sql.DB, cfg dbperf.AutoExplainConfig) func(id int) dbperf.WorkerConn {
+	return func(id int) dbperf.WorkerConn {
+		wc := dbperf.WorkerConn{Connect: dbperf.SQLConnector(db)}
+		if conns != nil {
+			wc = conns(id)
+		}
+		wc.Connect = dbperf.WithAutoExplain(wc.Connect, cfg)
+		return wc
+	}
+}
+
+// printBackends prints the backend pids of the connections of every worker
+func printBackends(backends map[int][]int) {
+	ids := make([]int, 0, len(backends))
+	for id := range backends {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	fmt.Print("backend pids:")
+	for _, id := range ids {
+		pids := make([]string, len(backends[id]))
+		for i, pid := range backends[id] {
+			pids[i] = strconv.Itoa(pid)
+		}
+		fmt.Printf(" worker %d: %s;", id, strings.Join(pids, ", "))
+	}
+	fmt.Println()
+}
+
+// printWaits prints the server time of the run broken down by wait event type and the events with the most time,
+// elapsed being the total time of the queries on the client
+func printWaits(ws *dbperf.WaitStats, elapsed time.Duration) {
+	total := ws.Total()
+	fmt.Printf("wait events (sampled every %s, %d samples failed): ~%s server time", ws.Interval, ws.Failures, total)
+	if elapsed > 0 {
+		fmt.Printf(" (%.1f%% of the query time on the client)", float64(total)/float64(elapsed)*100)
+	}
+	fmt.Println()
+	if total == 0 {
+		return
+	}
+
+	types := ws.ByType()
+	names := make([]string, 0, len(types))
+	for t := range types {
+		names = append(names, t)
+	}
+	sort.Slice(names, func(i, j int) bool { return types[names[i]] > types[names[j]] })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "  type\ttime\tshare\n")
+	for _, t := range names {
+		fmt.Fprintf(w, "  %s\t%s\t%.1f%%\n", t, types[t], float64(types[t])/float64(total)*100)
+	}
+	w.Flush()
+
+	fmt.Print("top wait events:")
+	for _, e := range ws.Top(5) {
+		fmt.Printf(" %s: ~%s;", e, ws.Time(e))
+	}
+	fmt.Println()
+}
+
+// formatBytes formats the # of bytes in MB
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+// prepareTables analyzes, and vacuums first if vacuum is set, the comma separated tables before the run
+func prepareTables(ctx context.Context, db *sql.DB, tables string, vacuum bool) {
+	if tables == "" {
+		return
+	}
+
+	for _, table := range strings.Split(tables, ",") {
+		table = strings.TrimSpace(table)
+		start := time.Now()
+		if err := dbperf.AnalyzeTable(ctx, db, table, vacuum); err != nil {
+			fatalf("failed to prepare %s: %s", table, err)
+		}
+		slog.Info("table prepared", "table", table, "vacuum", vacuum, "elapsed", time.Since(start))
+	}
+}
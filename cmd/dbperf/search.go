@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"timescale/dbperf"
+)
+
+// runSearch searches for the max throughput that can be sustained within the SLO given on the command line and
+// prints the latency curve
+func runSearch(ctx context.Context, cli *CliArgs, db dbperf.Queryable, newGenerator func() (dbperf.QueryGenerator, error), configure func(c *dbperf.Controller)) {
+	cfg := dbperf.SearchConfig{
+		PoolSize:   cli.nworkers,
+		StartRate:  cli.searchStart,
+		MaxRate:    cli.searchMax,
+		Step:       cli.searchStep,
+		Percentile: cli.searchPercentile,
+		Target:     cli.searchSLO,
+		Configure:  configure,
+		OnStep: func(step dbperf.SearchStep) {
+			slog.Info("search step", "rate", step.Rate, "achieved", step.Throughput, "percentile", cli.searchPercentile, "latency", step.Latency, "ok", step.OK)
+		},
+	}
+
+	res, err := dbperf.SearchMaxThroughput(ctx, db, newGenerator, cfg)
+	if err != nil {
+		fatalf("throughput search failed: %s", err)
+	}
+
+	fmt.Printf("%-12s %-12s %-12s %s\n", "rate", "achieved", fmt.Sprintf("p%v", cli.searchPercentile), "ok")
+	for _, step := range res.Steps {
+		fmt.Printf("%-12.1f %-12.1f %-12s %t\n", step.Rate, step.Throughput, step.Latency, step.OK)
+	}
+
+	if res.MaxRate == 0 {
+		fmt.Printf("no rate could be sustained within p%v <= %s\n", cli.searchPercentile, cli.searchSLO)
+	} else {
+		fmt.Printf("max sustainable rate: %.1f qps (p%v <= %s)\n", res.MaxRate, cli.searchPercentile, cli.searchSLO)
+	}
+
+	if res.Knee > 0 {
+		fmt.Printf("latency knee: %.1f qps\n", res.Knee)
+	}
+}
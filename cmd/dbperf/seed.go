@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+	"timescale/dbperf"
+)
+
+// seedCommand creates the cpu_usage hypertable and loads the test data into it
+func seedCommand(args []string) {
+	var cli CliArgs
+	var drop bool
+	fs := newFlagSet("seed", "FILENAME", "Filename is a CSV file of ts,host,usage readings with a header, e.g. scripts/cpu_usage.csv")
+	cli.RegisterConn(fs)
+	fs.StringVar(&cli.filename, "f", "", "path to the CSV file of readings to load")
+	fs.BoolVar(&drop, "drop", false, "drop and recreate the cpu_usage table if it already exists")
+	parseFlags(fs, &cli, args)
+	filename := inputFilename(fs, &cli)
+
+	f, err := os.Open(filename)
+	if err != nil {
+		fatalf("open %s: %s", filename, err)
+	}
+	defer f.Close()
+
+	ctx := context.Background()
+	db, _ := openDB(ctx, &cli)
+	defer db.Close()
+
+	if err := dbperf.CreateCPUUsageTable(ctx, db, drop); err != nil {
+		fatalf("failed to create cpu_usage: %s", err)
+	}
+
+	start := time.Now()
+	n, err := dbperf.LoadCPUUsage(ctx, db, f)
+	if err != nil {
+		fatalf("failed to load %s: %s", filename, err)
+	}
+	slog.Info("loaded cpu_usage", "rows", n, "elapsed", time.Since(start).Round(time.Millisecond))
+}
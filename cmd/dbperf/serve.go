@@ -0,0 +1,556 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"timescale/dbperf"
+	"timescale/dbperf/rpc"
+
+	"google.golang.org/grpc"
+)
+
+// run statuses reported by the API
+const (
+	statusRunning   = "running"
+	statusDone      = "done"
+	statusFailed    = "failed"
+	statusCancelled = "cancelled"
+)
+
+// progressInterval is how often the progress of a run submitted to the API is updated
+const progressInterval = time.Second
+
+// timelineSize is the max # of progress updates kept per run for the latency charts of the web UI, older ones are
+// dropped
+const timelineSize = 3600
+
+// runRequest is the body of a request to submit a run
+type runRequest struct {
+	Query    string      `json:"query"`             // built-in query template, minmax by default
+	Queries  string      `json:"queries,omitempty"` // CSV input inline, not echoed back in the run status
+	File     string      `json:"file"`              // path to the CSV input within the server's -input-dir, if not inline
+	Workers  int         `json:"workers"`           // worker pool size, the server's -n by default
+	Duration string      `json:"duration"`          // stop dispatching after this long, e.g. 5m
+	Rate     float64     `json:"rate"`              // queries per second, 0 for as fast as possible
+	Tags     dbperf.Tags `json:"tags,omitempty"`    // labels to group and filter runs by
+}
+
+// apiRun is a run submitted to the API
+type apiRun struct {
+	ID       string             `json:"id"`
+	Status   string             `json:"status"`
+	Error    string             `json:"error,omitempty"`
+	Started  time.Time          `json:"started"`
+	Finished *time.Time         `json:"finished,omitempty"`
+	Request  runRequest         `json:"request"`
+	Progress *dbperf.Checkpoint `json:"progress,omitempty"`
+	Recent   *dbperf.QueryStats `json:"recent,omitempty"` // stats of the last -window of the run while it runs
+	Stats    *dbperf.QueryStats `json:"stats,omitempty"`
+	results  *dbperf.Results    // set once done
+	timeline []timelinePoint    // latest progress updates
+	cancel   context.CancelFunc // cancels the run
+	updated  chan struct{}      // closed and replaced on every update
+}
+
+// timelinePoint is the throughput and latency of a run between two progress updates
+type timelinePoint struct {
+	Elapsed time.Duration `json:"elapsed"`
+	QPS     float64       `json:"qps"`
+	Median  time.Duration `json:"median"`
+	P95     time.Duration `json:"p95"`
+	P99     time.Duration `json:"p99"`
+	Errors  int64         `json:"errors"` // errors so far
+}
+
+// server runs workloads submitted over HTTP one at a time
+type server struct {
+	db    dbperf.Queryable
+	cli   *CliArgs
+	fs    *flag.FlagSet        // flags of the server, recorded in the manifest of every run
+	store *dbperf.ResultsStore // finished runs are kept here when set
+	keep  int                  // # finished runs kept in memory, older ones are evicted
+	input string               // directory the file inputs of runs are opened in, none are allowed if empty
+	slot  *dbperf.RunSlot      // shared with the gRPC API
+
+	mu   sync.Mutex
+	runs map[string]*apiRun
+}
+
+// serveCommand runs the HTTP API server
+func serveCommand(args []string) {
+	var cli CliArgs
+	var addr, grpcAddr string
+	var keep int
+	var inputDir string
+	fs := newFlagSet("serve", "", `Serves a web UI following the runs live at / and a REST API to submit runs and fetch their results:
+
+  POST   /runs               submit a run: {"queries": CSV, "file": PATH, "query": TEMPLATE, "workers": N, "duration": "5m", "rate": QPS, "tags": {KEY: VALUE}}
+  GET    /runs               list runs, only those with the given tags with ?tag=KEY=VALUE
+  GET    /runs/ID            status and latest progress of a run
+  GET    /runs/ID/progress   stream the progress of a run as JSON lines until it finishes
+  GET    /runs/ID/timeline   throughput and latency of a run at every progress update (up to an hour)
+  GET    /runs/ID/results    results of a finished run in the format saved by run -out
+  DELETE /runs/ID            cancel a run
+  GET    /history            runs kept in -results-dir, including those of earlier servers, filtered like /runs
+
+Only one run executes at a time so runs don't skew each other's results. The gRPC control API defined in
+rpc/dbperf.proto is served as well when -grpc-addr is set, its runs taking turns with those of the REST API.
+
+The "file" of a run is a path within -input-dir, runs must send their queries inline if it isn't set.
+
+The last -keep-runs finished runs are kept in memory, older ones are evicted. The results of runs kept in
+-results-dir are only kept there once saved, and still served by /runs/ID/results.
+`)
+	cli.RegisterConn(fs)
+	fs.IntVar(&cli.nworkers, "n", 8, "default number of concurrent workers of a run")
+	fs.StringVar(&addr, "addr", ":8080", "address to listen on")
+	fs.StringVar(&grpcAddr, "grpc-addr", "", "also serve the gRPC control API on this address")
+	fs.StringVar(&cli.resultsDir, "results-dir", "", "keep the results of finished runs in this directory for the history")
+	fs.StringVar(&inputDir, "input-dir", "", "directory the file inputs of runs are opened in, only inline queries are accepted if not set")
+	fs.IntVar(&keep, "keep-runs", 100, "number of finished runs kept in memory, older ones are evicted")
+	fs.DurationVar(&cli.window, "window", 10*time.Second, "sliding window (up to 1m) of the recent stats of the runs in progress")
+	parseFlags(fs, &cli, args)
+
+	s := &server{cli: &cli, fs: fs, keep: keep, input: inputDir, slot: &dbperf.RunSlot{}, runs: make(map[string]*apiRun)}
+	if cli.resultsDir != "" {
+		var err error
+		if s.store, err = dbperf.OpenResultsStore(cli.resultsDir); err != nil {
+			fatalf("failed to open results store: %s", err)
+		}
+	}
+
+	db, _ := openDB(context.Background(), &cli)
+	s.db = db
+
+	if grpcAddr != "" {
+		go func() {
+			cfg := rpc.ServerConfig{Generators: generators, Workers: cli.nworkers, Slot: s.slot, KeepRuns: keep, InputDir: inputDir}
+			fatalf("gRPC server stopped: %s", serveGRPC(grpcAddr, db, cfg))
+		}()
+	}
+
+	slog.Info("serving the dbperf API", "addr", addr)
+	fatalf("server stopped: %s", http.ListenAndServe(addr, s))
+}
+
+// serveGRPC serves the gRPC control API on addr until it fails
+func serveGRPC(addr string, db dbperf.Queryable, cfg rpc.ServerConfig) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	gs := grpc.NewServer()
+	rpc.RegisterDbperfServer(gs, rpc.NewServer(db, cfg))
+	slog.Info("serving the dbperf gRPC API", "addr", addr)
+	return gs.Serve(lis)
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, uiPage)
+		return
+	case path == "history" && r.Method == http.MethodGet:
+		s.listHistory(w, r)
+		return
+	case parts[0] != "runs" || len(parts) > 3:
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			s.listRuns(w, r)
+		case http.MethodPost:
+			s.submitRun(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	s.mu.Lock()
+	run, ok := s.runs[parts[1]]
+	s.mu.Unlock()
+	if !ok && len(parts) == 3 && parts[2] == "results" && r.Method == http.MethodGet {
+		// runs of earlier servers only have their results
+		s.storedResults(w, r, parts[1])
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		s.writeJSON(w, http.StatusOK, s.snapshot(run))
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		run.cancel()
+		w.WriteHeader(http.StatusAccepted)
+	case len(parts) == 3 && parts[2] == "progress" && r.Method == http.MethodGet:
+		s.streamProgress(w, r, run)
+	case len(parts) == 3 && parts[2] == "timeline" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		timeline := append([]timelinePoint{}, run.timeline...)
+		s.mu.Unlock()
+		s.writeJSON(w, http.StatusOK, timeline)
+	case len(parts) == 3 && parts[2] == "results" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		results, done := run.results, run.Status == statusDone
+		s.mu.Unlock()
+		if results == nil && done {
+			// the results of runs saved to the results store are only kept there
+			s.storedResults(w, r, run.ID)
+			return
+		}
+		if results == nil {
+			http.Error(w, "run has no results yet", http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		dbperf.WriteResults(w, results)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("failed to write response", "err", err)
+	}
+}
+
+// snapshot copies the run's exported state while holding the lock
+func (s *server) snapshot(run *apiRun) apiRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *run
+}
+
+// tagFilter returns the tags given as tag=key=value query parameters to filter runs by
+func tagFilter(r *http.Request) (dbperf.Tags, error) {
+	var filter dbperf.Tags
+	for _, tag := range r.URL.Query()["tag"] {
+		if err := filter.Set(tag); err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
+func (s *server) listRuns(w http.ResponseWriter, r *http.Request) {
+	filter, err := tagFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	runs := make([]apiRun, 0, len(s.runs))
+	for _, run := range s.runs {
+		if !run.Request.Tags.Match(filter) {
+			continue
+		}
+		r := *run
+		r.Stats = nil
+		runs = append(runs, r)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Started.Before(runs[j].Started) })
+	s.writeJSON(w, http.StatusOK, runs)
+}
+
+// listHistory writes the records of the runs kept in the results store
+func (s *server) listHistory(w http.ResponseWriter, r *http.Request) {
+	filter, err := tagFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records := []*dbperf.RunRecord{}
+	if s.store != nil {
+		if records, err = s.store.List(filter); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	s.writeJSON(w, http.StatusOK, records)
+}
+
+// storedResults writes the results of a run kept in the results store
+func (s *server) storedResults(w http.ResponseWriter, r *http.Request, id string) {
+	if s.store == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	results, err := s.store.Load(id)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	dbperf.WriteResults(w, results)
+}
+
+func (s *server) submitRun(w http.ResponseWriter, r *http.Request) {
+	var req runRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid run request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.Query == "" {
+		req.Query = "minmax"
+	}
+	newGenerator, ok := generators[req.Query]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown query template: %s", req.Query), http.StatusBadRequest)
+		return
+	}
+
+	var duration time.Duration
+	if req.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(req.Duration); err != nil {
+			http.Error(w, fmt.Sprintf("invalid duration: %s", req.Duration), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Workers <= 0 {
+		req.Workers = s.cli.nworkers
+	}
+
+	// the run's settings are recorded under the names of the equivalent run flags
+	manifest := newManifest(s.fs)
+	manifest.Flags["query"] = req.Query
+	manifest.Flags["n"] = strconv.Itoa(req.Workers)
+	manifest.Flags["duration"] = duration.String()
+	manifest.Flags["rate"] = strconv.FormatFloat(req.Rate, 'g', -1, 64)
+
+	var input io.ReadCloser
+	switch {
+	case req.Queries != "":
+		manifest.SetInput("inline", strings.NewReader(req.Queries))
+		input = ioutil.NopCloser(strings.NewReader(req.Queries))
+	case req.File != "" && s.input == "":
+		http.Error(w, "file inputs aren't allowed by the server, send the queries inline", http.StatusBadRequest)
+		return
+	case req.File != "":
+		f, err := os.OpenInRoot(s.input, req.File)
+		if err == nil {
+			err = manifest.SetInput(req.File, f)
+		}
+		if err == nil {
+			_, err = f.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			if f != nil {
+				f.Close()
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		input = f
+	default:
+		http.Error(w, "either queries or file is required", http.StatusBadRequest)
+		return
+	}
+
+	// the inline input can be large, keep it out of the run status
+	req.Queries = ""
+
+	id := dbperf.NewRunID()
+	if holder, ok := s.slot.Acquire(id); !ok {
+		input.Close()
+		http.Error(w, fmt.Sprintf("run %s is still running", holder), http.StatusConflict)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &apiRun{
+		ID:      id,
+		Status:  statusRunning,
+		Started: time.Now(),
+		Request: req,
+		cancel:  cancel,
+		updated: make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.runs[run.ID] = run
+	s.mu.Unlock()
+
+	if err := manifest.ReadServerVersions(ctx, s.db); err != nil {
+		slog.Warn("failed to read the server version", "run", run.ID, "err", err)
+	}
+	if err := manifest.ReadServerSettings(ctx, s.db); err != nil {
+		slog.Warn("failed to read the server settings", "run", run.ID, "err", err)
+	}
+
+	c := dbperf.NewController(dbperf.WithPoolSize(req.Workers), dbperf.WithLogger(slog.Default().With("run", run.ID)))
+	c.SetDuration(duration)
+	c.SetRateLimit(req.Rate)
+	c.SetCheckpoint(progressInterval, func(cp *dbperf.Checkpoint) error {
+		recent := c.RecentStats(s.cli.window)
+		s.update(run, func() {
+			run.Progress = cp
+			run.Recent = recent
+			if len(run.timeline) == timelineSize {
+				run.timeline = run.timeline[1:]
+			}
+			run.timeline = append(run.timeline, timelinePoint{
+				Elapsed: cp.Elapsed,
+				QPS:     cp.Interval.Throughput(),
+				Median:  cp.Interval.Median,
+				P95:     cp.Interval.P95,
+				P99:     cp.Interval.P99,
+				Errors:  cp.Total.Errors,
+			})
+		})
+		return nil
+	})
+
+	go func() {
+		defer input.Close()
+		slog.Info("run started", "run", run.ID, "workers", req.Workers)
+
+		var stats *dbperf.QueryStats
+		report, err := c.RunTest(ctx, s.db, newGenerator(input))
+		if err == nil {
+			stats = report.QueryStats
+		}
+		var status string
+		s.update(run, func() {
+			now := time.Now()
+			run.Finished = &now
+			switch {
+			case ctx.Err() != nil:
+				run.Status = statusCancelled
+			case err != nil:
+				run.Status = statusFailed
+				run.Error = err.Error()
+			default:
+				run.Status = statusDone
+				run.Stats = stats
+				run.results = dbperf.NewResults(stats)
+				run.results.ID = run.ID
+				run.results.Tags = req.Tags
+				run.results.Manifest = manifest
+			}
+			status = run.Status
+		})
+		s.slot.Release(run.ID)
+		cancel()
+		slog.Info("run "+status, "run", run.ID)
+
+		if s.store != nil && status == statusDone {
+			rec := &dbperf.RunRecord{ID: run.ID, Name: workloadName(req.Query, req.File), Tags: req.Tags, Started: run.Started, Finished: *run.Finished, Stats: stats}
+			if err := s.store.Save(rec, run.results); err != nil {
+				slog.Error("failed to save results", "run", run.ID, "err", err)
+			} else {
+				s.update(run, func() {
+					summary := *run.Stats
+					summary.Latencies = nil
+					run.Stats = &summary
+					run.results = nil
+				})
+			}
+		}
+		s.evict()
+	}()
+
+	s.writeJSON(w, http.StatusCreated, s.snapshot(run))
+}
+
+// evict drops the oldest finished runs beyond the # kept
+func (s *server) evict() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var finished []*apiRun
+	for _, run := range s.runs {
+		if run.Finished != nil {
+			finished = append(finished, run)
+		}
+	}
+	if len(finished) <= s.keep {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool { return finished[i].Finished.Before(*finished[j].Finished) })
+	for _, run := range finished[:len(finished)-s.keep] {
+		delete(s.runs, run.ID)
+	}
+}
+
+// update applies a change to the run and wakes up the progress streams
+func (s *server) update(run *apiRun, change func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change()
+	close(run.updated)
+	run.updated = make(chan struct{})
+}
+
+// streamProgress writes the state of the run as a JSON line on every update until it's finished
+func (s *server) streamProgress(w http.ResponseWriter, r *http.Request, run *apiRun) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	for {
+		s.mu.Lock()
+		snapshot := *run
+		updated := run.updated
+		s.mu.Unlock()
+
+		snapshot.Stats = nil
+		if err := enc.Encode(snapshot); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if snapshot.Status != statusRunning {
+			return
+		}
+
+		select {
+		case <-updated:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
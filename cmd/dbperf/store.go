@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// storeTokenEnv names the environment variable holding the bearer token sent to the object store, if it needs one
+// (e.g. an OAuth access token for the GCS XML API)
+const storeTokenEnv = "DBPERF_STORE_TOKEN"
+
+// isURL reports whether a file argument refers to an object in an HTTP object store rather than a local file
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// fetchObject reads an object from the object store
+func fetchObject(url string) ([]byte, error) {
+	resp, err := storeRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// putObject writes an object to the object store
+func putObject(url string, body []byte) error {
+	resp, err := storeRequest(http.MethodPut, url, body)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// storeRequest sends a request to the object store, responses other than 2xx are returned as errors
+func storeRequest(method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv(storeTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+
+	return resp, nil
+}
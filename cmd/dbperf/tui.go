@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"timescale/dbperf"
+)
+
+const (
+	tuiRefresh  = 250 * time.Millisecond // how often the dashboard is redrawn
+	tuiWindow   = 10                     // # of seconds the live latency percentiles are calculated over
+	tuiWorkers  = 32                     // max # of worker queues shown
+	tuiErrors   = 5                      // max # of distinct errors shown
+	tuiLogLines = 5                      // # of most recent log lines shown
+	tuiBarWidth = 40                     // width of the longest queue depth bar
+)
+
+// ANSI escape sequences used to draw the dashboard
+const (
+	ansiAltScreen  = "\x1b[?1049h\x1b[?25l" // switch to the alternate screen and hide the cursor
+	ansiMainScreen = "\x1b[?25h\x1b[?1049l" // restore the cursor and the main screen
+	ansiHome       = "\x1b[H"
+	ansiClearLine  = "\x1b[K"
+	ansiClearDown  = "\x1b[J"
+	ansiBold       = "\x1b[1m"
+	ansiReset      = "\x1b[0m"
+)
+
+// tuiSecond holds the results completed within one second of the run
+type tuiSecond struct {
+	at        int64 // unix second
+	latencies *dbperf.Histogram
+	errors    int64
+}
+
+// dashboard is a terminal UI showing the progress of a run live, for interactive tuning sessions
+type dashboard struct {
+	out        io.Writer
+	controller *dbperf.Controller
+	start      time.Time
+	quit       chan struct{}
+	done       chan struct{}
+
+	mu      sync.Mutex
+	total   *dbperf.Histogram
+	errors  int64
+	byError map[string]int64
+	seconds [tuiWindow + 1]tuiSecond // ring buffer by unix second, the current second is incomplete
+	logs    bytes.Buffer             // log output captured while the dashboard is shown
+}
+
+// newDashboard creates a dashboard following the run of c, drawn to out
+func newDashboard(out io.Writer, c *dbperf.Controller) *dashboard {
+	d := &dashboard{
+		out:        out,
+		controller: c,
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+		total:      dbperf.NewHistogram(nil),
+		byError:    make(map[string]int64),
+	}
+	c.SetResultFunc(d.record)
+	return d
+}
+
+// Write captures log output while the dashboard is shown
+func (d *dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.logs.Write(p)
+}
+
+// record adds the result of a query, it's called on the controller's dispatch goroutine
+func (d *dashboard) record(r dbperf.QueryResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sec := r.Completed.Unix()
+	s := &d.seconds[sec%int64(len(d.seconds))]
+	if s.at != sec {
+		*s = tuiSecond{at: sec, latencies: dbperf.NewHistogram(nil)}
+	}
+
+	if r.Err != nil {
+		d.errors++
+		d.byError[r.Err.Error()]++
+		s.errors++
+		return
+	}
+	if r.Cancelled {
+		return
+	}
+
+	d.total.Record(r.Latency)
+	s.latencies.Record(r.Latency)
+}
+
+// Start shows the dashboard until Stop is called, log output is shown in the dashboard in the meantime. An interrupt
+// restores the terminal before exiting.
+func (d *dashboard) Start() {
+	d.start = time.Now()
+	logOutput.Set(d)
+	io.WriteString(d.out, ansiAltScreen)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	go func() {
+		defer close(d.done)
+		defer signal.Stop(interrupt)
+
+		ticker := time.NewTicker(tuiRefresh)
+		defer ticker.Stop()
+		for {
+			d.draw(time.Now())
+			select {
+			case <-ticker.C:
+			case <-interrupt:
+				d.restore()
+				os.Exit(130)
+			case <-d.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop removes the dashboard, restoring the terminal and passing the log output captured on to stderr
+func (d *dashboard) Stop() {
+	close(d.quit)
+	<-d.done
+	d.restore()
+}
+
+func (d *dashboard) restore() {
+	io.WriteString(d.out, ansiMainScreen)
+	logOutput.Set(os.Stderr)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	os.Stderr.Write(d.logs.Bytes())
+	d.logs.Reset()
+}
+
+// draw redraws the whole dashboard in place
+func (d *dashboard) draw(now time.Time) {
+	depths := d.controller.QueueDepths()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// the last complete second gives the current rate, the window before now the live latencies
+	sec := now.Unix()
+	var qps int64
+	window := dbperf.NewHistogram(nil)
+	for _, s := range d.seconds {
+		if s.at == sec-1 {
+			qps = s.latencies.Count + s.errors
+		}
+		if s.at >= sec-tuiWindow && s.at < sec {
+			window.Merge(s.latencies)
+		}
+	}
+
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString(ansiClearLine + "\n")
+	}
+
+	b.WriteString(ansiHome)
+	line("%sdbperf%s  elapsed %s  (ctrl-c to quit)", ansiBold, ansiReset, now.Sub(d.start).Round(time.Second))
+	line("")
+	line("queries   %d completed  %d qps  %d errors", d.total.Count, qps, d.errors)
+	line("")
+	line("%-12s %10s %10s %10s %10s %10s", "latency", "min", "median", "p95", "p99", "max")
+	for _, row := range []struct {
+		name string
+		h    *dbperf.Histogram
+	}{{fmt.Sprintf("last %ds", tuiWindow), window}, {"total", d.total}} {
+		line("%-12s %10s %10s %10s %10s %10s", row.name, tuiDuration(row.h.Min), tuiDuration(row.h.Percentile(50)),
+			tuiDuration(row.h.Percentile(95)), tuiDuration(row.h.Percentile(99)), tuiDuration(row.h.Max))
+	}
+
+	line("")
+	line("worker queue depth")
+	max := 1
+	for _, n := range depths {
+		if n > max {
+			max = n
+		}
+	}
+	for i, n := range depths {
+		if i == tuiWorkers {
+			line("  ... %d more workers", len(depths)-tuiWorkers)
+			break
+		}
+		line("  %4d %-*s %d", i, tuiBarWidth, strings.Repeat("#", n*tuiBarWidth/max), n)
+	}
+
+	if len(d.byError) > 0 {
+		line("")
+		line("errors")
+		for _, e := range topErrors(d.byError, tuiErrors) {
+			line("  %8d %s", d.byError[e], e)
+		}
+	}
+
+	if d.logs.Len() > 0 {
+		lines := strings.Split(strings.TrimRight(d.logs.String(), "\n"), "\n")
+		if len(lines) > tuiLogLines {
+			lines = lines[len(lines)-tuiLogLines:]
+		}
+		line("")
+		line("log")
+		for _, l := range lines {
+			line("  %s", l)
+		}
+	}
+
+	b.WriteString(ansiClearDown)
+	io.WriteString(d.out, b.String())
+}
+
+// tuiDuration formats a latency compactly, - before any queries completed
+func tuiDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Microsecond).String()
+}
+
+// topErrors returns the n most frequent errors, most frequent first
+func topErrors(byError map[string]int64, n int) []string {
+	errs := make([]string, 0, len(byError))
+	for e := range byError {
+		errs = append(errs, e)
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		if byError[errs[i]] != byError[errs[j]] {
+			return byError[errs[i]] > byError[errs[j]]
+		}
+		return errs[i] < errs[j]
+	})
+
+	if len(errs) > n {
+		errs = errs[:n]
+	}
+	return errs
+}
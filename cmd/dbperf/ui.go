@@ -0,0 +1,261 @@
+package main
+
+// uiPage is the web UI of the serve command, following the runs live through the REST API
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>dbperf</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+tr.selected { background: #eef4fb; }
+tbody tr { cursor: pointer; }
+canvas { border: 1px solid #ccc; margin-bottom: 0.5em; }
+.legend span { margin-right: 1.5em; }
+.muted { color: #888; }
+</style>
+</head>
+<body>
+<h1>dbperf</h1>
+
+<h2>Runs</h2>
+<table>
+<thead><tr><th>id</th><th>status</th><th>query</th><th>tags</th><th>started</th><th>queries</th><th>qps</th><th>median</th><th>p99</th><th></th></tr></thead>
+<tbody id="runs"></tbody>
+</table>
+
+<h2 id="live-title">Live latency</h2>
+<p id="live-status" class="muted">no run selected</p>
+<canvas id="latency" width="900" height="250"></canvas>
+<div class="legend"><span style="color: #4a90d9">median</span><span style="color: #f5a623">p95</span><span style="color: #d0021b">p99</span></div>
+<canvas id="qps" width="900" height="120"></canvas>
+<div class="legend"><span style="color: #417505">queries per second</span></div>
+
+<h2>History</h2>
+<p id="history-empty" class="muted">no runs kept, start the server with -results-dir to keep a history</p>
+<canvas id="history" width="900" height="200"></canvas>
+<div class="legend"><span style="color: #4a90d9">median</span><span style="color: #d0021b">p99</span></div>
+<table>
+<thead><tr><th>id</th><th>query</th><th>tags</th><th>started</th><th>duration</th><th>queries</th><th>qps</th><th>median</th><th>p95</th><th>p99</th><th></th></tr></thead>
+<tbody id="history-runs"></tbody>
+</table>
+
+<script>
+"use strict";
+
+var selected = null;   // id of the run followed live
+var timeline = [];     // progress of the run followed live
+var stream = null;     // progress stream of the run followed live
+var picked = false;    // a run was picked to follow rather than the latest
+
+// durations are nanoseconds in the API
+function ms(ns) { return ns / 1e6; }
+function fmt(ns) { return ns ? ms(ns).toFixed(3) + "ms" : "-"; }
+function qps(stats) { return stats && stats.Duration ? (stats.Processed / (stats.Duration / 1e9)).toFixed(1) : "-"; }
+
+function tags(t) {
+	return Object.keys(t || {}).sort().map(function(k) { return k + "=" + t[k]; }).join(", ");
+}
+
+function cell(row, text) {
+	var td = document.createElement("td");
+	td.textContent = text;
+	row.appendChild(td);
+	return td;
+}
+
+// chart draws each series as a line scaled to the largest value of them all
+function chart(canvas, series, labels) {
+	var ctx = canvas.getContext("2d");
+	var w = canvas.width, h = canvas.height, pad = 40;
+	ctx.clearRect(0, 0, w, h);
+
+	var max = 0, n = 0;
+	series.forEach(function(s) {
+		n = Math.max(n, s.values.length);
+		s.values.forEach(function(v) { max = Math.max(max, v); });
+	});
+	if (n === 0) {
+		return;
+	}
+	max = max || 1;
+
+	ctx.strokeStyle = "#ccc";
+	ctx.fillStyle = "#888";
+	ctx.font = "11px sans-serif";
+	for (var i = 0; i <= 4; i++) {
+		var y = h - pad / 2 - (h - pad) * i / 4;
+		ctx.beginPath();
+		ctx.moveTo(pad, y);
+		ctx.lineTo(w, y);
+		ctx.stroke();
+		ctx.fillText(labels(max * i / 4), 2, y + 4);
+	}
+
+	series.forEach(function(s) {
+		ctx.strokeStyle = s.color;
+		ctx.beginPath();
+		s.values.forEach(function(v, i) {
+			var x = pad + (w - pad) * (n === 1 ? 0.5 : i / (n - 1));
+			var y = h - pad / 2 - (h - pad) * v / max;
+			if (i === 0) {
+				ctx.moveTo(x, y);
+			} else {
+				ctx.lineTo(x, y);
+			}
+		});
+		ctx.stroke();
+	});
+}
+
+function drawLive() {
+	chart(document.getElementById("latency"), [
+		{color: "#4a90d9", values: timeline.map(function(p) { return ms(p.median); })},
+		{color: "#f5a623", values: timeline.map(function(p) { return ms(p.p95); })},
+		{color: "#d0021b", values: timeline.map(function(p) { return ms(p.p99); })}
+	], function(v) { return v.toFixed(1) + "ms"; });
+	chart(document.getElementById("qps"), [
+		{color: "#417505", values: timeline.map(function(p) { return p.qps; })}
+	], function(v) { return v.toFixed(0); });
+}
+
+// follow loads the timeline of the run and streams its progress until it finishes
+function follow(id) {
+	if (stream) {
+		stream.abort();
+	}
+	selected = id;
+	timeline = [];
+	stream = new AbortController();
+	var signal = stream.signal;
+	document.getElementById("live-title").textContent = "Live latency: run " + id;
+
+	fetch("runs/" + id + "/timeline", {signal: signal}).then(function(resp) {
+		return resp.json();
+	}).then(function(points) {
+		timeline = points;
+		drawLive();
+		return fetch("runs/" + id + "/progress", {signal: signal});
+	}).then(function(resp) {
+		var reader = resp.body.getReader(), decoder = new TextDecoder(), buf = "";
+		var last = timeline.length ? timeline[timeline.length - 1].elapsed : -1;
+
+		function read() {
+			return reader.read().then(function(chunk) {
+				if (chunk.done) {
+					return;
+				}
+				buf += decoder.decode(chunk.value, {stream: true});
+				var lines = buf.split("\n");
+				buf = lines.pop();
+				lines.forEach(function(line) {
+					if (!line) {
+						return;
+					}
+					var run = JSON.parse(line);
+					var status = run.status;
+					if (run.progress) {
+						var cp = run.progress, total = cp.total;
+						status += ": " + total.Processed + " queries after " + (cp.elapsed / 1e9).toFixed(0) + "s, " + total.Errors + " errors";
+						if (cp.elapsed > last) {
+							last = cp.elapsed;
+							timeline.push({elapsed: cp.elapsed, qps: cp.interval.Processed / (cp.interval.Duration / 1e9),
+								median: cp.interval.Median, p95: cp.interval.P95, p99: cp.interval.P99, errors: total.Errors});
+							drawLive();
+						}
+					}
+					document.getElementById("live-status").textContent = status;
+				});
+				return read();
+			});
+		}
+		return read();
+	}).catch(function(err) {
+		if (err.name !== "AbortError") {
+			document.getElementById("live-status").textContent = "failed to follow run " + id + ": " + err;
+		}
+	});
+}
+
+function refreshRuns() {
+	fetch("runs").then(function(resp) { return resp.json(); }).then(function(runs) {
+		var tbody = document.getElementById("runs");
+		tbody.innerHTML = "";
+		runs.reverse().forEach(function(run) {
+			var row = document.createElement("tr");
+			if (run.id === selected) {
+				row.className = "selected";
+			}
+			row.onclick = function() { picked = true; follow(run.id); refreshRuns(); };
+			var p = run.progress;
+			cell(row, run.id);
+			cell(row, run.status + (run.error ? ": " + run.error : ""));
+			cell(row, run.request.query);
+			cell(row, tags(run.request.tags));
+			cell(row, new Date(run.started).toLocaleString());
+			cell(row, p ? p.total.Processed : "-");
+			cell(row, p ? qps(p.total) : "-");
+			cell(row, p ? fmt(p.interval.Median) : "-");
+			cell(row, p ? fmt(p.interval.P99) : "-");
+			var actions = cell(row, "");
+			if (run.status === "running") {
+				var button = document.createElement("button");
+				button.textContent = "cancel";
+				button.onclick = function(e) {
+					e.stopPropagation();
+					fetch("runs/" + run.id, {method: "DELETE"}).then(refreshRuns);
+				};
+				actions.appendChild(button);
+			}
+			tbody.appendChild(row);
+		});
+
+		// follow the latest run until one is picked
+		if (!picked && runs.length && runs[0].id !== selected) {
+			follow(runs[0].id);
+		}
+	});
+}
+
+function refreshHistory() {
+	fetch("history").then(function(resp) { return resp.json(); }).then(function(records) {
+		document.getElementById("history-empty").style.display = records.length ? "none" : "";
+		chart(document.getElementById("history"), [
+			{color: "#4a90d9", values: records.map(function(r) { return ms(r.stats.Median); })},
+			{color: "#d0021b", values: records.map(function(r) { return ms(r.stats.P99); })}
+		], function(v) { return v.toFixed(1) + "ms"; });
+
+		var tbody = document.getElementById("history-runs");
+		tbody.innerHTML = "";
+		records.slice().reverse().forEach(function(rec) {
+			var row = document.createElement("tr"), s = rec.stats;
+			cell(row, rec.id);
+			cell(row, rec.name || "");
+			cell(row, tags(rec.tags));
+			cell(row, new Date(rec.started).toLocaleString());
+			cell(row, ((new Date(rec.finished) - new Date(rec.started)) / 1000).toFixed(0) + "s");
+			cell(row, s.Processed);
+			cell(row, qps(s));
+			cell(row, fmt(s.Median));
+			cell(row, fmt(s.P95));
+			cell(row, fmt(s.P99));
+			var link = document.createElement("a");
+			link.href = "runs/" + rec.id + "/results";
+			link.textContent = "results";
+			cell(row, "").appendChild(link);
+			tbody.appendChild(row);
+		});
+	});
+}
+
+refreshRuns();
+refreshHistory();
+setInterval(refreshRuns, 2000);
+setInterval(refreshHistory, 10000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"timescale/dbperf"
+)
+
+// validateCommand checks everything run would read before it starts without connecting to the database
+func validateCommand(args []string) {
+	var cli CliArgs
+	fs := newFlagSet("validate", "FILENAME", "Takes the same flags as run and checks the input, schedule, load shape and tenant files")
+	cli.Register(fs)
+	parseFlags(fs, &cli, args)
+	filename := inputFilename(fs, &cli)
+
+	valid := true
+	report := func(what string, err error) {
+		if err != nil {
+			fmt.Printf("%s: %s\n", what, err)
+			valid = false
+		}
+	}
+
+	if newGenerator, ok := generators[cli.query]; ok {
+		f, err := os.Open(filename)
+		if err == nil {
+			var n, invalid int
+			n, invalid, err = checkQueries(f, validateRanges(&cli, newGenerator))
+			f.Close()
+			if err == nil {
+				fmt.Printf("%s: %d queries\n", filename, n)
+				if invalid > 0 {
+					err = fmt.Errorf("%d invalid rows", invalid)
+				}
+			}
+		}
+		report(filename, err)
+	} else {
+		report("query", fmt.Errorf("unknown query template: %s", cli.query))
+	}
+
+	if cli.schedule != "" {
+		schedule, err := readSchedule(cli.schedule)
+		report(cli.schedule, err)
+		if err == nil {
+			fmt.Printf("%s: %d phases over %s\n", cli.schedule, len(schedule), schedule.Duration())
+		}
+	}
+
+	if cli.shape != "" {
+		_, err := dbperf.ParseLoadShape(cli.shape)
+		report("shape", err)
+	}
+
+	if cli.tenants != "" {
+		tenants, err := readTenants(cli.tenants)
+		report(cli.tenants, err)
+		if err == nil {
+			fmt.Printf("%s: %d tenants; %d workers\n", cli.tenants, len(tenants), tenants.Workers())
+		}
+	}
+
+	if !valid {
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+}
+
+// checkQueries reads every query of the input, printing every invalid row, and returns how many valid queries and
+// invalid rows there are or the first error reading the input
+func checkQueries(r io.Reader, newGenerator func(io.Reader) dbperf.QueryGenerator) (int, int, error) {
+	g := newGenerator(r)
+	var n, invalid int
+	for {
+		_, err := g.Next()
+		var rowErr *dbperf.InvalidRowError
+		switch {
+		case err == io.EOF:
+			return n, invalid, nil
+		case errors.As(err, &rowErr):
+			fmt.Println(rowErr)
+			invalid++
+		case err != nil:
+			return n, invalid, fmt.Errorf("query %d: %s", n+invalid+1, err)
+		default:
+			n++
+		}
+	}
+}
+
+// countQueries reads every query of the input and returns how many there are or the first invalid one
+func countQueries(r io.Reader, newGenerator func(io.Reader) dbperf.QueryGenerator) (int, error) {
+	g := newGenerator(r)
+	for n := 0; ; n++ {
+		if _, err := g.Next(); err != nil {
+			if err == io.EOF {
+				return n, nil
+			}
+			return n, fmt.Errorf("query %d: %s", n+1, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+	"timescale/dbperf"
+)
+
+// webhookTimeout bounds how long notifying the webhook may hold up the exit
+const webhookTimeout = 10 * time.Second
+
+// webhookEvent is the JSON body POSTed to the -webhook URL when a run finishes or fails
+type webhookEvent struct {
+	ID       string             `json:"id"`
+	Status   string             `json:"status"` // done or failed
+	Error    string             `json:"error,omitempty"`
+	Workload string             `json:"workload"`
+	Tags     dbperf.Tags        `json:"tags,omitempty"`
+	Started  time.Time          `json:"started"`
+	Finished time.Time          `json:"finished"`
+	Stats    *dbperf.QueryStats `json:"stats,omitempty"`
+}
+
+// notifyWebhook POSTs the event to the webhook, failing to is logged but doesn't fail the run
+func notifyWebhook(url string, event *webhookEvent) {
+	if event.Stats != nil {
+		// the summary is enough for notifications
+		stats := *event.Stats
+		stats.Histogram = nil
+		event.Stats = &stats
+	}
+
+	if err := postWebhook(url, event); err != nil {
+		slog.Warn("failed to notify the webhook", "url", url, "err", err)
+	}
+}
+
+func postWebhook(url string, event *webhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	client := http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", url, resp.Status)
+	}
+	return nil
+}
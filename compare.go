@@ -0,0 +1,130 @@
+package dbperf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// StatDelta is the difference of a single statistic between a baseline and another run
+type StatDelta struct {
+	Name     string  // statistic name
+	Base     float64 // value of the baseline run
+	Other    float64 // value of the other run
+	Duration bool    // values are durations in nanoseconds
+}
+
+// Diff returns the absolute difference of the other run from the baseline
+func (d StatDelta) Diff() float64 {
+	return d.Other - d.Base
+}
+
+// Change returns the relative difference of the other run from the baseline in percent, 0 if the baseline is 0
+func (d StatDelta) Change() float64 {
+	if d.Base == 0 {
+		return 0
+	}
+	return (d.Other - d.Base) / d.Base * 100
+}
+
+// format a value of the statistic
+func (d StatDelta) format(v float64) string {
+	if d.Duration {
+		return time.Duration(v).String()
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+func (d StatDelta) String() string {
+	return fmt.Sprintf("%s: %s -> %s (%s, %+.2f%%)", d.Name, d.format(d.Base), d.format(d.Other), d.format(d.Diff()), d.Change())
+}
+
+// mainStat is one of the main statistics of a run
+type mainStat struct {
+	name     string
+	duration bool // values are durations in nanoseconds
+	value    func(s *QueryStats) float64
+}
+
+// mainStats are the main statistics of a run in the order they're reported
+var mainStats = []mainStat{
+	{"processed", false, func(s *QueryStats) float64 { return float64(s.Processed) }},
+	{"throughput", false, (*QueryStats).Throughput},
+	{"min", true, func(s *QueryStats) float64 { return float64(s.Min) }},
+	{"max", true, func(s *QueryStats) float64 { return float64(s.Max) }},
+	{"avg", true, func(s *QueryStats) float64 { return float64(s.Avg) }},
+	{"median", true, func(s *QueryStats) float64 { return float64(s.Median) }},
+	{"p95", true, func(s *QueryStats) float64 { return float64(s.P95) }},
+	{"p99", true, func(s *QueryStats) float64 { return float64(s.P99) }},
+}
+
+// Compare returns the differences of the main statistics of the other run from the baseline
+func Compare(base, other *QueryStats) []StatDelta {
+	deltas := make([]StatDelta, len(mainStats))
+	for i, stat := range mainStats {
+		deltas[i] = StatDelta{Name: stat.name, Base: stat.value(base), Other: stat.value(other), Duration: stat.duration}
+	}
+	return deltas
+}
+
+// MannWhitney tests whether the latencies of two runs come from the same distribution with a two-sided Mann-Whitney
+// U test (the test benchstat uses) and returns the p-value. A small p-value (e.g. < 0.05) means the difference
+// between the runs is significant rather than noise. The normal approximation with tie correction is used which is
+// accurate for the sample sizes of a run; 1 is returned if either sample is empty.
+func MannWhitney(a, b []time.Duration) float64 {
+	n1, n2 := len(a), len(b)
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	type sample struct {
+		v     time.Duration
+		first bool
+	}
+	all := make([]sample, 0, n1+n2)
+	for _, v := range a {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range b {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// sum the ranks of the first sample, ties get the average of their ranks
+	var r1, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].first {
+				r1 += rank
+			}
+		}
+
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	fn1, fn2 := float64(n1), float64(n2)
+	n := fn1 + fn2
+	u := r1 - fn1*(fn1+1)/2
+	mean := fn1 * fn2 / 2
+	sigma := math.Sqrt(fn1 * fn2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+
+	// continuity correction
+	z := (math.Abs(u-mean) - 0.5) / sigma
+	if z < 0 {
+		z = 0
+	}
+
+	return math.Erfc(z / math.Sqrt2)
+}
@@ -0,0 +1,77 @@
+package dbperf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	base := &QueryStats{
+		Processed: 100,
+		Duration:  time.Second * 10,
+		Min:       time.Millisecond,
+		Max:       time.Millisecond * 100,
+		Avg:       time.Millisecond * 10,
+		Median:    time.Millisecond * 8,
+		P95:       time.Millisecond * 40,
+		P99:       time.Millisecond * 80,
+	}
+
+	other := &QueryStats{
+		Processed: 100,
+		Duration:  time.Second * 20,
+		Min:       time.Millisecond * 2,
+		Max:       time.Millisecond * 100,
+		Avg:       time.Millisecond * 15,
+		Median:    time.Millisecond * 10,
+		P95:       time.Millisecond * 50,
+		P99:       time.Millisecond * 60,
+	}
+
+	deltas := Compare(base, other)
+	byName := make(map[string]StatDelta)
+	for _, d := range deltas {
+		byName[d.Name] = d
+	}
+
+	assert.Equal(t, float64(0), byName["processed"].Change())
+	assert.InDelta(t, -50, byName["throughput"].Change(), 1e-9)
+	assert.InDelta(t, 100, byName["min"].Change(), 1e-9)
+	assert.InDelta(t, 25, byName["median"].Change(), 1e-9)
+	assert.InDelta(t, -25, byName["p99"].Change(), 1e-9)
+	assert.Equal(t, float64(time.Millisecond*2), byName["median"].Diff())
+
+	assert.Equal(t, "median: 8ms -> 10ms (2ms, +25.00%)", byName["median"].String())
+	assert.Equal(t, "throughput: 10.00 -> 5.00 (-5.00, -50.00%)", byName["throughput"].String())
+}
+
+func TestStatDeltaZeroBase(t *testing.T) {
+	d := StatDelta{Name: "errors", Base: 0, Other: 5}
+	assert.Equal(t, float64(0), d.Change())
+	assert.Equal(t, float64(5), d.Diff())
+}
+
+func TestMannWhitney(t *testing.T) {
+	ms := func(vs ...int) []time.Duration {
+		d := make([]time.Duration, len(vs))
+		for i, v := range vs {
+			d[i] = time.Duration(v) * time.Millisecond
+		}
+		return d
+	}
+
+	// completely separated samples
+	assert.InDelta(t, 0.0122, MannWhitney(ms(1, 2, 3, 4, 5), ms(6, 7, 8, 9, 10)), 0.0001)
+	assert.InDelta(t, 0.0122, MannWhitney(ms(6, 7, 8, 9, 10), ms(1, 2, 3, 4, 5)), 0.0001)
+
+	// same distribution
+	assert.Equal(t, 1.0, MannWhitney(ms(1, 2, 3, 4, 5), ms(1, 2, 3, 4, 5)))
+	assert.True(t, MannWhitney(ms(1, 3, 5, 7, 9), ms(2, 4, 6, 8, 10)) > 0.5)
+
+	// all ties
+	assert.Equal(t, 1.0, MannWhitney(ms(5, 5, 5), ms(5, 5)))
+
+	assert.Equal(t, 1.0, MannWhitney(nil, ms(1)))
+}
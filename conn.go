@@ -0,0 +1,24 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Conn is a dedicated database connection. The standard library sql.Conn satisfies this interface.
+type Conn interface {
+	Queryable
+	Close() error
+}
+
+// ConnectFunc opens a new dedicated database connection
+type ConnectFunc func(ctx context.Context) (Conn, error)
+
+// SQLConnector returns a ConnectFunc that checks out dedicated connections from db. Released connections go back to
+// the pool of db, call db.SetMaxIdleConns(0) to have them physically closed so every checkout opens (and
+// authenticates) a brand new connection.
+func SQLConnector(db *sql.DB) ConnectFunc {
+	return func(ctx context.Context) (Conn, error) {
+		return db.Conn(ctx)
+	}
+}
@@ -7,10 +7,11 @@ import (
 	"math"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// jobQueueSize is the size of each individual worker queue
+// jobQueueSize is the default size of each individual worker queue
 const jobQueueSize = 20
 
 // Queryable is the interace that wraps the basic database query operations. It is expected the implementation
@@ -44,151 +45,1851 @@ type QueryStats struct {
 	Min          time.Duration // min query time
 	Max          time.Duration // max query time
 	Avg          time.Duration // average query time
+	StdDev       time.Duration // standard deviation of the query time
+	GeoMean      time.Duration // geometric mean query time, less sensitive to the slowest queries than Avg
+	HarmonicMean time.Duration // harmonic mean query time, dominated by the fastest queries
 	Median       time.Duration // median query time
+	P95          time.Duration // 95th percentile query time
+	P99          time.Duration // 99th percentile query time
+	Duration     time.Duration // wall clock duration of the run
+	Errors       int64         // total # queries that failed but were tolerated (see SetMultiNode)
+	Rows         int64         // total # rows read by queries whose rows were fetched (see SetFetch and Query.Expect)
+	Affected     int64         // total # rows affected by queries executed without reading their rows (SELECTs count theirs)
+
+	// NodeErrors breaks Errors down by the data node that raised them (multi-node only)
+	NodeErrors map[string]int64
+
+	// Partitions breaks the query stats down by space partition (see SetPartitioner)
+	Partitions map[string]*QueryStats
+
+	// Phases breaks the query stats down by the schedule phase the queries completed in (see SetSchedule)
+	Phases []*QueryStats
+
+	// Spikes reports the latency recovery after each injected spike (see SetSpikes)
+	Spikes *SpikeStats
+
+	// Outages lists the windows of time the database was unreachable (see SetReconnect)
+	Outages []Outage
+
+	// Tenants breaks the query stats down by the tenant of the worker that executed them (see SetWorkerConns)
+	Tenants map[string]*QueryStats
+
+	// Templates breaks the query stats down by query template (see NormalizeQuery) when the run executed queries of
+	// more than one, e.g. a mixed workload
+	Templates map[string]*QueryStats
+
+	// Labels breaks the query stats down by the label of the queries, queries without one aren't included
+	Labels map[string]*QueryStats
+
+	// Verification reports the results of the queries verified against their expectation (see Query.Expect), nil
+	// if there were none
+	Verification *VerifyStats
+
+	// Cancellations reports the queries cancelled while in flight (see SetCancellation)
+	Cancellations *CancelStats
+
+	// Chaos reports the sessions terminated by chaos injection (see SetChaos)
+	Chaos *ChaosStats
+
+	// Locks reports the lock waits sampled during the run (see SetLockSampling)
+	Locks *LockStats
+
+	// BgWriter reports the checkpoint activity sampled during the run (see SetBgWriterSampling)
+	BgWriter *BgWriterStats
+
+	// Waits reports the wait events of the sessions sampled during the run (see SetWaitSampling)
+	Waits *WaitStats
+
+	// Notify reports the delivery latency of the notifications sent during the run (see SetNotifyLatency)
+	Notify *NotifyStats
+
+	// Interference reports the heavy queries run in the background of the run (see SetInterference)
+	Interference *InterferenceStats
+
+	// DDL reports the DDL operations executed during the run (see SetDDL)
+	DDL *DDLStats
+
+	// Vacuum reports the vacuums of the table vacuumed during the run (see SetVacuum)
+	Vacuum *VacuumStats
+
+	// Backends lists the backend pids of the connections every worker opened by worker id (see SetBackendPIDs)
+	Backends map[int][]int
+
+	// QueueWait reports the time queries waited in their worker's queue before the worker started executing them,
+	// which query times exclude. It grows when queries are dispatched faster than their workers complete them, e.g.
+	// under a rate limit or when the keys of the input are skewed towards a few workers.
+	QueueWait *QueryStats
+
+	// Cold and Warm split the query stats into the first queries of the run, completed while the caches of the
+	// database warm up, and the rest (see SetWarmup)
+	Cold *QueryStats
+	Warm *QueryStats
+
+	// Trimmed reports the query stats without the fastest and slowest queries, Outliers the # queries that are outliers
+	// by the outlier rule (see SetOutliers). They're only set on the stats of the whole run.
+	Trimmed  *QueryStats
+	Outliers int64
+
+	// Fetches reports the time taken by every fetch from the cursors of queries (see FetchCursor), query times include
+	// declaring the cursor and all the fetches
+	Fetches *QueryStats
+
+	// FirstRow reports the time taken until the first row of queries returning rows was read when fetching their rows
+	// (see SetFetch), against the query times to read all of them
+	FirstRow *QueryStats
+
+	// Connects reports the time taken to open connections (see SetConnectionChurn). Query times include the time
+	// taken to connect for queries that opened a new connection.
+	Connects *QueryStats
+
+	// Latencies is the latency of every query of the run in ascending order for analyses beyond the summary above,
+	// or a random sample of them (see SetSampleSize). It's only set on the stats of the whole run and isn't included
+	// in checkpoints or when streaming without sampling (see SetStreaming).
+	Latencies []time.Duration `json:"-"`
+
+	// Histogram is a mergeable summary of the latencies (see Merge), only set on the stats of the whole run
+	Histogram *Histogram `json:",omitempty"`
+}
+
+// result of a single query that was executed
+type result struct {
+	elapsed time.Duration
+	err     error
+	space   string        // space dimension value of the query
+	connect time.Duration // time taken to open a new connection for the query, included in elapsed
+	outage  *outage       // outage the worker rode out before the query succeeded
+	cancel  *cancellation // outcome of cancelling the query if it was selected for cancellation
+	tenant  string        // tenant of the worker that executed the query
+	worker  int           // id of the worker that executed the query
+	key     string        // key the query was scheduled by
+	query   string        // query text executed
+	label   string        // label of the query
+	checked bool          // result of the query was verified against its expectation
+	differs *Mismatch     // result of the query didn't meet its expectation
+	rows    int64         // # rows of the query read
+	wait    time.Duration // time the query waited in the worker's queue, not included in elapsed
+	pid     int           // backend pid of the worker's connection, 0 if not recorded
+
+	firstRow time.Duration   // time taken until the first row of the query was read, 0 if none was
+	fetches  []time.Duration // time taken by every fetch from the cursor of the query
+	affected int64           // # rows affected by the query as reported by the server when its rows weren't read
+}
+
+// job is a query queued on a worker
+type job struct {
+	q        *Query
+	enqueued time.Time
+}
+
+type worker struct {
+	id        int
+	db        Queryable       // the database interface
+	jobs      chan job        // individual worker queue
+	results   chan<- result   // result channel
+	done      chan struct{}   // stop channel worker exits on
+	wg        *sync.WaitGroup // signalled when the worker has exited
+	processed int             // the number of queries processed by this worker
+
+	// dispatch counters read by DispatchStats while the test runs, accessed atomically
+	dispatched int64
+	completed  int64
+
+	mu      sync.Mutex // guards current and since, read by the watchdog while the test runs
+	current *Query     // query being executed, nil when idle
+	since   time.Time  // when current started
+
+	connect ConnectFunc // opens a dedicated connection, a new one every churn queries (if > 0) when set
+	churn   int
+	conn    Conn   // current dedicated connection
+	used    int    // # queries executed on conn
+	tenant  string // tenant the worker belongs to
+
+	// backend pids of the connections opened, recorded when set
+	backends bool
+	pid      int64 // backend pid of conn, 0 if unknown, accessed atomically
+	pids     []int
+
+	reconnect *ReconnectConfig // retry queries that fail due to lost connections when set
+	canceller *canceller       // cancel queries at random when set
+	hooks     *Hooks           // called around every query when set
+	golden    *GoldenRecorder  // records the result set of every distinct query when set
+	plans     *PlanChecker     // checks the plan of the first query of every template when set
+	fetch     FetchMode        // how the rows of queries are read
+	fetchSize int              // # rows fetched from a cursor at a time
+	format    ResultFormat     // format of the rows fetched from cursors
+	protocol  Protocol         // how queries are executed
+	logger    Logger
+}
+
+// execute a single query, retrying with backoff if it failed because the connection was lost
+func (w *worker) execute(ctx context.Context, q *Query) result {
+	r := w.attempt(ctx, q)
+	if w.reconnect == nil || !isConnectionError(r.err) {
+		return r
+	}
+
+	w.logger.Warn("connection lost, reconnecting", w.logAttrs("err", r.err)...)
+	o := outage{start: time.Now(), attempts: 1}
+	backoff := w.reconnect.initialBackoff()
+	for isConnectionError(r.err) {
+		if time.Since(o.start) >= w.reconnect.maxOutage() {
+			w.logger.Error("failed to reconnect", w.logAttrs("outage", time.Since(o.start), "attempts", o.attempts, "err", r.err)...)
+			return r
+		}
+
+		// the connection is likely dead, make sure a new one is opened when churning
+		w.closeConn()
+
+		select {
+		case <-time.After(backoff):
+		case <-w.done:
+			return r
+		case <-ctx.Done():
+			return r
+		}
+
+		if backoff *= 2; backoff > w.reconnect.maxBackoff() {
+			backoff = w.reconnect.maxBackoff()
+		}
+
+		r = w.attempt(ctx, q)
+		if isConnectionError(r.err) {
+			o.attempts++
+		}
+	}
+
+	o.end = time.Now()
+	r.outage = &o
+	w.logger.Info("reconnected", w.logAttrs("outage", o.end.Sub(o.start), "attempts", o.attempts)...)
+	return r
+}
+
+// attempt to execute a single query, calling the hooks around it
+func (w *worker) attempt(ctx context.Context, q *Query) result {
+	if w.hooks == nil {
+		return w.exec(ctx, q)
+	}
+
+	if w.hooks.BeforeQuery != nil {
+		ctx = w.hooks.BeforeQuery(ctx, q)
+	}
+	r := w.exec(ctx, q)
+	if w.hooks.AfterQuery != nil {
+		w.hooks.AfterQuery(ctx, q, r.elapsed, r.err)
+	}
+	if r.err != nil && w.hooks.OnError != nil {
+		w.hooks.OnError(ctx, q, r.err)
+	}
+	return r
+}
+
+// exec executes a single query
+func (w *worker) exec(ctx context.Context, q *Query) result {
+	start := time.Now()
+
+	db := w.db
+	var connect time.Duration
+	if w.connect != nil {
+		if w.conn == nil || (w.churn > 0 && w.used >= w.churn) {
+			w.closeConn()
+
+			conn, err := w.connect(ctx)
+			connect = time.Since(start)
+			if err != nil {
+				return result{elapsed: connect, err: err, space: q.Space, connect: connect, tenant: w.tenant, worker: w.id, key: q.key, query: q.Query, label: q.Label}
+			}
+			w.conn = conn
+			if w.backends {
+				recorded := time.Now()
+				w.recordBackend(ctx)
+				// reading the pid isn't part of the query time
+				start = start.Add(time.Since(recorded))
+			}
+		}
+
+		w.used++
+		db = w.conn
+	}
+
+	run := q
+	if w.protocol == ProtocolSimple && len(q.Args) > 0 {
+		query, err := InlineArgs(q.Query, q.Args)
+		if err != nil {
+			return result{elapsed: time.Since(start), err: err, space: q.Space, connect: connect, tenant: w.tenant, worker: w.id, key: q.key, query: q.Query, label: q.Label}
+		}
+		inlined := *q
+		inlined.Query, inlined.Args = query, nil
+		run = &inlined
+	}
+
+	if e, ok := w.plans.claimQuery(q); ok {
+		explain := time.Now()
+		if err := w.plans.check(ctx, db, run, e); err != nil {
+			w.logger.Warn("failed to check the plan of a query", w.logAttrs("template", e.Template, "err", err)...)
+		}
+		// the EXPLAIN isn't part of the query time
+		start = start.Add(time.Since(explain))
+	}
+
+	if w.canceller != nil && w.canceller.selected() {
+		c, err := w.canceller.execCancelled(ctx, db, run)
+		return result{elapsed: time.Since(start), err: err, space: q.Space, connect: connect, cancel: c, tenant: w.tenant, worker: w.id, key: q.key, query: q.Query, label: q.Label}
+	}
+
+	var mismatch *Mismatch
+	var rows int64
+	var firstRow time.Duration
+	var fetches []time.Duration
+	var affected int64
+	var err error
+	if q.Expect != nil {
+		rows, mismatch, err = verifyQuery(ctx, db, run)
+		if mismatch != nil {
+			mismatch.Query, mismatch.Args = q.Query, q.Args
+		}
+	} else if key, args, ok := w.golden.claimQuery(q); ok {
+		err = w.golden.record(ctx, db, q, key, args)
+	} else if w.fetch == FetchCursor {
+		rows, firstRow, fetches, err = cursorQuery(ctx, db, run, w.fetchSize, w.format)
+	} else if w.fetch != "" && w.fetch != FetchNone {
+		rows, firstRow, err = fetchQuery(ctx, db, run, w.fetch)
+	} else {
+		var res sql.Result
+		res, err = db.ExecContext(ctx, run.Query, run.Args...)
+		if err == nil && res != nil {
+			// not every statement reports the rows it affected
+			affected, _ = res.RowsAffected()
+		}
+	}
+	elapsed := time.Since(start)
+
+	return result{elapsed: elapsed, err: err, space: q.Space, connect: connect, tenant: w.tenant, worker: w.id, key: q.key, query: q.Query, label: q.Label,
+		checked: q.Expect != nil && err == nil, differs: mismatch, rows: rows, firstRow: firstRow, fetches: fetches,
+		affected: affected}
+}
+
+// closeConn closes the current connection when churning
+func (w *worker) closeConn() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+		w.used = 0
+		atomic.StoreInt64(&w.pid, 0)
+	}
+}
+
+// recordBackend records the backend pid of the worker's new connection
+func (w *worker) recordBackend(ctx context.Context) {
+	var pid int
+	if err := w.conn.QueryRowContext(ctx, "SELECT pg_backend_pid();").Scan(&pid); err != nil {
+		w.logger.Warn("failed to read the backend pid of a connection", "worker", w.id, "err", err)
+		return
+	}
+	atomic.StoreInt64(&w.pid, int64(pid))
+	w.pids = append(w.pids, pid)
+	w.logger.Debug("worker connected", "worker", w.id, "pid", pid)
+}
+
+// logAttrs returns the attributes of a log of the worker, identified by its id and backend pid if recorded
+func (w *worker) logAttrs(args ...interface{}) []interface{} {
+	attrs := []interface{}{"worker", w.id}
+	if pid := atomic.LoadInt64(&w.pid); pid != 0 {
+		attrs = append(attrs, "pid", pid)
+	}
+	return append(attrs, args...)
+}
+
+// setCurrent records the query the worker is executing, nil when done
+func (w *worker) setCurrent(q *Query) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.current = q
+	w.since = time.Now()
+}
+
+// status returns the query the worker is executing and since when, nil if idle
+func (w *worker) status() (*Query, time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current, w.since
+}
+
+func (w *worker) run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer w.wg.Done()
+	defer w.closeConn()
+
+	// a hard exit cancels the query in flight rather than waiting for it, which may never return
+	go func() {
+		select {
+		case <-w.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case j, ok := <-w.jobs:
+			if !ok {
+				// no more jobs will be sent, exit normally
+				return
+			}
+
+			// execute a single query and post the results
+			wait := time.Since(j.enqueued)
+			w.setCurrent(j.q)
+			r := w.execute(ctx, j.q)
+			w.setCurrent(nil)
+			r.wait = wait
+			r.pid = int(atomic.LoadInt64(&w.pid))
+			atomic.AddInt64(&w.completed, 1)
+			w.results <- r
+
+			w.processed++
+		case <-w.done:
+			// hard exit
+			return
+		}
+	}
+}
+
+// Controller executes test runs against a database with a pool of workers, one run at a time
+type Controller struct {
+	poolSize         int       // worker pool size
+	queueSize        int       // size of each individual worker queue
+	workers          []*worker // worker pool
+	scheduler        Scheduler // picks the worker of each query
+	completedQueries chan result
+	inflight         int                     // # queries dispatched that have not completed yet
+	multiNode        bool                    // tolerate errors raised by individual data nodes
+	partitioner      Partitioner             // break stats down by space partition when set
+	limiter          *rateLimiter            // open loop dispatch at a target rate when set
+	duration         time.Duration           // stop dispatching after the run has lasted this long when set
+	schedule         Schedule                // load profile to follow when set
+	concurrency      int                     // max # outstanding queries, 0 for the default of the dispatch mode
+	checkpointEvery  time.Duration           // how often to checkpoint the stats of the run in progress
+	checkpoint       CheckpointFunc          // called with every checkpoint when set
+	bgWriter         *BgWriterConfig         // sample checkpoint activity when set
+	interference     *InterferenceConfig     // run heavy queries in the background when set
+	ddl              []DDLOperation          // execute DDL operations at points of the run when set
+	vacuum           *VacuumConfig           // vacuum a table during the run when set
+	spikes           *SpikeConfig            // inject spikes of queries when set
+	connect          ConnectFunc             // open a new connection every churn queries when set
+	workerConns      func(id int) WorkerConn // dedicated per worker connections when set
+	churn            int
+	reconnect        *ReconnectConfig // ride out lost connections when set
+	chaos            *ChaosConfig     // terminate sessions at random when set
+	locks            *LockConfig      // sample lock waits when set
+	waits            *WaitConfig      // sample wait events when set
+	notify           *NotifyConfig    // time the delivery of notifications when set
+	cancel           *CancelConfig    // cancel queries at random when set
+	onResult         ResultFunc       // called with the result of every query when set
+	onDispatch       DispatchFunc     // called with every query dispatched when set
+	runStart         time.Time        // when the current run started
+	total            *QueryStats      // stats accumulated across the runs of the controller
+	hooks            *Hooks           // called around every query when set
+	golden           *GoldenRecorder  // record the result set of every distinct query when set
+	plans            *PlanChecker     // check the plan of the first query of every template when set
+	fetch            FetchMode        // how the rows of queries are read
+	fetchSize        int              // # rows fetched from a cursor at a time
+	format           ResultFormat     // format of the rows fetched from cursors
+	protocol         Protocol         // how queries are executed
+	logger           Logger
+	watchdog         *WatchdogConfig // detect stalled runs when set
+	streaming        bool            // summarize latencies in histograms instead of keeping every one
+	backends         bool            // record the backend pid of the connection of every worker
+	sampleSize       int             // keep a random sample of this many latencies instead of every one if > 0
+	seed             int64           // seed of the randomness of runs, based on the time if 0
+	warmup           float64         // fraction of the queries of a run reported as cold, see SetWarmup
+	outliers         *OutlierConfig
+	recent           *recentWindow // results of the last seconds of the run, see RecentStats
+	logInterval      time.Duration // how often to log a summary of the run in progress, see SetLogInterval
+
+	poolMu sync.Mutex // guards workers for QueueDepths, which may be called from other goroutines
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// Option configures a Controller when it's created, see NewController
+type Option func(c *Controller)
+
+// WithPoolSize sets the # of workers executing queries concurrently, 1 by default
+func WithPoolSize(n int) Option {
+	return func(c *Controller) {
+		c.poolSize = n
+	}
+}
+
+// WithQueueSize sets the # of queries each worker can have queued, 20 by default. Queries only queue up when
+// dispatched at a rate (see WithRateLimit) faster than the workers complete them.
+func WithQueueSize(n int) Option {
+	return func(c *Controller) {
+		c.queueSize = n
+	}
+}
+
+// WithScheduler sets the scheduler picking the worker that executes each query, NewKeyScheduler by default
+func WithScheduler(s Scheduler) Option {
+	return func(c *Controller) {
+		c.scheduler = s
+	}
+}
+
+// WithRateLimit dispatches queries at a fixed rate (queries per second), see SetRateLimit
+func WithRateLimit(qps float64) Option {
+	return func(c *Controller) {
+		c.SetRateLimit(qps)
+	}
+}
+
+// WithReporter passes the result of every query to fn as it completes, see SetResultFunc
+func WithReporter(fn ResultFunc) Option {
+	return func(c *Controller) {
+		c.SetResultFunc(fn)
+	}
+}
+
+// WithHooks sets the hooks called around every query, see SetHooks
+func WithHooks(h Hooks) Option {
+	return func(c *Controller) {
+		c.SetHooks(h)
+	}
+}
+
+// WithGoldenRecorder records the result set of every distinct query, see SetGoldenRecorder
+func WithGoldenRecorder(g *GoldenRecorder) Option {
+	return func(c *Controller) {
+		c.SetGoldenRecorder(g)
+	}
+}
+
+// WithLogger sets the logger the controller logs through, see SetLogger
+func WithLogger(l Logger) Option {
+	return func(c *Controller) {
+		c.SetLogger(l)
+	}
+}
+
+// WithFetch sets how workers read the rows returned by queries, see SetFetch
+func WithFetch(mode FetchMode) Option {
+	return func(c *Controller) {
+		c.SetFetch(mode)
+	}
+}
+
+// WithFetchSize sets the # rows fetched from the cursor of a query at a time, see SetFetchSize
+func WithFetchSize(n int) Option {
+	return func(c *Controller) {
+		c.SetFetchSize(n)
+	}
+}
+
+// WithResultFormat sets the format of the rows fetched from cursors, see SetResultFormat
+func WithResultFormat(f ResultFormat) Option {
+	return func(c *Controller) {
+		c.SetResultFormat(f)
+	}
+}
+
+// WithProtocol sets the protocol queries are executed with, see SetProtocol
+func WithProtocol(p Protocol) Option {
+	return func(c *Controller) {
+		c.SetProtocol(p)
+	}
+}
+
+// WithStreaming summarizes the latencies in histograms instead of keeping every one, see SetStreaming
+func WithStreaming() Option {
+	return func(c *Controller) {
+		c.SetStreaming(true)
+	}
+}
+
+// WithSampleSize keeps a random sample of n latencies instead of every one, see SetSampleSize
+func WithSampleSize(n int) Option {
+	return func(c *Controller) {
+		c.SetSampleSize(n)
+	}
+}
+
+// WithWarmup reports the first fraction of the queries of a run separately, see SetWarmup
+func WithWarmup(fraction float64) Option {
+	return func(c *Controller) {
+		c.SetWarmup(fraction)
+	}
+}
+
+// WithOutliers reports trimmed stats and counts outliers, see SetOutliers
+func WithOutliers(cfg OutlierConfig) Option {
+	return func(c *Controller) {
+		c.SetOutliers(cfg)
+	}
+}
+
+// WithLogInterval logs a summary line every interval of a run, see SetLogInterval
+func WithLogInterval(interval time.Duration) Option {
+	return func(c *Controller) {
+		c.SetLogInterval(interval)
+	}
+}
+
+// WithWatchdog detects stalled runs, see SetWatchdog
+func WithWatchdog(cfg WatchdogConfig) Option {
+	return func(c *Controller) {
+		c.SetWatchdog(cfg)
+	}
+}
+
+// WithSeed seeds the randomness of runs, see SetSeed
+func WithSeed(seed int64) Option {
+	return func(c *Controller) {
+		c.SetSeed(seed)
+	}
+}
+
+// NewController initializes a test controller configured by the given options
+func NewController(opts ...Option) *Controller {
+	c := &Controller{
+		poolSize:  1,
+		queueSize: jobQueueSize,
+		logger:    nopLogger{},
+		recent:    &recentWindow{},
+		fetchSize: defaultFetchSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.poolSize <= 0 {
+		c.poolSize = 1
+	}
+	if c.queueSize <= 0 {
+		c.queueSize = 1
+	}
+	if c.scheduler == nil {
+		c.scheduler = NewKeyScheduler()
+	}
+
+	c.reset()
+	return c
+}
+
+// reset prepares the controller for a run, waiting for the workers of a previous run that failed to exit first
+func (c *Controller) reset() {
+	c.wg.Wait()
+
+	c.poolMu.Lock()
+	c.workers = make([]*worker, 0, c.poolSize)
+	c.poolMu.Unlock()
+
+	c.quit = make(chan struct{})
+	// result queue needs to be as large as the number of *possible* outstanding jobs to avoid deadlock
+	c.completedQueries = make(chan result, c.queueSize*c.poolSize)
+	c.inflight = 0
+	c.concurrency = 0
+}
+
+// get the next available worker for the given query
+func (c *Controller) getWorker(q *Query) *worker {
+	return c.workers[c.scheduler.Worker(q, len(c.workers))]
+}
+
+func (c *Controller) initPool(db Queryable) {
+	// start the workers
+	for i := 0; i < c.poolSize; i++ {
+		w := &worker{
+			id:        i,
+			db:        db,
+			jobs:      make(chan job, c.queueSize),
+			results:   c.completedQueries,
+			done:      c.quit,
+			wg:        &c.wg,
+			connect:   c.connect,
+			churn:     c.churn,
+			reconnect: c.reconnect,
+			hooks:     c.hooks,
+			golden:    c.golden,
+			plans:     c.plans,
+			fetch:     c.fetch,
+			fetchSize: c.fetchSize,
+			format:    c.format,
+			protocol:  c.protocol,
+			logger:    c.logger,
+		}
+		if c.workerConns != nil {
+			wc := c.workerConns(i)
+			w.connect = wc.Connect
+			w.tenant = wc.Tenant
+		}
+		if c.backends {
+			// the pid of a worker is only meaningful for a connection of its own
+			if sdb, ok := db.(*sql.DB); ok && w.connect == nil {
+				w.connect = SQLConnector(sdb)
+			}
+			w.backends = w.connect != nil
+		}
+		if c.cancel != nil {
+			w.canceller = newCanceller(*c.cancel, c.randSeed(int64(i)))
+		}
+
+		c.poolMu.Lock()
+		c.workers = append(c.workers, w)
+		c.poolMu.Unlock()
+		go w.run()
+	}
+
+	c.wg.Add(len(c.workers))
+}
+
+// dispatch queues the next query from the generator on the correct worker. io.EOF is returned when the generator
+// is exhausted.
+func (c *Controller) dispatch(g QueryGenerator) error {
+	query, err := g.Next()
+	if err != nil {
+		return err
+	}
+
+	worker := c.getWorker(query)
+	if c.onDispatch != nil {
+		c.onDispatch(Dispatch{At: time.Since(c.runStart), Worker: worker.id, Query: query})
+	}
+
+	// FIXME - there is potential here that if the input query's are skewed to a single key we may starve the other workers when this worker's job queue is full
+	//         this is dependent on the input queries generated and how clustered the queries are by a particular key are
+	atomic.AddInt64(&worker.dispatched, 1)
+	worker.jobs <- job{q: query, enqueued: time.Now()}
+	c.inflight++
+
+	return nil
+}
+
+// maxInflight is the number of outstanding queries that can be dispatched without risking deadlock, i.e. the
+// capacity of the result queue
+func (c *Controller) maxInflight() int {
+	return cap(c.completedQueries)
+}
+
+// maxOutstanding is the number of queries allowed to be outstanding at once. Unless limited by the current schedule
+// phase this is one per worker when dispatching as fast as possible or as many as is safe when rate limited.
+func (c *Controller) maxOutstanding() int {
+	if c.concurrency > 0 && c.concurrency < c.maxInflight() {
+		return c.concurrency
+	}
+
+	if c.limiter == nil {
+		return c.poolSize
+	}
+
+	return c.maxInflight()
+}
+
+// fill dispatches queries until the max allowed are outstanding
+func (c *Controller) fill(g QueryGenerator) error {
+	for c.inflight < c.maxOutstanding() {
+		if err := c.dispatch(g); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyPhase switches the dispatch mode to that of the schedule phase
+func (c *Controller) applyPhase(p Phase, now time.Time) {
+	c.concurrency = p.Workers
+	if p.Rate <= 0 {
+		c.limiter = nil
+		return
+	}
+
+	if c.limiter == nil {
+		c.limiter = newRateLimiter(p.Rate)
+	}
+	c.limiter.setRate(p.Rate, now)
+}
+
+func (c *Controller) seedWorkers(g QueryGenerator) error {
+	// ensure every worker starts off with 1 job (or as many as allowed) or until generator is exhausted
+	for i := 0; i < c.maxOutstanding(); i++ {
+		if err := c.dispatch(g); err != nil {
+			if err == io.EOF && i > 0 {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// closeQueues closes all of the individual worker job queus signalling them to finish
+// what they are doing and exit normally
+func (c *Controller) closeQueues() {
+	for _, w := range c.workers {
+		close(w.jobs)
+	}
+}
+
+// SetMultiNode configures the controller for benchmarking a multi-node TimescaleDB access node. When enabled,
+// errors that were raised by an individual data node are counted in the error stats instead of aborting the run.
+func (c *Controller) SetMultiNode(enabled bool) {
+	c.multiNode = enabled
+}
+
+// SetPartitioner configures the controller to break the latency stats down by the space partition each query
+// targets as determined by the given partitioner, revealing any imbalance between partitions.
+func (c *Controller) SetPartitioner(p Partitioner) {
+	c.partitioner = p
+}
+
+// SetScheduler replaces the scheduler picking the worker that executes each query (see WithScheduler), e.g. with a
+// NewBalancedScheduler of the keys of the input counted before the run.
+func (c *Controller) SetScheduler(s Scheduler) {
+	c.scheduler = s
+}
+
+// SetRateLimit configures the controller to dispatch queries at a fixed rate (queries per second) rather than
+// as fast as the workers complete them. A rate <= 0 removes the limit.
+//
+// Dispatches that come due while the maximum number of queries are already outstanding are dropped, in which case
+// the achieved throughput will be lower than the target rate.
+func (c *Controller) SetRateLimit(qps float64) {
+	if qps <= 0 {
+		c.limiter = nil
+		return
+	}
+
+	c.limiter = newRateLimiter(qps)
+}
+
+// SetLoadShape configures the controller to dispatch queries at a rate that varies over the run according to the
+// load shape, e.g. periodic bursts or a sine wave. It replaces any rate limit set by SetRateLimit and vice versa.
+func (c *Controller) SetLoadShape(shape LoadShape) {
+	if shape == nil {
+		c.limiter = nil
+		return
+	}
+
+	c.limiter = newShapedLimiter(shape)
+}
+
+// SetDuration limits the run to the given duration after which no more queries are dispatched. Queries already
+// dispatched are allowed to complete. A duration <= 0 runs until the generator is exhausted.
+func (c *Controller) SetDuration(d time.Duration) {
+	c.duration = d
+}
+
+// SetSchedule configures the controller to follow the load profile phase by phase, changing the target rate and
+// concurrency as each phase begins. The run ends when the schedule completes (or the generator is exhausted) and
+// the stats are additionally broken down by phase. The worker pool is grown to the largest worker count of any phase.
+func (c *Controller) SetSchedule(s Schedule) {
+	c.schedule = s
+	if n := s.maxWorkers(); n > c.poolSize {
+		c.poolSize = n
+		c.reset()
+	}
+}
+
+// SetCheckpoint configures the controller to take a snapshot of the stats every interval while the run is in
+// progress and pass it to fn, e.g. to persist the progress of long running soak tests. Snapshots are summarized from
+// the latency histograms, so their percentiles have the histogram precision. They are taken on the dispatch
+// goroutine, fn should return quickly.
+func (c *Controller) SetCheckpoint(interval time.Duration, fn CheckpointFunc) {
+	c.checkpointEvery = interval
+	c.checkpoint = fn
+}
+
+// SetSpikes configures the controller to inject spikes of queries on top of the regular load, typically a steady
+// baseline rate set with SetRateLimit. The stats report how long it took the p99 latency to recover to the baseline
+// after every spike.
+func (c *Controller) SetSpikes(cfg SpikeConfig) {
+	c.spikes = &cfg
+}
+
+// SetConnectionChurn configures every worker to open a new connection with connect for every n queries it
+// executes rather than using the shared database handle, to benchmark connection establishment cost. The time
+// taken to connect is included in the query times and additionally reported on its own.
+func (c *Controller) SetConnectionChurn(connect ConnectFunc, n int) {
+	if n <= 0 {
+		n = 1
+	}
+
+	c.connect = connect
+	c.churn = n
+}
+
+// WorkerConn configures the dedicated connection of a single worker
+type WorkerConn struct {
+	Connect ConnectFunc // opens the worker's connection
+	Tenant  string      // tenant the worker's queries are reported under, empty for none
+}
+
+// SetWorkerConns gives every worker a dedicated connection opened as configured by conns for the worker id
+// (0 to pool size - 1), e.g. to run workers as different database roles. Queries of workers that belong to a
+// tenant are additionally reported per tenant. Combined with SetConnectionChurn the connection is reopened every
+// n queries.
+func (c *Controller) SetWorkerConns(conns func(id int) WorkerConn) {
+	c.workerConns = conns
+}
+
+// SetBackendPIDs configures every worker to record the backend pid (pg_backend_pid()) of the connections it opens,
+// included in its logs, the results of its queries and the stats of the run, to correlate the workers with the
+// server logs and pg_stat_activity. Workers without a connection of their own (see SetWorkerConns) check one out
+// from the database of the run for the duration of the run.
+func (c *Controller) SetBackendPIDs(enabled bool) {
+	c.backends = enabled
+}
+
+// SetReconnect configures workers to retry queries that failed because the connection to the database was lost
+// (e.g. a server restart or failover) with exponential backoff instead of aborting the run. The windows of time the
+// database was unreachable are reported as outages.
+func (c *Controller) SetReconnect(cfg ReconnectConfig) {
+	c.reconnect = &cfg
+}
+
+// SetChaos configures the controller to terminate the benchmark's own database sessions at random during the run,
+// typically combined with SetReconnect to validate and measure the impact of reconnecting.
+func (c *Controller) SetChaos(cfg ChaosConfig) {
+	c.chaos = &cfg
+}
+
+// SetLockSampling configures the controller to sample the sessions waiting for locks during the run, reported by lock
+// type and correlated with the latency timeline of the run (see Report.LockCorrelation) to explain latency spikes of
+// mixed or write heavy workloads
+func (c *Controller) SetLockSampling(cfg LockConfig) {
+	c.locks = &cfg
+}
+
+// SetBgWriterSampling configures the controller to sample the server's checkpointer during the run, marking the
+// seconds of the timeline of the run a checkpoint was in progress during (see Report.BgWriterCorrelation) to
+// explain periodic latency spikes
+func (c *Controller) SetBgWriterSampling(cfg BgWriterConfig) {
+	c.bgWriter = &cfg
+}
+
+// SetWaitSampling configures the controller to sample the wait events of the sessions executing queries during the
+// run, breaking the server time of the run down by what it was spent on (IO, LWLock, Lock, Client, CPU, ...) rather
+// than only reporting the time elapsed on the client
+func (c *Controller) SetWaitSampling(cfg WaitConfig) {
+	c.waits = &cfg
+}
+
+// SetNotifyLatency configures the controller to send notifications (NOTIFY) at an interval during the run and time
+// their delivery to a listener (LISTEN) on a connection of its own, to measure how the load of the run affects
+// notifications, e.g. those used for cache invalidation. The notifications in flight at the end of the run are waited
+// for up to a timeout.
+func (c *Controller) SetNotifyLatency(cfg NotifyConfig) {
+	c.notify = &cfg
+}
+
+// SetInterference configures the controller to run heavy queries, e.g. long analytical queries, on connections of
+// their own in the background for the duration of the run, to measure how they interfere with the workload. The heavy
+// queries still running at the end of the run are cancelled and they aren't part of the stats of the run.
+func (c *Controller) SetInterference(cfg InterferenceConfig) {
+	c.interference = &cfg
+}
+
+// SetDDL configures the controller to execute DDL operations, e.g. creating an index concurrently or compressing a
+// chunk, at their offsets of the run, one at a time on a connection of their own, to validate that online migrations
+// don't disrupt the workload (see Report.DDLImpact). The operation still executing at the end of the run is
+// cancelled and those not reached are skipped.
+func (c *Controller) SetDDL(ops []DDLOperation) {
+	c.ddl = ops
+}
+
+// SetVacuum configures the controller to vacuum a table during the run, either with VACUUM over and over on a
+// connection of its own or by making autovacuum process it aggressively for the duration of the run, marking the
+// seconds of the timeline of the run the table was being vacuumed during (see Report.VacuumCorrelation) to quantify
+// the impact of vacuum on the workload and tune maintenance settings
+func (c *Controller) SetVacuum(cfg VacuumConfig) {
+	c.vacuum = &cfg
+}
+
+// SetCancellation configures workers to cancel a fraction of the queries they execute (through context
+// cancellation) a fixed delay after starting them, to test how quickly the server releases cancelled statements
+// under load. Cancelled queries are reported separately and are not included in the query stats.
+func (c *Controller) SetCancellation(cfg CancelConfig) {
+	c.cancel = &cfg
+}
+
+// QueryResult is the outcome of a single query passed to a ResultFunc
+type QueryResult struct {
+	Latency   time.Duration // time taken to execute the query
+	Err       error         // error the query failed with, if any
+	Cancelled bool          // query was cancelled while in flight (see SetCancellation)
+	Space     string        // space dimension value of the query
+	Tenant    string        // tenant of the worker that executed the query
+	Label     string        // label of the query
+	Worker    int           // id of the worker that executed the query
+	Backend   int           // backend pid of the worker's connection, 0 if not recorded (see SetBackendPIDs)
+	Connect   time.Duration // time taken to open a new connection for the query, included in Latency
+	Wait      time.Duration // time the query waited in the worker's queue, not included in Latency
+	Completed time.Time     // when the result was collected
+}
+
+// ResultFunc is called with the result of every query as the run progresses
+type ResultFunc func(r QueryResult)
+
+// SetResultFunc configures the controller to pass the result of every query to fn as it completes, e.g. to stream
+// results elsewhere. fn is called on the dispatch goroutine and should return quickly.
+func (c *Controller) SetResultFunc(fn ResultFunc) {
+	c.onResult = fn
+}
+
+// Hooks are called by the worker executing a query around every attempt to execute it, e.g. to inject tracing,
+// logging or custom metrics. Any of them may be nil. They're called concurrently by the workers outside of the
+// latency measured but hold up the worker's next query, they should be safe for concurrent use and return quickly.
+type Hooks struct {
+
+	// BeforeQuery is called before the query is executed, the query is executed with the context it returns, e.g. to
+	// carry a tracing span
+	BeforeQuery func(ctx context.Context, q *Query) context.Context
+
+	// AfterQuery is called once the query completed with its latency and the error it failed with, if any
+	AfterQuery func(ctx context.Context, q *Query, latency time.Duration, err error)
+
+	// OnError is called after AfterQuery when the query failed, including failures to connect for it
+	OnError func(ctx context.Context, q *Query, err error)
+}
+
+// SetDispatchFunc configures the controller to pass every query to fn as it's dispatched, e.g. to record the dispatch
+// schedule of the run (see Recorder). fn is called on the dispatch goroutine and should return quickly.
+func (c *Controller) SetDispatchFunc(fn DispatchFunc) {
+	c.onDispatch = fn
+}
+
+// SetLogger configures the controller to log the progress of runs and the connections lost and recovered by workers
+// through l, nothing is logged by default
+func (c *Controller) SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	c.logger = l
+}
+
+// SetFetch configures how workers read the rows returned by queries, FetchNone by default. Query times include
+// reading the rows, and decoding them when scanning, so they reflect what applications pay to read the results.
+// Queries verified against their expected result (see Query.Expect) always read their rows.
+func (c *Controller) SetFetch(mode FetchMode) {
+	c.fetch = mode
+}
+
+// SetFetchSize configures the # rows fetched from the cursor of a query at a time (see FetchCursor), 1000 by default
+func (c *Controller) SetFetchSize(n int) {
+	if n <= 0 {
+		n = defaultFetchSize
+	}
+	c.fetchSize = n
+}
+
+// SetResultFormat configures the format the rows fetched from the cursors of queries are transferred in (see
+// FetchCursor), FormatText by default, to compare the cost of decoding and transferring text and binary results
+func (c *Controller) SetResultFormat(f ResultFormat) {
+	c.format = f
+}
+
+// SetProtocol configures the protocol queries are executed with, ProtocolExtended by default. With ProtocolSimple the
+// arguments of queries are inlined (see InlineArgs) so query times include the cost of planning the queries with
+// literals every time, as they are through poolers that don't support prepared statements.
+func (c *Controller) SetProtocol(p Protocol) {
+	c.protocol = p
+}
+
+// SetStreaming configures the controller to summarize the latencies of a run in histograms as they complete instead
+// of keeping every one, bounding the memory used by runs of hundreds of millions of queries. The min, max and average
+// are exact but the percentiles and standard deviation are estimated within the histogram precision, and the raw
+// latencies aren't reported. The breakdown by key is limited to the first 10000 keys, the latencies of the rest are
+// reported together under OtherKeys.
+func (c *Controller) SetStreaming(enabled bool) {
+	c.streaming = enabled
+}
+
+// SetSampleSize configures the controller to keep a uniform random sample of at most n latencies of a run (reservoir
+// sampling) for distribution plots and comparisons when keeping every one is too expensive. The stats are summarized
+// in histograms as when streaming (see SetStreaming), the sample is reported as the latencies of the run. n <= 0 keeps
+// every latency unless streaming.
+func (c *Controller) SetSampleSize(n int) {
+	c.sampleSize = n
+}
+
+// SetWarmup configures the controller to report the first fraction (0-1) of the queries of a run, completed while the
+// caches of the database warm up, separately from the rest as the Cold and Warm stats so the warm-up effect isn't
+// smeared across the percentiles of the run. When streaming the split is made at the end of the second of the run the
+// fraction was reached in. fraction <= 0 disables the split.
+func (c *Controller) SetWarmup(fraction float64) {
+	c.warmup = fraction
+}
+
+// SetOutliers configures the controller to report the stats of a run without its fastest and slowest queries as the
+// Trimmed stats, if cfg.Trim > 0, and to count the queries that are outliers by cfg.Rule. The latencies are needed to
+// do either, neither is done when streaming (see SetStreaming).
+func (c *Controller) SetOutliers(cfg OutlierConfig) {
+	c.outliers = &cfg
+}
+
+// SetLogInterval configures the controller to log a summary line of the queries completed within every interval of a
+// run, like pgbench -P, for log collectors to capture: the # completed, errors, throughput and p50, p95 and p99 within
+// the histogram precision. interval <= 0 disables it.
+func (c *Controller) SetLogInterval(interval time.Duration) {
+	c.logInterval = interval
+}
+
+// SetWatchdog configures the controller to detect runs that stalled, no query having completed for cfg.Stall while
+// queries are in flight, instead of hanging silently. The query every busy worker is stuck on and the benchmark's
+// sessions on the server are logged (see SetLogger) once per stall, and the run is aborted with ErrStalled if
+// configured. Workers cancel the queries they're stuck on when the run is aborted.
+func (c *Controller) SetWatchdog(cfg WatchdogConfig) {
+	c.watchdog = &cfg
+}
+
+// SetSeed seeds every source of randomness of the runs, the queries selected for cancellation, the sessions chaos
+// terminates and the latencies sampled, so runs with the same seed and input make the same choices. Seed 0 seeds them
+// from the current time.
+func (c *Controller) SetSeed(seed int64) {
+	c.seed = seed
+}
+
+// randSeed returns the seed of a source of randomness of a run, distinct sources get distinct offsets
+func (c *Controller) randSeed(offset int64) int64 {
+	seed := c.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return seed + offset
+}
+
+// SetGoldenRecorder configures the controller to record the result set of every distinct query with g, the first
+// time it's executed, for later runs to be verified against (see ReadGolden)
+func (c *Controller) SetGoldenRecorder(g *GoldenRecorder) {
+	c.golden = g
+}
+
+// SetPlanChecker configures the controller to check the plan of the first query of every query template with an
+// expectation with pc before executing it. The plans are explained outside of the query times, their violations are
+// reported by pc.
+func (c *Controller) SetPlanChecker(pc *PlanChecker) {
+	c.plans = pc
+}
+
+// SetHooks configures the controller to call the hooks around every query. Queries retried after losing the
+// connection (see SetReconnect) call them for every attempt.
+func (c *Controller) SetHooks(h Hooks) {
+	c.hooks = &h
+}
+
+// QueueDepths returns the # of queries waiting in each worker's queue, by worker id, to monitor a run in progress.
+// It's safe to call from other goroutines while the test runs.
+func (c *Controller) QueueDepths() []int {
+	c.poolMu.Lock()
+	defer c.poolMu.Unlock()
+
+	depths := make([]int, len(c.workers))
+	for i, w := range c.workers {
+		depths[i] = len(w.jobs)
+	}
+	return depths
+}
+
+// WorkerStats is the dispatch state of a single worker, see DispatchStats
+type WorkerStats struct {
+	ID         int   `json:"id"`
+	QueueDepth int   `json:"queue_depth"` // # queries waiting in the worker's queue
+	Inflight   int64 `json:"inflight"`    // # queries dispatched to the worker that haven't completed
+	Dispatched int64 `json:"dispatched"`  // # queries dispatched to the worker
+	Completed  int64 `json:"completed"`   // # queries the worker completed
+	PID        int64 `json:"pid"`         // backend pid of the worker's connection, 0 if not recorded
+}
+
+// DispatchStats is the dispatch state of a test run in progress, see Controller.DispatchStats
+type DispatchStats struct {
+	Dispatched int64         `json:"dispatched"`
+	Completed  int64         `json:"completed"`
+	Inflight   int64         `json:"inflight"`
+	Workers    []WorkerStats `json:"workers"`
+}
+
+// DispatchStats returns the # of queries dispatched, completed and in flight in total and by worker, e.g. to spot
+// workers the scheduler overloads while the test runs. It's safe to call from other goroutines while the test runs.
+func (c *Controller) DispatchStats() DispatchStats {
+	c.poolMu.Lock()
+	defer c.poolMu.Unlock()
+
+	ds := DispatchStats{Workers: make([]WorkerStats, len(c.workers))}
+	for i, w := range c.workers {
+		// read completed first so a query completing in between can't make inflight negative
+		completed := atomic.LoadInt64(&w.completed)
+		dispatched := atomic.LoadInt64(&w.dispatched)
+		ws := WorkerStats{
+			ID:         w.id,
+			QueueDepth: len(w.jobs),
+			Inflight:   dispatched - completed,
+			Dispatched: dispatched,
+			Completed:  completed,
+			PID:        atomic.LoadInt64(&w.pid),
+		}
+		ds.Workers[i] = ws
+		ds.Dispatched += ws.Dispatched
+		ds.Completed += ws.Completed
+		ds.Inflight += ws.Inflight
+	}
+	return ds
+}
+
+// collector accumulates the results of a single test run
+type collector struct {
+	c          *Controller
+	latencies  []time.Duration // not kept when streaming
+	hist       *Histogram      // latencies of the run
+	interval   *Histogram      // latencies since the previous checkpoint when checkpointing
+	sample     *reservoir      // random sample of the latencies when sampling
+	waits      *samples        // time queries waited in the worker queues
+	usage      *usageSampler   // resource usage of the process
+	nodeErrors map[string]int64
+	bySpace    map[string]*samples
+	phases     []phaseResults // results by schedule phase
+	seq        int            // previous checkpoint sequence number
+	prev       time.Time      // when the previous checkpoint was taken
+	start      time.Time      // when the run started
+	timeline   []*samples     // latencies by spike window they completed in
+	spikes     []Spike        // spikes injected
+	connects   *samples       // time taken to open new connections
+	fetches    *samples       // time taken by cursor fetches
+	firstRows  *samples       // time taken until the first row of queries was read
+	outages    []outage       // outages observed by the workers
+	logged     *Histogram     // latencies since the previous interval summary, see SetLogInterval
+	loggedErrs int64          // errors since the previous interval summary
+	loggedAt   time.Time      // when the previous interval summary was logged
+	cancels    CancelStats    // cancelled queries
+	cancelled  *samples       // cancellation latencies
+	byTenant   map[string]*samples
+	byTemplate map[string]*samples
+	byQuery    map[string]*samples // latencies of the template of every query text seen, see templateSamples
+	byLabel    map[string]*samples
+	verify     VerifyStats
+	rows       int64 // # rows read
+	affected   int64 // # rows affected
+
+	// breakdowns of the report
+	byKey       map[string]*Histogram
+	errorCounts map[string]int64
+	buckets     []timelineResults // results by second of the run they completed in
+}
+
+// phaseResults are the results of a single schedule phase
+type phaseResults struct {
+	start     time.Time
+	end       time.Time
+	latencies *samples
 }
 
-// result of a single query that was executed
-type result struct {
-	elapsed time.Duration
-	err     error
+// samples are the latencies of a breakdown of the run, summarized in a histogram when streaming
+type samples struct {
+	latencies []time.Duration
+	hist      *Histogram
 }
 
-type worker struct {
-	id        int
-	db        Queryable       // the database interface
-	jobs      chan *Query     // individual worker queue
-	results   chan<- result   // result channel
-	done      chan struct{}   // stop channel worker exits on
-	wg        *sync.WaitGroup // signalled when the worker has exited
-	processed int             // the number of queries processed by this worker
+func newSamples(streaming bool) *samples {
+	if streaming {
+		return &samples{hist: NewHistogram(nil)}
+	}
+	return &samples{}
 }
 
-func (w *worker) run() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	defer w.wg.Done()
+func (s *samples) record(d time.Duration) {
+	if s.hist != nil {
+		s.hist.Record(d)
+		return
+	}
+	s.latencies = append(s.latencies, d)
+}
 
-	for {
-		select {
-		case q, ok := <-w.jobs:
-			if !ok {
-				// no more jobs will be sent, exit normally
-				return
-			}
+// merge adds the latencies of other, a breakdown summarized the same way
+func (s *samples) merge(other *samples) {
+	if s.hist != nil {
+		s.hist.Merge(other.hist)
+		return
+	}
+	s.latencies = append(s.latencies, other.latencies...)
+}
 
-			// execute a single query
-			start := time.Now()
-			_, err := w.db.ExecContext(ctx, q.Query, q.Args...)
-			elapsed := time.Since(start)
+// count returns the # latencies recorded
+func (s *samples) count() int64 {
+	if s.hist != nil {
+		return s.hist.Count
+	}
+	return int64(len(s.latencies))
+}
 
-			// post the results
-			w.results <- result{elapsed, err}
+func (s *samples) stats() *QueryStats {
+	if s.hist != nil {
+		return histogramStats(s.hist)
+	}
+	return calculateStats(s.latencies)
+}
 
-			w.processed++
-		case <-w.done:
-			// hard exit
-			return
-		}
+// histogramStats summarizes the latencies counted by h like calculateStats, without the histogram which is only set
+// on the stats of the whole run
+func histogramStats(h *Histogram) *QueryStats {
+	stats := h.stats()
+	stats.Histogram = nil
+	return stats
+}
+
+func (c *Controller) newCollector() *collector {
+	col := &collector{
+		c:          c,
+		start:      time.Now(),
+		hist:       NewHistogram(nil),
+		nodeErrors: make(map[string]int64),
+		bySpace:    make(map[string]*samples),
+		byKey:      make(map[string]*Histogram),
+		byTemplate: make(map[string]*samples),
+		byQuery:    make(map[string]*samples),
+	}
+	col.waits = newSamples(c.streamingStats())
+	col.connects = newSamples(c.streamingStats())
+	col.fetches = newSamples(c.streamingStats())
+	col.firstRows = newSamples(c.streamingStats())
+	col.cancelled = newSamples(c.streamingStats())
+	col.logged = NewHistogram(nil)
+	col.loggedAt = col.start
+	col.usage = newUsageSampler(col.start)
+	if c.sampleSize > 0 {
+		col.sample = newReservoir(c.sampleSize, c.randSeed(-1))
 	}
+	return col
 }
 
-// Controller is a handle for executing a single test run
-type Controller struct {
-	poolSize         int                // worker pool size
-	workers          []*worker          // worker pool
-	byKey            map[string]*worker // route same key to the same worker every time
-	nextWorker       int                // next random worker when key has not been seen before
-	completedQueries chan result
+// streamingStats returns true if the latencies are summarized in histograms instead of kept, see SetStreaming
+func (c *Controller) streamingStats() bool {
+	return c.streaming || c.sampleSize > 0
+}
 
-	quit chan struct{}
-	wg   sync.WaitGroup
+// bucket returns the results of the timeline bucket of the current second of the run
+func (col *collector) bucket() *timelineResults {
+	i := int(time.Since(col.start) / timelineBucket)
+	for len(col.buckets) <= i {
+		col.buckets = append(col.buckets, timelineResults{})
+	}
+	return &col.buckets[i]
 }
 
-// NewController initializes a test controller with the given worker pool size
-func NewController(poolSize int) *Controller {
-	if poolSize <= 0 {
-		poolSize = 1
+// record a completed query, an error is returned if the query failed and the error is fatal to the run
+func (col *collector) record(r result) error {
+	if col.c.onResult != nil {
+		col.c.onResult(QueryResult{
+			Latency:   r.elapsed,
+			Err:       r.err,
+			Cancelled: r.cancel != nil && !r.cancel.completed,
+			Space:     r.space,
+			Tenant:    r.tenant,
+			Label:     r.label,
+			Worker:    r.worker,
+			Backend:   r.pid,
+			Connect:   r.connect,
+			Wait:      r.wait,
+			Completed: time.Now(),
+		})
+	}
+
+	col.waits.record(r.wait)
+	if r.connect > 0 {
+		col.connects.record(r.connect)
+	}
+	if r.outage != nil {
+		col.outages = append(col.outages, *r.outage)
+	}
+	if r.cancel != nil {
+		col.cancels.Attempted++
+		if !r.cancel.completed {
+			col.cancels.Cancelled++
+			col.cancelled.record(r.cancel.latency)
+			return nil
+		}
+		col.cancels.Completed++
+	}
+
+	if r.err != nil {
+		if !col.tolerate(r.err) {
+			return r.err
+		}
+		if col.errorCounts == nil {
+			col.errorCounts = make(map[string]int64)
+		}
+		col.errorCounts[r.err.Error()]++
+		col.bucket().errors++
+		col.c.recent.recordError(time.Now())
+		col.loggedErrs++
+		return nil
+	}
+
+	col.rows += r.rows
+	col.affected += r.affected
+	for _, d := range r.fetches {
+		col.fetches.record(d)
+	}
+	if r.firstRow > 0 {
+		col.firstRows.record(r.firstRow)
+	}
+	if r.checked {
+		col.verify.Verified++
+	}
+	if m := r.differs; m != nil {
+		col.verify.Mismatches++
+		if len(col.verify.Samples) < maxMismatchSamples {
+			col.verify.Samples = append(col.verify.Samples, *m)
+			col.c.logger.Warn("result mismatch", "query", m.Query, "args", m.Args, "expected", m.Expected, "actual", m.Actual)
+		}
 	}
 
-	return &Controller{
-		poolSize: poolSize,
-		quit:     make(chan struct{}),
-		workers:  make([]*worker, 0, poolSize),
-		byKey:    make(map[string]*worker),
-		// result queue needs to be as large as the number of *possible* outstanding jobs to avoid deadlock
-		completedQueries: make(chan result, jobQueueSize*poolSize),
+	col.hist.Record(r.elapsed)
+	col.c.recent.record(time.Now(), r.elapsed)
+	if col.c.logInterval > 0 {
+		col.logged.Record(r.elapsed)
+	}
+	if col.c.checkpoint != nil {
+		if col.interval == nil {
+			col.interval = NewHistogram(nil)
+		}
+		col.interval.Record(r.elapsed)
+	}
+	if col.c.streamingStats() {
+		if col.sample != nil {
+			col.sample.record(r.elapsed)
+		}
+	} else {
+		col.latencies = append(col.latencies, r.elapsed)
+	}
+	b := col.bucket()
+	if b.latencies == nil {
+		b.latencies = NewHistogram(nil)
+	}
+	b.latencies.Record(r.elapsed)
+	col.keyHistogram(r.key).Record(r.elapsed)
+	if n := len(col.phases); n > 0 {
+		col.phases[n-1].latencies.record(r.elapsed)
 	}
+	if col.c.partitioner != nil {
+		s, ok := col.bySpace[r.space]
+		if !ok {
+			s = newSamples(col.c.streamingStats())
+			col.bySpace[r.space] = s
+		}
+		s.record(r.elapsed)
+	}
+	if r.tenant != "" {
+		if col.byTenant == nil {
+			col.byTenant = make(map[string]*samples)
+		}
+		s, ok := col.byTenant[r.tenant]
+		if !ok {
+			s = newSamples(col.c.streamingStats())
+			col.byTenant[r.tenant] = s
+		}
+		s.record(r.elapsed)
+	}
+	col.templateSamples(r.query).record(r.elapsed)
+	if r.label != "" {
+		if col.byLabel == nil {
+			col.byLabel = make(map[string]*samples)
+		}
+		s, ok := col.byLabel[r.label]
+		if !ok {
+			s = newSamples(col.c.streamingStats())
+			col.byLabel[r.label] = s
+		}
+		s.record(r.elapsed)
+	}
+	if col.c.spikes != nil {
+		i := int(time.Since(col.start) / col.c.spikes.window())
+		for len(col.timeline) <= i {
+			col.timeline = append(col.timeline, newSamples(col.c.streamingStats()))
+		}
+		col.timeline[i].record(r.elapsed)
+	}
+
+	return nil
 }
 
-// get the next available worker for the given query
-func (c *Controller) getWorker(q *Query) *worker {
-	// TODO - implement an option to turn this pinning behavior off
-	worker, ok := c.byKey[q.key]
-	if !ok {
-		// round robin when the key hasn't been seen
-		worker = c.workers[c.nextWorker]
-		c.nextWorker = (c.nextWorker + 1) % len(c.workers)
-		c.byKey[q.key] = worker
+// maxCachedQueries is the max # distinct query texts whose template is cached, the template of queries beyond are
+// normalized every time so queries with inline literals don't grow the cache without bound
+const maxCachedQueries = 10000
+
+// templateSamples returns the latencies of the template of the query text, normalizing each query text only once
+func (col *collector) templateSamples(query string) *samples {
+	if s, ok := col.byQuery[query]; ok {
+		return s
 	}
 
-	return worker
+	template := NormalizeQuery(query)
+	s, ok := col.byTemplate[template]
+	if !ok {
+		s = newSamples(col.c.streamingStats())
+		col.byTemplate[template] = s
+	}
+	if len(col.byQuery) < maxCachedQueries {
+		col.byQuery[query] = s
+	}
+	return s
 }
 
-func (c *Controller) initPool(db Queryable) {
-	// start the workers
-	for i := 0; i < c.poolSize; i++ {
-		w := &worker{
-			id:      i,
-			db:      db,
-			jobs:    make(chan *Query, jobQueueSize),
-			results: c.completedQueries,
-			done:    c.quit,
-			wg:      &c.wg,
+// maxStreamedKeys is the max # keys the latencies are broken down by when streaming, the latencies of the keys beyond
+// are reported together under OtherKeys so the memory used doesn't grow with the cardinality of the keys
+const maxStreamedKeys = 10000
+
+// OtherKeys is the key the latencies of the keys beyond the first 10000 are reported under when streaming
+const OtherKeys = "(other)"
+
+// keyHistogram returns the histogram of the latencies of the key, shared by the keys beyond maxStreamedKeys when
+// streaming
+func (col *collector) keyHistogram(key string) *Histogram {
+	if h, ok := col.byKey[key]; ok {
+		return h
+	}
+
+	if col.c.streamingStats() && len(col.byKey) >= maxStreamedKeys {
+		key = OtherKeys
+		if h, ok := col.byKey[key]; ok {
+			return h
 		}
+	}
+	h := NewHistogram(nil)
+	col.byKey[key] = h
+	return h
+}
 
-		c.workers = append(c.workers, w)
-		go w.run()
+// takeCheckpoint snapshots the stats of the run so far and since the previous checkpoint
+func (col *collector) takeCheckpoint(start, now time.Time) *Checkpoint {
+	// the histograms are summarized rather than the latencies so long runs don't sort all of them every interval
+	total := histogramStats(col.hist)
+	interval := histogramStats(NewHistogram(nil))
+	if col.interval != nil {
+		interval = histogramStats(col.interval)
 	}
+	col.interval = nil
 
-	c.wg.Add(len(c.workers))
+	prev := col.prev
+	if prev.IsZero() {
+		prev = start
+	}
+
+	col.prev = now
+	col.seq++
+
+	cp := &Checkpoint{
+		Seq:      col.seq,
+		Start:    start,
+		Time:     now,
+		Elapsed:  now.Sub(start),
+		Total:    total,
+		Interval: interval,
+	}
+	cp.Total.Duration = cp.Elapsed
+	cp.Interval.Duration = now.Sub(prev)
+	for _, n := range col.nodeErrors {
+		cp.Total.Errors += n
+	}
+
+	return cp
 }
 
-func (c *Controller) seedWorkers(g QueryGenerator) error {
-	// ensure every worker starts off with 1 job or until generator is exhausted
-	for i := 0; i < c.poolSize; i++ {
-		query, err := g.Next()
-		if err != nil {
-			if err == io.EOF && i > 0 {
-				return nil
+// logSummary logs a summary of the queries completed since the previous one, see SetLogInterval
+func (col *collector) logSummary(now time.Time) {
+	stats := histogramStats(col.logged)
+	stats.Duration = now.Sub(col.loggedAt)
+	col.c.logger.Info("interval", "elapsed", now.Sub(col.start).Round(time.Millisecond), "completed", stats.Processed,
+		"errors", col.loggedErrs, "qps", stats.Throughput(), "p50", stats.Median, "p95", stats.P95, "p99", stats.P99)
+
+	col.logged = NewHistogram(nil)
+	col.loggedErrs = 0
+	col.loggedAt = now
+}
+
+// startPhase begins a new schedule phase, ending the previous one
+func (col *collector) startPhase(now time.Time) {
+	col.endPhase(now)
+	col.phases = append(col.phases, phaseResults{start: now, latencies: newSamples(col.c.streamingStats())})
+}
+
+// endPhase ends the current schedule phase if it hasn't already
+func (col *collector) endPhase(now time.Time) {
+	if n := len(col.phases); n > 0 && col.phases[n-1].end.IsZero() {
+		col.phases[n-1].end = now
+	}
+}
+
+// tolerate records the result error in the node error counts and returns true if it is not fatal to the run
+func (col *collector) tolerate(err error) bool {
+	if !col.c.multiNode {
+		return false
+	}
+
+	node, ok := dataNodeFromError(err)
+	if !ok {
+		return false
+	}
+
+	col.nodeErrors[node]++
+	col.c.logger.Debug("data node error", "node", node, "err", err)
+	return true
+}
+
+// stats calculates the final stats of the run
+func (col *collector) stats(ctx context.Context) (*QueryStats, error) {
+	// split before the latencies are sorted out of completion order
+	var cold, warm *QueryStats
+	if col.c.warmup > 0 {
+		cold, warm = col.warmupStats()
+	}
+
+	var stats *QueryStats
+	if col.c.streamingStats() {
+		stats = col.hist.stats()
+		if col.sample != nil {
+			stats.Latencies = col.sample.sorted()
+		}
+	} else {
+		stats = calculateStats(col.latencies)
+		stats.Latencies = col.latencies
+		stats.Histogram = col.hist
+		if cfg := col.c.outliers; cfg != nil {
+			if cfg.Trim > 0 {
+				stats.Trimmed = trimmedStats(col.latencies, cfg.Trim)
 			}
-			return err
+			stats.Outliers = countOutliers(col.latencies, cfg.Rule)
+		}
+	}
+	if len(col.nodeErrors) > 0 {
+		stats.NodeErrors = col.nodeErrors
+		for _, n := range col.nodeErrors {
+			stats.Errors += n
 		}
+	}
 
-		// get the correct worker for the job
-		worker := c.getWorker(query)
-		worker.jobs <- query
+	if len(col.byTenant) > 0 {
+		stats.Tenants = make(map[string]*QueryStats, len(col.byTenant))
+		for tenant, s := range col.byTenant {
+			stats.Tenants[tenant] = s.stats()
+		}
 	}
 
-	return nil
+	if len(col.byTemplate) > 1 {
+		stats.Templates = make(map[string]*QueryStats, len(col.byTemplate))
+		for template, s := range col.byTemplate {
+			stats.Templates[template] = s.stats()
+		}
+	}
+
+	if col.verify.Verified > 0 {
+		v := col.verify
+		stats.Verification = &v
+	}
+
+	if len(col.byLabel) > 0 {
+		stats.Labels = make(map[string]*QueryStats, len(col.byLabel))
+		for label, s := range col.byLabel {
+			stats.Labels[label] = s.stats()
+		}
+	}
+
+	if col.c.cancel != nil {
+		cs := col.cancels
+		cs.Latency = col.cancelled.stats()
+		stats.Cancellations = &cs
+	}
+
+	if len(col.outages) > 0 {
+		stats.Outages = mergeOutages(col.outages)
+	}
+
+	stats.QueueWait = col.waits.stats()
+	stats.Rows = col.rows
+	stats.Affected = col.affected
+	stats.Cold = cold
+	stats.Warm = warm
+
+	if col.c.connect != nil {
+		stats.Connects = col.connects.stats()
+	}
+
+	if col.c.fetch == FetchCursor {
+		stats.Fetches = col.fetches.stats()
+	}
+	if col.c.fetch != "" && col.c.fetch != FetchNone {
+		stats.FirstRow = col.firstRows.stats()
+	}
+
+	if col.c.spikes != nil {
+		stats.Spikes = analyzeSpikes(*col.c.spikes, col.timeline, col.spikes, col.c.streamingStats())
+	}
+
+	for _, p := range col.phases {
+		ps := p.latencies.stats()
+		ps.Duration = p.end.Sub(p.start)
+		stats.Phases = append(stats.Phases, ps)
+	}
+
+	if col.c.partitioner != nil {
+		partitions, err := partitionStats(ctx, col.c.partitioner, col.bySpace)
+		if err != nil {
+			return nil, err
+		}
+		stats.Partitions = partitions
+	}
+
+	return stats, nil
 }
 
-// closeQueues closes all of the individual worker job queus signalling them to finish
-// what they are doing and exit normally
-func (c *Controller) closeQueues() {
-	for _, w := range c.workers {
-		close(w.jobs)
+// warmupStats splits the latencies of the run into the first fraction completed and the rest, see SetWarmup. When
+// streaming the latencies are only summarized by second of the run, so the split is made at the end of the second the
+// fraction was reached in.
+func (col *collector) warmupStats() (cold, warm *QueryStats) {
+	fraction := math.Min(col.c.warmup, 1)
+	if !col.c.streamingStats() {
+		n := int(math.Ceil(fraction * float64(len(col.latencies))))
+		cold = calculateStats(append([]time.Duration(nil), col.latencies[:n]...))
+		warm = calculateStats(append([]time.Duration(nil), col.latencies[n:]...))
+		return cold, warm
+	}
+
+	coldHist, warmHist := NewHistogram(nil), NewHistogram(nil)
+	target := int64(math.Ceil(fraction * float64(col.hist.Count)))
+	for _, b := range col.buckets {
+		if coldHist.Count < target {
+			coldHist.Merge(b.latencies)
+		} else {
+			warmHist.Merge(b.latencies)
+		}
 	}
+	return histogramStats(coldHist), histogramStats(warmHist)
 }
 
-func (c *Controller) RunTest(ctx context.Context, db Queryable, g QueryGenerator) (*QueryStats, error) {
-	results := make([]time.Duration, 0)
+// RunTest executes the queries of the generator against db and returns a report of their stats. A controller may run
+// tests one after the other with the same configuration, each reporting on its own run (see Total for all of them).
+func (c *Controller) RunTest(ctx context.Context, db Queryable, g QueryGenerator) (*Report, error) {
+	c.reset()
+	// stops the workers however the run ends, they've exited already when it completes
+	defer close(c.quit)
+	col := c.newCollector()
+	start := col.start
+	c.runStart = start
+	c.recent.reset(start)
+
+	// the tasks running in the background of the run, stopped however it ends
+	var tasks []backgroundTask
+	defer func() { haltAll(tasks) }()
+
+	var vacuumTask *background[VacuumStats]
+	if c.vacuum != nil {
+		// a table failing to be set up fails the run before anything else runs
+		v := newVacuumer(*c.vacuum, db, start)
+		if err := v.setup(ctx); err != nil {
+			return nil, err
+		}
+		vacuumTask = startBackground(&tasks, v.run)
+	}
+	var chaosTask *background[ChaosStats]
+	if c.chaos != nil && c.chaos.Rate > 0 {
+		chaosTask = startBackground(&tasks, newChaos(*c.chaos, db, c.randSeed(-2)).run)
+	}
+	var locksTask *background[LockStats]
+	if c.locks != nil {
+		locksTask = startBackground(&tasks, newLockSampler(*c.locks, db, start).run)
+	}
+	var bgWriterTask *background[BgWriterStats]
+	if c.bgWriter != nil {
+		bgWriterTask = startBackground(&tasks, newBgWriterSampler(*c.bgWriter, db, start).run)
+	}
+	var notifyTask *background[NotifyStats]
+	if c.notify != nil {
+		notifyTask = startBackground(&tasks, newNotifier(*c.notify, db, start).run)
+	}
+	var interferenceTask *background[InterferenceStats]
+	if c.interference != nil {
+		interferenceTask = startBackground(&tasks, newInterference(*c.interference, db).run)
+	}
+	var ddlTask *background[DDLStats]
+	if len(c.ddl) > 0 {
+		ddlTask = startBackground(&tasks, newDDLRunner(c.ddl, db, start).run)
+	}
+	var waitsTask *background[WaitStats]
+	if c.waits != nil {
+		waitsTask = startBackground(&tasks, newWaitSampler(*c.waits, db).run)
+	}
 
 	// start the worker pool
 	c.initPool(db)
+	c.logger.Debug("run started", "workers", c.poolSize)
 
-	// seed the workers
-	if err := c.seedWorkers(g); err != nil {
-		return nil, err
+	// the schedule drives the dispatch mode phase by phase
+	var phaseEnd <-chan time.Time
+	phase := 0
+	if len(c.schedule) > 0 {
+		c.applyPhase(c.schedule[0], start)
+		col.startPhase(start)
+		timer := time.NewTimer(c.schedule[0].Duration)
+		defer timer.Stop()
+		phaseEnd = timer.C
+	}
+
+	var tick <-chan time.Time
+	if c.limiter != nil || len(c.schedule) > 0 {
+		// rate limited dispatch is paced by the ticker
+		ticker := time.NewTicker(dispatchInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+		if c.limiter != nil {
+			c.limiter.reset(start)
+		}
+	}
+
+	if c.limiter == nil {
+		// seed the workers, from here on every completed query dispatches the next
+		if err := c.seedWorkers(g); err != nil {
+			return nil, err
+		}
+	}
+
+	var checkpoints <-chan time.Time
+	if c.checkpoint != nil && c.checkpointEvery > 0 {
+		ticker := time.NewTicker(c.checkpointEvery)
+		defer ticker.Stop()
+		checkpoints = ticker.C
+	}
+
+	var spikes <-chan time.Time
+	if c.spikes != nil && c.spikes.Every > 0 {
+		ticker := time.NewTicker(c.spikes.Every)
+		defer ticker.Stop()
+		spikes = ticker.C
+	}
+
+	usageTicker := time.NewTicker(timelineBucket)
+	defer usageTicker.Stop()
+
+	var summaries <-chan time.Time
+	if c.logInterval > 0 {
+		ticker := time.NewTicker(c.logInterval)
+		defer ticker.Stop()
+		summaries = ticker.C
+	}
+
+	var watchdog <-chan time.Time
+	if c.watchdog != nil && c.watchdog.Stall > 0 {
+		ticker := time.NewTicker(c.watchdog.checkInterval())
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+	lastCompleted := start
+	stalled := false
+
+	var deadline <-chan time.Time
+	if c.duration > 0 {
+		timer := time.NewTimer(c.duration)
+		defer timer.Stop()
+		deadline = timer.C
 	}
 
 outer:
@@ -196,32 +1897,101 @@ outer:
 		select {
 		case result := <-c.completedQueries:
 			// process completed query
-			if result.err != nil {
-				close(c.quit)
-				return nil, result.err
+			c.inflight--
+			lastCompleted = time.Now()
+			stalled = false
+			if err := col.record(result); err != nil {
+				return nil, err
 			}
 
-			results = append(results, result.elapsed)
+			if c.limiter != nil {
+				continue
+			}
 
 			// queue up more work if available
-			q, err := g.Next()
-			if err != nil {
+			if err := c.fill(g); err != nil {
 				if err == io.EOF {
 					// done, gather results
 					break outer
 				}
-				close(c.quit)
 				return nil, err
 			}
 
-			worker := c.getWorker(q)
+		case now := <-tick:
+			if c.limiter == nil {
+				continue
+			}
+
+			// dispatch everything that has come due, dropping what can't be outstanding at once
+			for n := c.limiter.take(now); n > 0 && c.inflight < c.maxOutstanding(); n-- {
+				if err := c.dispatch(g); err != nil {
+					if err == io.EOF {
+						break outer
+					}
+					return nil, err
+				}
+			}
+
+		case now := <-phaseEnd:
+			phase++
+			if phase == len(c.schedule) {
+				col.endPhase(now)
+				break outer
+			}
+
+			c.applyPhase(c.schedule[phase], now)
+			col.startPhase(now)
+			phaseEnd = time.After(c.schedule[phase].Duration)
+
+			if c.limiter == nil {
+				// top up to the concurrency of the new phase
+				if err := c.fill(g); err != nil {
+					if err == io.EOF {
+						break outer
+					}
+					return nil, err
+				}
+			}
+
+		case now := <-spikes:
+			spike := Spike{At: now.Sub(start)}
+			for ; spike.Size < c.spikes.Size && c.inflight < c.maxInflight(); spike.Size++ {
+				if err := c.dispatch(g); err != nil {
+					if err == io.EOF {
+						break outer
+					}
+					return nil, err
+				}
+			}
+			col.spikes = append(col.spikes, spike)
+
+		case now := <-checkpoints:
+			if err := c.checkpoint(col.takeCheckpoint(start, now)); err != nil {
+				return nil, err
+			}
+
+		case now := <-usageTicker.C:
+			col.usage.sample(now)
+
+		case now := <-summaries:
+			col.logSummary(now)
+
+		case now := <-watchdog:
+			if c.inflight == 0 || stalled || now.Sub(lastCompleted) < c.watchdog.Stall {
+				continue
+			}
+
+			// only log once per stall
+			stalled = true
+			c.logStall(ctx, db, now.Sub(lastCompleted))
+			if c.watchdog.Abort {
+				return nil, ErrStalled
+			}
 
-			// FIXME - there is potential here that if the input query's are skewed to a single key we may starve the other workers when this worker's job queue is full
-			//         this is dependent on the input queries generated and how clustered the queries are by a particular key are
-			worker.jobs <- q
+		case <-deadline:
+			break outer
 
 		case <-ctx.Done():
-			close(c.quit)
 			return nil, ctx.Err()
 		}
 	}
@@ -232,17 +2002,59 @@ outer:
 	// wait for workers to exit
 	c.wg.Wait()
 	close(c.completedQueries)
+	haltAll(tasks)
 
 	// drain any remaining results
 	for result := range c.completedQueries {
-		if result.err != nil {
-			return nil, result.err
+		c.inflight--
+		if err := col.record(result); err != nil {
+			return nil, err
+		}
+	}
+
+	end := time.Now()
+	col.endPhase(end)
+
+	stats, err := col.stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.Duration = end.Sub(start)
+	stats.Chaos = chaosTask.result()
+	stats.Locks = locksTask.result()
+	stats.BgWriter = bgWriterTask.result()
+	stats.Waits = waitsTask.result()
+	stats.Notify = notifyTask.result()
+	stats.Interference = interferenceTask.result()
+	stats.DDL = ddlTask.result()
+	stats.Vacuum = vacuumTask.result()
+	if c.backends {
+		stats.Backends = make(map[int][]int, len(c.workers))
+		for _, w := range c.workers {
+			if len(w.pids) > 0 {
+				stats.Backends[w.id] = w.pids
+			}
 		}
+	}
 
-		results = append(results, result.elapsed)
+	if c.total == nil {
+		c.total = &QueryStats{}
 	}
+	duration := c.total.Duration + stats.Duration
+	if err := c.total.Merge(stats); err != nil {
+		return nil, err
+	}
+	c.total.Duration = duration
+
+	c.logger.Debug("run finished", "processed", stats.Processed, "errors", stats.Errors, "duration", stats.Duration)
+	return col.newReport(stats, end), nil
+}
 
-	return calculateStats(results), nil
+// Total returns the stats accumulated across the runs of the controller so far, nil before the first run completes.
+// The latencies of the runs are merged (see QueryStats.Merge) and their durations added up, the stats of each run
+// are returned by RunTest.
+func (c *Controller) Total() *QueryStats {
+	return c.total
 }
 
 func calculateStats(results []time.Duration) *QueryStats {
@@ -251,6 +2063,10 @@ func calculateStats(results []time.Duration) *QueryStats {
 	})
 
 	n := len(results)
+	if n == 0 {
+		return &QueryStats{}
+	}
+
 	stats := QueryStats{
 		Processed: int64(n),
 		Min:       time.Duration(math.MaxInt64),
@@ -270,6 +2086,10 @@ func calculateStats(results []time.Duration) *QueryStats {
 	}
 
 	stats.Avg = time.Duration(int64(stats.TotalElapsed) / int64(n))
+	stats.StdDev = stdDev(results, stats.Avg)
+	stats.GeoMean, stats.HarmonicMean = means(results)
+	stats.P95 = percentile(results, 95)
+	stats.P99 = percentile(results, 99)
 
 	if n%2 == 0 {
 		n--
@@ -281,3 +2101,60 @@ func calculateStats(results []time.Duration) *QueryStats {
 
 	return &stats
 }
+
+// stdDev returns the (population) standard deviation of the results from their mean
+func stdDev(results []time.Duration, mean time.Duration) time.Duration {
+	if len(results) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range results {
+		d := float64(v - mean)
+		sum += d * d
+	}
+	return time.Duration(math.Sqrt(sum / float64(len(results))))
+}
+
+// means returns the geometric and harmonic means of the results, which are more robust than the arithmetic mean for
+// heavy-tailed latencies. Latencies below 1ns are taken as 1ns for both to be defined.
+func means(results []time.Duration) (geo, harmonic time.Duration) {
+	if len(results) == 0 {
+		return 0, 0
+	}
+
+	var logs, inverses float64
+	for _, v := range results {
+		x := math.Max(float64(v), 1)
+		logs += math.Log(x)
+		inverses += 1 / x
+	}
+	n := float64(len(results))
+	return time.Duration(math.Exp(logs / n)), time.Duration(n / inverses)
+}
+
+// percentile returns the p-th percentile (0 < p <= 100) of the sorted results using the nearest-rank method
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(n)))
+	if rank < 1 {
+		rank = 1
+	} else if rank > n {
+		rank = n
+	}
+
+	return sorted[rank-1]
+}
+
+// Throughput returns the achieved number of queries processed per second over the duration of the run
+func (s *QueryStats) Throughput() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+
+	return float64(s.Processed) / s.Duration.Seconds()
+}
@@ -2,6 +2,10 @@ package dbperf
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"testing"
@@ -9,9 +13,28 @@ import (
 	"timescale/dbperf/test/mocks/mock_dbperf"
 
 	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// testLogger records the level and message of every log
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) log(level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, level+" "+msg)
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) { l.log("DEBUG", msg) }
+func (l *testLogger) Info(msg string, args ...interface{})  { l.log("INFO", msg) }
+func (l *testLogger) Warn(msg string, args ...interface{})  { l.log("WARN", msg) }
+func (l *testLogger) Error(msg string, args ...interface{}) { l.log("ERROR", msg) }
+
 func TestWorker(t *testing.T) {
 	t.Run("done", func(t *testing.T) {
 		var wg sync.WaitGroup
@@ -39,8 +62,8 @@ func TestWorker(t *testing.T) {
 		mdb.EXPECT().ExecContext(gomock.Any(), query.Query, query.Args...).Return(nil, nil)
 
 		results := make(chan result, 1)
-		jobs := make(chan *Query, 1)
-		jobs <- query
+		jobs := make(chan job, 1)
+		jobs <- job{q: query, enqueued: time.Now()}
 
 		var wg sync.WaitGroup
 		w := &worker{
@@ -82,7 +105,12 @@ func TestCalculateResults(t *testing.T) {
 			Min:          time.Millisecond * 900,
 			Max:          time.Millisecond * 3000,
 			Avg:          (time.Millisecond * 6450) / 4,
+			StdDev:       817293551,
+			GeoMean:      1446171154,
+			HarmonicMean: 1325153374,
 			Median:       time.Millisecond * 1275,
+			P95:          time.Millisecond * 3000,
+			P99:          time.Millisecond * 3000,
 		}
 
 		actual := calculateStats(results)
@@ -104,7 +132,12 @@ func TestCalculateResults(t *testing.T) {
 			Min:          time.Millisecond * 900,
 			Max:          time.Millisecond * 3000,
 			Avg:          time.Millisecond * 1545,
+			StdDev:       743370701,
+			GeoMean:      1410190526,
+			HarmonicMean: 1314809510,
 			Median:       time.Millisecond * 1275,
+			P95:          time.Millisecond * 3000,
+			P99:          time.Millisecond * 3000,
 		}
 
 		actual := calculateStats(results)
@@ -113,6 +146,20 @@ func TestCalculateResults(t *testing.T) {
 	})
 }
 
+func TestPercentile(t *testing.T) {
+	results := make([]time.Duration, 0, 100)
+	for i := 1; i <= 100; i++ {
+		results = append(results, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, time.Duration(0), percentile(nil, 99))
+	assert.Equal(t, time.Millisecond, percentile(results, 0))
+	assert.Equal(t, time.Millisecond*50, percentile(results, 50))
+	assert.Equal(t, time.Millisecond*95, percentile(results, 95))
+	assert.Equal(t, time.Millisecond*99, percentile(results, 99))
+	assert.Equal(t, time.Millisecond*100, percentile(results, 100))
+}
+
 const testQueries = `hostname,start_time,end_time
 host_000008,2017-01-01 08:59:22,2017-01-01 09:59:22
 host_000001,2017-01-02 13:02:02,2017-01-02 14:02:02
@@ -130,7 +177,7 @@ func TestRunTest(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	c := NewController(4)
+	c := NewController(WithPoolSize(4))
 
 	generator := NewCPUTestGenerator(strings.NewReader(testQueries))
 
@@ -144,3 +191,820 @@ func TestRunTest(t *testing.T) {
 	assert.Equal(t, 3, c.workers[2].processed) // 02, 02, 06
 	assert.Equal(t, 1, c.workers[3].processed) // 03
 }
+
+func TestRunTestBalanced(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	counts, err := CountKeys(NewCPUTestGenerator(strings.NewReader(testQueries)))
+	require.NoError(t, err)
+	c := NewController(WithPoolSize(4), WithScheduler(NewBalancedScheduler(counts)))
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(10)
+
+	_, err = c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, c.workers[0].processed) // 08, 08, 08
+	assert.Equal(t, 3, c.workers[1].processed) // 02, 02, 06
+	assert.Equal(t, 2, c.workers[2].processed) // 00, 03
+	assert.Equal(t, 2, c.workers[3].processed) // 01, 05
+}
+
+func TestRunTestMultiNode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	nodeErr := errors.New("pq: [dn_1]: could not connect to \"dn_1\"")
+
+	t.Run("tolerated", func(t *testing.T) {
+		c := NewController(WithPoolSize(2))
+		c.SetMultiNode(true)
+
+		mdb := mock_dbperf.NewMockQueryable(ctrl)
+		mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), "host_000008", gomock.Any(), gomock.Any()).Return(nil, nodeErr).Times(3)
+		mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(7)
+
+		stats, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), stats.Processed)
+		assert.Equal(t, int64(3), stats.Errors)
+		assert.Equal(t, map[string]int64{"dn_1": 3}, stats.NodeErrors)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := NewController(WithPoolSize(1))
+
+		mdb := mock_dbperf.NewMockQueryable(ctrl)
+		mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nodeErr).AnyTimes()
+
+		_, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+		assert.Equal(t, nodeErr, err)
+	})
+}
+
+func TestRunTestRateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	c := NewController(WithPoolSize(2))
+	c.SetRateLimit(200)
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(10)
+
+	start := time.Now()
+	stats, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), stats.Processed)
+
+	// 10 queries at 200 qps takes at least 45ms to dispatch (the 10th is due after 50ms)
+	assert.True(t, time.Since(start) >= time.Millisecond*45)
+}
+
+func TestRunTestDuration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	c := NewController(WithPoolSize(1))
+	c.SetRateLimit(10)
+	c.SetDuration(time.Millisecond * 250)
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).MinTimes(1).MaxTimes(3)
+
+	stats, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+	assert.True(t, stats.Processed < 10)
+	assert.True(t, stats.Duration >= time.Millisecond*250)
+}
+
+// repeatGenerator endlessly generates the same query
+type repeatGenerator struct {
+	query Query
+}
+
+func (g *repeatGenerator) Next() (*Query, error) {
+	q := g.query
+	return &q, nil
+}
+
+func TestRunTestSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	c := NewController(WithPoolSize(1))
+	c.SetSchedule(Schedule{
+		{Duration: time.Millisecond * 100, Rate: 50},
+		{Duration: time.Millisecond * 100, Workers: 2},
+	})
+
+	// grown to the largest phase
+	assert.Equal(t, 2, c.poolSize)
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+		time.Sleep(time.Millisecond * 10)
+		return nil, nil
+	}).MinTimes(1)
+
+	stats, err := c.RunTest(context.Background(), mdb, &repeatGenerator{Query{Query: "SELECT 1"}})
+	assert.NoError(t, err)
+	assert.Len(t, stats.Phases, 2)
+
+	// ~5 queries at 50 qps then ~20 queries at 2 x 100 qps
+	assert.InDelta(t, 5, stats.Phases[0].Processed, 2)
+	assert.True(t, stats.Phases[1].Processed > stats.Phases[0].Processed)
+	assert.True(t, stats.Duration >= time.Millisecond*200)
+}
+
+func TestRunTestSpikes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	c := NewController(WithPoolSize(2))
+	c.SetRateLimit(20)
+	c.SetDuration(time.Millisecond * 130)
+	c.SetSpikes(SpikeConfig{Every: time.Millisecond * 50, Size: 5, Window: time.Millisecond * 10})
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any()).Return(nil, nil).MinTimes(10)
+
+	stats, err := c.RunTest(context.Background(), mdb, &repeatGenerator{Query{Query: "SELECT 1"}})
+	assert.NoError(t, err)
+	if assert.NotNil(t, stats.Spikes) && assert.Len(t, stats.Spikes.Spikes, 2) {
+		assert.Equal(t, 5, stats.Spikes.Spikes[0].Size)
+		assert.True(t, stats.Spikes.Spikes[0].At >= time.Millisecond*50)
+	}
+}
+
+// fakeConn is a Conn backed by a mock that counts how often it is closed
+type fakeConn struct {
+	*mock_dbperf.MockQueryable
+	closed *int
+}
+
+func (c fakeConn) Close() error {
+	*c.closed++
+	return nil
+}
+
+func TestRunTestConnectionChurn(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mconn := mock_dbperf.NewMockQueryable(ctrl)
+	mconn.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(10)
+
+	connects, closed := 0, 0
+	connect := func(ctx context.Context) (Conn, error) {
+		connects++
+		time.Sleep(time.Millisecond)
+		return fakeConn{mconn, &closed}, nil
+	}
+
+	// the shared handle is never used
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+
+	c := NewController(WithPoolSize(1))
+	c.SetConnectionChurn(connect, 3)
+
+	stats, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), stats.Processed)
+
+	// 10 queries, 3 per connection
+	assert.Equal(t, 4, connects)
+	assert.Equal(t, 4, closed)
+	assert.Equal(t, int64(4), stats.Connects.Processed)
+	assert.True(t, stats.Connects.Min >= time.Millisecond)
+}
+
+func TestWorkerReconnect(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	query := &Query{Query: "query"}
+
+	t.Run("recovered", func(t *testing.T) {
+		mdb := mock_dbperf.NewMockQueryable(ctrl)
+		gomock.InOrder(
+			mdb.EXPECT().ExecContext(gomock.Any(), query.Query).Return(nil, driver.ErrBadConn),
+			mdb.EXPECT().ExecContext(gomock.Any(), query.Query).Return(nil, &pq.Error{Code: "57P03"}),
+			mdb.EXPECT().ExecContext(gomock.Any(), query.Query).Return(nil, nil),
+		)
+
+		logger := &testLogger{}
+		w := &worker{
+			db:        mdb,
+			done:      make(chan struct{}),
+			reconnect: &ReconnectConfig{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond * 2},
+			logger:    logger,
+		}
+
+		r := w.execute(context.Background(), query)
+		assert.NoError(t, r.err)
+		if assert.NotNil(t, r.outage) {
+			assert.Equal(t, 2, r.outage.attempts)
+			assert.True(t, r.outage.end.Sub(r.outage.start) >= time.Millisecond*3)
+		}
+		assert.Equal(t, []string{"WARN connection lost, reconnecting", "INFO reconnected"}, logger.messages)
+	})
+
+	t.Run("gave up", func(t *testing.T) {
+		mdb := mock_dbperf.NewMockQueryable(ctrl)
+		mdb.EXPECT().ExecContext(gomock.Any(), query.Query).Return(nil, driver.ErrBadConn).MinTimes(2)
+
+		logger := &testLogger{}
+		w := &worker{
+			db:        mdb,
+			done:      make(chan struct{}),
+			reconnect: &ReconnectConfig{InitialBackoff: time.Millisecond, MaxOutage: time.Millisecond * 20},
+			logger:    logger,
+		}
+
+		r := w.execute(context.Background(), query)
+		assert.Equal(t, driver.ErrBadConn, r.err)
+		assert.Equal(t, []string{"WARN connection lost, reconnecting", "ERROR failed to reconnect"}, logger.messages)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mdb := mock_dbperf.NewMockQueryable(ctrl)
+		mdb.EXPECT().ExecContext(gomock.Any(), query.Query).Return(nil, driver.ErrBadConn)
+
+		w := &worker{db: mdb, done: make(chan struct{})}
+
+		r := w.execute(context.Background(), query)
+		assert.Equal(t, driver.ErrBadConn, r.err)
+		assert.Nil(t, r.outage)
+	})
+}
+
+func TestRunTestWorkerConns(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	closed := make([]int, 2)
+	conns := make([]*mock_dbperf.MockQueryable, 2)
+	for i := range conns {
+		conns[i] = mock_dbperf.NewMockQueryable(ctrl)
+	}
+
+	// keys are pinned round robin, see TestRunTest
+	conns[0].EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(7) // 08, 08, 02, 02, 08, 00, 06
+	conns[1].EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(3) // 01, 03, 05
+
+	c := NewController(WithPoolSize(2))
+	c.SetWorkerConns(func(id int) WorkerConn {
+		return WorkerConn{
+			Connect: func(ctx context.Context) (Conn, error) {
+				return fakeConn{conns[id], &closed[id]}, nil
+			},
+			Tenant: fmt.Sprintf("tenant_%d", id),
+		}
+	})
+
+	stats, err := c.RunTest(context.Background(), mock_dbperf.NewMockQueryable(ctrl), NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 1}, closed)
+	assert.Equal(t, int64(7), stats.Tenants["tenant_0"].Processed)
+	assert.Equal(t, int64(3), stats.Tenants["tenant_1"].Processed)
+}
+
+func TestRunTestBackendPIDs(t *testing.T) {
+	var mu sync.Mutex
+	pid := 100
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		if !strings.Contains(query, "pg_backend_pid") {
+			return nil, nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		pid++
+		return []string{"pg_backend_pid"}, [][]driver.Value{{int64(pid)}}
+	})
+	defer db.Close()
+
+	var backends []int
+	c := NewController(WithPoolSize(2))
+	c.SetBackendPIDs(true)
+	c.SetResultFunc(func(r QueryResult) {
+		backends = append(backends, r.Backend)
+	})
+
+	stats, err := c.RunTest(context.Background(), db, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	require.NoError(t, err)
+	assert.Len(t, stats.Backends, 2)
+	pids := map[int]bool{}
+	for id, ps := range stats.Backends {
+		require.Len(t, ps, 1, "worker %d", id)
+		pids[ps[0]] = true
+	}
+	assert.Equal(t, map[int]bool{101: true, 102: true}, pids)
+
+	// every result carries the pid of the worker that executed it
+	require.Len(t, backends, 10)
+	for _, b := range backends {
+		assert.True(t, pids[b], b)
+	}
+}
+
+func TestRunTestResultFunc(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	nodeErr := errors.New("pq: [dn_1]: could not connect to \"dn_1\"")
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), "host_000008", gomock.Any(), gomock.Any()).Return(nil, nodeErr).Times(3)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(7)
+
+	var results []QueryResult
+	c := NewController(WithPoolSize(2))
+	c.SetMultiNode(true)
+	c.SetResultFunc(func(r QueryResult) {
+		results = append(results, r)
+	})
+
+	_, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+
+	// failed queries are passed on too
+	assert.Len(t, results, 10)
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			assert.Equal(t, "host_000008", r.Space)
+		}
+		assert.False(t, r.Completed.IsZero())
+		assert.True(t, r.Worker >= 0 && r.Worker < 2)
+	}
+	assert.Equal(t, 3, failed)
+}
+
+func TestQueueDepths(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	release := make(chan struct{})
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			<-release
+			return nil, nil
+		}).Times(10)
+
+	// queries only queue up when rate limited, as fast as possible dispatch keeps one outstanding per worker
+	c := NewController(WithPoolSize(2))
+	c.SetRateLimit(1000)
+	assert.Empty(t, c.QueueDepths())
+
+	done := make(chan error)
+	go func() {
+		_, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+		done <- err
+	}()
+
+	// both workers are stuck on their first query with the rest of the queries waiting in their queues
+	queued := func() int {
+		n := 0
+		for _, d := range c.QueueDepths() {
+			n += d
+		}
+		return n
+	}
+	for i := 0; i < 100 && queued() < 8; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Len(t, c.QueueDepths(), 2)
+	assert.Equal(t, 8, queued())
+
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 0, queued())
+}
+
+func TestDispatchStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	release := make(chan struct{})
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			<-release
+			return nil, nil
+		}).Times(10)
+
+	c := NewController(WithPoolSize(2), WithRateLimit(1000))
+	assert.Empty(t, c.DispatchStats().Workers)
+
+	done := make(chan error)
+	go func() {
+		_, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+		done <- err
+	}()
+
+	for i := 0; i < 100 && c.DispatchStats().Dispatched < 10; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	ds := c.DispatchStats()
+	assert.Equal(t, int64(10), ds.Dispatched)
+	assert.Equal(t, int64(0), ds.Completed)
+	assert.Equal(t, int64(10), ds.Inflight)
+	assert.Len(t, ds.Workers, 2)
+	for _, ws := range ds.Workers {
+		// one executing, the rest queued
+		assert.Equal(t, ws.Dispatched, ws.Inflight)
+		assert.Equal(t, ws.Inflight-1, int64(ws.QueueDepth))
+	}
+
+	close(release)
+	assert.NoError(t, <-done)
+	ds = c.DispatchStats()
+	assert.Equal(t, int64(10), ds.Completed)
+	assert.Equal(t, int64(0), ds.Inflight)
+}
+
+func TestNewControllerOptions(t *testing.T) {
+	c := NewController()
+	assert.Equal(t, 1, c.poolSize)
+	assert.Equal(t, jobQueueSize, cap(c.completedQueries))
+	assert.Nil(t, c.limiter)
+
+	var results int
+	c = NewController(WithPoolSize(4), WithQueueSize(5), WithRateLimit(100), WithReporter(func(QueryResult) { results++ }),
+		WithScheduler(NewRoundRobinScheduler()))
+	assert.Equal(t, 4, c.poolSize)
+	assert.Equal(t, 20, cap(c.completedQueries))
+	assert.NotNil(t, c.limiter)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(10)
+
+	c = NewController(WithPoolSize(4), WithReporter(func(QueryResult) { results++ }), WithScheduler(NewRoundRobinScheduler()))
+	_, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+	assert.Equal(t, 10, results)
+
+	// the queries are spread evenly rather than pinned by host
+	assert.Equal(t, 3, c.workers[0].processed)
+	assert.Equal(t, 3, c.workers[1].processed)
+	assert.Equal(t, 2, c.workers[2].processed)
+	assert.Equal(t, 2, c.workers[3].processed)
+}
+
+func TestRunTestReuse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	gomock.InOrder(
+		mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(10),
+		mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("boom")),
+		mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes(),
+	)
+
+	c := NewController(WithPoolSize(2))
+	assert.Nil(t, c.Total())
+
+	first, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), first.Processed)
+
+	// a failed run doesn't keep the next from running
+	_, err = c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.Error(t, err)
+
+	// nor does a run failing on its first row, before the workers are seeded
+	_, err = c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader("hostname,start_time,end_time\nhost_000001,bad,bad\n")))
+	assert.Error(t, err)
+
+	second, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), second.Processed)
+
+	total := c.Total()
+	assert.Equal(t, int64(20), total.Processed)
+	assert.Equal(t, first.Duration+second.Duration, total.Duration)
+	assert.Equal(t, first.TotalElapsed+second.TotalElapsed, total.TotalElapsed)
+}
+
+func TestRunTestHooks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	type key struct{}
+	nodeErr := errors.New("pq: [dn_1]: could not connect to \"dn_1\"")
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), "host_000008", gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			assert.Equal(t, "span", ctx.Value(key{}))
+			return nil, nodeErr
+		}).Times(3)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(7)
+
+	var mu sync.Mutex
+	var before, after, failed int
+	c := NewController(WithPoolSize(2), WithHooks(Hooks{
+		BeforeQuery: func(ctx context.Context, q *Query) context.Context {
+			mu.Lock()
+			defer mu.Unlock()
+			before++
+			return context.WithValue(ctx, key{}, "span")
+		},
+		AfterQuery: func(ctx context.Context, q *Query, latency time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			after++
+			assert.Equal(t, "span", ctx.Value(key{}))
+		},
+		OnError: func(ctx context.Context, q *Query, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed++
+			assert.Equal(t, nodeErr, err)
+			assert.Equal(t, "host_000008", q.Args[0])
+		},
+	}))
+	c.SetMultiNode(true)
+
+	_, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+	assert.Equal(t, 10, before)
+	assert.Equal(t, 10, after)
+	assert.Equal(t, 3, failed)
+}
+
+func TestRunTestStreaming(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(10)
+
+	c := NewController(WithPoolSize(2), WithStreaming())
+	report, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+
+	// summarized without keeping the latencies
+	assert.Equal(t, int64(10), report.Processed)
+	assert.Nil(t, report.Latencies)
+	assert.Equal(t, int64(10), report.Histogram.Count)
+	assert.True(t, report.Min <= report.Median && report.Median <= report.P99 && report.P99 <= report.Max)
+	assert.Equal(t, report.TotalElapsed/10, report.Avg)
+}
+
+func TestRunTestSampleSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(10)
+
+	c := NewController(WithPoolSize(2), WithSampleSize(4))
+	report, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+
+	// the stats cover every query, the latencies only the sample
+	assert.Equal(t, int64(10), report.Processed)
+	assert.Equal(t, int64(10), report.Histogram.Count)
+	assert.Len(t, report.Latencies, 4)
+	assert.True(t, report.Latencies[0] >= report.Min && report.Latencies[3] <= report.Max)
+	assert.True(t, report.Latencies[0] <= report.Latencies[3])
+}
+
+func TestRunTestQueueWait(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			time.Sleep(5 * time.Millisecond)
+			return nil, nil
+		}).Times(10)
+
+	// queries are dispatched faster than the single worker completes them so they queue up
+	c := NewController(WithRateLimit(1000))
+	report, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(10), report.QueueWait.Processed)
+	assert.True(t, report.QueueWait.Max >= 20*time.Millisecond, "max queue wait %s", report.QueueWait.Max)
+	// the query times exclude the wait
+	assert.True(t, report.Max < report.QueueWait.Max, "max %s", report.Max)
+}
+
+func TestRunTestWarmup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the first queries are slow while the caches warm up
+	n := 0
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			if n++; n <= 3 {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return nil, nil
+		}).Times(10)
+
+	c := NewController(WithPoolSize(1), WithWarmup(0.3))
+	report, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(3), report.Cold.Processed)
+	assert.Equal(t, int64(7), report.Warm.Processed)
+	assert.True(t, report.Cold.Min >= 20*time.Millisecond, "cold min %s", report.Cold.Min)
+	assert.True(t, report.Warm.Max < 20*time.Millisecond, "warm max %s", report.Warm.Max)
+	// the stats of the whole run still cover every query
+	assert.Equal(t, int64(10), report.Processed)
+	assert.Equal(t, report.Cold.Max, report.Max)
+}
+
+func TestRunTestTemplates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the inserts are slower than the selects
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			if strings.HasPrefix(query, "INSERT") {
+				time.Sleep(5 * time.Millisecond)
+			}
+			return nil, nil
+		}).Times(9)
+
+	queries := []*Query{
+		{Query: "SELECT * FROM cpu_usage WHERE host = 'host_1'", Label: "dashboard", key: "a"},
+		{Query: "SELECT * FROM cpu_usage WHERE host = 'host_2'", Label: "dashboard", key: "b"},
+		{Query: "INSERT INTO cpu_usage VALUES ($1, $2, $3)", key: "c"},
+	}
+	c := NewController(WithPoolSize(1))
+	report, err := c.RunTest(context.Background(), mdb, &replayGenerator{queries: queries, n: 9})
+	assert.NoError(t, err)
+
+	assert.Len(t, report.Templates, 2)
+	selects := report.Templates["SELECT * FROM cpu_usage WHERE host = ?"]
+	inserts := report.Templates["INSERT INTO cpu_usage VALUES ($1, $2, $3)"]
+	if !assert.NotNil(t, selects) || !assert.NotNil(t, inserts) {
+		return
+	}
+	assert.Equal(t, int64(6), selects.Processed)
+	assert.Equal(t, int64(3), inserts.Processed)
+	assert.True(t, inserts.Min >= 5*time.Millisecond, "insert min %s", inserts.Min)
+	assert.True(t, selects.Max < 5*time.Millisecond, "select max %s", selects.Max)
+
+	// only labeled queries are grouped by label
+	assert.Len(t, report.Labels, 1)
+	if assert.NotNil(t, report.Labels["dashboard"]) {
+		assert.Equal(t, int64(6), report.Labels["dashboard"].Processed)
+	}
+
+	// a single template isn't broken down
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(2)
+	report, err = c.RunTest(context.Background(), mdb, &replayGenerator{queries: queries[:2], n: 2})
+	assert.NoError(t, err)
+	assert.Nil(t, report.Templates)
+}
+
+func TestCollectorTemplateSamples(t *testing.T) {
+	col := NewController().newCollector()
+	s := col.templateSamples("SELECT * FROM t WHERE id = 1")
+	assert.True(t, s == col.templateSamples("SELECT * FROM t WHERE id = 2"))
+	assert.True(t, s == col.templateSamples("SELECT * FROM t WHERE id = 1"))
+	assert.True(t, s != col.templateSamples("SELECT 1"))
+	assert.Len(t, col.byTemplate, 2)
+	assert.Len(t, col.byQuery, 3)
+}
+
+func TestCollectorKeyHistogram(t *testing.T) {
+	col := NewController().newCollector()
+	for i := 0; i < maxStreamedKeys+10; i++ {
+		col.keyHistogram(fmt.Sprintf("host_%d", i)).Record(time.Millisecond)
+	}
+	assert.Len(t, col.byKey, maxStreamedKeys+10)
+
+	// when streaming the keys beyond the max share one histogram
+	c := NewController()
+	c.SetStreaming(true)
+	col = c.newCollector()
+	for i := 0; i < maxStreamedKeys+10; i++ {
+		col.keyHistogram(fmt.Sprintf("host_%d", i)).Record(time.Millisecond)
+	}
+	assert.Len(t, col.byKey, maxStreamedKeys+1)
+	assert.Equal(t, int64(10), col.byKey[OtherKeys].Count)
+	assert.True(t, col.byKey["host_0"] == col.keyHistogram("host_0"))
+}
+
+func TestRunTestOutliers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// a single blip among fast queries
+	n := 0
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			if n++; n == 5 {
+				time.Sleep(50 * time.Millisecond)
+			}
+			return nil, nil
+		}).Times(10)
+
+	c := NewController(WithPoolSize(1), WithOutliers(OutlierConfig{Trim: 0.1, Rule: OutlierIQR}))
+	report, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+
+	assert.True(t, report.Outliers >= 1, "outliers %d", report.Outliers)
+	assert.Equal(t, int64(8), report.Trimmed.Processed)
+	assert.True(t, report.Trimmed.Max < 50*time.Millisecond, "trimmed max %s", report.Trimmed.Max)
+	assert.True(t, report.Max >= 50*time.Millisecond, "max %s", report.Max)
+}
+
+func TestRunTestRecentStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			time.Sleep(time.Millisecond)
+			return nil, nil
+		}).Times(10)
+
+	c := NewController(WithPoolSize(2))
+
+	// the window is followed while the run progresses
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				c.RecentStats(10 * time.Second)
+			}
+		}
+	}()
+
+	_, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	close(stop)
+	<-done
+	assert.NoError(t, err)
+
+	recent := c.RecentStats(10 * time.Second)
+	assert.Equal(t, int64(10), recent.Processed)
+	assert.True(t, recent.P99 >= time.Millisecond, "p99 %s", recent.P99)
+	assert.True(t, recent.Throughput() > 0)
+}
+
+func TestRunTestLogInterval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+			time.Sleep(10 * time.Millisecond)
+			return nil, nil
+		}).Times(10)
+
+	logger := &testLogger{}
+	c := NewController(WithLogger(logger), WithLogInterval(20*time.Millisecond))
+	_, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+
+	// ~100ms run
+	intervals := 0
+	for _, msg := range logger.messages {
+		if msg == "INFO interval" {
+			intervals++
+		}
+	}
+	assert.True(t, intervals >= 3, "%d interval summaries", intervals)
+}
+
+func TestControllerSeed(t *testing.T) {
+	// the same seed makes the same random choices
+	a, b := NewController(WithSeed(42)), NewController(WithSeed(42))
+	assert.Equal(t, a.randSeed(1), b.randSeed(1))
+	assert.NotEqual(t, a.randSeed(1), a.randSeed(2))
+
+	ra, rb := newReservoir(10, a.randSeed(-1)), newReservoir(10, b.randSeed(-1))
+	for i := 0; i < 1000; i++ {
+		ra.record(time.Duration(i))
+		rb.record(time.Duration(i))
+	}
+	assert.Equal(t, ra.sorted(), rb.sorted())
+}
@@ -0,0 +1,92 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// cursorName is the name of the cursor queries are fetched through (see FetchCursor)
+const cursorName = "dbperf_cursor"
+
+// defaultFetchSize is the # rows fetched from a cursor at a time unless configured (see SetFetchSize)
+const defaultFetchSize = 1000
+
+// ResultFormat is the format the rows fetched from cursors are transferred in
+type ResultFormat string
+
+const (
+	// FormatText transfers the values as text, decoded by the driver into typed values, the default
+	FormatText ResultFormat = "text"
+
+	// FormatBinary declares binary cursors transferring the rows in the server's binary format. lib/pq only decodes
+	// a few types in binary so every row is fetched as the bytea of its binary encoding (record_send), the server's
+	// encoding and the transfer are measured but not the decoding of the values by the client.
+	FormatBinary ResultFormat = "binary"
+)
+
+// txBeginner is a database that queries may be executed in transactions on, cursors only live within one
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// cursorQuery declares a server side cursor for the query and fetches its rows size at a time in the given format,
+// returning the # rows read, the time taken until the first one was (0 if there were none) and the time taken by
+// every fetch
+func cursorQuery(ctx context.Context, db Queryable, q *Query, size int, format ResultFormat) (int64, time.Duration, []time.Duration, error) {
+	b, ok := db.(txBeginner)
+	if !ok {
+		return 0, 0, nil, fmt.Errorf("fetching through a cursor needs a database supporting transactions")
+	}
+
+	begin := time.Now()
+	tx, err := b.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	// rolling back closes the cursor if the fetches fail
+	defer tx.Rollback()
+
+	query := strings.TrimRight(strings.TrimSpace(q.Query), ";")
+	declare := "DECLARE " + cursorName + " NO SCROLL CURSOR FOR " + query
+	if format == FormatBinary {
+		// the fetches without arguments are simple queries, the rows of which are sent in the format of the cursor
+		declare = "DECLARE " + cursorName + " BINARY NO SCROLL CURSOR FOR SELECT record_send(r) FROM (" + query + ") r"
+	}
+	if _, err := tx.ExecContext(ctx, declare, q.Args...); err != nil {
+		return 0, 0, nil, err
+	}
+
+	fetch := fmt.Sprintf("FETCH %d FROM %s", size, cursorName)
+	var n int64
+	var first time.Duration
+	var fetches []time.Duration
+	for {
+		start := time.Now()
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			return n, first, fetches, err
+		}
+		got := 0
+		for rows.Next() {
+			if n == 0 && got == 0 {
+				first = time.Since(begin)
+			}
+			got++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return n, first, fetches, err
+		}
+		fetches = append(fetches, time.Since(start))
+
+		n += int64(got)
+		if got < size {
+			break
+		}
+	}
+
+	return n, first, fetches, tx.Commit()
+}
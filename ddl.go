@@ -0,0 +1,134 @@
+package dbperf
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// DDLOperation is a DDL statement executed at a point of a run, e.g. CREATE INDEX CONCURRENTLY, ALTER TABLE ... ADD
+// COLUMN or SELECT compress_chunk(...)
+type DDLOperation struct {
+	At        time.Duration `yaml:"at"`        // offset from the start of the run the statement is executed at
+	Statement string        `yaml:"statement"` // the statement, executed outside of a transaction
+}
+
+// DDLResult reports a DDL operation executed during a run
+type DDLResult struct {
+	DDLOperation
+	Start time.Duration // offset from the start of the run it started at, later than At if the previous one was running
+	End   time.Duration // offset from the start of the run it ended at
+	Err   string        // why it failed, empty if it succeeded
+}
+
+// Elapsed returns the time the operation took
+func (r DDLResult) Elapsed() time.Duration {
+	return r.End - r.Start
+}
+
+// DDLStats reports the DDL operations executed during a run (see SetDDL)
+type DDLStats struct {
+	Operations []DDLResult // the operations executed, in order
+	Skipped    int         // # operations not reached by the end of the run
+}
+
+// ddlRunner executes DDL operations at their offsets of a run until stopped
+type ddlRunner struct {
+	ops   []DDLOperation
+	db    Queryable
+	start time.Time
+	stats DDLStats
+}
+
+func newDDLRunner(ops []DDLOperation, db Queryable, start time.Time) *ddlRunner {
+	sorted := append([]DDLOperation(nil), ops...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].At < sorted[j].At })
+	return &ddlRunner{ops: sorted, db: db, start: start}
+}
+
+// run executes the operations one at a time in the order of their offsets until stop is closed, cancelling the one in
+// flight, the stats are sent on done when finished
+func (dr *ddlRunner) run(stop <-chan struct{}, done chan<- DDLStats) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	for i, op := range dr.ops {
+		timer := time.NewTimer(time.Until(dr.start.Add(op.At)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			dr.stats.Skipped = len(dr.ops) - i
+			break
+		}
+		dr.stats.Operations = append(dr.stats.Operations, dr.exec(ctx, op))
+	}
+
+	<-ctx.Done()
+	done <- dr.stats
+}
+
+// exec executes a single operation
+func (dr *ddlRunner) exec(ctx context.Context, op DDLOperation) DDLResult {
+	r := DDLResult{DDLOperation: op, Start: time.Since(dr.start)}
+	_, err := dr.db.ExecContext(ctx, op.Statement)
+	r.End = time.Since(dr.start)
+	switch {
+	case ctx.Err() != nil:
+		r.Err = "cancelled at the end of the run"
+	case err != nil:
+		r.Err = err.Error()
+	}
+	return r
+}
+
+// DDLImpact compares the latency of the seconds of a run a DDL operation was executing during to the seconds no DDL
+// operation was
+type DDLImpact struct {
+	Operation   DDLResult
+	Seconds     int           // # seconds of the timeline the operation was executing during
+	P99         time.Duration // median p99 of the seconds the operation was executing during
+	MaxP99      time.Duration // max p99 of the seconds the operation was executing during
+	BaselineP99 time.Duration // median p99 of the seconds without DDL operations
+	Errors      int64         // # queries that failed but were tolerated during the operation
+}
+
+// DDLImpact reports the impact of every DDL operation executed during the run on its latency, nil if none were
+func (r *Report) DDLImpact() []DDLImpact {
+	if r.DDL == nil || len(r.DDL.Operations) == 0 {
+		return nil
+	}
+
+	during := func(op DDLResult, i int) bool {
+		return i >= int(op.Start/timelineBucket) && i <= int(op.End/timelineBucket)
+	}
+	baseline := correlate(r.Timeline, func(i int) bool {
+		for _, op := range r.DDL.Operations {
+			if during(op, i) {
+				return true
+			}
+		}
+		return false
+	})
+
+	impacts := make([]DDLImpact, len(r.DDL.Operations))
+	for n, op := range r.DDL.Operations {
+		c := correlate(r.Timeline, func(i int) bool { return during(op, i) })
+		impact := DDLImpact{Operation: op, Seconds: c.marked, P99: c.markedP99, BaselineP99: baseline.otherP99}
+		for i, b := range r.Timeline {
+			if during(op, i) {
+				if b.P99 > impact.MaxP99 {
+					impact.MaxP99 = b.P99
+				}
+				impact.Errors += b.Errors
+			}
+		}
+		impacts[n] = impact
+	}
+	return impacts
+}
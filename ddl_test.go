@@ -0,0 +1,95 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDDLRunner(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), "ALTER TABLE cpu_usage ADD COLUMN note text;").Return(nil, nil)
+	mdb.EXPECT().ExecContext(gomock.Any(), "CREATE INDEX broken;").Return(nil, errors.New("syntax error"))
+	mdb.EXPECT().ExecContext(gomock.Any(), "CREATE INDEX CONCURRENTLY ON cpu_usage (usage);").DoAndReturn(func(ctx context.Context, query string, args ...interface{}) (driver.Result, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	ops := []DDLOperation{
+		{At: time.Hour, Statement: "SELECT compress_chunk(c) FROM show_chunks('cpu_usage') c;"},
+		{At: 10 * time.Millisecond, Statement: "CREATE INDEX CONCURRENTLY ON cpu_usage (usage);"},
+		{At: 0, Statement: "ALTER TABLE cpu_usage ADD COLUMN note text;"},
+		{At: 5 * time.Millisecond, Statement: "CREATE INDEX broken;"},
+	}
+	stop := make(chan struct{})
+	done := make(chan DDLStats, 1)
+	go newDDLRunner(ops, mdb, time.Now()).run(stop, done)
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	s := <-done
+
+	require.Len(t, s.Operations, 3)
+	assert.Equal(t, 1, s.Skipped)
+	assert.Equal(t, ops[2], s.Operations[0].DDLOperation)
+	assert.Empty(t, s.Operations[0].Err)
+	assert.Equal(t, "syntax error", s.Operations[1].Err)
+	assert.True(t, s.Operations[1].Start >= 5*time.Millisecond)
+	assert.Equal(t, "cancelled at the end of the run", s.Operations[2].Err)
+	assert.True(t, s.Operations[2].Elapsed() >= 30*time.Millisecond, s.Operations[2].Elapsed())
+}
+
+func TestDDLImpact(t *testing.T) {
+	r := &Report{QueryStats: &QueryStats{}}
+	assert.Nil(t, r.DDLImpact())
+
+	ms := time.Millisecond
+	ops := []DDLResult{
+		{Start: 1500 * ms, End: 2500 * ms},
+		{Start: 4200 * ms, End: 4300 * ms, Err: "lock timeout"},
+	}
+	r.DDL = &DDLStats{Operations: ops}
+	r.Timeline = []TimelineBucket{
+		{Processed: 10, P99: 10 * ms},
+		{Processed: 10, P99: 50 * ms, Errors: 1},
+		{Processed: 10, P99: 30 * ms, Errors: 2},
+		{Processed: 10, P99: 12 * ms},
+		{Processed: 10, P99: 11 * ms},
+		{Processed: 10, P99: 14 * ms},
+	}
+
+	assert.Equal(t, []DDLImpact{
+		{Operation: ops[0], Seconds: 2, P99: 30 * ms, MaxP99: 50 * ms, BaselineP99: 12 * ms, Errors: 3},
+		{Operation: ops[1], Seconds: 1, P99: 11 * ms, MaxP99: 11 * ms, BaselineP99: 12 * ms},
+	}, r.DDLImpact())
+}
+
+func TestRunTestDDL(t *testing.T) {
+	executed := make(chan string, 10)
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		if !strings.HasPrefix(query, "SELECT 1") {
+			executed <- query
+		}
+		return nil, nil
+	})
+	defer db.Close()
+
+	report := runInBackground(t, db, 50*time.Millisecond, func(c *Controller) {
+		c.SetDDL([]DDLOperation{{At: 10 * time.Millisecond, Statement: "ALTER TABLE cpu_usage ADD COLUMN note text;"}})
+	})
+	require.NotNil(t, report.DDL)
+	require.Len(t, report.DDL.Operations, 1)
+	assert.Empty(t, report.DDL.Operations[0].Err)
+	assert.Equal(t, "ALTER TABLE cpu_usage ADD COLUMN note text;", <-executed)
+	require.Len(t, report.DDLImpact(), 1)
+}
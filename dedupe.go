@@ -0,0 +1,51 @@
+package dbperf
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+)
+
+// Deduplicator skips the exact duplicates of queries already generated, by their text and arguments, e.g. the rows
+// repeated in concatenated exports of query logs, which would otherwise inflate the cache hits of a run
+type Deduplicator struct {
+	duplicates int64
+}
+
+// Duplicates returns the # duplicates the last generator of the deduplicator skipped
+func (d *Deduplicator) Duplicates() int64 {
+	return d.duplicates
+}
+
+// Generator returns a generator of the distinct queries of gen. Every generator tracks the queries it generated on its
+// own, so generators over the same input for different runs generate the same queries.
+func (d *Deduplicator) Generator(gen QueryGenerator) QueryGenerator {
+	d.duplicates = 0
+	return &dedupeGenerator{gen: gen, d: d, seen: make(map[[sha256.Size]byte]bool)}
+}
+
+type dedupeGenerator struct {
+	gen  QueryGenerator
+	d    *Deduplicator
+	seen map[[sha256.Size]byte]bool // hashes of the queries generated
+}
+
+func (g *dedupeGenerator) Next() (*Query, error) {
+	for {
+		q, err := g.gen.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		args, err := json.Marshal(q.Args)
+		if err != nil {
+			// queries whose arguments can't be encoded can't be told apart, they're all distinct
+			return q, nil
+		}
+		h := sha256.Sum256([]byte(goldenKey(q.Query, args)))
+		if !g.seen[h] {
+			g.seen[h] = true
+			return q, nil
+		}
+		g.d.duplicates++
+	}
+}
@@ -0,0 +1,28 @@
+package dbperf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicator(t *testing.T) {
+	input := `hostname,start_time,end_time
+host_000001,2017-01-01 08:00:00,2017-01-01 09:00:00
+host_000002,2017-01-01 08:00:00,2017-01-01 09:00:00
+host_000001,2017-01-01 08:00:00,2017-01-01 09:00:00
+host_000001,2017-01-01 08:00:00,2017-01-01 09:00:01
+host_000002,2017-01-01 08:00:00,2017-01-01 09:00:00
+`
+
+	var d Deduplicator
+	// a second generator over the same input generates the same queries
+	for i := 0; i < 2; i++ {
+		hosts, err := readHosts(d.Generator(NewCPUTestGenerator(strings.NewReader(input))))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"host_000001", "host_000002", "host_000001"}, hosts)
+		assert.Equal(t, int64(2), d.Duplicates())
+	}
+}
@@ -0,0 +1,122 @@
+package dbperf
+
+import (
+	"database/sql/driver"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ReconnectConfig configures how workers ride out lost database connections
+type ReconnectConfig struct {
+	InitialBackoff time.Duration // wait before the first retry (default 100ms)
+	MaxBackoff     time.Duration // backoff doubles up to this (default 5s)
+	MaxOutage      time.Duration // give up and fail the run if a worker can't reconnect for this long (default 5m)
+}
+
+func (cfg *ReconnectConfig) initialBackoff() time.Duration {
+	if cfg.InitialBackoff <= 0 {
+		return 100 * time.Millisecond
+	}
+	return cfg.InitialBackoff
+}
+
+func (cfg *ReconnectConfig) maxBackoff() time.Duration {
+	if cfg.MaxBackoff <= 0 {
+		return 5 * time.Second
+	}
+	return cfg.MaxBackoff
+}
+
+func (cfg *ReconnectConfig) maxOutage() time.Duration {
+	if cfg.MaxOutage <= 0 {
+		return 5 * time.Minute
+	}
+	return cfg.MaxOutage
+}
+
+// Outage is a window of time during which queries failed because the database was unreachable
+type Outage struct {
+	Start    time.Time     // first failure
+	End      time.Time     // first successful query after the failures
+	Duration time.Duration // End - Start
+	Errors   int           // # failed attempts during the outage
+}
+
+// outage observed by a single worker
+type outage struct {
+	start    time.Time
+	end      time.Time
+	attempts int
+}
+
+// connection related postgres error codes that are worth reconnecting for
+var reconnectCodes = map[pq.ErrorCode]bool{
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// isConnectionError returns true if err indicates the connection to the database was lost or could not be
+// established, as opposed to an error with the query itself
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if err == driver.ErrBadConn || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+
+	switch e := err.(type) {
+	case *pq.Error:
+		// class 08 - connection exception
+		return e.Code.Class() == "08" || reconnectCodes[e.Code]
+	case net.Error:
+		return true
+	}
+
+	msg := err.Error()
+	for _, s := range []string{"connection refused", "connection reset", "broken pipe", "bad connection", "the database system is"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mergeOutages combines the outages observed by individual workers into overlapping windows
+func mergeOutages(observed []outage) []Outage {
+	sorted := make([]outage, len(observed))
+	copy(sorted, observed)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].start.Before(sorted[j].start)
+	})
+
+	var merged []Outage
+	for _, o := range sorted {
+		if n := len(merged); n > 0 && !o.start.After(merged[n-1].End) {
+			last := &merged[n-1]
+			if o.end.After(last.End) {
+				last.End = o.end
+				last.Duration = last.End.Sub(last.Start)
+			}
+			last.Errors += o.attempts
+			continue
+		}
+
+		merged = append(merged, Outage{
+			Start:    o.start,
+			End:      o.end,
+			Duration: o.end.Sub(o.start),
+			Errors:   o.attempts,
+		})
+	}
+
+	return merged
+}
@@ -0,0 +1,56 @@
+package dbperf
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{driver.ErrBadConn, true},
+		{io.ErrUnexpectedEOF, true},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{&pq.Error{Code: "08006"}, true},
+		{&pq.Error{Code: "57P01"}, true},
+		{&pq.Error{Code: "42P01"}, false},
+		{&pq.Error{Code: "57014"}, false}, // query_canceled
+		{errors.New("dial tcp 127.0.0.1:5432: connect: connection refused"), true},
+		{errors.New("pq: syntax error at or near \"SELEC\""), false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, isConnectionError(tt.err), "%v", tt.err)
+	}
+}
+
+func TestMergeOutages(t *testing.T) {
+	t0 := time.Now()
+	at := func(s int) time.Time {
+		return t0.Add(time.Duration(s) * time.Second)
+	}
+
+	observed := []outage{
+		{start: at(12), end: at(15), attempts: 2},
+		{start: at(1), end: at(4), attempts: 3},
+		{start: at(2), end: at(5), attempts: 4},
+		{start: at(3), end: at(4), attempts: 1},
+	}
+
+	expected := []Outage{
+		{Start: at(1), End: at(5), Duration: time.Second * 4, Errors: 8},
+		{Start: at(12), End: at(15), Duration: time.Second * 3, Errors: 2},
+	}
+
+	assert.Equal(t, expected, mergeOutages(observed))
+}
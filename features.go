@@ -0,0 +1,67 @@
+package dbperf
+
+import (
+	"context"
+	"strings"
+)
+
+// featuresQuery returns whether the TimescaleDB extension is installed, its license, timescaledb.license since 2.0 or
+// timescaledb.license_key before, and whether the multi-node catalog exists, which it did from 2.0 until 2.14
+const featuresQuery = `SELECT EXISTS (SELECT FROM pg_extension WHERE extname = 'timescaledb'),
+	COALESCE(current_setting('timescaledb.license', true), current_setting('timescaledb.license_key', true), ''),
+	to_regclass('timescaledb_information.data_nodes') IS NOT NULL;`
+
+// Features are the TimescaleDB features available on a server, which depend on the TimescaleDB version and license.
+// The community (Timescale License) features aren't part of the Apache 2 licensed edition.
+type Features struct {
+	TimescaleDB          bool   `json:"timescaledb"`           // the extension is installed
+	License              string `json:"license,omitempty"`     // e.g. apache or timescale
+	Compression          bool   `json:"compression"`           // native compression of chunks
+	ContinuousAggregates bool   `json:"continuous_aggregates"` // continuous aggregates
+	MultiNode            bool   `json:"multi_node"`            // distributed hypertables on data nodes
+}
+
+// DetectFeatures determines the TimescaleDB features available on the server db is connected to, none if the
+// TimescaleDB extension isn't installed
+func DetectFeatures(ctx context.Context, db Queryable) (*Features, error) {
+	f := &Features{}
+	var multiNodeCatalog bool
+	if err := db.QueryRowContext(ctx, featuresQuery).Scan(&f.TimescaleDB, &f.License, &multiNodeCatalog); err != nil {
+		return nil, err
+	}
+	if !f.TimescaleDB {
+		return &Features{}, nil
+	}
+
+	// the 1.x editions were ApacheOnly, CommunityLicense or an enterprise license key
+	community := f.License != "" && !strings.EqualFold(f.License, "apache") && !strings.EqualFold(f.License, "ApacheOnly")
+	f.Compression = community
+	f.ContinuousAggregates = community
+	f.MultiNode = community && multiNodeCatalog
+	return f, nil
+}
+
+// String lists the features available
+func (f *Features) String() string {
+	if !f.TimescaleDB {
+		return "no timescaledb"
+	}
+
+	var available []string
+	if f.License != "" {
+		available = append(available, f.License+" license")
+	}
+	if f.Compression {
+		available = append(available, "compression")
+	}
+	if f.ContinuousAggregates {
+		available = append(available, "continuous aggregates")
+	}
+	if f.MultiNode {
+		available = append(available, "multi-node")
+	}
+	if len(available) == 0 {
+		return "timescaledb"
+	}
+	return strings.Join(available, ", ")
+}
@@ -0,0 +1,49 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFeatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		row      []driver.Value
+		expected *Features
+		str      string
+	}{
+		{"postgres", []driver.Value{false, "", false}, &Features{}, "no timescaledb"},
+		{"apache", []driver.Value{true, "apache", false}, &Features{TimescaleDB: true, License: "apache"}, "apache license"},
+		{
+			"community",
+			[]driver.Value{true, "timescale", false},
+			&Features{TimescaleDB: true, License: "timescale", Compression: true, ContinuousAggregates: true},
+			"timescale license, compression, continuous aggregates",
+		},
+		{
+			"multi-node",
+			[]driver.Value{true, "timescale", true},
+			&Features{TimescaleDB: true, License: "timescale", Compression: true, ContinuousAggregates: true, MultiNode: true},
+			"timescale license, compression, continuous aggregates, multi-node",
+		},
+		{"1.x apache", []driver.Value{true, "ApacheOnly", false}, &Features{TimescaleDB: true, License: "ApacheOnly"}, "ApacheOnly license"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+				return []string{"installed", "license", "multi_node"}, [][]driver.Value{tt.row}
+			})
+			defer db.Close()
+
+			f, err := DetectFeatures(context.Background(), db)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, f)
+			assert.Equal(t, tt.str, f.String())
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"time"
+)
+
+// FetchMode is how workers read the rows returned by queries
+type FetchMode string
+
+const (
+	// FetchNone executes queries without reading their rows, the default
+	FetchNone FetchMode = "none"
+
+	// FetchRows iterates over the rows of queries without decoding them
+	FetchRows FetchMode = "rows"
+
+	// FetchScan scans every row into typed values (time.Time, float64, etc. by the column types), paying the decoding
+	// cost applications do
+	FetchScan FetchMode = "scan"
+
+	// FetchCursor declares a server side cursor for every query within a transaction and fetches its rows in batches
+	// (see SetFetchSize), as applications streaming large result sets do. The time taken by every fetch is reported
+	// separately (see QueryStats.Fetches).
+	FetchCursor FetchMode = "cursor"
+)
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// fetchQuery executes the query and reads its rows as configured by the fetch mode, returning the # rows read and the
+// time taken until the first one was, 0 if there were none
+func fetchQuery(ctx context.Context, db Queryable, q *Query, mode FetchMode) (int64, time.Duration, error) {
+	start := time.Now()
+	rows, err := db.QueryContext(ctx, q.Query, q.Args...)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	var dest []interface{}
+	if mode == FetchScan {
+		cols, err := rows.ColumnTypes()
+		if err != nil {
+			return 0, 0, err
+		}
+		dest = scanDest(cols)
+	}
+
+	var n int64
+	var first time.Duration
+	for rows.Next() {
+		if dest != nil {
+			if err := rows.Scan(dest...); err != nil {
+				return n, first, err
+			}
+		}
+		if n == 0 {
+			first = time.Since(start)
+		}
+		n++
+	}
+	return n, first, rows.Err()
+}
+
+// scanDest returns the typed destinations of the columns, nullable as any column may be NULL
+func scanDest(cols []*sql.ColumnType) []interface{} {
+	dest := make([]interface{}, len(cols))
+	for i, col := range cols {
+		t := col.ScanType()
+		switch {
+		case t == timeType:
+			dest[i] = new(sql.NullTime)
+		case t == bytesType:
+			dest[i] = new([]byte)
+		case t == nil:
+			dest[i] = new(interface{})
+		default:
+			switch t.Kind() {
+			case reflect.Float32, reflect.Float64:
+				dest[i] = new(sql.NullFloat64)
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				dest[i] = new(sql.NullInt64)
+			case reflect.String:
+				dest[i] = new(sql.NullString)
+			case reflect.Bool:
+				dest[i] = new(sql.NullBool)
+			default:
+				dest[i] = new(interface{})
+			}
+		}
+	}
+	return dest
+}
@@ -0,0 +1,149 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTestFetch(t *testing.T) {
+	ts := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		return []string{"minute", "min", "max", "host"}, [][]driver.Value{
+			{ts, 1.5, 2.5, "host_1"},
+			{ts.Add(time.Minute), nil, int64(3), []byte("host_1")},
+		}
+	})
+	defer db.Close()
+
+	queries := []*Query{{Query: "SELECT", key: "host_1"}}
+	for _, mode := range []FetchMode{FetchRows, FetchScan} {
+		c := NewController(WithFetch(mode))
+		report, err := c.RunTest(context.Background(), db, &replayGenerator{queries: queries, n: 3})
+		require.NoError(t, err, mode)
+		assert.Equal(t, int64(3), report.Processed, mode)
+		assert.Equal(t, int64(6), report.Rows, mode)
+		if assert.NotNil(t, report.FirstRow, mode) {
+			assert.Equal(t, int64(3), report.FirstRow.Processed, mode)
+			assert.True(t, report.FirstRow.Max <= report.Max, mode)
+		}
+	}
+
+	// the rows aren't read by default
+	report, err := NewController().RunTest(context.Background(), db, &replayGenerator{queries: queries, n: 3})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), report.Rows)
+	assert.Nil(t, report.FirstRow)
+}
+
+func TestScanDest(t *testing.T) {
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		return []string{"ts", "usage", "n", "host", "ok", "raw", "null"}, [][]driver.Value{
+			{time.Now(), 1.5, int64(1), "host_1", true, []byte{1}, nil},
+		}
+	})
+	defer db.Close()
+
+	rows, err := db.Query("SELECT")
+	require.NoError(t, err)
+	defer rows.Close()
+	cols, err := rows.ColumnTypes()
+	require.NoError(t, err)
+
+	dest := scanDest(cols)
+	assert.IsType(t, &sql.NullTime{}, dest[0])
+	assert.IsType(t, &sql.NullFloat64{}, dest[1])
+	assert.IsType(t, &sql.NullInt64{}, dest[2])
+	assert.IsType(t, &sql.NullString{}, dest[3])
+	assert.IsType(t, &sql.NullBool{}, dest[4])
+	assert.IsType(t, new([]byte), dest[5])
+	assert.IsType(t, new(interface{}), dest[6])
+
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(dest...))
+	assert.Equal(t, 1.5, dest[1].(*sql.NullFloat64).Float64)
+	assert.True(t, dest[0].(*sql.NullTime).Valid)
+}
+
+func TestRunTestFetchCursor(t *testing.T) {
+	var mu sync.Mutex
+	var declared []string
+	remaining := 0
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var rows [][]driver.Value
+		switch {
+		case strings.HasPrefix(query, "DECLARE"):
+			declared = append(declared, query)
+			remaining = 5
+		case query == "FETCH 2 FROM dbperf_cursor":
+			for ; remaining > 0 && len(rows) < 2; remaining-- {
+				rows = append(rows, []driver.Value{int64(remaining)})
+			}
+		default:
+			t.Errorf("unexpected query: %s", query)
+		}
+		return []string{"n"}, rows
+	})
+	defer db.Close()
+
+	queries := []*Query{{Query: "SELECT n FROM t WHERE host = $1;", Args: []interface{}{"host_1"}, key: "host_1"}}
+	c := NewController(WithFetch(FetchCursor), WithFetchSize(2))
+	report, err := c.RunTest(context.Background(), db, &replayGenerator{queries: queries, n: 2})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"DECLARE dbperf_cursor NO SCROLL CURSOR FOR SELECT n FROM t WHERE host = $1",
+		"DECLARE dbperf_cursor NO SCROLL CURSOR FOR SELECT n FROM t WHERE host = $1",
+	}, declared)
+	assert.Equal(t, int64(10), report.Rows)
+	// 2 + 2 + 1 rows by query
+	require.NotNil(t, report.Fetches)
+	assert.Equal(t, int64(6), report.Fetches.Processed)
+	require.NotNil(t, report.FirstRow)
+	assert.Equal(t, int64(2), report.FirstRow.Processed)
+}
+
+func TestRunTestFetchCursorBinary(t *testing.T) {
+	var mu sync.Mutex
+	var declared []string
+	remaining := 0
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var rows [][]driver.Value
+		switch {
+		case strings.HasPrefix(query, "DECLARE"):
+			declared = append(declared, query)
+			remaining = 3
+		case query == "FETCH 1000 FROM dbperf_cursor":
+			for ; remaining > 0; remaining-- {
+				rows = append(rows, []driver.Value{[]byte{0, 0, 0, 1}})
+			}
+		default:
+			t.Errorf("unexpected query: %s", query)
+		}
+		return []string{"record_send"}, rows
+	})
+	defer db.Close()
+
+	queries := []*Query{{Query: "SELECT n FROM t WHERE host = $1;", Args: []interface{}{"host_1"}, key: "host_1"}}
+	c := NewController(WithFetch(FetchCursor), WithResultFormat(FormatBinary))
+	report, err := c.RunTest(context.Background(), db, &replayGenerator{queries: queries, n: 1})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"DECLARE dbperf_cursor BINARY NO SCROLL CURSOR FOR SELECT record_send(r) FROM (SELECT n FROM t WHERE host = $1) r",
+	}, declared)
+	assert.Equal(t, int64(3), report.Rows)
+}
@@ -0,0 +1,141 @@
+package dbperf
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Perturbation is a way the arguments of a query are perturbed by NewFuzzGenerator
+type Perturbation string
+
+const (
+	// PerturbWiden widens the time range of the query tenfold around its middle
+	PerturbWiden Perturbation = "widen"
+
+	// PerturbNarrow narrows the time range of the query down to a single second in its middle
+	PerturbNarrow Perturbation = "narrow"
+
+	// PerturbSwap swaps the bounds of the time range of the query, which then matches no rows
+	PerturbSwap Perturbation = "swap"
+
+	// PerturbUnknownHost retargets the query at a host that doesn't exist
+	PerturbUnknownHost Perturbation = "unknown-host"
+)
+
+// Perturbations are all the perturbations of NewFuzzGenerator
+var Perturbations = []Perturbation{PerturbWiden, PerturbNarrow, PerturbSwap, PerturbUnknownHost}
+
+// FuzzConfig configures the perturbation of the arguments of queries
+type FuzzConfig struct {
+	Rate          float64        // fraction (0-1) of the queries to perturb
+	Perturbations []Perturbation // the perturbations picked from at random, all of them if empty
+	Seed          int64          // random seed, the same seed perturbs the same queries the same way
+}
+
+// NewFuzzGenerator returns a generator of the queries of gen, a fraction of which have their arguments perturbed to
+// exercise edge cases and the empty result paths alongside the happy path. Queries are expected to take a host and
+// the start and end of their time range as their first three arguments, as those of the cpu usage test case do. The
+// perturbed queries are labeled fuzz:PERTURBATION so their stats are broken down separately and the results expected
+// of them are dropped.
+func NewFuzzGenerator(gen QueryGenerator, cfg FuzzConfig) QueryGenerator {
+	if len(cfg.Perturbations) == 0 {
+		cfg.Perturbations = Perturbations
+	}
+	return &fuzzGenerator{gen: gen, cfg: cfg, rnd: rand.New(rand.NewSource(cfg.Seed))}
+}
+
+type fuzzGenerator struct {
+	gen QueryGenerator
+	cfg FuzzConfig
+	rnd *rand.Rand
+	n   int64 // # queries perturbed, numbering the unknown hosts
+}
+
+func (g *fuzzGenerator) Next() (*Query, error) {
+	q, err := g.gen.Next()
+	if err != nil {
+		return nil, err
+	}
+	if g.rnd.Float64() >= g.cfg.Rate {
+		return q, nil
+	}
+
+	p := g.cfg.Perturbations[g.rnd.Intn(len(g.cfg.Perturbations))]
+	if err := g.perturb(q, p); err != nil {
+		return nil, err
+	}
+	g.n++
+	q.Label = "fuzz:" + string(p)
+	q.Expect = nil
+	return q, nil
+}
+
+// perturb perturbs the arguments of the query
+func (g *fuzzGenerator) perturb(q *Query, p Perturbation) error {
+	if len(q.Args) < 3 {
+		return fmt.Errorf("query has no host and time range arguments to perturb: %s", q.Query)
+	}
+
+	if p == PerturbUnknownHost {
+		host := fmt.Sprintf("unknown_host_%06d", g.n)
+		q.Args = append([]interface{}{host}, q.Args[1:]...)
+		q.Space = host
+		q.key = host
+		return nil
+	}
+
+	start, err := parseArgTime(q.Args[1])
+	if err != nil {
+		return err
+	}
+	end, err := parseArgTime(q.Args[2])
+	if err != nil {
+		return err
+	}
+
+	mid := start.Add(end.Sub(start) / 2)
+	switch p {
+	case PerturbWiden:
+		half := 5 * end.Sub(start)
+		start, end = mid.Add(-half), mid.Add(half)
+	case PerturbNarrow:
+		start, end = mid, mid.Add(time.Second)
+	case PerturbSwap:
+		start, end = end, start
+	default:
+		return fmt.Errorf("unknown perturbation: %s", p)
+	}
+
+	q.Args = append([]interface{}{q.Args[0], formatArgTime(q.Args[1], start), formatArgTime(q.Args[2], end)}, q.Args[3:]...)
+	q.Start, q.End = start, end
+	if p == PerturbSwap {
+		// the swapped range targets no time at all
+		q.Start, q.End = time.Time{}, time.Time{}
+	}
+	return nil
+}
+
+// parseArgTime returns the time of a time argument, a time.Time or a datetime string of the input
+func parseArgTime(arg interface{}) (time.Time, error) {
+	switch v := arg.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		t, _, err := parseDateTime(v, nil)
+		return t, err
+	}
+	return time.Time{}, fmt.Errorf("argument %v isn't a time", arg)
+}
+
+// formatArgTime formats the time like the time argument was
+func formatArgTime(arg interface{}, t time.Time) interface{} {
+	s, ok := arg.(string)
+	if !ok {
+		return t
+	}
+	if _, zoned, _ := parseDateTime(s, nil); zoned {
+		return t.Format(boundDateTimeLayout)
+	}
+	return t.Format(dateTimeLayout)
+}
@@ -0,0 +1,68 @@
+package dbperf
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzGenerator(t *testing.T) {
+	input := "hostname,start_time,end_time\n"
+	for i := 0; i < 1000; i++ {
+		input += "host_000001,2017-01-01 08:00:00,2017-01-01 09:00:00\n"
+	}
+
+	g := NewFuzzGenerator(NewCPUTestGenerator(strings.NewReader(input)), FuzzConfig{Rate: 0.2, Seed: 1})
+	labels := make(map[string]int)
+	for {
+		q, err := g.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		labels[q.Label]++
+
+		args := q.Args
+		switch q.Label {
+		case "":
+			assert.Equal(t, []interface{}{"host_000001", "2017-01-01 08:00:00", "2017-01-01 09:00:00"}, args)
+		case "fuzz:widen":
+			assert.Equal(t, []interface{}{"host_000001", "2017-01-01 03:30:00", "2017-01-01 13:30:00"}, args)
+			assert.Equal(t, 10*time.Hour, q.End.Sub(q.Start))
+		case "fuzz:narrow":
+			assert.Equal(t, []interface{}{"host_000001", "2017-01-01 08:30:00", "2017-01-01 08:30:01"}, args)
+		case "fuzz:swap":
+			assert.Equal(t, []interface{}{"host_000001", "2017-01-01 09:00:00", "2017-01-01 08:00:00"}, args)
+			assert.True(t, q.Start.IsZero())
+		case "fuzz:unknown-host":
+			assert.True(t, strings.HasPrefix(args[0].(string), "unknown_host_"), args[0])
+			assert.Equal(t, args[0], q.Space)
+			assert.Equal(t, []interface{}{"2017-01-01 08:00:00", "2017-01-01 09:00:00"}, args[1:])
+		default:
+			t.Errorf("unexpected label %q", q.Label)
+		}
+	}
+
+	assert.InDelta(t, 800, labels[""], 40)
+	for _, p := range Perturbations {
+		assert.True(t, labels["fuzz:"+string(p)] > 0, p)
+	}
+}
+
+func TestFuzzGeneratorTimeArgs(t *testing.T) {
+	start := time.Date(2017, 1, 1, 8, 0, 0, 0, time.UTC)
+	q := &Query{Args: []interface{}{"host_000001", start, start.Add(time.Hour)}}
+	g := NewFuzzGenerator(&repeatGenerator{*q}, FuzzConfig{Rate: 1, Perturbations: []Perturbation{PerturbNarrow}})
+
+	fuzzed, err := g.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"host_000001", start.Add(30 * time.Minute), start.Add(30*time.Minute + time.Second)}, fuzzed.Args)
+	assert.Equal(t, "fuzz:narrow", fuzzed.Label)
+
+	_, err = NewFuzzGenerator(&repeatGenerator{Query{Query: "SELECT 1"}}, FuzzConfig{Rate: 1}).Next()
+	assert.Error(t, err)
+}
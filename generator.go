@@ -2,6 +2,7 @@ package dbperf
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -13,7 +14,16 @@ import (
 type Query struct {
 	Query string        // The query to run
 	Args  []interface{} // Any arguments to pass on and fill placeholders in the query
+	Space string        // Value of the space partitioning dimension the query targets, if any
+	Label string        // Label to group the stats of the query by, e.g. "dashboard" or "export", if any
+	Start time.Time     // Start of the time range the query targets, if known (see ChunkExclusion)
+	End   time.Time     // End of the time range the query targets, inclusive
 	key   string        // Internal key used for pinning workers - this is dependent on the test being run
+	line  int           // line # of the row of the input the query was read from, if any
+
+	// Expect is the result the query is expected to return, if set the worker fetches the rows of the query and
+	// verifies them (see VerifyStats)
+	Expect *Expectation
 }
 
 // QueryGenerator is an interface for generating queries
@@ -24,26 +34,101 @@ type QueryGenerator interface {
 	Next() (*Query, error)
 }
 
-// NewCPUTestGenerator creats a query generator that understands the cpu usage select test case from the given source
+// NewCPUTestGenerator creats a query generator that understands the cpu usage select test case from the given source.
+// The source is a CSV file of hostname, start and end time with an optional fourth column of the result every query
+// is expected to return, empty for queries not to verify (see ParseExpectation).
 func NewCPUTestGenerator(r io.Reader) QueryGenerator {
 	return &cpuTestGenerator{
 		reader: csv.NewReader(r),
+		query:  cpuTestQuery,
+	}
+}
+
+// NewLastFirstTestGenerator creates a query generator for the cpu usage test case that selects the first and last
+// reading per host over the time range using the TimescaleDB first()/last() aggregates. The input source has the same
+// format as NewCPUTestGenerator.
+func NewLastFirstTestGenerator(r io.Reader) QueryGenerator {
+	return &cpuTestGenerator{
+		reader: csv.NewReader(r),
+		query:  lastFirstTestQuery,
 	}
 }
 
+// InvalidRowError is a row of the input of a generator that isn't a valid query specification. The generator can
+// still be asked for the queries of the rows after it (see InvalidRows).
+type InvalidRowError struct {
+	Line int      // line # of the row in the input
+	Row  []string // fields of the row, nil if it couldn't be split into fields
+	Err  error    // why the row is invalid
+}
+
+func (e *InvalidRowError) Error() string {
+	return fmt.Sprintf("invalid query specification on line %d: %s: %s", e.Line, strings.Join(e.Row, ","), e.Err)
+}
+
+func (e *InvalidRowError) Unwrap() error {
+	return e.Err
+}
+
 type cpuTestGenerator struct {
 	reader     *csv.Reader
 	headerRead bool
+	query      string         // query template the records are bound to
+	loc        *time.Location // location of the timestamps without zone information, nil if unknown
+}
+
+// SetLocation sets the location the timestamps of the input without zone information are in, e.g. UTC for query logs
+// exported in UTC. The timestamps are then bound with their UTC offset, so the server interprets them as the same
+// instants whatever the TimeZone of its sessions.
+func (g *cpuTestGenerator) SetLocation(loc *time.Location) {
+	g.loc = loc
+}
+
+// LocationSetter is implemented by the generators reading timestamps from their input (see NewCPUTestGenerator)
+type LocationSetter interface {
+	SetLocation(loc *time.Location)
 }
 
 // dateTimeLayout specifies the expected format of datetime strings in the CSV file for time.Parse
 const dateTimeLayout = "2006-01-02 15:04:05"
 
+// zonedDateTimeLayouts are the formats of datetime strings with zone information accepted in the CSV file, e.g.
+// 2017-01-01 08:00:00+00 as exported by PostgreSQL or 2017-01-01T08:00:00Z. Fractional seconds are accepted too.
+var zonedDateTimeLayouts = []string{
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05Z07",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02T15:04:05Z07:00",
+}
+
+// boundDateTimeLayout is the format datetime strings with a known zone are bound in, with their UTC offset
+const boundDateTimeLayout = "2006-01-02 15:04:05.999999999-07:00"
+
+// parseDateTime parses a datetime string of the CSV file, in loc (UTC if nil) unless it has zone information, and
+// returns whether it had
+func parseDateTime(s string, loc *time.Location) (time.Time, bool, error) {
+	for _, layout := range zonedDateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true, nil
+		}
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation(dateTimeLayout, s, loc)
+	return t, false, err
+}
+
 const cpuTestQuery = `SELECT date_trunc('minute', ts) AS minute, MIN(usage), MAX(usage) from cpu_usage 
 	WHERE host = $1
     AND ts BETWEEN $2 AND $3
     GROUP BY date_trunc('minute', ts);`
 
+const lastFirstTestQuery = `SELECT host, first(usage, ts), last(usage, ts) from cpu_usage
+	WHERE host = $1
+    AND ts BETWEEN $2 AND $3
+    GROUP BY host;`
+
 func isValidDateTime(s string) bool {
 	_, err := time.Parse(dateTimeLayout, s)
 	return err == nil
@@ -58,23 +143,56 @@ func (g *cpuTestGenerator) Next() (*Query, error) {
 	}
 
 	records, err := g.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		// the reader moves on to the next row after a malformed one
+		return nil, &InvalidRowError{Line: parseErr.StartLine, Row: records, Err: parseErr.Err}
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if len(records) != 3 || !isValidDateTime(records[1]) || !isValidDateTime(records[2]) {
-		return nil, fmt.Errorf("invalid query specification: %s", strings.Join(records, ","))
+	line, _ := g.reader.FieldPos(0)
+	invalid := func(err error) error {
+		return &InvalidRowError{Line: line, Row: records, Err: err}
 	}
 
-	args := make([]interface{}, 0, 3)
-	for _, r := range records {
-		args = append(args, r)
+	if len(records) < 3 || len(records) > 4 {
+		return nil, invalid(fmt.Errorf("expected 3 or 4 fields, got %d", len(records)))
+	}
+	start, startZoned, err := parseDateTime(records[1], g.loc)
+	if err != nil {
+		return nil, invalid(fmt.Errorf("invalid start time: %s", err))
+	}
+	end, endZoned, err := parseDateTime(records[2], g.loc)
+	if err != nil {
+		return nil, invalid(fmt.Errorf("invalid end time: %s", err))
+	}
+
+	// timestamps are bound as they are unless their zone is known
+	args := []interface{}{records[0], records[1], records[2]}
+	if startZoned || g.loc != nil {
+		args[1] = start.Format(boundDateTimeLayout)
+	}
+	if endZoned || g.loc != nil {
+		args[2] = end.Format(boundDateTimeLayout)
 	}
 
 	q := &Query{
 		key:   records[0],
-		Query: cpuTestQuery,
+		Space: records[0],
+		Query: g.query,
 		Args:  args,
+		Start: start,
+		End:   end,
+		line:  line,
+	}
+
+	// the optional fourth column is the result expected (see ParseExpectation)
+	if len(records) == 4 {
+		if q.Expect, err = ParseExpectation(records[3]); err != nil {
+			return nil, invalid(err)
+		}
 	}
 
 	return q, nil
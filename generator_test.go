@@ -1,11 +1,14 @@
 package dbperf
 
 import (
+	"errors"
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // SELECT date_trunc('minute', ts) AS minute, MIN(usage), MAX(usage) from cpu_usage
@@ -31,23 +34,31 @@ host_000001,2017-01-02 13:02:02,2017-01-02 14:02:02`
 			{
 				&Query{
 					key:   "host_000008",
+					line:  2,
+					Space: "host_000008",
 					Query: cpuTestQuery,
 					Args: []interface{}{
 						"host_000008",
 						"2017-01-01 08:59:22",
 						"2017-01-01 09:59:22",
 					},
+					Start: time.Date(2017, 1, 1, 8, 59, 22, 0, time.UTC),
+					End:   time.Date(2017, 1, 1, 9, 59, 22, 0, time.UTC),
 				}, nil,
 			},
 			{
 				&Query{
 					key:   "host_000001",
+					line:  3,
+					Space: "host_000001",
 					Query: cpuTestQuery,
 					Args: []interface{}{
 						"host_000001",
 						"2017-01-02 13:02:02",
 						"2017-01-02 14:02:02",
 					},
+					Start: time.Date(2017, 1, 2, 13, 2, 2, 0, time.UTC),
+					End:   time.Date(2017, 1, 2, 14, 2, 2, 0, time.UTC),
 				}, nil,
 			},
 			{
@@ -68,6 +79,27 @@ host_000001,2017-01-02 13:02:02,2017-01-02 14:02:02`
 		}
 	})
 
+	t.Run("expected results", func(t *testing.T) {
+		input := `hostname,start_time,end_time,expected
+host_000008,2017-01-01 08:59:22,2017-01-01 09:59:22,60
+host_000001,2017-01-02 13:02:02,2017-01-02 14:02:02,
+host_000002,2017-01-02 13:02:02,2017-01-02 14:02:02,sixty`
+
+		g := NewCPUTestGenerator(strings.NewReader(input))
+
+		q, err := g.Next()
+		assert.NoError(t, err)
+		assert.Len(t, q.Args, 3)
+		assert.Equal(t, &Expectation{Rows: 60}, q.Expect)
+
+		q, err = g.Next()
+		assert.NoError(t, err)
+		assert.Nil(t, q.Expect)
+
+		_, err = g.Next()
+		assert.Error(t, err)
+	})
+
 	t.Run("invalid record", func(t *testing.T) {
 		input := `hostname,start_time,end_time
 host_000008,2017-01-0108:59:22,2017-01-01 09:59:22`
@@ -77,6 +109,59 @@ host_000008,2017-01-0108:59:22,2017-01-01 09:59:22`
 		g := NewCPUTestGenerator(buf)
 
 		_, err := g.Next()
-		assert.Contains(t, err.Error(), "invalid query specification")
+		assert.Contains(t, err.Error(), "invalid query specification on line 2")
+		var invalid *InvalidRowError
+		require.True(t, errors.As(err, &invalid))
+		assert.Equal(t, []string{"host_000008", "2017-01-0108:59:22", "2017-01-01 09:59:22"}, invalid.Row)
+	})
+
+	t.Run("time zones", func(t *testing.T) {
+		input := `hostname,start_time,end_time
+host_000001,2017-01-01 08:00:00+00,2017-01-01T10:30:00+01:00
+host_000002,2017-01-01 08:00:00,2017-01-01 09:00:00.5`
+
+		ny, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+		g := NewCPUTestGenerator(strings.NewReader(input))
+		g.(LocationSetter).SetLocation(ny)
+
+		// the timestamps with zone information keep it, those without are in the location set
+		q, err := g.Next()
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{"host_000001", "2017-01-01 08:00:00+00:00", "2017-01-01 10:30:00+01:00"}, q.Args)
+		assert.Equal(t, 90*time.Minute, q.End.Sub(q.Start))
+
+		q, err = g.Next()
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{"host_000002", "2017-01-01 08:00:00-05:00", "2017-01-01 09:00:00.5-05:00"}, q.Args)
+		assert.True(t, q.Start.Equal(time.Date(2017, 1, 1, 13, 0, 0, 0, time.UTC)))
 	})
 }
+
+func TestLastFirstGenerator(t *testing.T) {
+	input := `hostname,start_time,end_time
+host_000008,2017-01-01 08:59:22,2017-01-01 09:59:22`
+
+	g := NewLastFirstTestGenerator(strings.NewReader(input))
+
+	expected := &Query{
+		key:   "host_000008",
+		line:  2,
+		Space: "host_000008",
+		Query: lastFirstTestQuery,
+		Args: []interface{}{
+			"host_000008",
+			"2017-01-01 08:59:22",
+			"2017-01-01 09:59:22",
+		},
+		Start: time.Date(2017, 1, 1, 8, 59, 22, 0, time.UTC),
+		End:   time.Date(2017, 1, 1, 9, 59, 22, 0, time.UTC),
+	}
+
+	actual, err := g.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	_, err = g.Next()
+	assert.Equal(t, io.EOF, err)
+}
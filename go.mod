@@ -1,7 +1,22 @@
 module timescale/dbperf
 
+go 1.27.1
+
 require (
 	github.com/golang/mock v1.2.0
 	github.com/lib/pq v1.0.0
 	github.com/stretchr/testify v1.3.0
+	google.golang.org/grpc v1.71.0
+	google.golang.org/protobuf v1.36.6
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/objx v0.1.0 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
 )
@@ -0,0 +1,151 @@
+package dbperf
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// goldenRecord is the result set of a distinct query as written by a GoldenRecorder, one JSON object per line
+type goldenRecord struct {
+	Query    string          `json:"query"`
+	Args     json.RawMessage `json:"args"`
+	Rows     int64           `json:"rows"`
+	Checksum string          `json:"checksum"`
+	Result   [][]string      `json:"result"` // values of every row as formatted for the checksum
+}
+
+// goldenKey identifies a distinct query by its text and arguments as encoded in JSON
+func goldenKey(query string, args json.RawMessage) string {
+	return query + "\x00" + string(args)
+}
+
+// GoldenRecorder records the full result set of every distinct query (text and arguments) of a golden run once, for
+// later runs to be verified to return identical data (see ReadGolden), e.g. after compressing chunks or switching to a
+// continuous aggregate. Pass it to SetGoldenRecorder.
+type GoldenRecorder struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	enc  *json.Encoder
+	seen map[string]bool // queries recorded or being recorded
+	err  error
+}
+
+// NewGoldenRecorder creates a recorder writing the result sets to w
+func NewGoldenRecorder(w io.Writer) *GoldenRecorder {
+	bw := bufio.NewWriter(w)
+	return &GoldenRecorder{w: bw, enc: json.NewEncoder(bw), seen: make(map[string]bool)}
+}
+
+// claimQuery returns the key of the query if its result set is yet to be recorded, claiming it for the caller to
+// record. It's false for a nil recorder.
+func (g *GoldenRecorder) claimQuery(q *Query) (string, json.RawMessage, bool) {
+	if g == nil {
+		return "", nil, false
+	}
+	args, err := json.Marshal(q.Args)
+	if err != nil {
+		return "", nil, false
+	}
+	key := goldenKey(q.Query, args)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.seen[key] {
+		return "", nil, false
+	}
+	g.seen[key] = true
+	return key, args, true
+}
+
+// record executes the query and records its result set, errors writing it are returned by Flush
+func (g *GoldenRecorder) record(ctx context.Context, db Queryable, q *Query, key string, args json.RawMessage) error {
+	rec := goldenRecord{Query: q.Query, Args: args, Result: [][]string{}}
+	rows, err := db.QueryContext(ctx, q.Query, q.Args...)
+	if err == nil {
+		var s ResultSummary
+		s, err = summarizeRows(rows, func(row []string) { rec.Result = append(rec.Result, row) })
+		rec.Rows, rec.Checksum = s.Rows, s.Checksum
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err != nil {
+		// left for a later execution of the query to record
+		delete(g.seen, key)
+		return err
+	}
+	if g.err == nil {
+		g.err = g.enc.Encode(&rec)
+	}
+	return nil
+}
+
+// Flush writes any buffered result sets, returning the first error writing any of them
+func (g *GoldenRecorder) Flush() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		return g.err
+	}
+	return g.w.Flush()
+}
+
+// Golden is the result set recorded for every distinct query of a golden run, summarized as the result the queries
+// are expected to return
+type Golden map[string]*Expectation
+
+// ReadGolden reads the result sets written by a GoldenRecorder
+func ReadGolden(r io.Reader) (Golden, error) {
+	g := make(Golden)
+
+	dec := json.NewDecoder(r)
+	for {
+		var rec goldenRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		g[goldenKey(rec.Query, rec.Args)] = &Expectation{Rows: rec.Rows, Checksum: rec.Checksum}
+	}
+
+	return g, nil
+}
+
+// Expect sets the result the query is expected to return to the result set recorded for it, returning whether there
+// was one. It modifies the query it's given, which mustn't be executing or shared with a query that is.
+func (g Golden) Expect(q *Query) bool {
+	args, err := json.Marshal(q.Args)
+	if err != nil {
+		return false
+	}
+
+	e, ok := g[goldenKey(q.Query, args)]
+	if ok {
+		q.Expect = e
+	}
+	return ok
+}
+
+// Generator returns a generator of the queries of gen, which are verified to return the result set recorded for them
+// if there is one
+func (g Golden) Generator(gen QueryGenerator) QueryGenerator {
+	return &goldenGenerator{gen: gen, golden: g}
+}
+
+type goldenGenerator struct {
+	gen    QueryGenerator
+	golden Golden
+}
+
+func (g *goldenGenerator) Next() (*Query, error) {
+	q, err := g.gen.Next()
+	if err != nil {
+		return nil, err
+	}
+	g.golden.Expect(q)
+	return q, nil
+}
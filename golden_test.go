@@ -0,0 +1,61 @@
+package dbperf
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGolden(t *testing.T) {
+	compressed := false
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		rows := [][]driver.Value{{args[0], 1.5}, {args[0], 2.5}}
+		// compression lost precision for host_2
+		if compressed && args[0] == "host_2" {
+			rows[1][1] = 2.0
+		}
+		return []string{"host", "usage"}, rows
+	})
+	defer db.Close()
+
+	queries := []*Query{
+		{Query: "SELECT host, usage", Args: []interface{}{"host_1"}, key: "host_1"},
+		{Query: "SELECT host, usage", Args: []interface{}{"host_2"}, key: "host_2"},
+	}
+
+	// the golden run records every distinct query once
+	var buf bytes.Buffer
+	recorder := NewGoldenRecorder(&buf)
+	c := NewController(WithPoolSize(2), WithGoldenRecorder(recorder))
+	report, err := c.RunTest(context.Background(), db, &replayGenerator{queries: queries, n: 6})
+	require.NoError(t, err)
+	require.NoError(t, recorder.Flush())
+	assert.Equal(t, int64(6), report.Processed)
+	assert.Nil(t, report.Verification)
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+	assert.Contains(t, buf.String(), `"result":[["host_1","1.5"],["host_1","2.5"]]`)
+
+	golden, err := ReadGolden(&buf)
+	require.NoError(t, err)
+	assert.Len(t, golden, 2)
+	assert.False(t, golden.Expect(&Query{Query: "SELECT host, usage", Args: []interface{}{"host_3"}}))
+
+	// later runs are verified against it
+	compressed = true
+	queries = []*Query{
+		{Query: "SELECT host, usage", Args: []interface{}{"host_1"}, key: "host_1"},
+		{Query: "SELECT host, usage", Args: []interface{}{"host_2"}, key: "host_2"},
+	}
+	c = NewController(WithPoolSize(2))
+	report, err = c.RunTest(context.Background(), db, golden.Generator(&replayGenerator{queries: queries, n: 4}))
+	require.NoError(t, err)
+	require.NotNil(t, report.Verification)
+	assert.Equal(t, int64(4), report.Verification.Verified)
+	assert.Equal(t, int64(2), report.Verification.Mismatches)
+	assert.Equal(t, []interface{}{"host_2"}, report.Verification.Samples[0].Args)
+}
@@ -0,0 +1,253 @@
+package dbperf
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// histogramSubBits sets the precision of a Histogram: values below 2^histogramSubBits ns are counted exactly and
+// every power of two above is split into 2^(histogramSubBits-1) buckets, bounding the relative error of a
+// percentile to 1/2^(histogramSubBits-1) (~1.6%)
+const histogramSubBits = 7
+
+const (
+	histogramExact = 1 << histogramSubBits       // values counted exactly
+	histogramSubs  = 1 << (histogramSubBits - 1) // buckets per power of two above
+)
+
+// Histogram is a mergeable latency summary in the style of an HDR histogram. Latencies are counted in logarithmic
+// buckets of bounded relative width, so the histograms of runs executed by different processes can be merged and
+// percentiles calculated from the result without keeping every latency around.
+type Histogram struct {
+	Counts map[int]int64 `json:"counts"` // # latencies by bucket index
+	Count  int64         `json:"count"`
+	Sum    time.Duration `json:"sum"`
+	Min    time.Duration `json:"min"`
+	Max    time.Duration `json:"max"`
+}
+
+// NewHistogram creates a histogram of the latencies
+func NewHistogram(latencies []time.Duration) *Histogram {
+	h := &Histogram{Counts: make(map[int]int64)}
+	for _, l := range latencies {
+		h.Record(l)
+	}
+	return h
+}
+
+// histogramIndex returns the bucket a latency is counted in
+func histogramIndex(d time.Duration) int {
+	v := uint64(d)
+	if d < 0 {
+		v = 0
+	}
+	if v < histogramExact {
+		return int(v)
+	}
+
+	shift := bits.Len64(v) - histogramSubBits
+	m := v >> uint(shift) // in [histogramSubs, histogramExact)
+	return histogramExact + (shift-1)*histogramSubs + int(m) - histogramSubs
+}
+
+// histogramBounds returns the range [lo, hi] of latencies counted in the bucket
+func histogramBounds(idx int) (time.Duration, time.Duration) {
+	if idx < histogramExact {
+		return time.Duration(idx), time.Duration(idx)
+	}
+
+	i := idx - histogramExact
+	shift := uint(i/histogramSubs + 1)
+	m := uint64(i%histogramSubs + histogramSubs)
+	return time.Duration(m << shift), time.Duration((m+1)<<shift - 1)
+}
+
+// Record counts a latency
+func (h *Histogram) Record(d time.Duration) {
+	if h.Counts == nil {
+		h.Counts = make(map[int]int64)
+	}
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+	if h.Count == 0 || d > h.Max {
+		h.Max = d
+	}
+
+	h.Counts[histogramIndex(d)]++
+	h.Count++
+	h.Sum += d
+}
+
+// Merge adds the latencies counted by other to the histogram
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other.Count == 0 {
+		return
+	}
+	if h.Counts == nil {
+		h.Counts = make(map[int]int64, len(other.Counts))
+	}
+	if h.Count == 0 || other.Min < h.Min {
+		h.Min = other.Min
+	}
+	if h.Count == 0 || other.Max > h.Max {
+		h.Max = other.Max
+	}
+
+	for idx, n := range other.Counts {
+		h.Counts[idx] += n
+	}
+	h.Count += other.Count
+	h.Sum += other.Sum
+}
+
+// StdDev returns the standard deviation of the latencies counted, estimated from the middle of their buckets
+func (h *Histogram) StdDev() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	mean := float64(h.Sum) / float64(h.Count)
+	var sum float64
+	for idx, n := range h.Counts {
+		lo, hi := histogramBounds(idx)
+		d := float64(lo+hi)/2 - mean
+		sum += d * d * float64(n)
+	}
+	return time.Duration(math.Sqrt(sum / float64(h.Count)))
+}
+
+// Means returns the geometric and harmonic means of the latencies counted, estimated from the middle of their buckets
+// (see means)
+func (h *Histogram) Means() (geo, harmonic time.Duration) {
+	if h.Count == 0 {
+		return 0, 0
+	}
+
+	var logs, inverses float64
+	for idx, n := range h.Counts {
+		lo, hi := histogramBounds(idx)
+		x := math.Max(float64(lo+hi)/2, 1)
+		logs += math.Log(x) * float64(n)
+		inverses += float64(n) / x
+	}
+	count := float64(h.Count)
+	return time.Duration(math.Exp(logs / count)), time.Duration(count / inverses)
+}
+
+// stats summarizes the latencies counted, percentiles within the histogram precision
+func (h *Histogram) stats() *QueryStats {
+	s := &QueryStats{Processed: h.Count, TotalElapsed: h.Sum, Histogram: h}
+	if h.Count > 0 {
+		s.Min = h.Min
+		s.Max = h.Max
+		s.Avg = h.Sum / time.Duration(h.Count)
+		s.StdDev = h.StdDev()
+		s.GeoMean, s.HarmonicMean = h.Means()
+		s.Median = h.Percentile(50)
+		s.P95 = h.Percentile(95)
+		s.P99 = h.Percentile(99)
+	}
+	return s
+}
+
+// Percentile returns the nearest rank percentile p (0-100) of the latencies counted, within the histogram precision
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(h.Count)))
+	if rank < 1 {
+		rank = 1
+	} else if rank > h.Count {
+		rank = h.Count
+	}
+
+	indexes := make([]int, 0, len(h.Counts))
+	for idx := range h.Counts {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	var seen int64
+	for _, idx := range indexes {
+		seen += h.Counts[idx]
+		if seen < rank {
+			continue
+		}
+
+		// the middle of the bucket halves the error, clamped to the latencies actually seen
+		lo, hi := histogramBounds(idx)
+		v := lo + (hi-lo)/2
+		if v < h.Min {
+			v = h.Min
+		}
+		if v > h.Max {
+			v = h.Max
+		}
+		return v
+	}
+
+	return h.Max
+}
+
+// Merge combines the stats of a run executed in parallel with this one, e.g. by another agent sharing the workload,
+// into these stats. The percentiles are recalculated from the merged histograms so both stats need one unless they
+// have no queries. The raw latencies are combined if both stats have all of theirs. Breakdowns (partitions, phases, tenants,
+// etc.) aren't merged.
+func (s *QueryStats) Merge(other *QueryStats) error {
+	if s.Processed > 0 && s.Histogram == nil || other.Processed > 0 && other.Histogram == nil {
+		return fmt.Errorf("stats without a histogram can't be merged")
+	}
+
+	h := &Histogram{}
+	h.Merge(s.Histogram)
+	h.Merge(other.Histogram)
+
+	if int64(len(s.Latencies)) == s.Processed && int64(len(other.Latencies)) == other.Processed {
+		latencies := make([]time.Duration, 0, len(s.Latencies)+len(other.Latencies))
+		latencies = append(append(latencies, s.Latencies...), other.Latencies...)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		s.Latencies = latencies
+	} else {
+		s.Latencies = nil
+	}
+
+	s.Histogram = h
+	s.Processed = h.Count
+	s.TotalElapsed = h.Sum
+	s.Errors += other.Errors
+	s.Rows += other.Rows
+	s.Affected += other.Affected
+	if other.Duration > s.Duration {
+		s.Duration = other.Duration
+	}
+
+	for node, n := range other.NodeErrors {
+		if s.NodeErrors == nil {
+			s.NodeErrors = make(map[string]int64)
+		}
+		s.NodeErrors[node] += n
+	}
+
+	if h.Count > 0 {
+		s.Min = h.Min
+		s.Max = h.Max
+		s.Avg = h.Sum / time.Duration(h.Count)
+		s.StdDev = h.StdDev()
+		s.GeoMean, s.HarmonicMean = h.Means()
+		if s.Latencies != nil {
+			s.StdDev = stdDev(s.Latencies, s.Avg)
+			s.GeoMean, s.HarmonicMean = means(s.Latencies)
+		}
+		s.Median = h.Percentile(50)
+		s.P95 = h.Percentile(95)
+		s.P99 = h.Percentile(99)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package dbperf
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	for _, d := range []time.Duration{0, 1, 127, 128, 129, 255, 256, 1000, time.Millisecond, time.Second, time.Hour} {
+		lo, hi := histogramBounds(histogramIndex(d))
+		assert.True(t, lo <= d && d <= hi, "%d not in [%d, %d]", d, lo, hi)
+		assert.True(t, float64(hi-lo) <= float64(d)/float64(histogramSubs), "bucket of %d too wide: [%d, %d]", d, lo, hi)
+	}
+
+	// buckets are contiguous
+	for idx := 0; idx < 2000; idx++ {
+		_, hi := histogramBounds(idx)
+		lo, _ := histogramBounds(idx + 1)
+		assert.Equal(t, hi+1, lo, "gap after bucket %d", idx)
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	latencies := make([]time.Duration, 10000)
+	for i := range latencies {
+		latencies[i] = time.Duration(rnd.ExpFloat64() * float64(10*time.Millisecond))
+	}
+
+	h := NewHistogram(latencies)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	assert.Equal(t, int64(len(latencies)), h.Count)
+	assert.Equal(t, latencies[0], h.Min)
+	assert.Equal(t, latencies[len(latencies)-1], h.Max)
+	for _, p := range []float64{1, 50, 95, 99, 99.9} {
+		assert.InEpsilon(t, float64(percentile(latencies, p)), float64(h.Percentile(p)), 0.01, "p%v", p)
+	}
+	assert.Equal(t, time.Duration(0), (&Histogram{}).Percentile(50))
+}
+
+func TestHistogramMeans(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	latencies := make([]time.Duration, 10000)
+	for i := range latencies {
+		latencies[i] = time.Duration(rnd.ExpFloat64() * float64(10*time.Millisecond))
+	}
+
+	geo, harmonic := means(latencies)
+	hgeo, hharmonic := NewHistogram(latencies).Means()
+	assert.InEpsilon(t, float64(geo), float64(hgeo), 0.01)
+	assert.InEpsilon(t, float64(harmonic), float64(hharmonic), 0.01)
+	// the heavy tail pulls the arithmetic mean above both
+	assert.True(t, harmonic < geo && geo < 10*time.Millisecond, "geo %s harmonic %s", geo, harmonic)
+
+	geo, harmonic = (&Histogram{}).Means()
+	assert.Equal(t, time.Duration(0), geo)
+	assert.Equal(t, time.Duration(0), harmonic)
+}
+
+func TestQueryStatsMerge(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var all []time.Duration
+	stats := make([]*QueryStats, 3)
+	for i := range stats {
+		latencies := make([]time.Duration, 1000*(i+1))
+		for j := range latencies {
+			latencies[j] = time.Duration((rnd.NormFloat64() + 10*float64(i+1)) * float64(time.Millisecond))
+		}
+		all = append(all, latencies...)
+
+		stats[i] = calculateStats(latencies)
+		stats[i].Histogram = NewHistogram(latencies)
+		stats[i].Duration = time.Duration(i+1) * time.Second
+	}
+
+	merged := &QueryStats{}
+	for _, s := range stats {
+		require.NoError(t, merged.Merge(s))
+	}
+
+	// percentiles are as accurate as the histogram
+	exact := calculateStats(all)
+	assert.Equal(t, exact.Processed, merged.Processed)
+	assert.Equal(t, exact.TotalElapsed, merged.TotalElapsed)
+	assert.Equal(t, exact.Min, merged.Min)
+	assert.Equal(t, exact.Max, merged.Max)
+	assert.Equal(t, exact.Avg, merged.Avg)
+	assert.InEpsilon(t, float64(exact.P95), float64(merged.P95), 0.01)
+	assert.InEpsilon(t, float64(exact.P99), float64(merged.P99), 0.01)
+	assert.InEpsilon(t, float64(exact.StdDev), float64(merged.StdDev), 0.01)
+	assert.Equal(t, 3*time.Second, merged.Duration)
+
+	// raw latencies are dropped as the stats didn't have them
+	assert.Nil(t, merged.Latencies)
+
+	assert.Error(t, merged.Merge(calculateStats(all)))
+}
@@ -0,0 +1,66 @@
+package dbperf
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Trend summarizes how the p99 latency of a workload developed over its recent runs, to catch performance drift
+// between runs as part of continuous benchmarking
+type Trend struct {
+	Runs     []*RunRecord  // runs analyzed, oldest first
+	Baseline time.Duration // median p99 of the runs before the latest
+	Latest   time.Duration // p99 of the latest run
+	Change   float64       // relative change of the latest p99 from the baseline
+	Slope    float64       // least squares fit of the p99 across the runs, relative change per run
+	Drift    bool          // the latest run deviates from the baseline, or the runs drifted overall, by more than the threshold
+}
+
+// AnalyzeTrend analyzes the p99 latency of the last n runs (all of them if n <= 0), ordered oldest first. The runs
+// drifted if the latest p99 changed by more than threshold (e.g. 0.1 for 10%) relative to the median p99 of the
+// runs before it, or if the fitted trend changed by more than threshold from the first run to the last, to catch
+// regressions too gradual to stand out between consecutive runs.
+func AnalyzeTrend(runs []*RunRecord, n int, threshold float64) *Trend {
+	if n > 0 && len(runs) > n {
+		runs = runs[len(runs)-n:]
+	}
+
+	t := &Trend{Runs: runs}
+	if len(runs) == 0 {
+		return t
+	}
+
+	t.Latest = runs[len(runs)-1].Stats.P99
+	if len(runs) < 2 {
+		return t
+	}
+
+	previous := make([]time.Duration, len(runs)-1)
+	for i, run := range runs[:len(runs)-1] {
+		previous[i] = run.Stats.P99
+	}
+	sort.Slice(previous, func(i, j int) bool { return previous[i] < previous[j] })
+	t.Baseline = percentile(previous, 50)
+	if t.Baseline > 0 {
+		t.Change = float64(t.Latest-t.Baseline) / float64(t.Baseline)
+	}
+
+	// least squares fit of p99 = a + b*i
+	var sumX, sumY, sumXY, sumXX float64
+	for i, run := range runs {
+		x, y := float64(i), float64(run.Stats.P99)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	count := float64(len(runs))
+	mean := sumY / count
+	if mean > 0 {
+		t.Slope = (count*sumXY - sumX*sumY) / (count*sumXX - sumX*sumX) / mean
+	}
+
+	t.Drift = math.Abs(t.Change) > threshold || math.Abs(t.Slope*(count-1)) > threshold
+	return t
+}
@@ -0,0 +1,52 @@
+package dbperf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func trendRuns(p99s ...time.Duration) []*RunRecord {
+	runs := make([]*RunRecord, len(p99s))
+	for i, p99 := range p99s {
+		runs[i] = &RunRecord{Stats: &QueryStats{P99: p99}}
+	}
+	return runs
+}
+
+func TestAnalyzeTrend(t *testing.T) {
+	ms := time.Millisecond
+
+	// stable with noise
+	trend := AnalyzeTrend(trendRuns(10*ms, 11*ms, 9*ms, 10*ms, 10*ms), 0, 0.1)
+	assert.Equal(t, 10*ms, trend.Baseline)
+	assert.Equal(t, 10*ms, trend.Latest)
+	assert.Equal(t, 0.0, trend.Change)
+	assert.False(t, trend.Drift)
+
+	// the latest run regressed
+	trend = AnalyzeTrend(trendRuns(10*ms, 11*ms, 9*ms, 10*ms, 13*ms), 0, 0.1)
+	assert.InDelta(t, 0.3, trend.Change, 0.001)
+	assert.True(t, trend.Drift)
+
+	// a gradual regression doesn't stand out from the runs before, but the fit does
+	var gradual []time.Duration
+	for i := 0; i < 10; i++ {
+		gradual = append(gradual, 10*ms+time.Duration(i)*150*time.Microsecond)
+	}
+	trend = AnalyzeTrend(trendRuns(gradual...), 0, 0.1)
+	assert.True(t, trend.Change < 0.1)
+	assert.InDelta(t, 0.15/10.675, trend.Slope, 0.001)
+	assert.True(t, trend.Drift)
+
+	// only the last n runs are considered
+	trend = AnalyzeTrend(trendRuns(50*ms, 10*ms, 10*ms, 10*ms), 3, 0.1)
+	assert.Len(t, trend.Runs, 3)
+	assert.False(t, trend.Drift)
+
+	trend = AnalyzeTrend(trendRuns(10*ms), 0, 0.1)
+	assert.Equal(t, 10*ms, trend.Latest)
+	assert.False(t, trend.Drift)
+	assert.False(t, AnalyzeTrend(nil, 0, 0.1).Drift)
+}
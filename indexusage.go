@@ -0,0 +1,174 @@
+package dbperf
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+const (
+	// tableUsageQuery returns the scans of every user table
+	tableUsageQuery = `SELECT format('%I.%I', schemaname, relname), seq_scan, seq_tup_read, COALESCE(idx_scan, 0)
+	FROM pg_stat_user_tables;`
+
+	// indexUsageQuery returns the scans of every index of the user tables
+	indexUsageQuery = `SELECT format('%I.%I', schemaname, relname), indexrelname, idx_scan, idx_tup_read, idx_tup_fetch
+	FROM pg_stat_user_indexes;`
+)
+
+// TableUsage counts the scans of a table, with those of its chunks if it's a hypertable
+type TableUsage struct {
+	SeqScans      int64 `json:"seq_scans"`
+	SeqTuplesRead int64 `json:"seq_tuples_read"`
+	IndexScans    int64 `json:"index_scans"`
+}
+
+// IndexUsage counts the scans of an index, with those of the indexes of its chunks if it's the index of a hypertable
+type IndexUsage struct {
+	Table         string `json:"table"`
+	Index         string `json:"index"`
+	Scans         int64  `json:"scans"`
+	TuplesRead    int64  `json:"tuples_read"`    // index entries returned by the scans
+	TuplesFetched int64  `json:"tuples_fetched"` // live rows fetched by simple index scans
+}
+
+// UsageSnapshot is a snapshot of the cumulative scan statistics of the tables and indexes of a database. The chunks of
+// hypertables and their indexes are rolled up into the hypertable and its indexes.
+type UsageSnapshot struct {
+	Tables  map[string]*TableUsage // by schema qualified table name
+	Indexes map[string]*IndexUsage // by table and index name
+}
+
+// TakeUsageSnapshot takes a snapshot of the scan statistics of the tables and indexes of the database. The statistics
+// are cumulative across all sessions, so the queries of other clients show up in them as well.
+func TakeUsageSnapshot(ctx context.Context, db Queryable) (*UsageSnapshot, error) {
+	chunks, err := readChunkParents(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &UsageSnapshot{Tables: make(map[string]*TableUsage), Indexes: make(map[string]*IndexUsage)}
+
+	rows, err := db.QueryContext(ctx, tableUsageQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table string
+		var u TableUsage
+		if err := rows.Scan(&table, &u.SeqScans, &u.SeqTuplesRead, &u.IndexScans); err != nil {
+			return nil, err
+		}
+		if c, ok := chunks[table]; ok {
+			table = c.hypertable
+		}
+		t := s.Tables[table]
+		if t == nil {
+			t = &TableUsage{}
+			s.Tables[table] = t
+		}
+		t.SeqScans += u.SeqScans
+		t.SeqTuplesRead += u.SeqTuplesRead
+		t.IndexScans += u.IndexScans
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	irows, err := db.QueryContext(ctx, indexUsageQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer irows.Close()
+	for irows.Next() {
+		var u IndexUsage
+		if err := irows.Scan(&u.Table, &u.Index, &u.Scans, &u.TuplesRead, &u.TuplesFetched); err != nil {
+			return nil, err
+		}
+		// chunk indexes are named after the chunk and the index of the hypertable they were created from
+		if c, ok := chunks[u.Table]; ok {
+			u.Table = c.hypertable
+			u.Index = strings.TrimPrefix(u.Index, c.name+"_")
+		}
+		key := u.Table + " " + u.Index
+		i := s.Indexes[key]
+		if i == nil {
+			i = &IndexUsage{Table: u.Table, Index: u.Index}
+			s.Indexes[key] = i
+		}
+		i.Scans += u.Scans
+		i.TuplesRead += u.TuplesRead
+		i.TuplesFetched += u.TuplesFetched
+	}
+	return s, irows.Err()
+}
+
+// UsageReport reports the scans of the tables and indexes of a database during a run (see UsageDelta)
+type UsageReport struct {
+	Tables  map[string]TableUsage `json:"tables"`  // scans of every table scanned during the run
+	Indexes []IndexUsage          `json:"indexes"` // scans of every index of the tables scanned, most scanned first
+}
+
+// UsageDelta reports the scans made between the snapshots, of the tables scanned in between and all their indexes.
+// Tables and indexes created in between count from zero.
+func UsageDelta(before, after *UsageSnapshot) *UsageReport {
+	r := &UsageReport{Tables: make(map[string]TableUsage)}
+	for table, a := range after.Tables {
+		b := before.Tables[table]
+		if b == nil {
+			b = &TableUsage{}
+		}
+		d := TableUsage{SeqScans: a.SeqScans - b.SeqScans, SeqTuplesRead: a.SeqTuplesRead - b.SeqTuplesRead, IndexScans: a.IndexScans - b.IndexScans}
+		if d.SeqScans > 0 || d.IndexScans > 0 {
+			r.Tables[table] = d
+		}
+	}
+
+	for key, a := range after.Indexes {
+		if _, ok := r.Tables[a.Table]; !ok {
+			continue
+		}
+		d := *a
+		if b := before.Indexes[key]; b != nil {
+			d.Scans -= b.Scans
+			d.TuplesRead -= b.TuplesRead
+			d.TuplesFetched -= b.TuplesFetched
+		}
+		r.Indexes = append(r.Indexes, d)
+	}
+	sort.Slice(r.Indexes, func(i, j int) bool {
+		if r.Indexes[i].Scans != r.Indexes[j].Scans {
+			return r.Indexes[i].Scans > r.Indexes[j].Scans
+		}
+		if r.Indexes[i].Table != r.Indexes[j].Table {
+			return r.Indexes[i].Table < r.Indexes[j].Table
+		}
+		return r.Indexes[i].Index < r.Indexes[j].Index
+	})
+	return r
+}
+
+// Unused returns the indexes of the tables scanned during the run that weren't
+func (r *UsageReport) Unused() []IndexUsage {
+	var unused []IndexUsage
+	for _, i := range r.Indexes {
+		if i.Scans == 0 {
+			unused = append(unused, i)
+		}
+	}
+	return unused
+}
+
+// SeqScanned returns the tables scanned sequentially during the run in name order, which queries expected to use an
+// index shouldn't
+func (r *UsageReport) SeqScanned() []string {
+	var tables []string
+	for table, u := range r.Tables {
+		if u.SeqScans > 0 {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
@@ -0,0 +1,60 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageDelta(t *testing.T) {
+	// the chunk index scans of the run
+	var scans int64
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		switch {
+		case strings.Contains(query, "to_regclass"):
+			return []string{"exists"}, [][]driver.Value{{true}}
+		case strings.Contains(query, "timescaledb_information.chunks"):
+			return []string{"chunk", "chunk_name", "hypertable"}, [][]driver.Value{
+				{"_timescaledb_internal._hyper_1_1_chunk", "_hyper_1_1_chunk", "public.cpu_usage"},
+				{"_timescaledb_internal._hyper_1_2_chunk", "_hyper_1_2_chunk", "public.cpu_usage"},
+			}
+		case strings.Contains(query, "pg_stat_user_tables"):
+			return []string{"table", "seq_scan", "seq_tup_read", "idx_scan"}, [][]driver.Value{
+				{"public.cpu_usage", int64(1), int64(0), int64(0)},
+				{"_timescaledb_internal._hyper_1_1_chunk", int64(0), int64(0), scans},
+				{"_timescaledb_internal._hyper_1_2_chunk", scans / 2, scans * 100, scans},
+				{"public.tags", int64(5), int64(50), int64(0)},
+			}
+		default:
+			return []string{"table", "index", "idx_scan", "idx_tup_read", "idx_tup_fetch"}, [][]driver.Value{
+				{"_timescaledb_internal._hyper_1_1_chunk", "_hyper_1_1_chunk_cpu_usage_host_ts_idx", scans, scans * 10, scans * 10},
+				{"_timescaledb_internal._hyper_1_2_chunk", "_hyper_1_2_chunk_cpu_usage_host_ts_idx", scans, scans * 10, scans * 10},
+				{"_timescaledb_internal._hyper_1_1_chunk", "_hyper_1_1_chunk_cpu_usage_ts_idx", int64(3), int64(30), int64(30)},
+				{"public.tags", "tags_pkey", int64(7), int64(7), int64(7)},
+			}
+		}
+	})
+	defer db.Close()
+
+	before, err := TakeUsageSnapshot(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, &TableUsage{SeqScans: 1, IndexScans: 0}, before.Tables["public.cpu_usage"])
+	require.Contains(t, before.Indexes, "public.cpu_usage cpu_usage_ts_idx")
+
+	scans = 10
+	after, err := TakeUsageSnapshot(context.Background(), db)
+	require.NoError(t, err)
+
+	r := UsageDelta(before, after)
+	assert.Equal(t, map[string]TableUsage{"public.cpu_usage": {SeqScans: 5, SeqTuplesRead: 1000, IndexScans: 20}}, r.Tables)
+	assert.Equal(t, []IndexUsage{
+		{Table: "public.cpu_usage", Index: "cpu_usage_host_ts_idx", Scans: 20, TuplesRead: 200, TuplesFetched: 200},
+		{Table: "public.cpu_usage", Index: "cpu_usage_ts_idx"},
+	}, r.Indexes)
+	assert.Equal(t, []IndexUsage{{Table: "public.cpu_usage", Index: "cpu_usage_ts_idx"}}, r.Unused())
+	assert.Equal(t, []string{"public.cpu_usage"}, r.SeqScanned())
+}
@@ -0,0 +1,97 @@
+package dbperf
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// InterferenceConfig configures heavy queries run in the background of a run, e.g. analytical queries competing with
+// the workload for the server's resources
+type InterferenceConfig struct {
+	Queries     []string // the heavy queries, every connection running them in turn
+	Concurrency int      // # connections running them at the same time, 1 if 0
+}
+
+func (cfg InterferenceConfig) concurrency() int {
+	if cfg.Concurrency > 0 {
+		return cfg.Concurrency
+	}
+	return 1
+}
+
+// InterferenceStats reports the heavy queries run in the background of a run (see SetInterference)
+type InterferenceStats struct {
+	Completed int64 // # heavy queries that completed
+	Errors    int64 // # heavy queries that failed
+	Cancelled int64 // # heavy queries still running at the end of the run, which were cancelled
+
+	// Latency is the time taken by the heavy queries that completed
+	Latency *QueryStats
+}
+
+// interference runs heavy queries in the background until stopped
+type interference struct {
+	cfg InterferenceConfig
+	db  Queryable
+
+	mu        sync.Mutex
+	stats     InterferenceStats
+	latencies []time.Duration
+}
+
+func newInterference(cfg InterferenceConfig, db Queryable) *interference {
+	return &interference{cfg: cfg, db: db}
+}
+
+// run runs the heavy queries until stop is closed, cancelling those in flight, the stats are sent on done when
+// finished
+func (in *interference) run(stop <-chan struct{}, done chan<- InterferenceStats) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < in.cfg.concurrency(); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			in.loop(ctx, i)
+		}(i)
+	}
+
+	<-stop
+	cancel()
+	wg.Wait()
+
+	in.stats.Latency = calculateStats(in.latencies)
+	done <- in.stats
+}
+
+// loop runs the heavy queries in turn on a connection, starting from the i-th one so concurrent connections run
+// different queries, until ctx is cancelled
+func (in *interference) loop(ctx context.Context, i int) {
+	if len(in.cfg.Queries) == 0 {
+		return
+	}
+
+	for ; ; i++ {
+		start := time.Now()
+		_, err := in.db.ExecContext(ctx, in.cfg.Queries[i%len(in.cfg.Queries)])
+		elapsed := time.Since(start)
+
+		in.mu.Lock()
+		switch {
+		case err == nil:
+			in.stats.Completed++
+			in.latencies = append(in.latencies, elapsed)
+		case ctx.Err() != nil:
+			in.stats.Cancelled++
+		default:
+			in.stats.Errors++
+		}
+		in.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
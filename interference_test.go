@@ -0,0 +1,67 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterference(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var mu sync.Mutex
+	executed := make(map[string]int)
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, query string, args ...interface{}) (driver.Result, error) {
+		mu.Lock()
+		executed[query]++
+		mu.Unlock()
+		if query == "SELECT broken" {
+			return nil, errors.New("syntax error")
+		}
+		if query == "SELECT pg_sleep(10)" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		time.Sleep(time.Millisecond)
+		return nil, nil
+	}).AnyTimes()
+
+	in := newInterference(InterferenceConfig{Queries: []string{"SELECT 1", "SELECT broken", "SELECT pg_sleep(10)"}, Concurrency: 2}, mdb)
+	stop := make(chan struct{})
+	done := make(chan InterferenceStats, 1)
+	go in.run(stop, done)
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	s := <-done
+
+	// the connections start from different queries and both end up stuck on the sleep, cancelled at the end
+	assert.Equal(t, int64(2), s.Cancelled)
+	assert.Equal(t, int64(1), s.Completed)
+	assert.Equal(t, int64(2), s.Errors)
+	assert.Equal(t, int64(1), s.Latency.Processed)
+	assert.Equal(t, map[string]int{"SELECT 1": 1, "SELECT broken": 2, "SELECT pg_sleep(10)": 2}, executed)
+}
+
+func TestRunTestInterference(t *testing.T) {
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		return nil, nil
+	})
+	defer db.Close()
+
+	report := runInBackground(t, db, 50*time.Millisecond, func(c *Controller) {
+		c.SetInterference(InterferenceConfig{Queries: []string{"SELECT count(*) FROM cpu_usage"}})
+	})
+	require.NotNil(t, report.Interference)
+	assert.True(t, report.Interference.Completed > 0)
+	assert.Equal(t, int64(0), report.Interference.Errors)
+}
@@ -0,0 +1,104 @@
+package dbperf
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RowPolicy is how the invalid rows of the input are handled
+type RowPolicy string
+
+const (
+	// RowFail fails the run at the first invalid row
+	RowFail RowPolicy = "fail"
+
+	// RowSkip skips the invalid rows, counting them
+	RowSkip RowPolicy = "skip"
+
+	// RowReject skips the invalid rows and writes them to a rejects file, to be fixed and rerun
+	RowReject RowPolicy = "reject"
+)
+
+// InvalidRows handles the invalid rows of the input of generators (see InvalidRowError) by a policy, so a single bad
+// row doesn't abort a long run. Rows are told apart by their line, so a row read again by a later run over the same
+// input is only counted and rejected once.
+type InvalidRows struct {
+	policy  RowPolicy
+	rejects *csv.Writer
+	lines   map[int]bool
+	first   *InvalidRowError
+}
+
+// NewInvalidRows creates a handler of invalid rows by the policy, rejects being where RowReject writes the rows as CSV
+func NewInvalidRows(policy RowPolicy, rejects io.Writer) (*InvalidRows, error) {
+	switch policy {
+	case RowFail, RowSkip:
+	case RowReject:
+		if rejects == nil {
+			return nil, fmt.Errorf("the %s policy needs a rejects file", policy)
+		}
+	default:
+		return nil, fmt.Errorf("unknown invalid row policy: %s", policy)
+	}
+
+	ir := &InvalidRows{policy: policy, lines: make(map[int]bool)}
+	if rejects != nil {
+		ir.rejects = csv.NewWriter(rejects)
+	}
+	return ir, nil
+}
+
+// Count returns the # distinct invalid rows skipped
+func (ir *InvalidRows) Count() int {
+	return len(ir.lines)
+}
+
+// First returns the first invalid row skipped, nil if there were none
+func (ir *InvalidRows) First() *InvalidRowError {
+	return ir.first
+}
+
+// Generator returns a generator of the queries of gen handling its invalid rows by the policy
+func (ir *InvalidRows) Generator(gen QueryGenerator) QueryGenerator {
+	return &invalidRowsGenerator{gen: gen, ir: ir}
+}
+
+// skip records an invalid row skipped, writing it to the rejects file with RowReject
+func (ir *InvalidRows) skip(e *InvalidRowError) error {
+	if ir.lines[e.Line] {
+		return nil
+	}
+	ir.lines[e.Line] = true
+	if ir.first == nil {
+		ir.first = e
+	}
+
+	if ir.policy != RowReject {
+		return nil
+	}
+	if err := ir.rejects.Write(e.Row); err != nil {
+		return err
+	}
+	ir.rejects.Flush()
+	return ir.rejects.Error()
+}
+
+type invalidRowsGenerator struct {
+	gen QueryGenerator
+	ir  *InvalidRows
+}
+
+func (g *invalidRowsGenerator) Next() (*Query, error) {
+	for {
+		q, err := g.gen.Next()
+		var invalid *InvalidRowError
+		if g.ir.policy == RowFail || !errors.As(err, &invalid) {
+			return q, err
+		}
+		if err := g.ir.skip(invalid); err != nil {
+			return nil, fmt.Errorf("failed to write rejected row: %s", err)
+		}
+	}
+}
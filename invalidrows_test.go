@@ -0,0 +1,75 @@
+package dbperf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const invalidRowsInput = `hostname,start_time,end_time
+host_000001,2017-01-01 08:00:00,2017-01-01 09:00:00
+host_000002,yesterday,2017-01-01 09:00:00
+host_000003,2017-01-01 08:00:00
+host_000004,2017-01-01 08:00:00,2017-01-01 09:00:00
+`
+
+// readHosts reads the hosts of the queries of the generator until it's exhausted or fails
+func readHosts(g QueryGenerator) ([]string, error) {
+	var hosts []string
+	for {
+		q, err := g.Next()
+		if err == io.EOF {
+			return hosts, nil
+		}
+		if err != nil {
+			return hosts, err
+		}
+		hosts = append(hosts, q.Space)
+	}
+}
+
+func TestInvalidRowsFail(t *testing.T) {
+	ir, err := NewInvalidRows(RowFail, nil)
+	require.NoError(t, err)
+
+	hosts, err := readHosts(ir.Generator(NewCPUTestGenerator(strings.NewReader(invalidRowsInput))))
+	var invalid *InvalidRowError
+	require.True(t, errors.As(err, &invalid), err)
+	assert.Equal(t, 3, invalid.Line)
+	assert.Equal(t, []string{"host_000001"}, hosts)
+}
+
+func TestInvalidRowsSkip(t *testing.T) {
+	ir, err := NewInvalidRows(RowSkip, nil)
+	require.NoError(t, err)
+
+	// a second pass over the same input doesn't count the rows again
+	for i := 0; i < 2; i++ {
+		hosts, err := readHosts(ir.Generator(NewCPUTestGenerator(strings.NewReader(invalidRowsInput))))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"host_000001", "host_000004"}, hosts)
+	}
+	assert.Equal(t, 2, ir.Count())
+	assert.Equal(t, 3, ir.First().Line)
+}
+
+func TestInvalidRowsReject(t *testing.T) {
+	_, err := NewInvalidRows(RowReject, nil)
+	assert.Error(t, err)
+	_, err = NewInvalidRows("ignore", nil)
+	assert.Error(t, err)
+
+	var rejects bytes.Buffer
+	ir, err := NewInvalidRows(RowReject, &rejects)
+	require.NoError(t, err)
+
+	hosts, err := readHosts(ir.Generator(NewCPUTestGenerator(strings.NewReader(invalidRowsInput))))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"host_000001", "host_000004"}, hosts)
+	assert.Equal(t, "host_000002,yesterday,2017-01-01 09:00:00\nhost_000003,2017-01-01 08:00:00\n", rejects.String())
+}
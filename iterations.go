@@ -0,0 +1,103 @@
+package dbperf
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// tQuantiles are the 97.5th percentiles of Student's t distribution by degrees of freedom (1-30), bounding the two
+// sided 95% confidence interval of a mean
+var tQuantiles = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tQuantile returns the 97.5th percentile of Student's t distribution with df degrees of freedom, the normal
+// distribution's beyond the table
+func tQuantile(df int) float64 {
+	if df <= len(tQuantiles) {
+		return tQuantiles[df-1]
+	}
+	return 1.96
+}
+
+// StatInterval is the mean of a statistic across repeated runs of a workload with its 95% confidence interval
+type StatInterval struct {
+	Name     string  // statistic name
+	Mean     float64 // mean across the runs
+	Margin   float64 // half width of the confidence interval, the mean is Mean ± Margin
+	StdDev   float64 // sample standard deviation across the runs, the square root of their variance
+	Duration bool    // values are durations in nanoseconds
+}
+
+// RelativeMargin returns the margin relative to the mean in percent, 0 if the mean is 0
+func (s StatInterval) RelativeMargin() float64 {
+	if s.Mean == 0 {
+		return 0
+	}
+	return s.Margin / s.Mean * 100
+}
+
+// format a value of the statistic
+func (s StatInterval) format(v float64) string {
+	if s.Duration {
+		return time.Duration(v).String()
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+func (s StatInterval) String() string {
+	return fmt.Sprintf("%s: %s ± %s (±%.2f%%)", s.Name, s.format(s.Mean), s.format(s.Margin), s.RelativeMargin())
+}
+
+// SummarizeRuns returns the mean of the main statistics (see Compare) across repeated runs of a workload with their
+// 95% confidence intervals, based on Student's t distribution, and their spread. The margins and standard deviations
+// are 0 with fewer than two runs.
+func SummarizeRuns(runs []*QueryStats) []StatInterval {
+	intervals := make([]StatInterval, len(mainStats))
+	n := float64(len(runs))
+	for i, stat := range mainStats {
+		intervals[i] = StatInterval{Name: stat.name, Duration: stat.duration}
+		if len(runs) == 0 {
+			continue
+		}
+
+		var sum float64
+		for _, s := range runs {
+			sum += stat.value(s)
+		}
+		mean := sum / n
+		intervals[i].Mean = mean
+		if len(runs) < 2 {
+			continue
+		}
+
+		var squares float64
+		for _, s := range runs {
+			d := stat.value(s) - mean
+			squares += d * d
+		}
+		stdDev := math.Sqrt(squares / (n - 1))
+		intervals[i].StdDev = stdDev
+		intervals[i].Margin = tQuantile(len(runs)-1) * stdDev / math.Sqrt(n)
+	}
+	return intervals
+}
+
+// PoolRuns returns the stats of repeated runs of a workload pooled together, as if their queries were completed by a
+// single run lasting as long as all of them. The runs need histograms (see Merge), the latencies are pooled if every
+// run has all of them.
+func PoolRuns(runs []*QueryStats) (*QueryStats, error) {
+	pooled := &QueryStats{}
+	var duration time.Duration
+	for _, s := range runs {
+		if err := pooled.Merge(s); err != nil {
+			return nil, err
+		}
+		duration += s.Duration
+	}
+	pooled.Duration = duration
+	return pooled, nil
+}
@@ -0,0 +1,65 @@
+package dbperf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeRuns(t *testing.T) {
+	ms := time.Millisecond
+	runs := []*QueryStats{
+		{Processed: 100, Duration: time.Second, P99: 10 * ms},
+		{Processed: 100, Duration: time.Second, P99: 12 * ms},
+		{Processed: 100, Duration: time.Second, P99: 14 * ms},
+	}
+
+	intervals := SummarizeRuns(runs)
+	assert.Len(t, intervals, 8)
+
+	byName := make(map[string]StatInterval)
+	for _, s := range intervals {
+		byName[s.Name] = s
+	}
+
+	// identical runs have no spread
+	assert.Equal(t, StatInterval{Name: "throughput", Mean: 100}, byName["throughput"])
+
+	// stddev 2ms over 3 runs: 4.303 * 2ms / sqrt(3)
+	p99 := byName["p99"]
+	assert.True(t, p99.Duration)
+	assert.Equal(t, float64(12*ms), p99.Mean)
+	assert.InDelta(t, 4.969e6, p99.Margin, 1e3)
+	assert.InDelta(t, float64(2*ms), p99.StdDev, 1)
+	assert.InDelta(t, 41.4, p99.RelativeMargin(), 0.1)
+	assert.Contains(t, p99.String(), "p99: 12ms ± 4.96")
+
+	// a single run has no interval
+	single := SummarizeRuns(runs[:1])
+	assert.Equal(t, float64(10*ms), single[7].Mean)
+	assert.Equal(t, float64(0), single[7].Margin)
+}
+
+func TestPoolRuns(t *testing.T) {
+	ms := time.Millisecond
+	run := func(latencies ...time.Duration) *QueryStats {
+		s := calculateStats(latencies)
+		s.Latencies = latencies
+		s.Histogram = NewHistogram(latencies)
+		s.Duration = time.Second
+		return s
+	}
+
+	pooled, err := PoolRuns([]*QueryStats{run(ms, 2*ms), run(3*ms, 4*ms, 5*ms)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), pooled.Processed)
+	assert.Equal(t, 2*time.Second, pooled.Duration)
+	assert.Equal(t, 2.5, pooled.Throughput())
+	assert.Equal(t, []time.Duration{ms, 2 * ms, 3 * ms, 4 * ms, 5 * ms}, pooled.Latencies)
+	assert.Equal(t, ms, pooled.Min)
+	assert.Equal(t, 5*ms, pooled.Max)
+
+	_, err = PoolRuns([]*QueryStats{{Processed: 1}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package dbperf
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// jtlHeader is the header of JMeter's CSV results (JTL) format, in the column order JMeter writes by default
+var jtlHeader = []string{
+	"timeStamp", "elapsed", "label", "responseCode", "responseMessage", "threadName", "dataType", "success",
+	"failureMessage", "bytes", "sentBytes", "grpThreads", "allThreads", "URL", "Latency", "IdleTime", "Connect",
+}
+
+// JTLWriter writes the result of every query in JMeter's CSV results (JTL) format, for reporting pipelines built
+// around JMeter or Gatling to ingest. Every query is a sample labeled with its label, or the workload if it has none,
+// its Record method may be passed to SetResultFunc.
+type JTLWriter struct {
+	w       *csv.Writer
+	label   string
+	threads int
+}
+
+// NewJTLWriter creates a writer of the results of a run with the given # of workers, labeling the samples of queries
+// without a label with label, and writes the header
+func NewJTLWriter(w io.Writer, label string, threads int) *JTLWriter {
+	j := &JTLWriter{w: csv.NewWriter(w), label: label, threads: threads}
+	j.w.Write(jtlHeader)
+	return j
+}
+
+// Record writes the result of a query, errors writing it are returned by Flush
+func (j *JTLWriter) Record(r QueryResult) {
+	code, message, success, failure := "200", "OK", "true", ""
+	switch {
+	case r.Cancelled:
+		code, message, success, failure = "57014", "cancelled", "false", "query cancelled"
+	case r.Err != nil:
+		code, message, success, failure = "500", "error", "false", r.Err.Error()
+		if e, ok := r.Err.(*pq.Error); ok {
+			code = string(e.Code)
+		}
+	}
+
+	thread := "dbperf"
+	if r.Tenant != "" {
+		thread = r.Tenant
+	}
+	label := j.label
+	if r.Label != "" {
+		label = r.Label
+	}
+	threads := strconv.Itoa(j.threads)
+	elapsed := jtlMillis(r.Latency)
+
+	j.w.Write([]string{
+		strconv.FormatInt(r.Completed.Add(-r.Latency).UnixNano()/int64(time.Millisecond), 10),
+		elapsed,
+		label,
+		code,
+		message,
+		thread + " 1-" + strconv.Itoa(r.Worker+1),
+		"text",
+		success,
+		failure,
+		"0",
+		"0",
+		threads,
+		threads,
+		"",
+		elapsed, // the time to the first response, the whole query for a database
+		"0",
+		jtlMillis(r.Connect),
+	})
+}
+
+// Flush writes any buffered results, returning the first error writing any of them
+func (j *JTLWriter) Flush() error {
+	j.w.Flush()
+	return j.w.Error()
+}
+
+// jtlMillis formats a duration in the whole milliseconds of the JTL format
+func jtlMillis(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Millisecond), 10)
+}
@@ -0,0 +1,36 @@
+package dbperf
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJTLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	completed := time.Unix(1546300800, 0)
+
+	j := NewJTLWriter(&buf, "minmax", 4)
+	j.Record(QueryResult{Latency: 12500 * time.Microsecond, Worker: 2, Completed: completed})
+	j.Record(QueryResult{Latency: time.Millisecond, Err: &pq.Error{Code: "57P01", Message: "terminating connection"}, Tenant: "reader", Completed: completed})
+	j.Record(QueryResult{Latency: 3 * time.Millisecond, Err: errors.New("timeout"), Connect: 2 * time.Millisecond, Completed: completed})
+	j.Record(QueryResult{Latency: time.Millisecond, Label: "export", Completed: completed})
+	require.NoError(t, j.Flush())
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+	assert.Equal(t, jtlHeader, rows[0])
+
+	assert.Equal(t, []string{"1546300799987", "12", "minmax", "200", "OK", "dbperf 1-3", "text", "true", "", "0", "0", "4", "4", "", "12", "0", "0"}, rows[1])
+	assert.Equal(t, []string{"57P01", "reader 1-1", "false", "pq: terminating connection"}, []string{rows[2][3], rows[2][5], rows[2][7], rows[2][8]})
+	assert.Equal(t, []string{"500", "false", "timeout", "2"}, []string{rows[3][3], rows[3][7], rows[3][8], rows[3][16]})
+	// labeled queries are samples of their label
+	assert.Equal(t, "export", rows[4][2])
+}
@@ -0,0 +1,220 @@
+package dbperf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadShape describes a target dispatch rate that varies over the course of a run
+type LoadShape interface {
+	// Rate returns the target rate in queries per second at elapsed time t
+	Rate(t time.Duration) float64
+
+	// Total returns the total number of queries that should have been dispatched by elapsed time t, i.e. the
+	// integral of Rate from 0 to t
+	Total(t time.Duration) float64
+}
+
+// ConstantRate returns a load shape with a fixed rate in queries per second
+func ConstantRate(rate float64) LoadShape {
+	return constantRate(rate)
+}
+
+type constantRate float64
+
+func (r constantRate) Rate(t time.Duration) float64 {
+	return float64(r)
+}
+
+func (r constantRate) Total(t time.Duration) float64 {
+	return float64(r) * t.Seconds()
+}
+
+// SquareWave returns a load shape that alternates between a base rate and bursts at a peak rate. Every period starts
+// at the base rate and spends the final duty fraction (0-1) of the period bursting at the peak rate.
+func SquareWave(base, peak float64, period time.Duration, duty float64) LoadShape {
+	return &squareWave{
+		base:   base,
+		peak:   peak,
+		period: period,
+		duty:   math.Max(0, math.Min(1, duty)),
+	}
+}
+
+type squareWave struct {
+	base   float64
+	peak   float64
+	period time.Duration
+	duty   float64
+}
+
+// low returns how long the base rate lasts each period
+func (w *squareWave) low() float64 {
+	return w.period.Seconds() * (1 - w.duty)
+}
+
+func (w *squareWave) Rate(t time.Duration) float64 {
+	if math.Mod(t.Seconds(), w.period.Seconds()) < w.low() {
+		return w.base
+	}
+	return w.peak
+}
+
+func (w *squareWave) Total(t time.Duration) float64 {
+	period, low := w.period.Seconds(), w.low()
+	perPeriod := w.base*low + w.peak*(period-low)
+
+	cycles := math.Floor(t.Seconds() / period)
+	rem := t.Seconds() - cycles*period
+
+	total := cycles * perPeriod
+	if rem < low {
+		return total + w.base*rem
+	}
+	return total + w.base*low + w.peak*(rem-low)
+}
+
+// SineWave returns a load shape oscillating sinusoidally around the mean rate with the given amplitude and period.
+// The amplitude is capped at the mean so the rate never goes negative.
+func SineWave(mean, amplitude float64, period time.Duration) LoadShape {
+	return &sineWave{
+		mean:      mean,
+		amplitude: math.Min(amplitude, mean),
+		period:    period,
+	}
+}
+
+type sineWave struct {
+	mean      float64
+	amplitude float64
+	period    time.Duration
+}
+
+func (w *sineWave) Rate(t time.Duration) float64 {
+	return w.mean + w.amplitude*math.Sin(2*math.Pi*t.Seconds()/w.period.Seconds())
+}
+
+func (w *sineWave) Total(t time.Duration) float64 {
+	omega := 2 * math.Pi / w.period.Seconds()
+	return w.mean*t.Seconds() + w.amplitude/omega*(1-math.Cos(omega*t.Seconds()))
+}
+
+// ParseLoadShape parses a load shape specification of the form NAME:key=value,... where NAME is one of
+//
+//	constant:rate=R
+//	square:base=R,peak=R,period=D,duty=F
+//	sine:mean=R,amplitude=R,period=D
+//
+// rates (R) are in queries per second, periods (D) are Go durations and duty (F) is the fraction of each period
+// spent bursting (default 0.5), e.g. "square:base=100,peak=1000,period=1m,duty=0.1".
+func ParseLoadShape(spec string) (LoadShape, error) {
+	name, params := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, params = spec[:i], spec[i+1:]
+	}
+
+	values := make(map[string]string)
+	for _, kv := range strings.Split(params, ",") {
+		if kv == "" {
+			continue
+		}
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("load shape %s: expected key=value: %s", name, kv)
+		}
+		values[kv[:i]] = kv[i+1:]
+	}
+
+	p := shapeParams{name: name, values: values}
+	var shape LoadShape
+	switch name {
+	case "constant":
+		shape = ConstantRate(p.rate("rate"))
+	case "square":
+		shape = SquareWave(p.rate("base"), p.rate("peak"), p.period("period"), p.fraction("duty", 0.5))
+	case "sine":
+		shape = SineWave(p.rate("mean"), p.rate("amplitude"), p.period("period"))
+	default:
+		return nil, fmt.Errorf("unknown load shape: %s", name)
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	for key := range values {
+		if !p.used[key] {
+			return nil, fmt.Errorf("load shape %s: unknown parameter: %s", name, key)
+		}
+	}
+
+	return shape, nil
+}
+
+// shapeParams parses load shape parameters, keeping the first error encountered
+type shapeParams struct {
+	name   string
+	values map[string]string
+	used   map[string]bool
+	err    error
+}
+
+func (p *shapeParams) get(key string) (string, bool) {
+	if p.used == nil {
+		p.used = make(map[string]bool)
+	}
+	p.used[key] = true
+
+	v, ok := p.values[key]
+	return v, ok
+}
+
+func (p *shapeParams) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf("load shape %s: %s", p.name, fmt.Sprintf(format, args...))
+	}
+}
+
+func (p *shapeParams) rate(key string) float64 {
+	v, ok := p.get(key)
+	if !ok {
+		p.fail("missing %s", key)
+		return 0
+	}
+
+	r, err := strconv.ParseFloat(strings.TrimSuffix(v, "qps"), 64)
+	if err != nil || r < 0 {
+		p.fail("invalid %s: %s", key, v)
+	}
+	return r
+}
+
+func (p *shapeParams) period(key string) time.Duration {
+	v, ok := p.get(key)
+	if !ok {
+		p.fail("missing %s", key)
+		return 0
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		p.fail("invalid %s: %s", key, v)
+	}
+	return d
+}
+
+func (p *shapeParams) fraction(key string, def float64) float64 {
+	v, ok := p.get(key)
+	if !ok {
+		return def
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || f > 1 {
+		p.fail("invalid %s: %s", key, v)
+	}
+	return f
+}
@@ -0,0 +1,80 @@
+package dbperf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSquareWave(t *testing.T) {
+	// 8s at 10 qps then 2s at 100 qps
+	w := SquareWave(10, 100, time.Second*10, 0.2)
+
+	assert.Equal(t, float64(10), w.Rate(0))
+	assert.Equal(t, float64(10), w.Rate(time.Second*7))
+	assert.Equal(t, float64(100), w.Rate(time.Second*9))
+	assert.Equal(t, float64(10), w.Rate(time.Second*11))
+
+	assert.InDelta(t, 0, w.Total(0), 1e-9)
+	assert.InDelta(t, 40, w.Total(time.Second*4), 1e-9)
+	assert.InDelta(t, 180, w.Total(time.Second*9), 1e-9)
+	assert.InDelta(t, 280, w.Total(time.Second*10), 1e-9)
+	assert.InDelta(t, 570, w.Total(time.Second*21), 1e-9)
+}
+
+func TestSineWave(t *testing.T) {
+	w := SineWave(100, 50, time.Second*4)
+
+	assert.InDelta(t, 100, w.Rate(0), 1e-9)
+	assert.InDelta(t, 150, w.Rate(time.Second), 1e-9)
+	assert.InDelta(t, 50, w.Rate(time.Second*3), 1e-9)
+
+	// over whole periods the oscillation cancels out
+	assert.InDelta(t, 400, w.Total(time.Second*4), 1e-9)
+	assert.InDelta(t, 800, w.Total(time.Second*8), 1e-9)
+	assert.True(t, w.Total(time.Second*2) > 200)
+
+	// amplitude is capped at the mean
+	assert.InDelta(t, 0, SineWave(100, 500, time.Second*4).Rate(time.Second*3), 1e-9)
+}
+
+func TestParseLoadShape(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		tests := []struct {
+			spec     string
+			expected LoadShape
+		}{
+			{"constant:rate=100", ConstantRate(100)},
+			{"square:base=100qps,peak=1000qps,period=1m,duty=0.1", SquareWave(100, 1000, time.Minute, 0.1)},
+			{"square:base=100,peak=1000,period=1m", SquareWave(100, 1000, time.Minute, 0.5)},
+			{"sine:mean=500,amplitude=250,period=5m", SineWave(500, 250, time.Minute*5)},
+		}
+
+		for _, tt := range tests {
+			actual, err := ParseLoadShape(tt.spec)
+			assert.NoError(t, err, tt.spec)
+			assert.Equal(t, tt.expected, actual, tt.spec)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		specs := []string{
+			"",
+			"triangle:rate=100",
+			"constant",
+			"constant:rate=fast",
+			"constant:rate=-1",
+			"constant:rate=100,peak=200",
+			"square:base=100,peak=1000",
+			"square:base=100,peak=1000,period=1m,duty=2",
+			"sine:mean=500,amplitude=250,period=0s",
+			"sine:mean=500;amplitude=250",
+		}
+
+		for _, spec := range specs {
+			_, err := ParseLoadShape(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+}
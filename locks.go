@@ -0,0 +1,215 @@
+package dbperf
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// lockWaitsQuery counts the sessions of the current database waiting for a lock by lock type, only those with the
+// application_name $1 unless it's empty
+const lockWaitsQuery = `SELECT l.locktype, count(*) FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+	WHERE NOT l.granted AND a.datname = current_database() AND ($1 = '' OR a.application_name = $1)
+	GROUP BY l.locktype;`
+
+// defaultLockInterval is how often lock waits are sampled by default
+const defaultLockInterval = 100 * time.Millisecond
+
+// LockConfig configures the sampling of lock waits during a run
+type LockConfig struct {
+	Interval        time.Duration // how often to sample, 100ms if 0
+	ApplicationName string        // only the waits of sessions with this application_name are sampled if set
+}
+
+func (cfg LockConfig) interval() time.Duration {
+	if cfg.Interval > 0 {
+		return cfg.Interval
+	}
+	return defaultLockInterval
+}
+
+// LockSample is a sample of the sessions waiting for locks
+type LockSample struct {
+	At      time.Duration // offset of the sample from the start of the run
+	Waiting int64         // # sessions waiting for a lock
+}
+
+// LockStats reports the lock waits sampled during a run (see SetLockSampling)
+type LockStats struct {
+	Interval time.Duration    // time between samples
+	Samples  int64            // # samples taken
+	Failures int64            // # samples that failed
+	Waits    map[string]int64 // # sessions seen waiting by lock type (relation, transactionid, tuple, ...) over all samples
+	Timeline []LockSample     // the samples that found sessions waiting
+}
+
+// WaitTime estimates the total time sessions waited for locks of the type, every wait seen lasting a sample interval
+func (s *LockStats) WaitTime(lockType string) time.Duration {
+	return time.Duration(s.Waits[lockType]) * s.Interval
+}
+
+// Types returns the lock types waited for, most waited for first
+func (s *LockStats) Types() []string {
+	types := make([]string, 0, len(s.Waits))
+	for t := range s.Waits {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if s.Waits[types[i]] != s.Waits[types[j]] {
+			return s.Waits[types[i]] > s.Waits[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	return types
+}
+
+// lockSampler samples the lock waits of the database until stopped
+type lockSampler struct {
+	cfg   LockConfig
+	db    Queryable
+	start time.Time
+	stats LockStats
+}
+
+func newLockSampler(cfg LockConfig, db Queryable, start time.Time) *lockSampler {
+	return &lockSampler{
+		cfg:   cfg,
+		db:    db,
+		start: start,
+		stats: LockStats{Interval: cfg.interval(), Waits: make(map[string]int64)},
+	}
+}
+
+// run samples lock waits until stop is closed, the stats are sent on done when finished
+func (ls *lockSampler) run(stop <-chan struct{}, done chan<- LockStats) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ticker := time.NewTicker(ls.stats.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			ls.sample(ctx, now)
+		case <-stop:
+			done <- ls.stats
+			return
+		}
+	}
+}
+
+// sample counts the sessions waiting for locks
+func (ls *lockSampler) sample(ctx context.Context, now time.Time) {
+	ls.stats.Samples++
+	rows, err := ls.db.QueryContext(ctx, lockWaitsQuery, ls.cfg.ApplicationName)
+	if err != nil {
+		ls.stats.Failures++
+		return
+	}
+	defer rows.Close()
+
+	var waiting int64
+	for rows.Next() {
+		var lockType string
+		var n int64
+		if err := rows.Scan(&lockType, &n); err != nil {
+			ls.stats.Failures++
+			return
+		}
+		ls.stats.Waits[lockType] += n
+		waiting += n
+	}
+	if rows.Err() != nil {
+		ls.stats.Failures++
+		return
+	}
+	if waiting > 0 {
+		ls.stats.Timeline = append(ls.stats.Timeline, LockSample{At: now.Sub(ls.start), Waiting: waiting})
+	}
+}
+
+// LockCorrelation compares the latency of the seconds of a run sessions were seen waiting for locks in to the rest,
+// to tell whether lock contention explains latency spikes
+type LockCorrelation struct {
+	Seconds         int           // # seconds of the timeline of the run
+	Contended       int           // # seconds with lock waits
+	ContendedP99    time.Duration // median p99 of the seconds with lock waits
+	UncontendedP99  time.Duration // median p99 of the seconds without
+	Spikes          int           // # seconds whose p99 was over twice the median p99 of all seconds
+	ContendedSpikes int           // # spikes in seconds with lock waits
+}
+
+// LockCorrelation correlates the lock waits sampled during the run with its latency timeline, nil if lock waits
+// weren't sampled
+func (r *Report) LockCorrelation() *LockCorrelation {
+	if r.Locks == nil {
+		return nil
+	}
+
+	contended := make(map[int]bool)
+	for _, s := range r.Locks.Timeline {
+		contended[int(s.At/timelineBucket)] = true
+	}
+
+	c := correlate(r.Timeline, func(i int) bool { return contended[i] })
+	return &LockCorrelation{
+		Seconds:         c.seconds,
+		Contended:       c.marked,
+		ContendedP99:    c.markedP99,
+		UncontendedP99:  c.otherP99,
+		Spikes:          c.spikes,
+		ContendedSpikes: c.markedSpikes,
+	}
+}
+
+// correlation compares the latency of the marked seconds of a timeline to the rest
+type correlation struct {
+	seconds      int           // # seconds with queries
+	marked       int           // # marked seconds
+	markedP99    time.Duration // median p99 of the marked seconds
+	otherP99     time.Duration // median p99 of the rest
+	spikes       int           // # seconds whose p99 was over twice the median p99 of all seconds
+	markedSpikes int           // # spikes in marked seconds
+}
+
+// correlate compares the latency of the seconds of the timeline marked returns true for to the rest, the seconds
+// without queries being skipped
+func correlate(timeline []TimelineBucket, marked func(i int) bool) correlation {
+	var c correlation
+	var all, with, without []time.Duration
+	for i, b := range timeline {
+		if b.Processed == 0 {
+			continue
+		}
+		c.seconds++
+		all = append(all, b.P99)
+		if marked(i) {
+			c.marked++
+			with = append(with, b.P99)
+		} else {
+			without = append(without, b.P99)
+		}
+	}
+	c.markedP99 = medianDuration(with)
+	c.otherP99 = medianDuration(without)
+
+	spike := 2 * medianDuration(all)
+	for i, b := range timeline {
+		if b.Processed == 0 || b.P99 <= spike {
+			continue
+		}
+		c.spikes++
+		if marked(i) {
+			c.markedSpikes++
+		}
+	}
+	return c
+}
+
+// medianDuration returns the median of the durations, 0 if there are none
+func medianDuration(ds []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return percentile(sorted, 50)
+}
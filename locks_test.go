@@ -0,0 +1,85 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockSample(t *testing.T) {
+	waits := [][][]driver.Value{
+		{{"relation", int64(2)}, {"transactionid", int64(1)}},
+		nil,
+		{{"transactionid", int64(3)}},
+	}
+	var n int
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		assert.Equal(t, "dbperf", args[0])
+		rows := waits[n]
+		n++
+		return []string{"locktype", "count"}, rows
+	})
+	defer db.Close()
+
+	start := time.Now()
+	ls := newLockSampler(LockConfig{ApplicationName: "dbperf"}, db, start)
+	for i := range waits {
+		ls.sample(context.Background(), start.Add(time.Duration(i)*time.Second))
+	}
+
+	s := ls.stats
+	assert.Equal(t, defaultLockInterval, s.Interval)
+	assert.Equal(t, int64(3), s.Samples)
+	assert.Equal(t, map[string]int64{"relation": 2, "transactionid": 4}, s.Waits)
+	assert.Equal(t, []string{"transactionid", "relation"}, s.Types())
+	assert.Equal(t, 4*defaultLockInterval, s.WaitTime("transactionid"))
+	assert.Equal(t, []LockSample{{At: 0, Waiting: 3}, {At: 2 * time.Second, Waiting: 3}}, s.Timeline)
+}
+
+func TestLockCorrelation(t *testing.T) {
+	r := &Report{QueryStats: &QueryStats{}}
+	assert.Nil(t, r.LockCorrelation())
+
+	ms := time.Millisecond
+	r.Timeline = []TimelineBucket{
+		{Processed: 10, P99: 10 * ms},
+		{Processed: 10, P99: 50 * ms},
+		{Processed: 10, P99: 12 * ms},
+		{},
+		{Processed: 10, P99: 11 * ms},
+		{Processed: 10, P99: 30 * ms},
+	}
+	r.Locks = &LockStats{Timeline: []LockSample{{At: 1100 * ms, Waiting: 2}, {At: 1900 * ms, Waiting: 1}, {At: 3500 * ms, Waiting: 1}}}
+
+	assert.Equal(t, &LockCorrelation{
+		Seconds:         5,
+		Contended:       1,
+		ContendedP99:    50 * ms,
+		UncontendedP99:  11 * ms,
+		Spikes:          2,
+		ContendedSpikes: 1,
+	}, r.LockCorrelation())
+}
+
+func TestRunTestLocks(t *testing.T) {
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		if strings.Contains(query, "pg_locks") {
+			return []string{"locktype", "count"}, [][]driver.Value{{"tuple", int64(1)}}
+		}
+		return nil, nil
+	})
+	defer db.Close()
+
+	report := runInBackground(t, db, 100*time.Millisecond, func(c *Controller) {
+		c.SetLockSampling(LockConfig{Interval: 10 * time.Millisecond})
+	})
+	require.NotNil(t, report.Locks)
+	assert.True(t, report.Locks.Samples > 0)
+	assert.Equal(t, report.Locks.Samples-report.Locks.Failures, report.Locks.Waits["tuple"])
+	assert.NotNil(t, report.LockCorrelation())
+}
@@ -0,0 +1,18 @@
+package dbperf
+
+// Logger is the structured logger the library logs through, a *slog.Logger satisfies it. The args following the
+// message are alternating keys and values (or slog.Attr values), as for slog.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// nopLogger discards the logs, the library is silent unless given a logger
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
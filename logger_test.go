@@ -0,0 +1,41 @@
+package dbperf
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// slog's logger can be passed to the library as is
+var _ Logger = slog.Default()
+
+func TestControllerLogger(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	nodeErr := errors.New("pq: [dn_1]: could not connect to \"dn_1\"")
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), "host_000008", gomock.Any(), gomock.Any()).Return(nil, nodeErr).Times(3)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(7)
+
+	logger := &testLogger{}
+	c := NewController(WithPoolSize(2), WithLogger(logger))
+	c.SetMultiNode(true)
+	_, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"DEBUG run started",
+		"DEBUG data node error",
+		"DEBUG data node error",
+		"DEBUG data node error",
+		"DEBUG run finished",
+	}, logger.messages)
+}
@@ -0,0 +1,29 @@
+package dbperf
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// AnalyzeTable updates the planner statistics of the table, vacuuming it first if vacuum is set, so runs compared to
+// each other are planned from equally fresh statistics. The table name may be schema qualified, hypertables are
+// processed with all their chunks.
+func AnalyzeTable(ctx context.Context, db Queryable, table string, vacuum bool) error {
+	stmt := "ANALYZE "
+	if vacuum {
+		stmt = "VACUUM (ANALYZE) "
+	}
+	_, err := db.ExecContext(ctx, stmt+quoteQualified(table)+";")
+	return err
+}
+
+// quoteQualified quotes every part of the possibly schema qualified name
+func quoteQualified(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = pq.QuoteIdentifier(p)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,23 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeTable(t *testing.T) {
+	var executed []string
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		executed = append(executed, query)
+		return nil, nil
+	})
+	defer db.Close()
+
+	require.NoError(t, AnalyzeTable(context.Background(), db, "cpu_usage", false))
+	require.NoError(t, AnalyzeTable(context.Background(), db, "metrics.cpu_usage", true))
+	assert.Equal(t, []string{`ANALYZE "cpu_usage";`, `VACUUM (ANALYZE) "metrics"."cpu_usage";`}, executed)
+}
@@ -0,0 +1,116 @@
+package dbperf
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// serverVersionsQuery returns the PostgreSQL server version, in full with the platform it was built for and short, and
+// the TimescaleDB extension version, empty if the extension isn't installed
+const serverVersionsQuery = `SELECT version(), current_setting('server_version'),
+	COALESCE((SELECT extversion FROM pg_extension WHERE extname = 'timescaledb'), '');`
+
+// serverSettingsQuery returns the value, with its unit, of every setting named by $1
+const serverSettingsQuery = `SELECT name, current_setting(name) FROM pg_settings WHERE name = ANY(string_to_array($1, ','));`
+
+// ServerSettings are the settings of the server that matter most to performance, recorded in the manifest
+var ServerSettings = []string{
+	"shared_buffers",
+	"max_connections",
+	"work_mem",
+	"effective_cache_size",
+	"max_parallel_workers_per_gather",
+	"random_page_cost",
+	"jit",
+}
+
+// Manifest records how a run was made, the settings, input and versions of everything involved, so its results are
+// reproducible and attributable. It's embedded in the saved results and every report rendered from them.
+type Manifest struct {
+	Args               []string          `json:"args,omitempty"`                // command line arguments
+	Flags              map[string]string `json:"flags,omitempty"`               // value of every setting, defaults included
+	Input              string            `json:"input,omitempty"`               // name of the input the queries were read from
+	InputSHA256        string            `json:"input_sha256,omitempty"`        // checksum of the input
+	Version            string            `json:"version"`                       // dbperf module version
+	Commit             string            `json:"commit,omitempty"`              // VCS revision dbperf was built from
+	GoVersion          string            `json:"go_version"`                    // Go version dbperf was built with
+	Server             string            `json:"server,omitempty"`              // full server version, see version()
+	ServerVersion      string            `json:"server_version,omitempty"`      // PostgreSQL server version
+	TimescaleDBVersion string            `json:"timescaledb_version,omitempty"` // TimescaleDB extension version
+	ServerSettings     map[string]string `json:"server_settings,omitempty"`     // value of every one of ServerSettings
+	Features           *Features         `json:"features,omitempty"`            // TimescaleDB features available
+}
+
+// NewManifest starts the manifest of a run with the versions of dbperf and Go it was built with
+func NewManifest() *Manifest {
+	m := &Manifest{
+		Version:   "(devel)",
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if bi.Main.Version != "" {
+			m.Version = bi.Main.Version
+		}
+		modified := false
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				m.Commit = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		if modified && m.Commit != "" {
+			m.Commit += "-dirty"
+		}
+	}
+
+	return m
+}
+
+// SetInput records the name and checksum of the input read from r
+func (m *Manifest) SetInput(name string, r io.Reader) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+
+	m.Input = name
+	m.InputSHA256 = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// ReadServerVersions records the versions of the PostgreSQL server and TimescaleDB extension of the database
+func (m *Manifest) ReadServerVersions(ctx context.Context, db Queryable) error {
+	return db.QueryRowContext(ctx, serverVersionsQuery).Scan(&m.Server, &m.ServerVersion, &m.TimescaleDBVersion)
+}
+
+// ReadServerSettings records the values of the ServerSettings of the database, so results of servers configured
+// differently are told apart
+func (m *Manifest) ReadServerSettings(ctx context.Context, db Queryable) error {
+	rows, err := db.QueryContext(ctx, serverSettingsQuery, strings.Join(ServerSettings, ","))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	settings := make(map[string]string, len(ServerSettings))
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return err
+		}
+		settings[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	m.ServerSettings = settings
+	return nil
+}
@@ -0,0 +1,45 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewManifest(t *testing.T) {
+	m := NewManifest()
+	assert.Equal(t, runtime.Version(), m.GoVersion)
+	assert.NotEmpty(t, m.Version)
+}
+
+func TestManifestSetInput(t *testing.T) {
+	m := NewManifest()
+	require.NoError(t, m.SetInput("queries.csv", strings.NewReader("abc")))
+	assert.Equal(t, "queries.csv", m.Input)
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", m.InputSHA256)
+}
+
+func TestManifestReadServer(t *testing.T) {
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		if strings.Contains(query, "pg_settings") {
+			assert.Equal(t, strings.Join(ServerSettings, ","), args[0])
+			return []string{"name", "current_setting"}, [][]driver.Value{{"shared_buffers", "128MB"}, {"max_connections", "100"}}
+		}
+		return []string{"version", "server_version", "extversion"}, [][]driver.Value{{"PostgreSQL 14.5 on x86_64-pc-linux-gnu", "14.5", "2.8.1"}}
+	})
+	defer db.Close()
+
+	m := NewManifest()
+	require.NoError(t, m.ReadServerVersions(context.Background(), db))
+	assert.Equal(t, "PostgreSQL 14.5 on x86_64-pc-linux-gnu", m.Server)
+	assert.Equal(t, "14.5", m.ServerVersion)
+	assert.Equal(t, "2.8.1", m.TimescaleDBVersion)
+
+	require.NoError(t, m.ReadServerSettings(context.Background(), db))
+	assert.Equal(t, map[string]string{"shared_buffers": "128MB", "max_connections": "100"}, m.ServerSettings)
+}
@@ -0,0 +1,48 @@
+package dbperf
+
+import (
+	"context"
+	"regexp"
+)
+
+// dataNodeQuery lists the data nodes attached to a multi-node access node
+const dataNodeQuery = `SELECT node_name FROM timescaledb_information.data_nodes ORDER BY node_name;`
+
+// remote errors raised by a data node are relayed by the access node prefixed with the node name, e.g.
+// "[dn_1]: could not connect to server"
+var dataNodeErrorRe = regexp.MustCompile(`\[([^\]\s]+)\]: `)
+
+// DataNodes returns the names of the data nodes attached to the access node db is connected to. An error is
+// returned if the target is not a multi-node access node (or the TimescaleDB version does not support it).
+func DataNodes(ctx context.Context, db Queryable) ([]string, error) {
+	rows, err := db.QueryContext(ctx, dataNodeQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, name)
+	}
+
+	return nodes, rows.Err()
+}
+
+// dataNodeFromError returns the name of the data node that raised err if it was relayed by an access node
+func dataNodeFromError(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+
+	m := dataNodeErrorRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return "", false
+	}
+
+	return m[1], true
+}
@@ -0,0 +1,27 @@
+package dbperf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataNodeFromError(t *testing.T) {
+	tests := []struct {
+		err  error
+		node string
+		ok   bool
+	}{
+		{nil, "", false},
+		{errors.New("pq: relation \"cpu_usage\" does not exist"), "", false},
+		{errors.New("pq: [dn_1]: could not connect to \"dn_1\""), "dn_1", true},
+		{errors.New("pq: [data-node-2]: canceling statement due to statement timeout"), "data-node-2", true},
+	}
+
+	for _, tt := range tests {
+		node, ok := dataNodeFromError(tt.err)
+		assert.Equal(t, tt.ok, ok)
+		assert.Equal(t, tt.node, node)
+	}
+}
@@ -0,0 +1,217 @@
+package dbperf
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// defaultNotifyChannel is the channel notified by default
+	defaultNotifyChannel = "dbperf_notify"
+
+	// defaultNotifyInterval is how often a notification is sent by default
+	defaultNotifyInterval = 100 * time.Millisecond
+
+	// defaultNotifyTimeout is how long notifications are waited for at the end of a run by default
+	defaultNotifyTimeout = 5 * time.Second
+)
+
+// NotifyListener listens for the notifications of channels on a connection of its own
+type NotifyListener interface {
+	Listen(channel string) error
+	Notifications() <-chan string // payloads of the notifications received
+	Close() error
+}
+
+// NotifyConfig configures measuring the delivery latency of notifications during a run
+type NotifyConfig struct {
+	Listener func() NotifyListener // opens the listening connection of a run, e.g. PQListener
+	Channel  string                // channel notified, dbperf_notify if empty
+	Interval time.Duration         // how often a notification is sent, 100ms if 0
+	Timeout  time.Duration         // how long notifications still in flight are waited for at the end, 5s if 0
+}
+
+func (cfg NotifyConfig) withDefaults() NotifyConfig {
+	if cfg.Channel == "" {
+		cfg.Channel = defaultNotifyChannel
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultNotifyInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultNotifyTimeout
+	}
+	return cfg
+}
+
+// NotifyStats reports the delivery of the notifications sent during a run (see SetNotifyLatency)
+type NotifyStats struct {
+	Sent     int64 // # notifications sent
+	Received int64 // # notifications received by the listener
+	Lost     int64 // # notifications sent that weren't received by the end of the run
+	Failures int64 // # notifications that failed to be sent
+
+	// Latency is the time between sending a notification (NOTIFY) and the listener receiving it
+	Latency *QueryStats
+}
+
+// notifier sends notifications on a database and times their delivery to a listener until stopped
+type notifier struct {
+	cfg       NotifyConfig
+	db        Queryable
+	prefix    string              // prefix of the payloads of this notifier, other notifications are ignored
+	seq       int64               // sequence # of the last notification sent
+	pending   map[int64]time.Time // notifications sent but not received yet, by sequence #
+	latencies []time.Duration
+	stats     NotifyStats
+}
+
+func newNotifier(cfg NotifyConfig, db Queryable, start time.Time) *notifier {
+	return &notifier{
+		cfg:     cfg.withDefaults(),
+		db:      db,
+		prefix:  strconv.FormatInt(start.UnixNano(), 36) + ":",
+		pending: make(map[int64]time.Time),
+	}
+}
+
+// run sends notifications until stop is closed and then waits for those in flight, the stats are sent on done when
+// finished. A listener that fails to listen fails every notification.
+func (n *notifier) run(stop <-chan struct{}, done chan<- NotifyStats) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l := n.cfg.Listener()
+	listening := l.Listen(n.cfg.Channel) == nil
+
+	ticker := time.NewTicker(n.cfg.Interval)
+	defer ticker.Stop()
+
+	// nil once the listener closed it
+	notifications := l.Notifications()
+	for stopped := false; !stopped; {
+		select {
+		case <-ticker.C:
+			if !listening {
+				n.stats.Failures++
+				continue
+			}
+			n.send(ctx)
+		case payload, ok := <-notifications:
+			if !ok {
+				notifications = nil
+				continue
+			}
+			n.receive(payload, time.Now())
+		case <-stop:
+			stopped = true
+		}
+	}
+
+	timeout := time.After(n.cfg.Timeout)
+	for len(n.pending) > 0 && notifications != nil {
+		select {
+		case payload, ok := <-notifications:
+			if !ok {
+				notifications = nil
+				continue
+			}
+			n.receive(payload, time.Now())
+		case <-timeout:
+			notifications = nil
+		}
+	}
+	n.stats.Lost = int64(len(n.pending))
+
+	l.Close()
+	n.stats.Latency = calculateStats(n.latencies)
+	done <- n.stats
+}
+
+// send sends the next notification
+func (n *notifier) send(ctx context.Context) {
+	n.seq++
+	sent := time.Now()
+	if _, err := n.db.ExecContext(ctx, "SELECT pg_notify($1, $2);", n.cfg.Channel, n.prefix+strconv.FormatInt(n.seq, 10)); err != nil {
+		n.stats.Failures++
+		return
+	}
+	n.stats.Sent++
+	n.pending[n.seq] = sent
+}
+
+// receive records the delivery of the notification with the payload
+func (n *notifier) receive(payload string, at time.Time) {
+	if !strings.HasPrefix(payload, n.prefix) {
+		return
+	}
+	seq, err := strconv.ParseInt(payload[len(n.prefix):], 10, 64)
+	if err != nil {
+		return
+	}
+	sent, ok := n.pending[seq]
+	if !ok {
+		return
+	}
+	delete(n.pending, seq)
+	n.stats.Received++
+	n.latencies = append(n.latencies, at.Sub(sent))
+}
+
+// PQListener returns a function opening a NotifyListener on a connection of its own to the database of the connection
+// string with lib/pq, for NotifyConfig
+func PQListener(connStr string) func() NotifyListener {
+	return func() NotifyListener {
+		l := &pqListener{
+			l:             pq.NewListener(connStr, 10*time.Millisecond, time.Second, nil),
+			notifications: make(chan string),
+			closed:        make(chan struct{}),
+		}
+		go l.forward()
+		return l
+	}
+}
+
+// pqListener adapts a pq.Listener to a NotifyListener
+type pqListener struct {
+	l             *pq.Listener
+	notifications chan string
+	closed        chan struct{}
+}
+
+func (l *pqListener) Listen(channel string) error {
+	if err := l.l.Listen(channel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %s", channel, err)
+	}
+	return nil
+}
+
+func (l *pqListener) Notifications() <-chan string {
+	return l.notifications
+}
+
+func (l *pqListener) Close() error {
+	close(l.closed)
+	return l.l.Close()
+}
+
+// forward forwards the payloads of the notifications received until the listener is closed, reconnects (nil
+// notifications) are skipped
+func (l *pqListener) forward() {
+	defer close(l.notifications)
+	for n := range l.l.Notify {
+		if n == nil {
+			continue
+		}
+		select {
+		case l.notifications <- n.Extra:
+		case <-l.closed:
+			return
+		}
+	}
+}
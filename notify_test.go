@@ -0,0 +1,94 @@
+package dbperf
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeListener receives the notifications sent to it
+type fakeListener struct {
+	channel       string
+	err           error
+	notifications chan string
+	closed        bool
+}
+
+func (l *fakeListener) Listen(channel string) error {
+	l.channel = channel
+	return l.err
+}
+
+func (l *fakeListener) Notifications() <-chan string { return l.notifications }
+func (l *fakeListener) Close() error                 { l.closed = true; return nil }
+
+func TestNotifierReceive(t *testing.T) {
+	start := time.Now()
+	n := newNotifier(NotifyConfig{}, nil, start)
+	n.pending[1] = start
+	n.pending[2] = start
+
+	n.receive(n.prefix+"1", start.Add(5*time.Millisecond))
+	n.receive("other:1", start.Add(time.Second))
+	n.receive(n.prefix+"1", start.Add(time.Second))
+	n.receive(n.prefix+"x", start.Add(time.Second))
+
+	assert.Equal(t, int64(1), n.stats.Received)
+	assert.Equal(t, []time.Duration{5 * time.Millisecond}, n.latencies)
+	assert.Len(t, n.pending, 1)
+}
+
+func TestRunTestNotify(t *testing.T) {
+	l := &fakeListener{notifications: make(chan string, 100)}
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		if strings.Contains(query, "pg_notify") {
+			assert.Equal(t, "cache", args[0])
+			// every other notification is lost
+			if strings.HasSuffix(args[1].(string), "0") || strings.HasSuffix(args[1].(string), "2") {
+				l.notifications <- args[1].(string)
+			}
+		}
+		return nil, nil
+	})
+	defer db.Close()
+
+	report := runInBackground(t, db, 100*time.Millisecond, func(c *Controller) {
+		c.SetNotifyLatency(NotifyConfig{
+			Listener: func() NotifyListener { return l },
+			Channel:  "cache",
+			Interval: 10 * time.Millisecond,
+			Timeout:  10 * time.Millisecond,
+		})
+	})
+	ns := report.Notify
+	require.NotNil(t, ns)
+	assert.Equal(t, "cache", l.channel)
+	assert.True(t, l.closed)
+	assert.True(t, ns.Sent > 2, ns.Sent)
+	assert.True(t, ns.Received > 0, ns.Received)
+	assert.Equal(t, ns.Sent, ns.Received+ns.Lost)
+	assert.Equal(t, ns.Received, ns.Latency.Processed)
+}
+
+func TestRunTestNotifyListenFailed(t *testing.T) {
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		assert.NotContains(t, query, "pg_notify")
+		return nil, nil
+	})
+	defer db.Close()
+
+	report := runInBackground(t, db, 50*time.Millisecond, func(c *Controller) {
+		c.SetNotifyLatency(NotifyConfig{
+			Listener: func() NotifyListener { return &fakeListener{err: errors.New("no connection")} },
+			Interval: 10 * time.Millisecond,
+		})
+	})
+	require.NotNil(t, report.Notify)
+	assert.Equal(t, int64(0), report.Notify.Sent)
+	assert.True(t, report.Notify.Failures > 0)
+}
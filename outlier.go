@@ -0,0 +1,67 @@
+package dbperf
+
+import (
+	"sort"
+	"time"
+)
+
+// OutlierRule is the rule queries are counted as outliers by, see OutlierConfig
+type OutlierRule string
+
+const (
+	// OutlierIQR counts the latencies beyond 1.5 interquartile ranges from the quartiles (Tukey's fences)
+	OutlierIQR OutlierRule = "iqr"
+	// OutlierMAD counts the latencies with a modified z-score, based on the median absolute deviation, beyond 3.5
+	OutlierMAD OutlierRule = "mad"
+)
+
+// OutlierConfig configures the outlier filtering of the stats of a run, so a couple of network blips don't dominate the
+// average of a short run
+type OutlierConfig struct {
+	Trim float64     // fraction (0-0.5) of both the fastest and the slowest queries left out of the trimmed stats
+	Rule OutlierRule // rule outliers are counted by, none are if empty
+}
+
+// trimmedStats summarizes the sorted latencies without the trim fraction of the fastest and of the slowest
+func trimmedStats(sorted []time.Duration, trim float64) *QueryStats {
+	k := int(trim * float64(len(sorted)))
+	if 2*k >= len(sorted) {
+		return &QueryStats{}
+	}
+	return calculateStats(append([]time.Duration(nil), sorted[k:len(sorted)-k]...))
+}
+
+// countOutliers returns the # of the sorted latencies that are outliers by the rule
+func countOutliers(sorted []time.Duration, rule OutlierRule) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	var lo, hi time.Duration
+	switch rule {
+	case OutlierIQR:
+		q1, q3 := percentile(sorted, 25), percentile(sorted, 75)
+		iqr := q3 - q1
+		lo, hi = q1-iqr*3/2, q3+iqr*3/2
+	case OutlierMAD:
+		median := percentile(sorted, 50)
+		deviations := make([]time.Duration, len(sorted))
+		for i, v := range sorted {
+			if deviations[i] = v - median; deviations[i] < 0 {
+				deviations[i] = -deviations[i]
+			}
+		}
+		sort.Slice(deviations, func(i, j int) bool { return deviations[i] < deviations[j] })
+
+		// |0.6745 (x - median) / MAD| > 3.5
+		limit := time.Duration(3.5 / 0.6745 * float64(percentile(deviations, 50)))
+		lo, hi = median-limit, median+limit
+	default:
+		return 0
+	}
+
+	// the latencies are sorted, count those below the low fence and above the high one
+	below := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= lo })
+	above := len(sorted) - sort.Search(len(sorted), func(i int) bool { return sorted[i] > hi })
+	return int64(below + above)
+}
@@ -0,0 +1,37 @@
+package dbperf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrimmedStats(t *testing.T) {
+	ms := time.Millisecond
+	sorted := []time.Duration{1 * ms, 10 * ms, 10 * ms, 11 * ms, 11 * ms, 12 * ms, 12 * ms, 13 * ms, 13 * ms, 500 * ms}
+
+	stats := trimmedStats(sorted, 0.1)
+	assert.Equal(t, int64(8), stats.Processed)
+	assert.Equal(t, 10*ms, stats.Min)
+	assert.Equal(t, 13*ms, stats.Max)
+	assert.Equal(t, 11500*time.Microsecond, stats.Avg)
+	// the latencies trimmed are left untouched
+	assert.Equal(t, 500*ms, sorted[9])
+
+	assert.Equal(t, int64(0), trimmedStats(sorted, 0.5).Processed)
+	assert.Equal(t, int64(10), trimmedStats(sorted, 0).Processed)
+}
+
+func TestCountOutliers(t *testing.T) {
+	ms := time.Millisecond
+	sorted := []time.Duration{1 * ms, 10 * ms, 10 * ms, 11 * ms, 11 * ms, 12 * ms, 12 * ms, 13 * ms, 13 * ms, 500 * ms}
+
+	// the fences are at 5.5ms and 17.5ms
+	assert.Equal(t, int64(2), countOutliers(sorted, OutlierIQR))
+	// the median is 11ms and the MAD 1ms, latencies further than ~5.2ms from the median are outliers
+	assert.Equal(t, int64(2), countOutliers(sorted, OutlierMAD))
+	assert.Equal(t, int64(0), countOutliers(sorted[1:9], OutlierIQR))
+	assert.Equal(t, int64(0), countOutliers(sorted, ""))
+	assert.Equal(t, int64(0), countOutliers(nil, OutlierMAD))
+}
@@ -0,0 +1,77 @@
+package dbperf
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// QueryParamsConfig configures the synthetic query parameters generated for the cpu usage test case
+type QueryParamsConfig struct {
+	Hosts  int           // # distinct hosts queried, named host_000000 and up
+	Count  int           // # queries to generate
+	Start  time.Time     // earliest time a query range may start
+	End    time.Time     // latest time a query range may end
+	Window time.Duration // length of every query's time range
+	Seed   int64         // random seed, the same seed generates the same queries
+
+	// Recency skews the query ranges towards the end of the time range, like the queries of monitoring dashboards
+	// mostly target the newest data, the time between the end of a query range and the end of the time range being
+	// exponentially distributed with this mean. Query ranges start uniformly within the time range if 0.
+	Recency time.Duration
+}
+
+// GenerateQueryParams writes cpu usage query parameters in the format read by NewCPUTestGenerator. Every query
+// targets a random host over a window starting at a random second within the configured time range, skewed towards
+// its end with Recency.
+func GenerateQueryParams(w io.Writer, cfg QueryParamsConfig) error {
+	if cfg.Hosts <= 0 {
+		return fmt.Errorf("host count must be positive")
+	}
+	if cfg.Window <= 0 {
+		return fmt.Errorf("window must be positive")
+	}
+	if cfg.Recency < 0 {
+		return fmt.Errorf("recency must not be negative")
+	}
+
+	span := cfg.End.Sub(cfg.Start) - cfg.Window
+	if span < 0 {
+		return fmt.Errorf("window %s is longer than the time range", cfg.Window)
+	}
+
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"hostname", "start_time", "end_time"}); err != nil {
+		return err
+	}
+
+	for i := 0; i < cfg.Count; i++ {
+		host := fmt.Sprintf("host_%06d", rnd.Intn(cfg.Hosts))
+		seconds := span / time.Second
+		offset := rnd.Int63n(int64(seconds) + 1)
+		if cfg.Recency > 0 {
+			offset = int64(seconds) - recentAge(rnd, cfg.Recency.Seconds(), float64(seconds))
+		}
+		start := cfg.Start.Add(time.Duration(offset) * time.Second)
+		end := start.Add(cfg.Window)
+
+		if err := writer.Write([]string{host, start.Format(dateTimeLayout), end.Format(dateTimeLayout)}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// recentAge draws a # seconds from an exponential distribution with the mean truncated to the max, by inverting its
+// distribution function, so ages past the max aren't piled up at it
+func recentAge(rnd *rand.Rand, mean, max float64) int64 {
+	u := rnd.Float64() * (1 - math.Exp(-max/mean))
+	age := -mean * math.Log(1-u)
+	return int64(math.Min(math.Floor(age), max))
+}
@@ -0,0 +1,97 @@
+package dbperf
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateQueryParams(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := QueryParamsConfig{
+		Hosts:  3,
+		Count:  50,
+		Start:  start,
+		End:    start.Add(24 * time.Hour),
+		Window: time.Hour,
+		Seed:   42,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, GenerateQueryParams(&buf, cfg))
+
+	// the output is readable by the cpu test generator and within the configured ranges
+	g := NewCPUTestGenerator(bytes.NewReader(buf.Bytes()))
+	n := 0
+	for {
+		q, err := g.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		n++
+
+		assert.Contains(t, []string{"host_000000", "host_000001", "host_000002"}, q.Space)
+
+		from, _ := time.Parse(dateTimeLayout, q.Args[1].(string))
+		to, _ := time.Parse(dateTimeLayout, q.Args[2].(string))
+		assert.Equal(t, cfg.Window, to.Sub(from))
+		assert.False(t, from.Before(cfg.Start))
+		assert.False(t, to.After(cfg.End))
+	}
+	assert.Equal(t, cfg.Count, n)
+
+	// the same seed generates the same queries
+	var again bytes.Buffer
+	require.NoError(t, GenerateQueryParams(&again, cfg))
+	assert.Equal(t, buf.String(), again.String())
+}
+
+func TestGenerateQueryParamsRecency(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := QueryParamsConfig{
+		Hosts:   3,
+		Count:   1000,
+		Start:   start,
+		End:     start.Add(7 * 24 * time.Hour),
+		Window:  time.Hour,
+		Seed:    42,
+		Recency: 6 * time.Hour,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, GenerateQueryParams(&buf, cfg))
+
+	// ~98% of the query ranges end within four means of the end, none of them outside of the time range
+	g := NewCPUTestGenerator(bytes.NewReader(buf.Bytes()))
+	recent := 0
+	for {
+		q, err := g.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		from, _ := time.Parse(dateTimeLayout, q.Args[1].(string))
+		to, _ := time.Parse(dateTimeLayout, q.Args[2].(string))
+		assert.False(t, from.Before(cfg.Start))
+		assert.False(t, to.After(cfg.End))
+		if cfg.End.Sub(to) < 4*cfg.Recency {
+			recent++
+		}
+	}
+	assert.InDelta(t, 980, recent, 20)
+}
+
+func TestGenerateQueryParamsInvalid(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	assert.Error(t, GenerateQueryParams(&buf, QueryParamsConfig{Hosts: 0, Count: 1, Start: start, End: start.Add(time.Hour), Window: time.Minute}))
+	assert.Error(t, GenerateQueryParams(&buf, QueryParamsConfig{Hosts: 1, Count: 1, Start: start, End: start.Add(time.Hour), Window: 2 * time.Hour}))
+	assert.Error(t, GenerateQueryParams(&buf, QueryParamsConfig{Hosts: 1, Count: 1, Start: start, End: start.Add(time.Hour), Window: time.Minute, Recency: -time.Minute}))
+}
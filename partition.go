@@ -0,0 +1,124 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Partitioner maps the space dimension value a query targets to the name of the space partition it is stored in
+type Partitioner interface {
+	Partition(ctx context.Context, value string) (string, error)
+}
+
+// numSlicesQuery returns the number of space partitions of a hypertable
+const numSlicesQuery = `SELECT d.num_slices FROM _timescaledb_catalog.dimension d
+	JOIN _timescaledb_catalog.hypertable h ON h.id = d.hypertable_id
+	WHERE h.table_name = $1 AND d.num_slices IS NOT NULL
+	LIMIT 1;`
+
+// hashFuncQuery resolves the partitioning hash function which moved schemas in TimescaleDB 2.12
+const hashFuncQuery = `SELECT COALESCE(to_regproc('_timescaledb_functions.get_partition_hash'),
+	to_regproc('_timescaledb_internal.get_partition_hash'))::text;`
+
+// SpacePartitioner is a Partitioner for the space (hash) dimension of a TimescaleDB hypertable. Values are hashed
+// by the server so the partition assignment always matches where the data actually lives.
+type SpacePartitioner struct {
+	db        Queryable
+	numSlices int
+	hashFunc  string
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// NewSpacePartitioner looks up the space partitioning of the given hypertable
+func NewSpacePartitioner(ctx context.Context, db Queryable, hypertable string) (*SpacePartitioner, error) {
+	p := &SpacePartitioner{
+		db:    db,
+		cache: make(map[string]string),
+	}
+
+	if err := db.QueryRowContext(ctx, numSlicesQuery, hypertable).Scan(&p.numSlices); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("hypertable %s has no space dimension", hypertable)
+		}
+		return nil, err
+	}
+
+	var hashFunc sql.NullString
+	if err := db.QueryRowContext(ctx, hashFuncQuery).Scan(&hashFunc); err != nil {
+		return nil, err
+	}
+	if !hashFunc.Valid {
+		return nil, fmt.Errorf("get_partition_hash function not found")
+	}
+	p.hashFunc = hashFunc.String
+
+	return p, nil
+}
+
+// Partition returns the partition the value hashes to
+func (p *SpacePartitioner) Partition(ctx context.Context, value string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if partition, ok := p.cache[value]; ok {
+		return partition, nil
+	}
+
+	var hash int64
+	query := fmt.Sprintf("SELECT %s($1::text);", p.hashFunc)
+	if err := p.db.QueryRowContext(ctx, query, value).Scan(&hash); err != nil {
+		return "", err
+	}
+
+	partition := partitionName(sliceIndex(hash, p.numSlices))
+	p.cache[value] = partition
+	return partition, nil
+}
+
+// sliceIndex calculates the dimension slice a hash value falls in when the hash range is divided evenly into n slices
+func sliceIndex(hash int64, n int) int {
+	if n <= 1 {
+		return 0
+	}
+
+	interval := int64(math.MaxInt32) / int64(n)
+	idx := int(hash / interval)
+	if idx >= n {
+		idx = n - 1
+	}
+
+	return idx
+}
+
+func partitionName(idx int) string {
+	return fmt.Sprintf("partition_%d", idx)
+}
+
+// partitionStats groups the latencies by space value into per partition stats
+func partitionStats(ctx context.Context, p Partitioner, bySpace map[string]*samples) (map[string]*QueryStats, error) {
+	byPartition := make(map[string]*samples)
+	for value, latencies := range bySpace {
+		partition, err := p.Partition(ctx, value)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := byPartition[partition]
+		if !ok {
+			s = newSamples(latencies.hist != nil)
+			byPartition[partition] = s
+		}
+		s.merge(latencies)
+	}
+
+	stats := make(map[string]*QueryStats, len(byPartition))
+	for partition, latencies := range byPartition {
+		stats[partition] = latencies.stats()
+	}
+
+	return stats, nil
+}
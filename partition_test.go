@@ -0,0 +1,60 @@
+package dbperf
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// staticPartitioner assigns values to partitions from a fixed map
+type staticPartitioner map[string]string
+
+func (p staticPartitioner) Partition(ctx context.Context, value string) (string, error) {
+	return p[value], nil
+}
+
+func TestSliceIndex(t *testing.T) {
+	tests := []struct {
+		hash     int64
+		n        int
+		expected int
+	}{
+		{0, 1, 0},
+		{math.MaxInt32 - 1, 1, 0},
+		{0, 4, 0},
+		{math.MaxInt32/4 - 1, 4, 0},
+		{math.MaxInt32 / 4, 4, 1},
+		{math.MaxInt32 - 1, 4, 3},
+		{math.MaxInt32 - 1, 3, 2},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, sliceIndex(tt.hash, tt.n), "hash %d n %d", tt.hash, tt.n)
+	}
+}
+
+func TestPartitionStats(t *testing.T) {
+	p := staticPartitioner{
+		"host_000001": "partition_0",
+		"host_000002": "partition_1",
+		"host_000003": "partition_0",
+	}
+
+	bySpace := map[string]*samples{
+		"host_000001": {latencies: []time.Duration{time.Millisecond * 10, time.Millisecond * 20}},
+		"host_000002": {latencies: []time.Duration{time.Millisecond * 100}},
+		"host_000003": {latencies: []time.Duration{time.Millisecond * 30}},
+	}
+
+	stats, err := partitionStats(context.Background(), p, bySpace)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+
+	assert.Equal(t, int64(3), stats["partition_0"].Processed)
+	assert.Equal(t, time.Millisecond*20, stats["partition_0"].Median)
+	assert.Equal(t, int64(1), stats["partition_1"].Processed)
+	assert.Equal(t, time.Millisecond*100, stats["partition_1"].Max)
+}
@@ -0,0 +1,165 @@
+package dbperf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// chunkSchema is the schema TimescaleDB creates the chunks of hypertables in
+const chunkSchema = "_timescaledb_internal"
+
+// PlanExpectation is what the plans of the queries of a query template are expected to look like
+type PlanExpectation struct {
+	Template  string   `yaml:"template"`    // the query template (see NormalizeQuery), or a query of it
+	UseIndex  []string `yaml:"use_index"`   // indexes the plan must scan, the indexes of chunks by their hypertable's
+	NoSeqScan bool     `yaml:"no_seq_scan"` // the plan must not scan any table sequentially
+	MaxChunks int      `yaml:"max_chunks"`  // the plan must not scan more chunks than this, the rest being excluded
+}
+
+// PlanViolation is a plan that didn't meet the expectation of its query template
+type PlanViolation struct {
+	Template string
+	Query    string
+	Args     []interface{}
+	Reasons  []string // the expectations the plan violated
+}
+
+// planNode is a node of a plan as explained in JSON
+type planNode struct {
+	NodeType string     `json:"Node Type"`
+	Relation string     `json:"Relation Name"`
+	Schema   string     `json:"Schema"`
+	Index    string     `json:"Index Name"`
+	Plans    []planNode `json:"Plans"`
+}
+
+// PlanChecker explains the first query of every query template with an expectation when it's executed and checks
+// its plan meets the expectation, e.g. that it uses an index or excludes chunks, combining plan validation with
+// performance testing. Later queries of a template aren't checked, their plans may still differ with their arguments.
+// Pass it to SetPlanChecker.
+type PlanChecker struct {
+	mu           sync.Mutex
+	expectations map[string]PlanExpectation // by template
+	checked      map[string]bool            // templates checked or being checked
+	violations   []PlanViolation
+}
+
+// NewPlanChecker creates a checker of the plans of the queries of the templates of the expectations
+func NewPlanChecker(expectations []PlanExpectation) *PlanChecker {
+	pc := &PlanChecker{
+		expectations: make(map[string]PlanExpectation, len(expectations)),
+		checked:      make(map[string]bool),
+	}
+	for _, e := range expectations {
+		e.Template = NormalizeQuery(e.Template)
+		pc.expectations[e.Template] = e
+	}
+	return pc
+}
+
+// claimQuery returns the expectation of the template of the query if its plan is yet to be checked, claiming it for
+// the caller to check. It's false for a nil checker.
+func (pc *PlanChecker) claimQuery(q *Query) (PlanExpectation, bool) {
+	if pc == nil {
+		return PlanExpectation{}, false
+	}
+	template := NormalizeQuery(q.Query)
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	e, ok := pc.expectations[template]
+	if !ok || pc.checked[template] {
+		return PlanExpectation{}, false
+	}
+	pc.checked[template] = true
+	return e, true
+}
+
+// check explains the query and records a violation if its plan doesn't meet the expectation
+func (pc *PlanChecker) check(ctx context.Context, db Queryable, q *Query, e PlanExpectation) error {
+	var explained string
+	err := db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON, VERBOSE) "+q.Query, q.Args...).Scan(&explained)
+
+	var plans []struct {
+		Plan planNode `json:"Plan"`
+	}
+	if err == nil {
+		err = json.Unmarshal([]byte(explained), &plans)
+	}
+	if err == nil && len(plans) == 0 {
+		err = fmt.Errorf("no plan")
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if err != nil {
+		// left for a later execution of the template to check
+		delete(pc.checked, e.Template)
+		return err
+	}
+	if reasons := e.violations(plans[0].Plan); len(reasons) > 0 {
+		pc.violations = append(pc.violations, PlanViolation{Template: e.Template, Query: q.Query, Args: q.Args, Reasons: reasons})
+	}
+	return nil
+}
+
+// Violations returns the plans checked so far that violated their expectations
+func (pc *PlanChecker) Violations() []PlanViolation {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return append([]PlanViolation(nil), pc.violations...)
+}
+
+// violations returns the expectations the plan violates
+func (e PlanExpectation) violations(plan planNode) []string {
+	indexes := make(map[string]bool)
+	chunks := make(map[string]bool)
+	var seqScans []string
+	var walk func(n planNode)
+	walk = func(n planNode) {
+		if n.Index != "" {
+			indexes[n.Index] = true
+		}
+		if n.Relation != "" && n.Schema == chunkSchema {
+			chunks[n.Relation] = true
+		}
+		if n.NodeType == "Seq Scan" {
+			seqScans = append(seqScans, n.Relation)
+		}
+		for _, c := range n.Plans {
+			walk(c)
+		}
+	}
+	walk(plan)
+
+	var reasons []string
+	for _, index := range e.UseIndex {
+		if !usesIndex(indexes, index) {
+			reasons = append(reasons, fmt.Sprintf("doesn't use index %s", index))
+		}
+	}
+	if e.NoSeqScan && len(seqScans) > 0 {
+		reasons = append(reasons, fmt.Sprintf("scans %s sequentially", strings.Join(seqScans, ", ")))
+	}
+	if e.MaxChunks > 0 && len(chunks) > e.MaxChunks {
+		reasons = append(reasons, fmt.Sprintf("scans %d chunks, expected at most %d", len(chunks), e.MaxChunks))
+	}
+	return reasons
+}
+
+// usesIndex returns whether the index, or an index of a chunk created from it, is one of the indexes scanned. Chunk
+// indexes are named after the chunk and the index of the hypertable they were created from.
+func usesIndex(scanned map[string]bool, index string) bool {
+	if scanned[index] {
+		return true
+	}
+	for i := range scanned {
+		if strings.HasPrefix(i, "_hyper_") && strings.HasSuffix(i, "_chunk_"+index) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkIndexPlan scans two chunks of cpu_usage by their host index
+const chunkIndexPlan = `[{"Plan": {"Node Type": "Append", "Plans": [
+	{"Node Type": "Index Scan", "Relation Name": "_hyper_1_1_chunk", "Schema": "_timescaledb_internal", "Index Name": "_hyper_1_1_chunk_cpu_usage_host_idx"},
+	{"Node Type": "Index Scan", "Relation Name": "_hyper_1_2_chunk", "Schema": "_timescaledb_internal", "Index Name": "_hyper_1_2_chunk_cpu_usage_host_idx"}
+]}}]`
+
+// seqScanPlan scans a table sequentially
+const seqScanPlan = `[{"Plan": {"Node Type": "Aggregate", "Plans": [{"Node Type": "Seq Scan", "Relation Name": "hosts", "Schema": "public"}]}}]`
+
+func TestPlanExpectationViolations(t *testing.T) {
+	tests := []struct {
+		e       PlanExpectation
+		plan    string
+		reasons []string
+	}{
+		{PlanExpectation{UseIndex: []string{"cpu_usage_host_idx"}, NoSeqScan: true, MaxChunks: 2}, chunkIndexPlan, nil},
+		{PlanExpectation{UseIndex: []string{"cpu_usage_ts_idx"}, MaxChunks: 1}, chunkIndexPlan,
+			[]string{"doesn't use index cpu_usage_ts_idx", "scans 2 chunks, expected at most 1"}},
+		{PlanExpectation{NoSeqScan: true}, seqScanPlan, []string{"scans hosts sequentially"}},
+		{PlanExpectation{}, seqScanPlan, nil},
+	}
+
+	for _, tt := range tests {
+		db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+			assert.True(t, strings.HasPrefix(query, "EXPLAIN (FORMAT JSON, VERBOSE) "), query)
+			return []string{"QUERY PLAN"}, [][]driver.Value{{tt.plan}}
+		})
+
+		pc := NewPlanChecker([]PlanExpectation{tt.e})
+		require.NoError(t, pc.check(context.Background(), db, &Query{Query: "SELECT 1"}, tt.e))
+		var reasons []string
+		for _, v := range pc.Violations() {
+			reasons = append(reasons, v.Reasons...)
+		}
+		assert.Equal(t, tt.reasons, reasons, "%+v", tt.e)
+		db.Close()
+	}
+}
+
+func TestRunTestPlanChecker(t *testing.T) {
+	var explained int
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		if strings.HasPrefix(query, "EXPLAIN") {
+			explained++
+			return []string{"QUERY PLAN"}, [][]driver.Value{{seqScanPlan}}
+		}
+		return nil, nil
+	})
+	defer db.Close()
+
+	queries := []*Query{
+		{Query: "SELECT max(usage) FROM hosts WHERE host = $1", Args: []interface{}{"host_1"}, key: "host_1"},
+		{Query: "SELECT 1", key: "host_2"},
+	}
+	pc := NewPlanChecker([]PlanExpectation{{Template: "SELECT max(usage)  FROM hosts\n\tWHERE host = $1", NoSeqScan: true}})
+	c := NewController(WithPoolSize(1))
+	c.SetPlanChecker(pc)
+	report, err := c.RunTest(context.Background(), db, &replayGenerator{queries: queries, n: 6})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(6), report.Processed)
+	assert.Equal(t, 1, explained)
+	violations := pc.Violations()
+	require.Len(t, violations, 1)
+	assert.Equal(t, "SELECT max(usage) FROM hosts WHERE host = $1", violations[0].Query)
+	assert.Equal(t, []interface{}{"host_1"}, violations[0].Args)
+	assert.Equal(t, []string{"scans hosts sequentially"}, violations[0].Reasons)
+}
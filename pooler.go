@@ -0,0 +1,60 @@
+package dbperf
+
+import "context"
+
+// SessionBehavior describes how session state behaves on a connection, which differs between direct connections
+// and poolers in transaction or statement pooling mode
+type SessionBehavior struct {
+	PreparedStatements bool // named prepared statements persist across statements
+	SessionSettings    bool // SET persists across statements
+	StableBackend      bool // every statement is served by the same server backend
+}
+
+const (
+	probePrepare    = `PREPARE dbperf_probe AS SELECT 1;`
+	probeExecute    = `EXECUTE dbperf_probe;`
+	probeDeallocate = `DEALLOCATE dbperf_probe;`
+	probeSet        = `SET dbperf.probe = 'on';`
+	probeReset      = `RESET dbperf.probe;`
+	probeBackendPID = `SELECT pg_backend_pid();`
+
+	// probeCheckSetting fails unless the setting made by probeSet is visible
+	probeCheckSetting = `DO $$ BEGIN
+	IF current_setting('dbperf.probe', true) IS DISTINCT FROM 'on' THEN
+		RAISE EXCEPTION 'setting not visible';
+	END IF;
+END $$;`
+)
+
+// ProbeSession determines how session state behaves on the connection by issuing every probe as a separate
+// statement. The session state created by the probes is cleaned up where possible.
+func ProbeSession(ctx context.Context, conn Conn) (*SessionBehavior, error) {
+	b := &SessionBehavior{}
+
+	if _, err := conn.ExecContext(ctx, probePrepare); err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, probeExecute); err == nil {
+		b.PreparedStatements = true
+		conn.ExecContext(ctx, probeDeallocate)
+	}
+
+	if _, err := conn.ExecContext(ctx, probeSet); err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, probeCheckSetting); err == nil {
+		b.SessionSettings = true
+	}
+	conn.ExecContext(ctx, probeReset)
+
+	var first, second int
+	if err := conn.QueryRowContext(ctx, probeBackendPID).Scan(&first); err != nil {
+		return nil, err
+	}
+	if err := conn.QueryRowContext(ctx, probeBackendPID).Scan(&second); err != nil {
+		return nil, err
+	}
+	b.StableBackend = first == second
+
+	return b, nil
+}
@@ -0,0 +1,50 @@
+package dbperf
+
+import "io"
+
+// PreloadedQueries are the queries of an input parsed and validated in full before a run, so an invalid row can't
+// abort the run halfway through and the exact # of queries is known up front
+type PreloadedQueries struct {
+	queries []*Query
+}
+
+// Preload reads every query of gen into memory, returning the first error reading any of them
+func Preload(gen QueryGenerator) (*PreloadedQueries, error) {
+	p := &PreloadedQueries{}
+	for {
+		q, err := gen.Next()
+		if err == io.EOF {
+			return p, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.queries = append(p.queries, q)
+	}
+}
+
+// Len returns the # of queries preloaded
+func (p *PreloadedQueries) Len() int {
+	return len(p.queries)
+}
+
+// Generator returns a generator of copies of the queries preloaded, in order. Every generator starts from the first
+// query, so the queries can be run any # of times.
+func (p *PreloadedQueries) Generator() QueryGenerator {
+	return &preloadedGenerator{queries: p.queries}
+}
+
+type preloadedGenerator struct {
+	queries []*Query
+	i       int
+}
+
+func (g *preloadedGenerator) Next() (*Query, error) {
+	if g.i >= len(g.queries) {
+		return nil, io.EOF
+	}
+
+	q := *g.queries[g.i]
+	g.i++
+	return &q, nil
+}
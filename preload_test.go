@@ -0,0 +1,41 @@
+package dbperf
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreload(t *testing.T) {
+	p, err := Preload(NewCPUTestGenerator(strings.NewReader(testQueries)))
+	require.NoError(t, err)
+	assert.Equal(t, 10, p.Len())
+
+	// every generator replays all the queries from the first
+	for i := 0; i < 2; i++ {
+		g := p.Generator()
+		var hosts []interface{}
+		for {
+			q, err := g.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			hosts = append(hosts, q.Args[0])
+		}
+		require.Len(t, hosts, 10)
+		assert.Equal(t, "host_000008", hosts[0])
+		assert.Equal(t, "host_000006", hosts[9])
+	}
+
+	// an invalid row anywhere in the input fails the preload rather than a run halfway through
+	input := testQueries + "\nhost_000001,bad,2017-01-02 02:18:53"
+	_, err = Preload(NewCPUTestGenerator(strings.NewReader(input)))
+	var invalid *InvalidRowError
+	require.True(t, errors.As(err, &invalid), err)
+	assert.Equal(t, 12, invalid.Line)
+}
@@ -0,0 +1,164 @@
+package dbperf
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Protocol is the message flow queries are executed with
+type Protocol string
+
+const (
+	// ProtocolExtended executes queries with their arguments bound separately by the extended protocol (parse, bind
+	// and execute of an unnamed statement), the default
+	ProtocolExtended Protocol = "extended"
+
+	// ProtocolSimple inlines the arguments of queries as literals and executes them with the simple query protocol,
+	// as applications going through poolers that don't support prepared statements often do
+	ProtocolSimple Protocol = "simple"
+)
+
+// InlineArgs replaces the $N placeholders of the query with its arguments quoted as literals, for the query to be
+// executed without binding them. The arguments are quoted as untyped literals like the extended protocol sends them,
+// so the server infers the same types for them. Placeholders within literals, quoted identifiers and comments are left
+// alone.
+func InlineArgs(query string, args []interface{}) (string, error) {
+	literals := make([]string, len(args))
+	for i, arg := range args {
+		lit, err := quoteArg(arg)
+		if err != nil {
+			return "", fmt.Errorf("argument $%d: %s", i+1, err)
+		}
+		literals[i] = lit
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(query); {
+		end := skipQuoted(query, i)
+		if end > i {
+			b.WriteString(query[i:end])
+			i = end
+			continue
+		}
+
+		if query[i] == '$' && i+1 < len(query) && isDigit(query[i+1]) {
+			j := i + 1
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			n, _ := strconv.Atoi(query[i+1 : j])
+			if n < 1 || n > len(args) {
+				return "", fmt.Errorf("no argument for $%d", n)
+			}
+			b.WriteString(literals[n-1])
+			i = j
+			continue
+		}
+
+		b.WriteByte(query[i])
+		i++
+	}
+	return b.String(), nil
+}
+
+// skipQuoted returns the end of the literal, quoted identifier or comment starting at i, i if there is none
+func skipQuoted(query string, i int) int {
+	rest := query[i:]
+	switch {
+	case strings.HasPrefix(rest, "--"):
+		if end := strings.IndexByte(rest, '\n'); end >= 0 {
+			return i + end + 1
+		}
+		return len(query)
+	case strings.HasPrefix(rest, "/*"):
+		if end := strings.Index(rest[2:], "*/"); end >= 0 {
+			return i + 2 + end + 2
+		}
+		return len(query)
+	case rest[0] == '\'':
+		// backslashes only escape within E'' strings
+		escapes := i > 0 && (query[i-1] == 'E' || query[i-1] == 'e')
+		return closeQuote(query, i, '\'', escapes)
+	case rest[0] == '"':
+		return closeQuote(query, i, '"', false)
+	case rest[0] == '$':
+		// dollar quoted string, $$...$$ or $tag$...$tag$
+		j := 1
+		for j < len(rest) && (rest[j] == '_' || isDigit(rest[j]) || isLetter(rest[j])) {
+			j++
+		}
+		if j == len(rest) || rest[j] != '$' || (j > 1 && isDigit(rest[1])) {
+			return i
+		}
+		tag := rest[:j+1]
+		if end := strings.Index(rest[len(tag):], tag); end >= 0 {
+			return i + len(tag) + end + len(tag)
+		}
+		return len(query)
+	}
+	return i
+}
+
+// closeQuote returns the end of the string quoted by q starting at i, doubled quotes (and backslashes if escapes) not
+// ending it
+func closeQuote(query string, i int, q byte, escapes bool) int {
+	for j := i + 1; j < len(query); j++ {
+		switch {
+		case escapes && query[j] == '\\':
+			j++
+		case query[j] == q:
+			if j+1 < len(query) && query[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(query)
+}
+
+// quoteArg quotes the argument as an untyped literal in the text format lib/pq sends arguments in
+func quoteArg(arg interface{}) (string, error) {
+	v, err := driver.DefaultParameterConverter.ConvertValue(arg)
+	if err != nil {
+		return "", err
+	}
+
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		s = v
+	case []byte:
+		s = `\x` + hex.EncodeToString(v)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		s = strconv.FormatBool(v)
+	case time.Time:
+		s = v.Format("2006-01-02 15:04:05.999999999Z07:00")
+	default:
+		return "", fmt.Errorf("unsupported type %T", v)
+	}
+	return quoteLiteral(s), nil
+}
+
+// quoteLiteral quotes the string as a literal, escaped (E'...') when it has backslashes so they're preserved
+// regardless of standard_conforming_strings
+func quoteLiteral(s string) string {
+	s = strings.Replace(s, "'", "''", -1)
+	if strings.Contains(s, `\`) {
+		return ` E'` + strings.Replace(s, `\`, `\\`, -1) + `'`
+	}
+	return "'" + s + "'"
+}
+
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80 }
@@ -0,0 +1,83 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineArgs(t *testing.T) {
+	ts := time.Date(2017, 1, 1, 8, 59, 22, 0, time.UTC)
+	tests := []struct {
+		query    string
+		args     []interface{}
+		expected string
+		err      bool
+	}{
+		{"SELECT 1", nil, "SELECT 1", false},
+		{
+			"SELECT max(usage) FROM cpu_usage WHERE host = $1 AND ts >= $2 AND ts < $3",
+			[]interface{}{"host_1", ts, "2017-01-01 09:59:22"},
+			"SELECT max(usage) FROM cpu_usage WHERE host = 'host_1' AND ts >= '2017-01-01 08:59:22Z' AND ts < '2017-01-01 09:59:22'",
+			false,
+		},
+		{"SELECT $1, $2, $3, $4", []interface{}{42, 1.5, true, nil}, "SELECT '42', '1.5', 'true', NULL", false},
+		{"SELECT $1", []interface{}{"O'Reilly"}, "SELECT 'O''Reilly'", false},
+		{"SELECT $1", []interface{}{`C:\dir`}, `SELECT  E'C:\\dir'`, false},
+		{"SELECT $1", []interface{}{[]byte{0xde, 0xad}}, `SELECT  E'\\xdead'`, false},
+		{"SELECT $2, $1, $1", []interface{}{"a", "b"}, "SELECT 'b', 'a', 'a'", false},
+		{
+			"SELECT '$1', \"$1\", $$ $1 $$, $tag$ $1 $tag$, $1 -- $1\n/* $1 */",
+			[]interface{}{"a"},
+			"SELECT '$1', \"$1\", $$ $1 $$, $tag$ $1 $tag$, 'a' -- $1\n/* $1 */",
+			false,
+		},
+		{"SELECT E'\\'$1', $1", []interface{}{"a"}, "SELECT E'\\'$1', 'a'", false},
+		{"SELECT $1, $2", []interface{}{"a"}, "", true},
+		{"SELECT $1", []interface{}{struct{}{}}, "", true},
+	}
+
+	for _, tt := range tests {
+		query, err := InlineArgs(tt.query, tt.args)
+		if tt.err {
+			assert.Error(t, err, tt.query)
+			continue
+		}
+		assert.NoError(t, err, tt.query)
+		assert.Equal(t, tt.expected, query)
+	}
+}
+
+func TestRunTestProtocol(t *testing.T) {
+	var mu sync.Mutex
+	var executed []string
+	var bound int
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		mu.Lock()
+		defer mu.Unlock()
+		executed = append(executed, query)
+		bound += len(args)
+		return nil, nil
+	})
+	defer db.Close()
+
+	queries := []*Query{
+		{Query: "SELECT $1", Args: []interface{}{"host_1"}, key: "host_1"},
+		{Query: "SELECT $1", Args: []interface{}{"host_2"}, key: "host_2"},
+	}
+	c := NewController(WithPoolSize(1), WithProtocol(ProtocolSimple))
+	report, err := c.RunTest(context.Background(), db, &replayGenerator{queries: queries, n: 2})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), report.Processed)
+	assert.Equal(t, int64(0), report.Errors)
+	assert.Equal(t, []string{"SELECT 'host_1'", "SELECT 'host_2'"}, executed)
+	assert.Equal(t, 0, bound)
+	// stats are still broken down by the query template rather than the inlined queries
+	assert.Nil(t, report.Templates)
+}
@@ -0,0 +1,51 @@
+package dbperf
+
+import (
+	"fmt"
+	"time"
+)
+
+// NewTimeRangeValidator returns a generator of the queries of gen that fails every query whose time range doesn't
+// start before it ends, which silently matches no rows and skews the latency low, or spans more than maxWindow when
+// it's positive, with an *InvalidRowError for InvalidRows to skip or reject. Queries without a known time range pass.
+func NewTimeRangeValidator(gen QueryGenerator, maxWindow time.Duration) QueryGenerator {
+	return &timeRangeValidator{gen: gen, maxWindow: maxWindow}
+}
+
+type timeRangeValidator struct {
+	gen       QueryGenerator
+	maxWindow time.Duration
+}
+
+func (g *timeRangeValidator) Next() (*Query, error) {
+	q, err := g.gen.Next()
+	if err != nil {
+		return nil, err
+	}
+	if q.Start.IsZero() && q.End.IsZero() {
+		return q, nil
+	}
+
+	window := q.End.Sub(q.Start)
+	switch {
+	case window <= 0:
+		return nil, q.invalid(fmt.Errorf("time range starts at %s, not before it ends at %s", q.Start.Format(dateTimeLayout), q.End.Format(dateTimeLayout)))
+	case g.maxWindow > 0 && window > g.maxWindow:
+		return nil, q.invalid(fmt.Errorf("time range of %s is longer than the max of %s", window, g.maxWindow))
+	}
+	return q, nil
+}
+
+// invalid returns the error of the row the query was read from being invalid, its fields being the arguments of the
+// query
+func (q *Query) invalid(err error) *InvalidRowError {
+	row := make([]string, len(q.Args))
+	for i, arg := range q.Args {
+		if t, ok := arg.(time.Time); ok {
+			row[i] = t.Format(dateTimeLayout)
+		} else {
+			row[i] = fmt.Sprint(arg)
+		}
+	}
+	return &InvalidRowError{Line: q.line, Row: row, Err: err}
+}
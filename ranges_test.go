@@ -0,0 +1,60 @@
+package dbperf
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeRangeValidator(t *testing.T) {
+	input := `hostname,start_time,end_time
+host_000001,2017-01-01 08:00:00,2017-01-01 09:00:00
+host_000002,2017-01-01 09:00:00,2017-01-01 08:00:00
+host_000003,2017-01-01 08:00:00,2017-01-01 08:00:00
+host_000004,2017-01-01 08:00:00,2017-01-02 09:00:00
+host_000005,2017-01-01 08:00:00,2017-01-01 10:00:00
+`
+
+	g := NewTimeRangeValidator(NewCPUTestGenerator(strings.NewReader(input)), 2*time.Hour)
+	var lines []int
+	var hosts []string
+	for {
+		q, err := g.Next()
+		var invalid *InvalidRowError
+		if errors.As(err, &invalid) {
+			lines = append(lines, invalid.Line)
+			continue
+		}
+		if err != nil {
+			break
+		}
+		hosts = append(hosts, q.Space)
+	}
+	assert.Equal(t, []int{3, 4, 5}, lines)
+	assert.Equal(t, []string{"host_000001", "host_000005"}, hosts)
+
+	// the queries without a time range pass
+	_, err := NewTimeRangeValidator(&repeatGenerator{Query{Query: "SELECT 1"}}, time.Hour).Next()
+	assert.NoError(t, err)
+}
+
+func TestTimeRangeValidatorReject(t *testing.T) {
+	input := `hostname,start_time,end_time
+host_000001,2017-01-01 08:00:00,2017-01-01 09:00:00
+host_000002,2017-01-01 09:00:00,2017-01-01 08:00:00
+`
+
+	var rejects bytes.Buffer
+	ir, err := NewInvalidRows(RowReject, &rejects)
+	require.NoError(t, err)
+	hosts, err := readHosts(ir.Generator(NewTimeRangeValidator(NewCPUTestGenerator(strings.NewReader(input)), 0)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"host_000001"}, hosts)
+	assert.Equal(t, "host_000002,2017-01-01 09:00:00,2017-01-01 08:00:00\n", rejects.String())
+	assert.Contains(t, ir.First().Error(), "invalid query specification on line 3")
+}
@@ -0,0 +1,54 @@
+package dbperf
+
+import "time"
+
+// dispatchInterval is how often a rate limited controller checks for queries that have come due
+const dispatchInterval = time.Millisecond
+
+// rateLimiter paces query dispatch to a target rate that may vary over time. Rather than sleeping between individual
+// dispatches it tracks how many dispatches are due since the load shape took effect, which keeps the average rate
+// accurate at high rates where the dispatch interval is finer than the timer resolution.
+type rateLimiter struct {
+	shape LoadShape // target rate over time
+	start time.Time // when the current shape took effect
+	taken int64     // # dispatches taken since start
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	return newShapedLimiter(ConstantRate(rate))
+}
+
+func newShapedLimiter(shape LoadShape) *rateLimiter {
+	return &rateLimiter{
+		shape: shape,
+		start: time.Now(),
+	}
+}
+
+// setRate changes to a constant target rate effective from now
+func (l *rateLimiter) setRate(rate float64, now time.Time) {
+	l.setShape(ConstantRate(rate), now)
+}
+
+// setShape changes the load shape effective from now
+func (l *rateLimiter) setShape(shape LoadShape, now time.Time) {
+	l.shape = shape
+	l.reset(now)
+}
+
+// reset restarts the current load shape from now
+func (l *rateLimiter) reset(now time.Time) {
+	l.start = now
+	l.taken = 0
+}
+
+// take returns the number of dispatches that have come due at now and marks them as taken
+func (l *rateLimiter) take(now time.Time) int {
+	due := int64(l.shape.Total(now.Sub(l.start))) - l.taken
+	if due <= 0 {
+		return 0
+	}
+
+	l.taken += due
+	return int(due)
+}
@@ -0,0 +1,25 @@
+package dbperf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Now()
+	l := newRateLimiter(100)
+	l.setRate(100, start)
+
+	assert.Equal(t, 0, l.take(start))
+	assert.Equal(t, 0, l.take(start.Add(time.Millisecond*5)))
+	assert.Equal(t, 1, l.take(start.Add(time.Millisecond*10)))
+	assert.Equal(t, 0, l.take(start.Add(time.Millisecond*15)))
+	assert.Equal(t, 99, l.take(start.Add(time.Second)))
+
+	// rate change takes effect from the given time
+	now := start.Add(time.Second)
+	l.setRate(1000, now)
+	assert.Equal(t, 500, l.take(now.Add(time.Millisecond*500)))
+}
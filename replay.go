@@ -0,0 +1,173 @@
+package dbperf
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// Dispatch is a query dispatched to a worker during a run
+type Dispatch struct {
+	At     time.Duration // offset from the start of the run
+	Worker int           // id of the worker the query was queued on
+	Query  *Query
+}
+
+// DispatchFunc is called with every query dispatched as the run progresses
+type DispatchFunc func(d Dispatch)
+
+// recordedDispatch is a dispatch as written by a Recorder, one JSON object per line
+type recordedDispatch struct {
+	At     time.Duration `json:"at"`
+	Worker int           `json:"worker"`
+	Key    string        `json:"key"`
+	Space  string        `json:"space,omitempty"`
+	Label  string        `json:"label,omitempty"`
+	Query  string        `json:"query"`
+	Args   []interface{} `json:"args"`
+}
+
+// Recorder records the dispatch schedule of a run, every query with the worker it was queued on and when, for the
+// run to be replayed exactly (see ReadRecording). Its Record method may be passed to SetDispatchFunc.
+type Recorder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder creates a recorder writing the dispatches to w
+func NewRecorder(w io.Writer) *Recorder {
+	bw := bufio.NewWriter(w)
+	return &Recorder{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// Record writes a dispatch, errors writing it are returned by Flush
+func (r *Recorder) Record(d Dispatch) {
+	if r.err != nil {
+		return
+	}
+
+	r.err = r.enc.Encode(recordedDispatch{
+		At:     d.At,
+		Worker: d.Worker,
+		Key:    d.Query.key,
+		Space:  d.Query.Space,
+		Label:  d.Query.Label,
+		Query:  d.Query.Query,
+		Args:   d.Query.Args,
+	})
+}
+
+// Flush writes any buffered dispatches, returning the first error writing any of them
+func (r *Recorder) Flush() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.w.Flush()
+}
+
+// Recording is a dispatch schedule written by a Recorder. Replaying it with a controller configured with its
+// Scheduler and Shape (see SetLoadShape) and at least Workers workers dispatches its queries in the same order, to the
+// same workers and with the same pacing as the recorded run. Query arguments are replayed as decoded from JSON, e.g.
+// times as strings. Dispatches the controller drops when too many queries are outstanding (see SetRateLimit) are
+// replayed late rather than skipped.
+type Recording struct {
+	queries []*Query
+	at      []time.Duration // when each query was dispatched
+	workers map[*Query]int  // worker each query was dispatched to
+	nworker int
+}
+
+// ReadRecording reads a dispatch schedule written by a Recorder
+func ReadRecording(r io.Reader) (*Recording, error) {
+	rec := &Recording{workers: make(map[*Query]int)}
+
+	dec := json.NewDecoder(r)
+	for {
+		var d recordedDispatch
+		if err := dec.Decode(&d); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		q := &Query{Query: d.Query, Args: d.Args, Space: d.Space, Label: d.Label, key: d.Key}
+		rec.queries = append(rec.queries, q)
+		rec.at = append(rec.at, d.At)
+		rec.workers[q] = d.Worker
+		if d.Worker >= rec.nworker {
+			rec.nworker = d.Worker + 1
+		}
+	}
+
+	return rec, nil
+}
+
+// Len returns the # of queries recorded
+func (rec *Recording) Len() int {
+	return len(rec.queries)
+}
+
+// Workers returns the # of workers the recorded run dispatched queries to
+func (rec *Recording) Workers() int {
+	return rec.nworker
+}
+
+// Generator returns a generator of the recorded queries in the order they were dispatched
+func (rec *Recording) Generator() QueryGenerator {
+	return &recordingGenerator{rec: rec}
+}
+
+type recordingGenerator struct {
+	rec *Recording
+	i   int
+}
+
+func (g *recordingGenerator) Next() (*Query, error) {
+	if g.i >= len(g.rec.queries) {
+		return nil, io.EOF
+	}
+
+	q := g.rec.queries[g.i]
+	g.i++
+	return q, nil
+}
+
+// Scheduler returns a scheduler queuing the recorded queries on the workers they were dispatched to
+func (rec *Recording) Scheduler() Scheduler {
+	return recordingScheduler{rec: rec}
+}
+
+type recordingScheduler struct {
+	rec *Recording
+}
+
+func (s recordingScheduler) Worker(q *Query, n int) int {
+	return s.rec.workers[q] % n
+}
+
+// Shape returns the load shape dispatching the recorded queries when they were dispatched
+func (rec *Recording) Shape() LoadShape {
+	return recordingShape{rec: rec}
+}
+
+type recordingShape struct {
+	rec *Recording
+}
+
+// Total returns the # of queries dispatched by t. One more is due after the last so the controller finds the
+// generator exhausted and ends the run.
+func (s recordingShape) Total(t time.Duration) float64 {
+	at := s.rec.at
+	if len(at) == 0 || t > at[len(at)-1] {
+		return float64(len(at) + 1)
+	}
+	return float64(sort.Search(len(at), func(i int) bool { return at[i] > t }))
+}
+
+// Rate returns the # of queries dispatched within the second before t
+func (s recordingShape) Rate(t time.Duration) float64 {
+	return s.Total(t) - s.Total(t-time.Second)
+}
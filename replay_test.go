@@ -0,0 +1,63 @@
+package dbperf
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordReplay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(20)
+
+	// record a paced run
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	var recorded []Dispatch
+	c := NewController(WithPoolSize(3), WithScheduler(NewRoundRobinScheduler()), WithRateLimit(200))
+	c.SetDispatchFunc(func(d Dispatch) {
+		recorded = append(recorded, d)
+		rec.Record(d)
+	})
+	_, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.NoError(t, err)
+	assert.NoError(t, rec.Flush())
+	assert.Len(t, recorded, 10)
+
+	recording, err := ReadRecording(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, recording.Len())
+	assert.Equal(t, 3, recording.Workers())
+	assert.Equal(t, float64(0), recording.Shape().Total(-time.Millisecond))
+	assert.Equal(t, float64(11), recording.Shape().Total(time.Hour))
+
+	// replay it on a pool of the recorded size
+	var replayed []Dispatch
+	c = NewController(WithPoolSize(recording.Workers()), WithScheduler(recording.Scheduler()))
+	c.SetLoadShape(recording.Shape())
+	c.SetDispatchFunc(func(d Dispatch) {
+		replayed = append(replayed, d)
+	})
+	report, err := c.RunTest(context.Background(), mdb, recording.Generator())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), report.Processed)
+
+	assert.Len(t, replayed, 10)
+	for i, d := range replayed {
+		want := recorded[i]
+		assert.Equal(t, want.Worker, d.Worker)
+		assert.Equal(t, want.Query.Query, d.Query.Query)
+		assert.Equal(t, want.Query.Args, d.Query.Args)
+		assert.Equal(t, want.Query.key, d.Query.key)
+		assert.InDelta(t, float64(want.At), float64(d.At), float64(20*time.Millisecond), "dispatch %d", i)
+	}
+}
@@ -0,0 +1,198 @@
+package dbperf
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// timelineBucket is the width of the buckets of a report's timeline
+const timelineBucket = time.Second
+
+// Report is the outcome of a run returned by RunTest, the stats of the whole run (which it embeds) broken down further
+// by query key and over the course of the run
+type Report struct {
+	*QueryStats
+
+	// Keys breaks the stats down by the key the queries were scheduled by, e.g. the host of the cpu usage queries
+	Keys map[string]*QueryStats
+
+	// ErrorCounts counts the errors tolerated during the run (see SetMultiNode) by message
+	ErrorCounts map[string]int64
+
+	// Timeline buckets the queries by the second of the run they completed in
+	Timeline []TimelineBucket
+
+	// Client is the resource usage of the process running the test during the run
+	Client *ClientUsage
+}
+
+// TimelineBucket summarizes the queries that completed within a bucket of the timeline of a run
+type TimelineBucket struct {
+	Start     time.Duration // offset of the bucket from the start of the run
+	Processed int64         // # queries that succeeded
+	Errors    int64         // # queries that failed but were tolerated
+	Median    time.Duration
+	P95       time.Duration
+	P99       time.Duration
+
+	// Checkpointing is whether a checkpoint was observed in progress during the bucket (see SetBgWriterSampling)
+	Checkpointing bool
+
+	// Vacuum is whether the table vacuumed during the run was being vacuumed during the bucket (see SetVacuum)
+	Vacuum bool
+}
+
+// Percentile returns the nearest rank percentile p (0-100) of the latencies of the run, exact unless the report
+// only has a histogram of them (e.g. it was read back from JSON)
+func (r *Report) Percentile(p float64) time.Duration {
+	if int64(len(r.Latencies)) == r.Processed {
+		return percentile(r.Latencies, p)
+	}
+	if r.Histogram != nil {
+		return r.Histogram.Percentile(p)
+	}
+	return 0
+}
+
+// KeyRank is the statistic keys are ranked by, see SlowestKeys
+type KeyRank string
+
+const (
+	RankByP99   KeyRank = "p99"   // p99 latency of the queries of the key
+	RankByTotal KeyRank = "total" // total time taken by the queries of the key
+)
+
+// KeyStats is the stats of the queries of a key
+type KeyStats struct {
+	Key string
+	*QueryStats
+}
+
+// SlowestKeys returns the k keys with the worst stats by rank, worst first, pointing at the hosts or partitions with
+// degraded performance. Ties are broken by key.
+func (r *Report) SlowestKeys(k int, rank KeyRank) []KeyStats {
+	value := func(s *QueryStats) time.Duration {
+		if rank == RankByTotal {
+			return s.TotalElapsed
+		}
+		return s.P99
+	}
+
+	keys := make([]KeyStats, 0, len(r.Keys))
+	for key, s := range r.Keys {
+		keys = append(keys, KeyStats{key, s})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if vi, vj := value(keys[i].QueryStats), value(keys[j].QueryStats); vi != vj {
+			return vi > vj
+		}
+		return keys[i].Key < keys[j].Key
+	})
+
+	if k < len(keys) {
+		keys = keys[:k]
+	}
+	return keys
+}
+
+// Results captures the results of the run to save them (see WriteResults) with the stats by key and the errors
+func (r *Report) Results() *Results {
+	res := NewResults(r.QueryStats)
+	if len(r.Keys) > 0 {
+		res.Keys = r.Keys
+	}
+	if len(r.ErrorCounts) > 0 {
+		res.ErrorCounts = r.ErrorCounts
+	}
+	res.Anomalies = r.Anomalies(AnomalyConfig{})
+	return res
+}
+
+// WriteJSON writes the report as JSON, the stats of the run at the top level next to the breakdowns
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// reportCSVHeader is the header of a report written as CSV, latencies in ms
+var reportCSVHeader = []string{"group", "processed", "errors", "min_ms", "max_ms", "avg_ms", "stddev_ms", "median_ms", "p95_ms", "p99_ms"}
+
+// WriteCSV writes the stats of the run and of every key as CSV, a row per group in key order after the run's
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(reportCSVHeader)
+	cw.Write(csvRow("all", r.QueryStats))
+
+	keys := make([]string, 0, len(r.Keys))
+	for key := range r.Keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cw.Write(csvRow("key "+key, r.Keys[key]))
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvRow(group string, s *QueryStats) []string {
+	ms := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+	}
+	return []string{
+		group,
+		strconv.FormatInt(s.Processed, 10),
+		strconv.FormatInt(s.Errors, 10),
+		ms(s.Min), ms(s.Max), ms(s.Avg), ms(s.StdDev), ms(s.Median), ms(s.P95), ms(s.P99),
+	}
+}
+
+// timelineResults collects the results of a bucket of the timeline
+type timelineResults struct {
+	latencies *Histogram
+	errors    int64
+}
+
+// newReport wraps the stats of a run in a report with the breakdowns the collector gathered, the run having ended at
+// end
+func (col *collector) newReport(stats *QueryStats, end time.Time) *Report {
+	r := &Report{
+		QueryStats:  stats,
+		Keys:        make(map[string]*QueryStats, len(col.byKey)),
+		ErrorCounts: col.errorCounts,
+		Timeline:    make([]TimelineBucket, len(col.buckets)),
+		Client:      col.usage.finish(end),
+	}
+	if r.ErrorCounts == nil {
+		r.ErrorCounts = make(map[string]int64)
+	}
+
+	for key, h := range col.byKey {
+		r.Keys[key] = h.stats()
+	}
+
+	for i, b := range col.buckets {
+		tb := TimelineBucket{Start: time.Duration(i) * timelineBucket, Errors: b.errors}
+		if b.latencies != nil {
+			tb.Processed = b.latencies.Count
+			tb.Median = b.latencies.Percentile(50)
+			tb.P95 = b.latencies.Percentile(95)
+			tb.P99 = b.latencies.Percentile(99)
+		}
+		r.Timeline[i] = tb
+	}
+	if stats.BgWriter != nil {
+		markBgWriter(r.Timeline, stats.BgWriter.Spans)
+	}
+	if stats.Vacuum != nil {
+		markVacuums(r.Timeline, stats.Vacuum.Spans)
+	}
+
+	return r
+}
@@ -0,0 +1,103 @@
+package dbperf
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTestReport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	nodeErr := errors.New("pq: [dn_1]: could not connect to \"dn_1\"")
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), "host_000008", gomock.Any(), gomock.Any()).Return(nil, nodeErr).Times(3)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(7)
+
+	c := NewController(WithPoolSize(2))
+	c.SetMultiNode(true)
+	report, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(7), report.Processed)
+	assert.Equal(t, map[string]int64{nodeErr.Error(): 3}, report.ErrorCounts)
+
+	// failed queries aren't in the breakdown by key
+	assert.Len(t, report.Keys, 6)
+	assert.NotContains(t, report.Keys, "host_000008")
+	assert.Equal(t, int64(2), report.Keys["host_000002"].Processed)
+
+	require.Len(t, report.Timeline, 1)
+	assert.Equal(t, int64(7), report.Timeline[0].Processed)
+	assert.Equal(t, int64(3), report.Timeline[0].Errors)
+
+	assert.Equal(t, report.Max, report.Percentile(100))
+	assert.Equal(t, report.Median, report.Percentile(50))
+}
+
+func TestReportWrite(t *testing.T) {
+	latencies := []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}
+	stats := calculateStats(latencies)
+	stats.Histogram = NewHistogram(latencies)
+	report := &Report{
+		QueryStats: stats,
+		Keys:       map[string]*QueryStats{"b": calculateStats(latencies[:1]), "a": calculateStats(latencies[1:])},
+		Timeline:   []TimelineBucket{{Processed: 3, Median: 2 * time.Millisecond}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buf))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, reportCSVHeader, rows[0])
+	assert.Equal(t, []string{"all", "3", "0", "1.000", "3.000", "2.000", "0.816", "2.000", "3.000", "3.000"}, rows[1])
+	assert.Equal(t, "key a", rows[2][0])
+	assert.Equal(t, "key b", rows[3][0])
+
+	// the stats of the run are at the top level, the percentiles come from the histogram once read back
+	buf.Reset()
+	require.NoError(t, report.WriteJSON(&buf))
+	var read Report
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &read))
+	assert.Equal(t, int64(3), read.Processed)
+	assert.Equal(t, report.Timeline, read.Timeline)
+	assert.Equal(t, 2*time.Millisecond, read.Keys["a"].Min)
+	assert.Nil(t, read.Latencies)
+	assert.InEpsilon(t, float64(2*time.Millisecond), float64(read.Percentile(50)), 0.02)
+}
+
+func TestReportSlowestKeys(t *testing.T) {
+	ms := time.Millisecond
+	report := &Report{Keys: map[string]*QueryStats{
+		"host_1": calculateStats([]time.Duration{5 * ms, 90 * ms}),
+		"host_2": calculateStats([]time.Duration{40 * ms, 40 * ms, 40 * ms, 40 * ms}),
+		"host_3": calculateStats([]time.Duration{10 * ms}),
+		"host_4": calculateStats([]time.Duration{10 * ms}),
+	}}
+
+	keys := func(ks []KeyStats) []string {
+		var names []string
+		for _, k := range ks {
+			names = append(names, k.Key)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"host_1", "host_2"}, keys(report.SlowestKeys(2, RankByP99)))
+	assert.Equal(t, []string{"host_2", "host_1", "host_3", "host_4"}, keys(report.SlowestKeys(10, RankByTotal)))
+	assert.Equal(t, 90*ms, report.SlowestKeys(1, RankByP99)[0].P99)
+	assert.Empty(t, report.SlowestKeys(0, RankByP99))
+}
@@ -0,0 +1,102 @@
+package dbperf
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Results are the saved outcome of a run that are compared or reported on after the fact
+type Results struct {
+	ID        string          `json:"id,omitempty"`       // unique id of the run (see NewRunID)
+	Tags      Tags            `json:"tags,omitempty"`     // user defined labels of the run
+	Manifest  *Manifest       `json:"manifest,omitempty"` // how the run was made
+	Stats     *QueryStats     `json:"stats"`
+	Latencies []time.Duration `json:"latencies"` // latency of every query, or a sample of them, in ascending order
+
+	// breakdowns of the report of the run (see Report.Results)
+	Keys        map[string]*QueryStats `json:"keys,omitempty"`   // stats by query key
+	ErrorCounts map[string]int64       `json:"errors,omitempty"` // errors tolerated by message
+	Chunks      *ChunkReport           `json:"chunks,omitempty"` // chunks of the hypertable queried, see ChunkExclusion
+	Usage       *UsageReport           `json:"usage,omitempty"`  // scans of the tables and indexes, see UsageDelta
+	Sizes       []SizeChange           `json:"sizes,omitempty"`  // changes of the sizes of the tables, see SizeDelta
+	WAL         *WALReport             `json:"wal,omitempty"`    // WAL generated during the run, see WALDelta
+	Plans       []LoggedPlan           `json:"plans,omitempty"`  // plans logged by auto_explain, see ParsePlans
+
+	// Anomalies are the latency anomalies found in the timeline of the run, see Report.Anomalies
+	Anomalies []Anomaly `json:"anomalies,omitempty"`
+}
+
+// NewResults captures the results of a run from its stats
+func NewResults(stats *QueryStats) *Results {
+	return &Results{
+		Stats:     stats,
+		Latencies: stats.Latencies,
+	}
+}
+
+// WriteResults writes the results as JSON
+func WriteResults(w io.Writer, res *Results) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// ReadResults reads results written by WriteResults
+func ReadResults(r io.Reader) (*Results, error) {
+	var res Results
+	if err := json.NewDecoder(r).Decode(&res); err != nil {
+		return nil, err
+	}
+
+	if res.Stats == nil {
+		res.Stats = calculateStats(res.Latencies)
+	}
+	res.Stats.Latencies = res.Latencies
+	if res.Stats.Histogram == nil && len(res.Latencies) > 0 && int64(len(res.Latencies)) == res.Stats.Processed {
+		res.Stats.Histogram = NewHistogram(res.Latencies)
+	}
+
+	return &res, nil
+}
+
+// MergeResults combines the results of runs executed in parallel, e.g. by several agents sharing a workload, into the
+// results of a single run (see QueryStats.Merge). Results without a histogram get one from their latencies. The
+// manifests aren't merged, it's up to the caller to record how the combined run was made.
+func MergeResults(results ...*Results) (*Results, error) {
+	merged := &QueryStats{}
+	keys := make(map[string]*QueryStats)
+	errorCounts := make(map[string]int64)
+	for _, res := range results {
+		for key, ks := range res.Keys {
+			if keys[key] == nil {
+				keys[key] = &QueryStats{}
+			}
+			if err := keys[key].Merge(ks); err != nil {
+				return nil, err
+			}
+		}
+		for msg, n := range res.ErrorCounts {
+			errorCounts[msg] += n
+		}
+
+		stats := *res.Stats
+		stats.Latencies = res.Latencies
+		if stats.Histogram == nil && int64(len(res.Latencies)) == stats.Processed {
+			stats.Histogram = NewHistogram(res.Latencies)
+		}
+
+		if err := merged.Merge(&stats); err != nil {
+			return nil, err
+		}
+	}
+
+	res := NewResults(merged)
+	if len(keys) > 0 {
+		res.Keys = keys
+	}
+	if len(errorCounts) > 0 {
+		res.ErrorCounts = errorCounts
+	}
+	return res, nil
+}
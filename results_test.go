@@ -0,0 +1,114 @@
+package dbperf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultsRoundTrip(t *testing.T) {
+	latencies := []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}
+	stats := calculateStats(latencies)
+	stats.Duration = time.Second
+	stats.Latencies = latencies
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteResults(&buf, NewResults(stats)))
+
+	res, err := ReadResults(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, latencies, res.Latencies)
+
+	// results saved without a histogram get one
+	assert.Equal(t, NewHistogram(latencies), res.Stats.Histogram)
+	res.Stats.Histogram = nil
+	assert.Equal(t, stats, res.Stats)
+}
+
+func TestResultsManifest(t *testing.T) {
+	stats := calculateStats([]time.Duration{time.Millisecond})
+	res := NewResults(stats)
+	res.ID = NewRunID()
+	res.Tags = Tags{"branch": "main"}
+	res.Manifest = &Manifest{Version: "v1.2.0", GoVersion: "go1.12", Flags: map[string]string{"n": "8"}, ServerVersion: "11.2"}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteResults(&buf, res))
+
+	actual, err := ReadResults(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, res.Manifest, actual.Manifest)
+	assert.Equal(t, res.ID, actual.ID)
+	assert.Equal(t, res.Tags, actual.Tags)
+
+	// results saved before manifests were recorded have none
+	actual, err = ReadResults(strings.NewReader(`{"latencies": [1000000]}`))
+	require.NoError(t, err)
+	assert.Nil(t, actual.Manifest)
+}
+
+func TestReadResultsLatenciesOnly(t *testing.T) {
+	res, err := ReadResults(strings.NewReader(`{"latencies": [3000000, 1000000, 2000000]}`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), res.Stats.Processed)
+	assert.Equal(t, 2*time.Millisecond, res.Stats.Median)
+}
+
+func TestMergeResults(t *testing.T) {
+	results := func(d time.Duration, errors int64, latencies ...time.Duration) *Results {
+		stats := calculateStats(latencies)
+		stats.Duration = d
+		stats.Errors = errors
+		stats.Latencies = latencies
+		return NewResults(stats)
+	}
+
+	a := results(time.Second, 1, time.Millisecond, 3*time.Millisecond)
+	b := results(2*time.Second, 0, 2*time.Millisecond, 4*time.Millisecond, 5*time.Millisecond)
+
+	merged, err := MergeResults(a, b)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond, 4 * time.Millisecond, 5 * time.Millisecond}, merged.Latencies)
+	assert.Equal(t, int64(5), merged.Stats.Processed)
+	assert.InEpsilon(t, float64(3*time.Millisecond), float64(merged.Stats.Median), 0.02)
+	assert.Equal(t, 5*time.Millisecond, merged.Stats.Max)
+	assert.Equal(t, 2*time.Second, merged.Stats.Duration)
+	assert.Equal(t, int64(1), merged.Stats.Errors)
+}
+
+func TestReportResults(t *testing.T) {
+	ms := time.Millisecond
+	report := func(host string, latencies ...time.Duration) *Report {
+		stats := calculateStats(latencies)
+		stats.Latencies = latencies
+		stats.Histogram = NewHistogram(latencies)
+		return &Report{
+			QueryStats:  stats,
+			Keys:        map[string]*QueryStats{host: NewHistogram(latencies).stats()},
+			ErrorCounts: map[string]int64{"timeout": 1},
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteResults(&buf, report("host_1", ms, 3*ms).Results()))
+	a, err := ReadResults(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), a.Keys["host_1"].Processed)
+	assert.Equal(t, 3*ms, a.Keys["host_1"].Max)
+	assert.Equal(t, map[string]int64{"timeout": 1}, a.ErrorCounts)
+
+	// the breakdowns of results run in parallel are merged as well
+	merged, err := MergeResults(a, report("host_1", 5*ms).Results(), report("host_2", 2*ms).Results())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), merged.Keys["host_1"].Processed)
+	assert.Equal(t, 5*ms, merged.Keys["host_1"].Max)
+	assert.Equal(t, int64(1), merged.Keys["host_2"].Processed)
+	assert.Equal(t, int64(3), merged.ErrorCounts["timeout"])
+
+	// without breakdowns the results are as before
+	assert.Nil(t, (&Report{QueryStats: &QueryStats{}}).Results().Keys)
+}
@@ -0,0 +1,245 @@
+package dbperf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RunRecord is the summary of a run kept in a ResultsStore
+type RunRecord struct {
+	ID       string      `json:"id"`
+	Name     string      `json:"name,omitempty"` // workload that was run, e.g. the query template and input file
+	Tags     Tags        `json:"tags,omitempty"`
+	Started  time.Time   `json:"started"`
+	Finished time.Time   `json:"finished"`
+	Stats    *QueryStats `json:"stats"`
+}
+
+// ResultsStore keeps the results of runs in a directory so they outlive the process that ran them, e.g. for the
+// history of runs submitted to the serve command. Every run is kept as a summary record next to its results in the
+// format written by WriteResults, so the history can be listed without reading every latency.
+//
+// The layout of the store is versioned, stores written by an earlier version of dbperf are migrated when opened.
+type ResultsStore struct {
+	dir string
+}
+
+// ResultsStoreVersion is the version of the layout of the results stores written by this version of dbperf
+const ResultsStoreVersion = 1
+
+// storeMigration upgrades a results store from the previous version of its layout to version
+type storeMigration struct {
+	version int
+	migrate func(s *ResultsStore) error
+}
+
+// storeMigrations upgrade the layout of the results store, in order. Stores from before the layout was versioned are
+// version 0.
+var storeMigrations = []storeMigration{
+	// the records only keep the summary stats, the breakdowns and histogram are in the results
+	{version: 1, migrate: (*ResultsStore).summarizeRecords},
+}
+
+// storeVersionFile is the file in the store directory recording the version of its layout
+const storeVersionFile = "store.json"
+
+type storeVersion struct {
+	Version int `json:"version"`
+}
+
+// OpenResultsStore opens the store in dir, creating the directory if it doesn't exist yet, and migrates the store to
+// the current version of its layout
+func OpenResultsStore(dir string) (*ResultsStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &ResultsStore{dir: dir}
+	version, err := s.version()
+	if err != nil {
+		return nil, err
+	}
+	if version > ResultsStoreVersion {
+		return nil, fmt.Errorf("results store %s is version %d, newer than the version %d supported, upgrade dbperf",
+			dir, version, ResultsStoreVersion)
+	}
+
+	for _, m := range storeMigrations {
+		if m.version <= version {
+			continue
+		}
+		if err := m.migrate(s); err != nil {
+			return nil, fmt.Errorf("failed to migrate results store %s to version %d: %s", dir, m.version, err)
+		}
+		// recorded after every migration so an interrupted upgrade resumes where it stopped
+		if err := s.setVersion(m.version); err != nil {
+			return nil, err
+		}
+		version = m.version
+	}
+	if version != ResultsStoreVersion {
+		// a new store
+		if err := s.setVersion(ResultsStoreVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// version returns the version of the layout of the store, 0 if it isn't recorded
+func (s *ResultsStore) version() (int, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, storeVersionFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var v storeVersion
+	if err := json.Unmarshal(data, &v); err != nil {
+		return 0, fmt.Errorf("%s: %s", storeVersionFile, err)
+	}
+	return v.Version, nil
+}
+
+func (s *ResultsStore) setVersion(version int) error {
+	data, err := json.Marshal(storeVersion{Version: version})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, storeVersionFile), data)
+}
+
+// summarizeRecords rewrites the records of the store with only their summary stats (see Save)
+func (s *ResultsStore) summarizeRecords() error {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*.run.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		var rec RunRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		if err := s.writeRecord(&rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summaryStats returns the summary of the stats without their breakdowns and histogram
+func summaryStats(s *QueryStats) *QueryStats {
+	if s == nil {
+		return nil
+	}
+	return &QueryStats{
+		Processed:    s.Processed,
+		TotalElapsed: s.TotalElapsed,
+		Min:          s.Min,
+		Max:          s.Max,
+		Avg:          s.Avg,
+		StdDev:       s.StdDev,
+		GeoMean:      s.GeoMean,
+		HarmonicMean: s.HarmonicMean,
+		Median:       s.Median,
+		P95:          s.P95,
+		P99:          s.P99,
+		Duration:     s.Duration,
+		Errors:       s.Errors,
+	}
+}
+
+// validRunID reports whether the run id is usable as part of a file name in the store
+func validRunID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`)
+}
+
+// Save stores the results of a run, replacing those of an earlier run with the same id. The record keeps only the
+// summary of the stats of the run, see Load for the rest.
+func (s *ResultsStore) Save(rec *RunRecord, res *Results) error {
+	if !validRunID(rec.ID) {
+		return fmt.Errorf("invalid run id: %q", rec.ID)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteResults(&buf, res); err != nil {
+		return err
+	}
+	// the results go first so every run listed has them
+	if err := writeFileAtomic(filepath.Join(s.dir, rec.ID+".results.json"), buf.Bytes()); err != nil {
+		return err
+	}
+
+	return s.writeRecord(rec)
+}
+
+// writeRecord writes the record of a run with only the summary of its stats, the breakdowns and histogram are in
+// its results
+func (s *ResultsStore) writeRecord(rec *RunRecord) error {
+	summary := *rec
+	summary.Stats = summaryStats(rec.Stats)
+	data, err := json.MarshalIndent(&summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, rec.ID+".run.json"), data)
+}
+
+// List returns the records of the runs in the store with every one of the filter tags, all of them if there are
+// none, ordered by when they started
+func (s *ResultsStore) List(filter Tags) ([]*RunRecord, error) {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*.run.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*RunRecord, 0, len(names))
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		var rec RunRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		if rec.Tags.Match(filter) {
+			records = append(records, &rec)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].Started.Before(records[j].Started) })
+	return records, nil
+}
+
+// Load returns the results of the run, os.IsNotExist reports whether an error is due to the run not being stored
+func (s *ResultsStore) Load(id string) (*Results, error) {
+	if !validRunID(id) {
+		return nil, fmt.Errorf("invalid run id: %q", id)
+	}
+
+	f, err := os.Open(filepath.Join(s.dir, id+".results.json"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadResults(f)
+}
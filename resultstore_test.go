@@ -0,0 +1,97 @@
+package dbperf
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultsStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbperf")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := OpenResultsStore(dir)
+	require.NoError(t, err)
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	save := func(id string, started time.Time, tags Tags, latencies ...time.Duration) {
+		stats := calculateStats(latencies)
+		stats.Latencies = latencies
+		rec := &RunRecord{ID: id, Name: "minmax queries.csv", Tags: tags, Started: started, Finished: started.Add(time.Minute), Stats: stats}
+		require.NoError(t, store.Save(rec, NewResults(stats)))
+	}
+	save("2", start.Add(time.Hour), Tags{"branch": "feature"}, 3*time.Millisecond)
+	save("1", start, Tags{"branch": "main"}, time.Millisecond, 2*time.Millisecond)
+
+	// listed by start without the latencies
+	records, err := store.List(nil)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "1", records[0].ID)
+	assert.Equal(t, "minmax queries.csv", records[0].Name)
+	assert.True(t, start.Equal(records[0].Started))
+	assert.Equal(t, int64(2), records[0].Stats.Processed)
+	assert.Empty(t, records[0].Stats.Latencies)
+	assert.Equal(t, "2", records[1].ID)
+
+	records, err = store.List(Tags{"branch": "feature"})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "2", records[0].ID)
+	assert.Equal(t, Tags{"branch": "feature"}, records[0].Tags)
+
+	res, err := store.Load("1")
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, res.Latencies)
+
+	_, err = store.Load("3")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = store.Load("../1")
+	assert.Error(t, err)
+	assert.Error(t, store.Save(&RunRecord{ID: "a/b"}, &Results{}))
+}
+
+func TestResultsStoreMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbperf")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// a record from before the layout was versioned, with the histogram and breakdowns of the run
+	stats := calculateStats([]time.Duration{time.Millisecond, 2 * time.Millisecond})
+	stats.Histogram = NewHistogram([]time.Duration{time.Millisecond, 2 * time.Millisecond})
+	stats.Partitions = map[string]*QueryStats{"1": calculateStats([]time.Duration{time.Millisecond})}
+	data, err := json.Marshal(&RunRecord{ID: "1", Started: time.Now(), Stats: stats})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "1.run.json"), data, 0644))
+
+	store, err := OpenResultsStore(dir)
+	require.NoError(t, err)
+	version, err := store.version()
+	require.NoError(t, err)
+	assert.Equal(t, ResultsStoreVersion, version)
+
+	records, err := store.List(nil)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, int64(2), records[0].Stats.Processed)
+	assert.Equal(t, stats.P99, records[0].Stats.P99)
+	assert.Nil(t, records[0].Stats.Histogram)
+	assert.Nil(t, records[0].Stats.Partitions)
+
+	// opening it again is a no-op
+	_, err = OpenResultsStore(dir)
+	require.NoError(t, err)
+
+	// stores written by a newer dbperf are left alone
+	require.NoError(t, store.setVersion(ResultsStoreVersion+1))
+	_, err = OpenResultsStore(dir)
+	assert.Error(t, err)
+}
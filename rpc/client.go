@@ -0,0 +1,30 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// FetchLatencies reads the latencies of a finished run from the server in ascending order
+func FetchLatencies(ctx context.Context, client DbperfClient, id string) ([]time.Duration, error) {
+	stream, err := client.GetLatencies(ctx, &RunRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+
+	var latencies []time.Duration
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return latencies, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range chunk.Nanos {
+			latencies = append(latencies, time.Duration(n))
+		}
+	}
+}
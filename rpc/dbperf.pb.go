@@ -0,0 +1,829 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: rpc/dbperf.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Status int32
+
+const (
+	Status_STATUS_UNSPECIFIED Status = 0
+	Status_STATUS_RUNNING     Status = 1
+	Status_STATUS_DONE        Status = 2
+	Status_STATUS_FAILED      Status = 3
+	Status_STATUS_CANCELLED   Status = 4
+)
+
+// Enum value maps for Status.
+var (
+	Status_name = map[int32]string{
+		0: "STATUS_UNSPECIFIED",
+		1: "STATUS_RUNNING",
+		2: "STATUS_DONE",
+		3: "STATUS_FAILED",
+		4: "STATUS_CANCELLED",
+	}
+	Status_value = map[string]int32{
+		"STATUS_UNSPECIFIED": 0,
+		"STATUS_RUNNING":     1,
+		"STATUS_DONE":        2,
+		"STATUS_FAILED":      3,
+		"STATUS_CANCELLED":   4,
+	}
+)
+
+func (x Status) Enum() *Status {
+	p := new(Status)
+	*p = x
+	return p
+}
+
+func (x Status) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Status) Descriptor() protoreflect.EnumDescriptor {
+	return file_rpc_dbperf_proto_enumTypes[0].Descriptor()
+}
+
+func (Status) Type() protoreflect.EnumType {
+	return &file_rpc_dbperf_proto_enumTypes[0]
+}
+
+func (x Status) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Status.Descriptor instead.
+func (Status) EnumDescriptor() ([]byte, []int) {
+	return file_rpc_dbperf_proto_rawDescGZIP(), []int{0}
+}
+
+type StartRunRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Query string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// Types that are valid to be assigned to Input:
+	//
+	//	*StartRunRequest_Queries
+	//	*StartRunRequest_File
+	Input         isStartRunRequest_Input `protobuf_oneof:"input"`
+	Workers       int32                   `protobuf:"varint,4,opt,name=workers,proto3" json:"workers,omitempty"`
+	Duration      *durationpb.Duration    `protobuf:"bytes,5,opt,name=duration,proto3" json:"duration,omitempty"`
+	Rate          float64                 `protobuf:"fixed64,6,opt,name=rate,proto3" json:"rate,omitempty"`
+	StartAt       *timestamppb.Timestamp  `protobuf:"bytes,7,opt,name=start_at,json=startAt,proto3" json:"start_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartRunRequest) Reset() {
+	*x = StartRunRequest{}
+	mi := &file_rpc_dbperf_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartRunRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartRunRequest) ProtoMessage() {}
+
+func (x *StartRunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_dbperf_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartRunRequest.ProtoReflect.Descriptor instead.
+func (*StartRunRequest) Descriptor() ([]byte, []int) {
+	return file_rpc_dbperf_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartRunRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *StartRunRequest) GetInput() isStartRunRequest_Input {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+func (x *StartRunRequest) GetQueries() string {
+	if x != nil {
+		if x, ok := x.Input.(*StartRunRequest_Queries); ok {
+			return x.Queries
+		}
+	}
+	return ""
+}
+
+func (x *StartRunRequest) GetFile() string {
+	if x != nil {
+		if x, ok := x.Input.(*StartRunRequest_File); ok {
+			return x.File
+		}
+	}
+	return ""
+}
+
+func (x *StartRunRequest) GetWorkers() int32 {
+	if x != nil {
+		return x.Workers
+	}
+	return 0
+}
+
+func (x *StartRunRequest) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *StartRunRequest) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+func (x *StartRunRequest) GetStartAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartAt
+	}
+	return nil
+}
+
+type isStartRunRequest_Input interface {
+	isStartRunRequest_Input()
+}
+
+type StartRunRequest_Queries struct {
+	Queries string `protobuf:"bytes,2,opt,name=queries,proto3,oneof"`
+}
+
+type StartRunRequest_File struct {
+	File string `protobuf:"bytes,3,opt,name=file,proto3,oneof"`
+}
+
+func (*StartRunRequest_Queries) isStartRunRequest_Input() {}
+
+func (*StartRunRequest_File) isStartRunRequest_Input() {}
+
+type RunRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunRequest) Reset() {
+	*x = RunRequest{}
+	mi := &file_rpc_dbperf_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunRequest) ProtoMessage() {}
+
+func (x *RunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_dbperf_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunRequest.ProtoReflect.Descriptor instead.
+func (*RunRequest) Descriptor() ([]byte, []int) {
+	return file_rpc_dbperf_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RunRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type Run struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status        Status                 `protobuf:"varint,2,opt,name=status,proto3,enum=dbperf.v1.Status" json:"status,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Started       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=started,proto3" json:"started,omitempty"`
+	Finished      *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=finished,proto3" json:"finished,omitempty"`
+	Stats         *Stats                 `protobuf:"bytes,6,opt,name=stats,proto3" json:"stats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Run) Reset() {
+	*x = Run{}
+	mi := &file_rpc_dbperf_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Run) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Run) ProtoMessage() {}
+
+func (x *Run) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_dbperf_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Run.ProtoReflect.Descriptor instead.
+func (*Run) Descriptor() ([]byte, []int) {
+	return file_rpc_dbperf_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Run) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Run) GetStatus() Status {
+	if x != nil {
+		return x.Status
+	}
+	return Status_STATUS_UNSPECIFIED
+}
+
+func (x *Run) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Run) GetStarted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Started
+	}
+	return nil
+}
+
+func (x *Run) GetFinished() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Finished
+	}
+	return nil
+}
+
+func (x *Run) GetStats() *Stats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+type Stats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Processed     int64                  `protobuf:"varint,1,opt,name=processed,proto3" json:"processed,omitempty"`
+	Errors        int64                  `protobuf:"varint,2,opt,name=errors,proto3" json:"errors,omitempty"`
+	Duration      *durationpb.Duration   `protobuf:"bytes,3,opt,name=duration,proto3" json:"duration,omitempty"`
+	Min           *durationpb.Duration   `protobuf:"bytes,4,opt,name=min,proto3" json:"min,omitempty"`
+	Max           *durationpb.Duration   `protobuf:"bytes,5,opt,name=max,proto3" json:"max,omitempty"`
+	Avg           *durationpb.Duration   `protobuf:"bytes,6,opt,name=avg,proto3" json:"avg,omitempty"`
+	Median        *durationpb.Duration   `protobuf:"bytes,7,opt,name=median,proto3" json:"median,omitempty"`
+	P95           *durationpb.Duration   `protobuf:"bytes,8,opt,name=p95,proto3" json:"p95,omitempty"`
+	P99           *durationpb.Duration   `protobuf:"bytes,9,opt,name=p99,proto3" json:"p99,omitempty"`
+	TotalElapsed  *durationpb.Duration   `protobuf:"bytes,10,opt,name=total_elapsed,json=totalElapsed,proto3" json:"total_elapsed,omitempty"`
+	Histogram     *Histogram             `protobuf:"bytes,11,opt,name=histogram,proto3" json:"histogram,omitempty"`
+	StdDev        *durationpb.Duration   `protobuf:"bytes,12,opt,name=std_dev,json=stdDev,proto3" json:"std_dev,omitempty"`
+	GeoMean       *durationpb.Duration   `protobuf:"bytes,13,opt,name=geo_mean,json=geoMean,proto3" json:"geo_mean,omitempty"`
+	HarmonicMean  *durationpb.Duration   `protobuf:"bytes,14,opt,name=harmonic_mean,json=harmonicMean,proto3" json:"harmonic_mean,omitempty"`
+	Rows          int64                  `protobuf:"varint,15,opt,name=rows,proto3" json:"rows,omitempty"`
+	Affected      int64                  `protobuf:"varint,16,opt,name=affected,proto3" json:"affected,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_rpc_dbperf_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_dbperf_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_rpc_dbperf_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Stats) GetProcessed() int64 {
+	if x != nil {
+		return x.Processed
+	}
+	return 0
+}
+
+func (x *Stats) GetErrors() int64 {
+	if x != nil {
+		return x.Errors
+	}
+	return 0
+}
+
+func (x *Stats) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *Stats) GetMin() *durationpb.Duration {
+	if x != nil {
+		return x.Min
+	}
+	return nil
+}
+
+func (x *Stats) GetMax() *durationpb.Duration {
+	if x != nil {
+		return x.Max
+	}
+	return nil
+}
+
+func (x *Stats) GetAvg() *durationpb.Duration {
+	if x != nil {
+		return x.Avg
+	}
+	return nil
+}
+
+func (x *Stats) GetMedian() *durationpb.Duration {
+	if x != nil {
+		return x.Median
+	}
+	return nil
+}
+
+func (x *Stats) GetP95() *durationpb.Duration {
+	if x != nil {
+		return x.P95
+	}
+	return nil
+}
+
+func (x *Stats) GetP99() *durationpb.Duration {
+	if x != nil {
+		return x.P99
+	}
+	return nil
+}
+
+func (x *Stats) GetTotalElapsed() *durationpb.Duration {
+	if x != nil {
+		return x.TotalElapsed
+	}
+	return nil
+}
+
+func (x *Stats) GetHistogram() *Histogram {
+	if x != nil {
+		return x.Histogram
+	}
+	return nil
+}
+
+func (x *Stats) GetStdDev() *durationpb.Duration {
+	if x != nil {
+		return x.StdDev
+	}
+	return nil
+}
+
+func (x *Stats) GetGeoMean() *durationpb.Duration {
+	if x != nil {
+		return x.GeoMean
+	}
+	return nil
+}
+
+func (x *Stats) GetHarmonicMean() *durationpb.Duration {
+	if x != nil {
+		return x.HarmonicMean
+	}
+	return nil
+}
+
+func (x *Stats) GetRows() int64 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+func (x *Stats) GetAffected() int64 {
+	if x != nil {
+		return x.Affected
+	}
+	return 0
+}
+
+type Histogram struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Counts        map[int32]int64        `protobuf:"bytes,1,rep,name=counts,proto3" json:"counts,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Histogram) Reset() {
+	*x = Histogram{}
+	mi := &file_rpc_dbperf_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Histogram) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Histogram) ProtoMessage() {}
+
+func (x *Histogram) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_dbperf_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Histogram.ProtoReflect.Descriptor instead.
+func (*Histogram) Descriptor() ([]byte, []int) {
+	return file_rpc_dbperf_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Histogram) GetCounts() map[int32]int64 {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+type QueryResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Latency       *durationpb.Duration   `protobuf:"bytes,1,opt,name=latency,proto3" json:"latency,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Cancelled     bool                   `protobuf:"varint,3,opt,name=cancelled,proto3" json:"cancelled,omitempty"`
+	Space         string                 `protobuf:"bytes,4,opt,name=space,proto3" json:"space,omitempty"`
+	Tenant        string                 `protobuf:"bytes,5,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	Completed     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=completed,proto3" json:"completed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryResult) Reset() {
+	*x = QueryResult{}
+	mi := &file_rpc_dbperf_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResult) ProtoMessage() {}
+
+func (x *QueryResult) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_dbperf_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryResult.ProtoReflect.Descriptor instead.
+func (*QueryResult) Descriptor() ([]byte, []int) {
+	return file_rpc_dbperf_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *QueryResult) GetLatency() *durationpb.Duration {
+	if x != nil {
+		return x.Latency
+	}
+	return nil
+}
+
+func (x *QueryResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *QueryResult) GetCancelled() bool {
+	if x != nil {
+		return x.Cancelled
+	}
+	return false
+}
+
+func (x *QueryResult) GetSpace() string {
+	if x != nil {
+		return x.Space
+	}
+	return ""
+}
+
+func (x *QueryResult) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+func (x *QueryResult) GetCompleted() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Completed
+	}
+	return nil
+}
+
+type Latencies struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nanos         []int64                `protobuf:"varint,1,rep,packed,name=nanos,proto3" json:"nanos,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Latencies) Reset() {
+	*x = Latencies{}
+	mi := &file_rpc_dbperf_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Latencies) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Latencies) ProtoMessage() {}
+
+func (x *Latencies) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_dbperf_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Latencies.ProtoReflect.Descriptor instead.
+func (*Latencies) Descriptor() ([]byte, []int) {
+	return file_rpc_dbperf_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Latencies) GetNanos() []int64 {
+	if x != nil {
+		return x.Nanos
+	}
+	return nil
+}
+
+var File_rpc_dbperf_proto protoreflect.FileDescriptor
+
+const file_rpc_dbperf_proto_rawDesc = "" +
+	"\n" +
+	"\x10rpc/dbperf.proto\x12\tdbperf.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xfe\x01\n" +
+	"\x0fStartRunRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x1a\n" +
+	"\aqueries\x18\x02 \x01(\tH\x00R\aqueries\x12\x14\n" +
+	"\x04file\x18\x03 \x01(\tH\x00R\x04file\x12\x18\n" +
+	"\aworkers\x18\x04 \x01(\x05R\aworkers\x125\n" +
+	"\bduration\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\bduration\x12\x12\n" +
+	"\x04rate\x18\x06 \x01(\x01R\x04rate\x125\n" +
+	"\bstart_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\astartAtB\a\n" +
+	"\x05input\"\x1c\n" +
+	"\n" +
+	"RunRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xec\x01\n" +
+	"\x03Run\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12)\n" +
+	"\x06status\x18\x02 \x01(\x0e2\x11.dbperf.v1.StatusR\x06status\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x124\n" +
+	"\astarted\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\astarted\x126\n" +
+	"\bfinished\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\bfinished\x12&\n" +
+	"\x05stats\x18\x06 \x01(\v2\x10.dbperf.v1.StatsR\x05stats\"\xd6\x05\n" +
+	"\x05Stats\x12\x1c\n" +
+	"\tprocessed\x18\x01 \x01(\x03R\tprocessed\x12\x16\n" +
+	"\x06errors\x18\x02 \x01(\x03R\x06errors\x125\n" +
+	"\bduration\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\bduration\x12+\n" +
+	"\x03min\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\x03min\x12+\n" +
+	"\x03max\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x03max\x12+\n" +
+	"\x03avg\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x03avg\x121\n" +
+	"\x06median\x18\a \x01(\v2\x19.google.protobuf.DurationR\x06median\x12+\n" +
+	"\x03p95\x18\b \x01(\v2\x19.google.protobuf.DurationR\x03p95\x12+\n" +
+	"\x03p99\x18\t \x01(\v2\x19.google.protobuf.DurationR\x03p99\x12>\n" +
+	"\rtotal_elapsed\x18\n" +
+	" \x01(\v2\x19.google.protobuf.DurationR\ftotalElapsed\x122\n" +
+	"\thistogram\x18\v \x01(\v2\x14.dbperf.v1.HistogramR\thistogram\x122\n" +
+	"\astd_dev\x18\f \x01(\v2\x19.google.protobuf.DurationR\x06stdDev\x124\n" +
+	"\bgeo_mean\x18\r \x01(\v2\x19.google.protobuf.DurationR\ageoMean\x12>\n" +
+	"\rharmonic_mean\x18\x0e \x01(\v2\x19.google.protobuf.DurationR\fharmonicMean\x12\x12\n" +
+	"\x04rows\x18\x0f \x01(\x03R\x04rows\x12\x1a\n" +
+	"\baffected\x18\x10 \x01(\x03R\baffected\"\x80\x01\n" +
+	"\tHistogram\x128\n" +
+	"\x06counts\x18\x01 \x03(\v2 .dbperf.v1.Histogram.CountsEntryR\x06counts\x1a9\n" +
+	"\vCountsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x05R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\xde\x01\n" +
+	"\vQueryResult\x123\n" +
+	"\alatency\x18\x01 \x01(\v2\x19.google.protobuf.DurationR\alatency\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x1c\n" +
+	"\tcancelled\x18\x03 \x01(\bR\tcancelled\x12\x14\n" +
+	"\x05space\x18\x04 \x01(\tR\x05space\x12\x16\n" +
+	"\x06tenant\x18\x05 \x01(\tR\x06tenant\x128\n" +
+	"\tcompleted\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tcompleted\"!\n" +
+	"\tLatencies\x12\x14\n" +
+	"\x05nanos\x18\x01 \x03(\x03R\x05nanos*n\n" +
+	"\x06Status\x12\x16\n" +
+	"\x12STATUS_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eSTATUS_RUNNING\x10\x01\x12\x0f\n" +
+	"\vSTATUS_DONE\x10\x02\x12\x11\n" +
+	"\rSTATUS_FAILED\x10\x03\x12\x14\n" +
+	"\x10STATUS_CANCELLED\x10\x042\xa4\x02\n" +
+	"\x06Dbperf\x126\n" +
+	"\bStartRun\x12\x1a.dbperf.v1.StartRunRequest\x1a\x0e.dbperf.v1.Run\x120\n" +
+	"\aStopRun\x12\x15.dbperf.v1.RunRequest\x1a\x0e.dbperf.v1.Run\x12/\n" +
+	"\x06GetRun\x12\x15.dbperf.v1.RunRequest\x1a\x0e.dbperf.v1.Run\x12@\n" +
+	"\rStreamResults\x12\x15.dbperf.v1.RunRequest\x1a\x16.dbperf.v1.QueryResult0\x01\x12=\n" +
+	"\fGetLatencies\x12\x15.dbperf.v1.RunRequest\x1a\x14.dbperf.v1.Latencies0\x01B\x16Z\x14timescale/dbperf/rpcb\x06proto3"
+
+var (
+	file_rpc_dbperf_proto_rawDescOnce sync.Once
+	file_rpc_dbperf_proto_rawDescData []byte
+)
+
+func file_rpc_dbperf_proto_rawDescGZIP() []byte {
+	file_rpc_dbperf_proto_rawDescOnce.Do(func() {
+		file_rpc_dbperf_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_rpc_dbperf_proto_rawDesc), len(file_rpc_dbperf_proto_rawDesc)))
+	})
+	return file_rpc_dbperf_proto_rawDescData
+}
+
+var file_rpc_dbperf_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_rpc_dbperf_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_rpc_dbperf_proto_goTypes = []any{
+	(Status)(0),                   // 0: dbperf.v1.Status
+	(*StartRunRequest)(nil),       // 1: dbperf.v1.StartRunRequest
+	(*RunRequest)(nil),            // 2: dbperf.v1.RunRequest
+	(*Run)(nil),                   // 3: dbperf.v1.Run
+	(*Stats)(nil),                 // 4: dbperf.v1.Stats
+	(*Histogram)(nil),             // 5: dbperf.v1.Histogram
+	(*QueryResult)(nil),           // 6: dbperf.v1.QueryResult
+	(*Latencies)(nil),             // 7: dbperf.v1.Latencies
+	nil,                           // 8: dbperf.v1.Histogram.CountsEntry
+	(*durationpb.Duration)(nil),   // 9: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
+}
+var file_rpc_dbperf_proto_depIdxs = []int32{
+	9,  // 0: dbperf.v1.StartRunRequest.duration:type_name -> google.protobuf.Duration
+	10, // 1: dbperf.v1.StartRunRequest.start_at:type_name -> google.protobuf.Timestamp
+	0,  // 2: dbperf.v1.Run.status:type_name -> dbperf.v1.Status
+	10, // 3: dbperf.v1.Run.started:type_name -> google.protobuf.Timestamp
+	10, // 4: dbperf.v1.Run.finished:type_name -> google.protobuf.Timestamp
+	4,  // 5: dbperf.v1.Run.stats:type_name -> dbperf.v1.Stats
+	9,  // 6: dbperf.v1.Stats.duration:type_name -> google.protobuf.Duration
+	9,  // 7: dbperf.v1.Stats.min:type_name -> google.protobuf.Duration
+	9,  // 8: dbperf.v1.Stats.max:type_name -> google.protobuf.Duration
+	9,  // 9: dbperf.v1.Stats.avg:type_name -> google.protobuf.Duration
+	9,  // 10: dbperf.v1.Stats.median:type_name -> google.protobuf.Duration
+	9,  // 11: dbperf.v1.Stats.p95:type_name -> google.protobuf.Duration
+	9,  // 12: dbperf.v1.Stats.p99:type_name -> google.protobuf.Duration
+	9,  // 13: dbperf.v1.Stats.total_elapsed:type_name -> google.protobuf.Duration
+	5,  // 14: dbperf.v1.Stats.histogram:type_name -> dbperf.v1.Histogram
+	9,  // 15: dbperf.v1.Stats.std_dev:type_name -> google.protobuf.Duration
+	9,  // 16: dbperf.v1.Stats.geo_mean:type_name -> google.protobuf.Duration
+	9,  // 17: dbperf.v1.Stats.harmonic_mean:type_name -> google.protobuf.Duration
+	8,  // 18: dbperf.v1.Histogram.counts:type_name -> dbperf.v1.Histogram.CountsEntry
+	9,  // 19: dbperf.v1.QueryResult.latency:type_name -> google.protobuf.Duration
+	10, // 20: dbperf.v1.QueryResult.completed:type_name -> google.protobuf.Timestamp
+	1,  // 21: dbperf.v1.Dbperf.StartRun:input_type -> dbperf.v1.StartRunRequest
+	2,  // 22: dbperf.v1.Dbperf.StopRun:input_type -> dbperf.v1.RunRequest
+	2,  // 23: dbperf.v1.Dbperf.GetRun:input_type -> dbperf.v1.RunRequest
+	2,  // 24: dbperf.v1.Dbperf.StreamResults:input_type -> dbperf.v1.RunRequest
+	2,  // 25: dbperf.v1.Dbperf.GetLatencies:input_type -> dbperf.v1.RunRequest
+	3,  // 26: dbperf.v1.Dbperf.StartRun:output_type -> dbperf.v1.Run
+	3,  // 27: dbperf.v1.Dbperf.StopRun:output_type -> dbperf.v1.Run
+	3,  // 28: dbperf.v1.Dbperf.GetRun:output_type -> dbperf.v1.Run
+	6,  // 29: dbperf.v1.Dbperf.StreamResults:output_type -> dbperf.v1.QueryResult
+	7,  // 30: dbperf.v1.Dbperf.GetLatencies:output_type -> dbperf.v1.Latencies
+	26, // [26:31] is the sub-list for method output_type
+	21, // [21:26] is the sub-list for method input_type
+	21, // [21:21] is the sub-list for extension type_name
+	21, // [21:21] is the sub-list for extension extendee
+	0,  // [0:21] is the sub-list for field type_name
+}
+
+func init() { file_rpc_dbperf_proto_init() }
+func file_rpc_dbperf_proto_init() {
+	if File_rpc_dbperf_proto != nil {
+		return
+	}
+	file_rpc_dbperf_proto_msgTypes[0].OneofWrappers = []any{
+		(*StartRunRequest_Queries)(nil),
+		(*StartRunRequest_File)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_rpc_dbperf_proto_rawDesc), len(file_rpc_dbperf_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_rpc_dbperf_proto_goTypes,
+		DependencyIndexes: file_rpc_dbperf_proto_depIdxs,
+		EnumInfos:         file_rpc_dbperf_proto_enumTypes,
+		MessageInfos:      file_rpc_dbperf_proto_msgTypes,
+	}.Build()
+	File_rpc_dbperf_proto = out.File
+	file_rpc_dbperf_proto_goTypes = nil
+	file_rpc_dbperf_proto_depIdxs = nil
+}
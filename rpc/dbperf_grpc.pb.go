@@ -0,0 +1,280 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: rpc/dbperf.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Dbperf_StartRun_FullMethodName      = "/dbperf.v1.Dbperf/StartRun"
+	Dbperf_StopRun_FullMethodName       = "/dbperf.v1.Dbperf/StopRun"
+	Dbperf_GetRun_FullMethodName        = "/dbperf.v1.Dbperf/GetRun"
+	Dbperf_StreamResults_FullMethodName = "/dbperf.v1.Dbperf/StreamResults"
+	Dbperf_GetLatencies_FullMethodName  = "/dbperf.v1.Dbperf/GetLatencies"
+)
+
+// DbperfClient is the client API for Dbperf service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DbperfClient interface {
+	StartRun(ctx context.Context, in *StartRunRequest, opts ...grpc.CallOption) (*Run, error)
+	StopRun(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error)
+	GetRun(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error)
+	StreamResults(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QueryResult], error)
+	GetLatencies(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Latencies], error)
+}
+
+type dbperfClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDbperfClient(cc grpc.ClientConnInterface) DbperfClient {
+	return &dbperfClient{cc}
+}
+
+func (c *dbperfClient) StartRun(ctx context.Context, in *StartRunRequest, opts ...grpc.CallOption) (*Run, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Run)
+	err := c.cc.Invoke(ctx, Dbperf_StartRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dbperfClient) StopRun(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Run)
+	err := c.cc.Invoke(ctx, Dbperf_StopRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dbperfClient) GetRun(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Run)
+	err := c.cc.Invoke(ctx, Dbperf_GetRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dbperfClient) StreamResults(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QueryResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Dbperf_ServiceDesc.Streams[0], Dbperf_StreamResults_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RunRequest, QueryResult]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dbperf_StreamResultsClient = grpc.ServerStreamingClient[QueryResult]
+
+func (c *dbperfClient) GetLatencies(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Latencies], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Dbperf_ServiceDesc.Streams[1], Dbperf_GetLatencies_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RunRequest, Latencies]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dbperf_GetLatenciesClient = grpc.ServerStreamingClient[Latencies]
+
+// DbperfServer is the server API for Dbperf service.
+// All implementations must embed UnimplementedDbperfServer
+// for forward compatibility.
+type DbperfServer interface {
+	StartRun(context.Context, *StartRunRequest) (*Run, error)
+	StopRun(context.Context, *RunRequest) (*Run, error)
+	GetRun(context.Context, *RunRequest) (*Run, error)
+	StreamResults(*RunRequest, grpc.ServerStreamingServer[QueryResult]) error
+	GetLatencies(*RunRequest, grpc.ServerStreamingServer[Latencies]) error
+	mustEmbedUnimplementedDbperfServer()
+}
+
+// UnimplementedDbperfServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDbperfServer struct{}
+
+func (UnimplementedDbperfServer) StartRun(context.Context, *StartRunRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartRun not implemented")
+}
+func (UnimplementedDbperfServer) StopRun(context.Context, *RunRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopRun not implemented")
+}
+func (UnimplementedDbperfServer) GetRun(context.Context, *RunRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRun not implemented")
+}
+func (UnimplementedDbperfServer) StreamResults(*RunRequest, grpc.ServerStreamingServer[QueryResult]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamResults not implemented")
+}
+func (UnimplementedDbperfServer) GetLatencies(*RunRequest, grpc.ServerStreamingServer[Latencies]) error {
+	return status.Errorf(codes.Unimplemented, "method GetLatencies not implemented")
+}
+func (UnimplementedDbperfServer) mustEmbedUnimplementedDbperfServer() {}
+func (UnimplementedDbperfServer) testEmbeddedByValue()                {}
+
+// UnsafeDbperfServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DbperfServer will
+// result in compilation errors.
+type UnsafeDbperfServer interface {
+	mustEmbedUnimplementedDbperfServer()
+}
+
+func RegisterDbperfServer(s grpc.ServiceRegistrar, srv DbperfServer) {
+	// If the following call pancis, it indicates UnimplementedDbperfServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Dbperf_ServiceDesc, srv)
+}
+
+func _Dbperf_StartRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DbperfServer).StartRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dbperf_StartRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DbperfServer).StartRun(ctx, req.(*StartRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dbperf_StopRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DbperfServer).StopRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dbperf_StopRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DbperfServer).StopRun(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dbperf_GetRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DbperfServer).GetRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dbperf_GetRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DbperfServer).GetRun(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dbperf_StreamResults_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RunRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DbperfServer).StreamResults(m, &grpc.GenericServerStream[RunRequest, QueryResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dbperf_StreamResultsServer = grpc.ServerStreamingServer[QueryResult]
+
+func _Dbperf_GetLatencies_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RunRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DbperfServer).GetLatencies(m, &grpc.GenericServerStream[RunRequest, Latencies]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dbperf_GetLatenciesServer = grpc.ServerStreamingServer[Latencies]
+
+// Dbperf_ServiceDesc is the grpc.ServiceDesc for Dbperf service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Dbperf_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dbperf.v1.Dbperf",
+	HandlerType: (*DbperfServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartRun",
+			Handler:    _Dbperf_StartRun_Handler,
+		},
+		{
+			MethodName: "StopRun",
+			Handler:    _Dbperf_StopRun_Handler,
+		},
+		{
+			MethodName: "GetRun",
+			Handler:    _Dbperf_GetRun_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamResults",
+			Handler:       _Dbperf_StreamResults_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetLatencies",
+			Handler:       _Dbperf_GetLatencies_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rpc/dbperf.proto",
+}
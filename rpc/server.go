@@ -0,0 +1,419 @@
+// Package rpc implements the dbperf gRPC control API defined in dbperf.proto
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"timescale/dbperf"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// streamBuffer is the # of query results buffered per StreamResults call, results are dropped for streams that
+// fall further behind rather than slowing the run down
+const streamBuffer = 4096
+
+// latencyChunk is the # of latencies sent per GetLatencies message, keeping messages well below the default limit
+const latencyChunk = 64 * 1024
+
+// ServerConfig configures the runs started through the API
+type ServerConfig struct {
+	Generators map[string]func(io.Reader) dbperf.QueryGenerator // query templates runs may use by name
+	Workers    int                                              // worker pool size of runs that don't specify one
+	Slot       *dbperf.RunSlot                                  // shared with other servers of db, one of its own if nil
+	KeepRuns   int                                              // # finished runs kept, older ones are evicted, 100 if 0
+	InputDir   string                                           // directory file inputs are opened in, refused if empty
+}
+
+// Server implements the Dbperf service, running one workload at a time against db
+type Server struct {
+	UnimplementedDbperfServer
+
+	db  dbperf.Queryable
+	cfg ServerConfig
+
+	mu   sync.Mutex
+	runs map[string]*run
+}
+
+// run is a run started through the API
+type run struct {
+	id       string
+	status   Status
+	err      string
+	started  time.Time
+	finished time.Time
+	stats    *dbperf.QueryStats
+	cancel   context.CancelFunc
+	streams  map[chan *QueryResult]struct{} // StreamResults calls following the run
+}
+
+// NewServer creates a Server running workloads against db
+func NewServer(db dbperf.Queryable, cfg ServerConfig) *Server {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Slot == nil {
+		cfg.Slot = &dbperf.RunSlot{}
+	}
+	if cfg.KeepRuns <= 0 {
+		cfg.KeepRuns = 100
+	}
+
+	return &Server{
+		db:   db,
+		cfg:  cfg,
+		runs: make(map[string]*run),
+	}
+}
+
+// StartRun starts a run in the background
+func (s *Server) StartRun(ctx context.Context, req *StartRunRequest) (*Run, error) {
+	name := req.GetQuery()
+	if name == "" {
+		name = "minmax"
+	}
+	newGenerator, ok := s.cfg.Generators[name]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown query template: %s", name)
+	}
+
+	workers := int(req.GetWorkers())
+	if workers <= 0 {
+		workers = s.cfg.Workers
+	}
+
+	var input io.ReadCloser
+	switch in := req.GetInput().(type) {
+	case *StartRunRequest_Queries:
+		input = ioutil.NopCloser(strings.NewReader(in.Queries))
+	case *StartRunRequest_File:
+		if s.cfg.InputDir == "" {
+			return nil, status.Error(codes.InvalidArgument, "file inputs aren't allowed by the server, send the queries inline")
+		}
+		f, err := os.OpenInRoot(s.cfg.InputDir, in.File)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		input = f
+	default:
+		return nil, status.Error(codes.InvalidArgument, "either queries or file is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the ids are unique across restarts and the HTTP API sharing the run slot
+	id := dbperf.NewRunID()
+	if holder, ok := s.cfg.Slot.Acquire(id); !ok {
+		input.Close()
+		return nil, status.Errorf(codes.FailedPrecondition, "run %s is still running", holder)
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	r := &run{
+		id:      id,
+		status:  Status_STATUS_RUNNING,
+		started: time.Now(),
+		cancel:  cancel,
+		streams: make(map[chan *QueryResult]struct{}),
+	}
+	s.runs[r.id] = r
+
+	c := dbperf.NewController(dbperf.WithPoolSize(workers))
+	c.SetDuration(req.GetDuration().AsDuration())
+	c.SetRateLimit(req.GetRate())
+	c.SetResultFunc(func(qr dbperf.QueryResult) {
+		s.publish(r, queryResult(qr))
+	})
+
+	startAt := req.GetStartAt()
+	go func() {
+		defer input.Close()
+		defer cancel()
+
+		if startAt != nil {
+			select {
+			case <-time.After(time.Until(startAt.AsTime())):
+			case <-runCtx.Done():
+				s.finish(runCtx, r, nil, runCtx.Err())
+				return
+			}
+		}
+
+		var stats *dbperf.QueryStats
+		report, err := c.RunTest(runCtx, s.db, newGenerator(input))
+		if err == nil {
+			stats = report.QueryStats
+		}
+		s.finish(runCtx, r, stats, err)
+	}()
+
+	return r.proto(), nil
+}
+
+// StopRun cancels a run in progress, it's a no-op for runs that have finished
+func (s *Server) StopRun(ctx context.Context, req *RunRequest) (*Run, error) {
+	r, err := s.lookup(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	r.cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return r.proto(), nil
+}
+
+// GetRun returns the status of a run
+func (s *Server) GetRun(ctx context.Context, req *RunRequest) (*Run, error) {
+	r, err := s.lookup(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return r.proto(), nil
+}
+
+// StreamResults streams the query results of a run until it finishes
+func (s *Server) StreamResults(req *RunRequest, stream Dbperf_StreamResultsServer) error {
+	r, err := s.lookup(req.GetId())
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan *QueryResult, streamBuffer)
+	s.mu.Lock()
+	if r.status != Status_STATUS_RUNNING {
+		s.mu.Unlock()
+		return nil
+	}
+	r.streams[ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(r.streams, ch)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case qr, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(qr); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// GetLatencies streams the latencies of a finished run
+func (s *Server) GetLatencies(req *RunRequest, stream Dbperf_GetLatenciesServer) error {
+	r, err := s.lookup(req.GetId())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	stats := r.stats
+	s.mu.Unlock()
+	if stats == nil {
+		return status.Errorf(codes.FailedPrecondition, "run %s has no results", r.id)
+	}
+
+	latencies := stats.Latencies
+	for len(latencies) > 0 {
+		n := latencyChunk
+		if n > len(latencies) {
+			n = len(latencies)
+		}
+
+		chunk := &Latencies{Nanos: make([]int64, n)}
+		for i, l := range latencies[:n] {
+			chunk.Nanos[i] = int64(l)
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+		latencies = latencies[n:]
+	}
+
+	return nil
+}
+
+func (s *Server) lookup(id string) (*run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.runs[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no run %s", id)
+	}
+	return r, nil
+}
+
+// publish passes a query result on to every stream following the run
+func (s *Server) publish(r *run, qr *QueryResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range r.streams {
+		select {
+		case ch <- qr:
+		default:
+		}
+	}
+}
+
+// finish records the outcome of a run and ends its streams
+func (s *Server) finish(ctx context.Context, r *run, stats *dbperf.QueryStats, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.finished = time.Now()
+	switch {
+	case ctx.Err() != nil:
+		r.status = Status_STATUS_CANCELLED
+	case err != nil:
+		r.status = Status_STATUS_FAILED
+		r.err = err.Error()
+	default:
+		r.status = Status_STATUS_DONE
+		r.stats = stats
+	}
+
+	for ch := range r.streams {
+		close(ch)
+		delete(r.streams, ch)
+	}
+	s.cfg.Slot.Release(r.id)
+	s.evict()
+}
+
+// evict drops the oldest finished runs beyond the # kept along with their latencies, the server lock must be held
+func (s *Server) evict() {
+	var finished []*run
+	for _, r := range s.runs {
+		if !r.finished.IsZero() {
+			finished = append(finished, r)
+		}
+	}
+	if len(finished) <= s.cfg.KeepRuns {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool { return finished[i].finished.Before(finished[j].finished) })
+	for _, r := range finished[:len(finished)-s.cfg.KeepRuns] {
+		delete(s.runs, r.id)
+	}
+}
+
+// proto returns the API representation of the run, the server lock must be held
+func (r *run) proto() *Run {
+	pr := &Run{
+		Id:      r.id,
+		Status:  r.status,
+		Error:   r.err,
+		Started: timestamppb.New(r.started),
+	}
+	if !r.finished.IsZero() {
+		pr.Finished = timestamppb.New(r.finished)
+	}
+
+	if st := r.stats; st != nil {
+		pr.Stats = &Stats{
+			Processed:    st.Processed,
+			Errors:       st.Errors,
+			Duration:     durationpb.New(st.Duration),
+			Min:          durationpb.New(st.Min),
+			Max:          durationpb.New(st.Max),
+			Avg:          durationpb.New(st.Avg),
+			Median:       durationpb.New(st.Median),
+			P95:          durationpb.New(st.P95),
+			P99:          durationpb.New(st.P99),
+			TotalElapsed: durationpb.New(st.TotalElapsed),
+			StdDev:       durationpb.New(st.StdDev),
+			GeoMean:      durationpb.New(st.GeoMean),
+			HarmonicMean: durationpb.New(st.HarmonicMean),
+			Rows:         st.Rows,
+			Affected:     st.Affected,
+		}
+		if st.Histogram != nil {
+			pr.Stats.Histogram = &Histogram{Counts: make(map[int32]int64, len(st.Histogram.Counts))}
+			for idx, n := range st.Histogram.Counts {
+				pr.Stats.Histogram.Counts[int32(idx)] = n
+			}
+		}
+	}
+
+	return pr
+}
+
+// QueryStats converts the stats of a run back to the library's, including the histogram so the stats of several
+// runs can be merged (see dbperf.QueryStats.Merge)
+func (st *Stats) QueryStats() *dbperf.QueryStats {
+	stats := &dbperf.QueryStats{
+		Processed:    st.GetProcessed(),
+		Errors:       st.GetErrors(),
+		Duration:     st.GetDuration().AsDuration(),
+		Min:          st.GetMin().AsDuration(),
+		Max:          st.GetMax().AsDuration(),
+		Avg:          st.GetAvg().AsDuration(),
+		Median:       st.GetMedian().AsDuration(),
+		P95:          st.GetP95().AsDuration(),
+		P99:          st.GetP99().AsDuration(),
+		TotalElapsed: st.GetTotalElapsed().AsDuration(),
+		StdDev:       st.GetStdDev().AsDuration(),
+		GeoMean:      st.GetGeoMean().AsDuration(),
+		HarmonicMean: st.GetHarmonicMean().AsDuration(),
+		Rows:         st.GetRows(),
+		Affected:     st.GetAffected(),
+	}
+
+	if h := st.GetHistogram(); h != nil {
+		stats.Histogram = &dbperf.Histogram{
+			Counts: make(map[int]int64, len(h.Counts)),
+			Count:  stats.Processed,
+			Sum:    stats.TotalElapsed,
+			Min:    stats.Min,
+			Max:    stats.Max,
+		}
+		for idx, n := range h.Counts {
+			stats.Histogram.Counts[int(idx)] = n
+		}
+	}
+
+	return stats
+}
+
+func queryResult(qr dbperf.QueryResult) *QueryResult {
+	pr := &QueryResult{
+		Latency:   durationpb.New(qr.Latency),
+		Cancelled: qr.Cancelled,
+		Space:     qr.Space,
+		Tenant:    qr.Tenant,
+		Completed: timestamppb.New(qr.Completed),
+	}
+	if qr.Err != nil {
+		pr.Error = fmt.Sprint(qr.Err)
+	}
+	return pr
+}
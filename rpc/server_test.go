@@ -0,0 +1,297 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"timescale/dbperf"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const testQueries = `hostname,start_time,end_time
+host_000008,2017-01-01 08:59:22,2017-01-01 09:59:22
+host_000001,2017-01-02 13:02:02,2017-01-02 14:02:02
+host_000008,2017-01-02 18:50:28,2017-01-02 19:50:28`
+
+// newTestClient serves the API over an in-memory connection
+func newTestClient(t *testing.T, db dbperf.Queryable) DbperfClient {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	RegisterDbperfServer(gs, NewServer(db, ServerConfig{
+		Generators: map[string]func(io.Reader) dbperf.QueryGenerator{"minmax": dbperf.NewCPUTestGenerator},
+		Workers:    2,
+	}))
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return NewDbperfClient(conn)
+}
+
+// waitForRun polls the run until it's no longer running
+func waitForRun(t *testing.T, client DbperfClient, id string) *Run {
+	for {
+		run, err := client.GetRun(context.Background(), &RunRequest{Id: id})
+		require.NoError(t, err)
+		if run.Status != Status_STATUS_RUNNING {
+			return run
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(3)
+
+	client := newTestClient(t, mdb)
+	ctx := context.Background()
+
+	run, err := client.StartRun(ctx, &StartRunRequest{Input: &StartRunRequest_Queries{Queries: testQueries}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, run.Id)
+
+	run = waitForRun(t, client, run.Id)
+	assert.Equal(t, Status_STATUS_DONE, run.Status)
+	if assert.NotNil(t, run.Stats) {
+		assert.Equal(t, int64(3), run.Stats.Processed)
+	}
+
+	latencies, err := FetchLatencies(ctx, client, run.Id)
+	require.NoError(t, err)
+	assert.Len(t, latencies, 3)
+}
+
+func TestServerStartAt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(3)
+
+	client := newTestClient(t, mdb)
+	ctx := context.Background()
+
+	run, err := client.StartRun(ctx, &StartRunRequest{
+		Input:   &StartRunRequest_Queries{Queries: testQueries},
+		StartAt: timestamppb.New(time.Now().Add(200 * time.Millisecond)),
+	})
+	require.NoError(t, err)
+
+	// no results until the run has started
+	_, err = FetchLatencies(ctx, client, run.Id)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	run = waitForRun(t, client, run.Id)
+	assert.Equal(t, Status_STATUS_DONE, run.Status)
+	assert.True(t, run.Finished.AsTime().Sub(run.Started.AsTime()) >= 150*time.Millisecond)
+}
+
+func TestServerStreamResults(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	client := newTestClient(t, mdb)
+	ctx := context.Background()
+
+	// a long input at a low rate so the stream is attached well before the run ends
+	queries := "hostname,start_time,end_time\n"
+	for i := 0; i < 1000; i++ {
+		queries += "host_000001,2017-01-01 08:59:22,2017-01-01 09:59:22\n"
+	}
+
+	run, err := client.StartRun(ctx, &StartRunRequest{
+		Input:    &StartRunRequest_Queries{Queries: queries},
+		Rate:     100,
+		Duration: durationpb.New(300 * time.Millisecond),
+	})
+	require.NoError(t, err)
+
+	// only one run at a time
+	_, err = client.StartRun(ctx, &StartRunRequest{Input: &StartRunRequest_Queries{Queries: testQueries}})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	stream, err := client.StreamResults(ctx, &RunRequest{Id: run.Id})
+	require.NoError(t, err)
+
+	n := 0
+	for {
+		qr, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, "host_000001", qr.Space)
+		n++
+	}
+	assert.True(t, n > 0)
+
+	run = waitForRun(t, client, run.Id)
+	assert.Equal(t, Status_STATUS_DONE, run.Status)
+}
+
+func TestServerStopRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	client := newTestClient(t, mdb)
+	ctx := context.Background()
+
+	queries := "hostname,start_time,end_time\n"
+	for i := 0; i < 1000; i++ {
+		queries += "host_000001,2017-01-01 08:59:22,2017-01-01 09:59:22\n"
+	}
+
+	run, err := client.StartRun(ctx, &StartRunRequest{Input: &StartRunRequest_Queries{Queries: queries}, Rate: 10})
+	require.NoError(t, err)
+
+	_, err = client.StopRun(ctx, &RunRequest{Id: run.Id})
+	require.NoError(t, err)
+
+	run = waitForRun(t, client, run.Id)
+	assert.Equal(t, Status_STATUS_CANCELLED, run.Status)
+
+	_, err = client.GetRun(ctx, &RunRequest{Id: "42"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.StartRun(ctx, &StartRunRequest{Query: "nope", Input: &StartRunRequest_Queries{Queries: testQueries}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServerSharedSlot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(3)
+
+	slot := &dbperf.RunSlot{}
+	s := NewServer(mdb, ServerConfig{
+		Generators: map[string]func(io.Reader) dbperf.QueryGenerator{"minmax": dbperf.NewCPUTestGenerator},
+		Slot:       slot,
+	})
+	ctx := context.Background()
+	req := &StartRunRequest{Input: &StartRunRequest_Queries{Queries: testQueries}}
+
+	// a run of another server sharing the slot keeps runs from starting
+	_, ok := slot.Acquire("http")
+	require.True(t, ok)
+	_, err := s.StartRun(ctx, req)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Contains(t, err.Error(), "run http is still running")
+
+	slot.Release("http")
+	run, err := s.StartRun(ctx, req)
+	require.NoError(t, err)
+	_, ok = slot.Acquire("http")
+	assert.False(t, ok)
+
+	for run.Status == Status_STATUS_RUNNING {
+		time.Sleep(10 * time.Millisecond)
+		run, err = s.GetRun(ctx, &RunRequest{Id: run.Id})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, Status_STATUS_DONE, run.Status)
+	_, ok = slot.Acquire("http")
+	assert.True(t, ok)
+}
+
+func TestServerKeepRuns(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(9)
+
+	s := NewServer(mdb, ServerConfig{
+		Generators: map[string]func(io.Reader) dbperf.QueryGenerator{"minmax": dbperf.NewCPUTestGenerator},
+		KeepRuns:   2,
+	})
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		run, err := s.StartRun(ctx, &StartRunRequest{Input: &StartRunRequest_Queries{Queries: testQueries}})
+		require.NoError(t, err)
+		ids = append(ids, run.Id)
+		for run.Status == Status_STATUS_RUNNING {
+			time.Sleep(10 * time.Millisecond)
+			run, err = s.GetRun(ctx, &RunRequest{Id: run.Id})
+			require.NoError(t, err)
+		}
+	}
+
+	// the oldest finished run is evicted
+	_, err := s.GetRun(ctx, &RunRequest{Id: ids[0]})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	for _, id := range ids[1:] {
+		_, err := s.GetRun(ctx, &RunRequest{Id: id})
+		assert.NoError(t, err)
+	}
+}
+
+func TestServerInputDir(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "queries.csv"), []byte(testQueries), 0644))
+	generators := map[string]func(io.Reader) dbperf.QueryGenerator{"minmax": dbperf.NewCPUTestGenerator}
+	ctx := context.Background()
+	file := func(name string) *StartRunRequest {
+		return &StartRunRequest{Input: &StartRunRequest_File{File: name}}
+	}
+
+	// file inputs are refused unless the server has an input directory
+	s := NewServer(mdb, ServerConfig{Generators: generators})
+	_, err := s.StartRun(ctx, file(filepath.Join(dir, "queries.csv")))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// and can't escape it
+	s = NewServer(mdb, ServerConfig{Generators: generators, InputDir: dir})
+	_, err = s.StartRun(ctx, file("../queries.csv"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.StartRun(ctx, file(filepath.Join(dir, "queries.csv")))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	run, err := s.StartRun(ctx, file("queries.csv"))
+	require.NoError(t, err)
+	for run.Status == Status_STATUS_RUNNING {
+		time.Sleep(10 * time.Millisecond)
+		run, err = s.GetRun(ctx, &RunRequest{Id: run.Id})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, Status_STATUS_DONE, run.Status)
+}
@@ -0,0 +1,33 @@
+package dbperf
+
+import "sync"
+
+// RunSlot lets one run at a time execute among the servers sharing it, e.g. the HTTP and gRPC APIs of the same
+// database, so runs don't skew each other's results
+type RunSlot struct {
+	mu     sync.Mutex
+	holder string // ID of the run executing, empty if none is
+}
+
+// Acquire takes the slot for the run with the given ID, returning false and the ID of the run holding it if it's
+// taken
+func (s *RunSlot) Acquire(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.holder != "" {
+		return s.holder, false
+	}
+	s.holder = id
+	return "", true
+}
+
+// Release frees the slot held by the run with the given ID, it's a no-op if another run holds it
+func (s *RunSlot) Release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.holder == id {
+		s.holder = ""
+	}
+}
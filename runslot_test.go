@@ -0,0 +1,26 @@
+package dbperf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSlot(t *testing.T) {
+	var s RunSlot
+	_, ok := s.Acquire("a")
+	assert.True(t, ok)
+
+	holder, ok := s.Acquire("b")
+	assert.False(t, ok)
+	assert.Equal(t, "a", holder)
+
+	// only the holder releases the slot
+	s.Release("b")
+	_, ok = s.Acquire("b")
+	assert.False(t, ok)
+
+	s.Release("a")
+	_, ok = s.Acquire("b")
+	assert.True(t, ok)
+}
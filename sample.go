@@ -0,0 +1,46 @@
+package dbperf
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// reservoir keeps a uniform random sample of a bounded number of latencies out of an unknown number of them
+// (reservoir sampling, Vitter's algorithm R)
+type reservoir struct {
+	size    int
+	seen    int64
+	sampled []time.Duration
+	rnd     *rand.Rand
+}
+
+func newReservoir(size int, seed int64) *reservoir {
+	return &reservoir{
+		size:    size,
+		sampled: make([]time.Duration, 0, size),
+		rnd:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// record offers a latency to the sample, it replaces a sampled latency at random with probability size/seen once
+// the sample is full
+func (r *reservoir) record(d time.Duration) {
+	r.seen++
+	if len(r.sampled) < r.size {
+		r.sampled = append(r.sampled, d)
+		return
+	}
+
+	if i := r.rnd.Int63n(r.seen); i < int64(r.size) {
+		r.sampled[i] = d
+	}
+}
+
+// sorted returns the sampled latencies in ascending order
+func (r *reservoir) sorted() []time.Duration {
+	sorted := make([]time.Duration, len(r.sampled))
+	copy(sorted, r.sampled)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
@@ -0,0 +1,31 @@
+package dbperf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservoir(t *testing.T) {
+	r := newReservoir(100, 1)
+	for i := 0; i < 50; i++ {
+		r.record(time.Duration(i))
+	}
+	// everything is kept until the sample is full
+	assert.Len(t, r.sorted(), 50)
+	assert.Equal(t, time.Duration(0), r.sorted()[0])
+
+	for i := 50; i < 100000; i++ {
+		r.record(time.Duration(i))
+	}
+	sorted := r.sorted()
+	assert.Len(t, sorted, 100)
+	for i := 1; i < len(sorted); i++ {
+		assert.True(t, sorted[i-1] <= sorted[i])
+	}
+
+	// a uniform sample of 0..99999 has a median near the middle
+	median := sorted[50]
+	assert.InDelta(t, 50000, float64(median), 15000)
+}
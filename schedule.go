@@ -0,0 +1,97 @@
+package dbperf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Phase is a single step of a load schedule
+type Phase struct {
+	Duration time.Duration // how long the phase lasts
+	Rate     float64       // target rate in queries per second, 0 dispatches as fast as the workers complete queries
+	Workers  int           // max # of concurrent queries, 0 uses the full worker pool
+}
+
+// Schedule is a load profile the controller follows phase by phase
+type Schedule []Phase
+
+// Duration returns the total duration of the schedule
+func (s Schedule) Duration() time.Duration {
+	var d time.Duration
+	for _, p := range s {
+		d += p.Duration
+	}
+	return d
+}
+
+// maxWorkers returns the largest worker count of any phase
+func (s Schedule) maxWorkers() int {
+	n := 0
+	for _, p := range s {
+		if p.Workers > n {
+			n = p.Workers
+		}
+	}
+	return n
+}
+
+// ParseSchedule reads a schedule with one phase per line in the form:
+//
+//	DURATION RATE [WORKERS]
+//
+// where DURATION is a Go duration (e.g. 2m), RATE is the target queries per second (with an optional "qps" suffix,
+// 0 for unlimited) and WORKERS is the optional max number of concurrent queries. Blank lines and lines starting
+// with # are ignored, e.g.
+//
+//	# warm up, peak, cool down
+//	2m 100qps
+//	5m 500qps 8
+//	2m 100qps
+func ParseSchedule(r io.Reader) (Schedule, error) {
+	var s Schedule
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("schedule line %d: expected DURATION RATE [WORKERS]: %s", line, text)
+		}
+
+		var p Phase
+		var err error
+		if p.Duration, err = time.ParseDuration(fields[0]); err != nil || p.Duration <= 0 {
+			return nil, fmt.Errorf("schedule line %d: invalid duration: %s", line, fields[0])
+		}
+
+		if p.Rate, err = strconv.ParseFloat(strings.TrimSuffix(fields[1], "qps"), 64); err != nil || p.Rate < 0 {
+			return nil, fmt.Errorf("schedule line %d: invalid rate: %s", line, fields[1])
+		}
+
+		if len(fields) == 3 {
+			if p.Workers, err = strconv.Atoi(fields[2]); err != nil || p.Workers < 0 {
+				return nil, fmt.Errorf("schedule line %d: invalid worker count: %s", line, fields[2])
+			}
+		}
+
+		s = append(s, p)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(s) == 0 {
+		return nil, fmt.Errorf("schedule has no phases")
+	}
+
+	return s, nil
+}
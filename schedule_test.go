@@ -0,0 +1,50 @@
+package dbperf
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		input := `# warm up, peak, cool down
+2m 100qps
+
+5m 500 8
+30s 0`
+
+		s, err := ParseSchedule(strings.NewReader(input))
+		assert.NoError(t, err)
+
+		expected := Schedule{
+			{Duration: time.Minute * 2, Rate: 100},
+			{Duration: time.Minute * 5, Rate: 500, Workers: 8},
+			{Duration: time.Second * 30},
+		}
+		assert.Equal(t, expected, s)
+		assert.Equal(t, time.Second*450, s.Duration())
+		assert.Equal(t, 8, s.maxWorkers())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		inputs := []string{
+			"",
+			"# only comments",
+			"2m",
+			"2m 100 4 extra",
+			"2x 100",
+			"-2m 100",
+			"2m fast",
+			"2m -100",
+			"2m 100 many",
+		}
+
+		for _, input := range inputs {
+			_, err := ParseSchedule(strings.NewReader(input))
+			assert.Error(t, err, input)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package dbperf
+
+import (
+	"io"
+	"sort"
+)
+
+// Scheduler picks the worker that executes each query, see WithScheduler. It's only called from the dispatch
+// goroutine.
+type Scheduler interface {
+
+	// Worker returns the id (0 to n-1) of the worker of the pool of n workers to queue the query on
+	Worker(q *Query, n int) int
+}
+
+// NewKeyScheduler creates the default scheduler, which pins queries with the same key (e.g. the host of the cpu usage
+// queries) to the same worker, assigning the workers round robin as new keys are seen. Pinning keeps the queries of
+// a key in order but may starve the other workers if the queries are skewed towards a few keys.
+func NewKeyScheduler() Scheduler {
+	return &keyScheduler{byKey: make(map[string]int)}
+}
+
+type keyScheduler struct {
+	byKey map[string]int // route same key to the same worker every time
+	next  int            // next worker when key has not been seen before
+}
+
+func (s *keyScheduler) Worker(q *Query, n int) int {
+	id, ok := s.byKey[q.key]
+	if !ok {
+		id = s.next
+		s.next = (s.next + 1) % n
+		s.byKey[q.key] = id
+	}
+
+	return id
+}
+
+// NewRoundRobinScheduler creates a scheduler that spreads the queries across the workers in turn regardless of their
+// key, for an even load when the order of the queries of a key doesn't matter
+func NewRoundRobinScheduler() Scheduler {
+	return &roundRobinScheduler{}
+}
+
+type roundRobinScheduler struct {
+	next int
+}
+
+func (s *roundRobinScheduler) Worker(q *Query, n int) int {
+	id := s.next % n
+	s.next = id + 1
+	return id
+}
+
+// NewBalancedScheduler creates a scheduler that pins queries with the same key to the same worker like
+// NewKeyScheduler, but assigns the keys to the workers up front by the # queries of every key (see CountKeys), the
+// keys with the most queries first and every key to the worker with the fewest queries so far, to balance the load of
+// the workers instead of assigning them as keys are seen. Keys missing from the counts go to the least loaded worker
+// when they're seen.
+func NewBalancedScheduler(counts map[string]int64) Scheduler {
+	return &balancedScheduler{counts: counts}
+}
+
+type balancedScheduler struct {
+	counts map[string]int64 // # queries by key
+	n      int              // # workers the keys are assigned to
+	byKey  map[string]int
+	loads  []int64 // # queries assigned to every worker
+}
+
+func (s *balancedScheduler) Worker(q *Query, n int) int {
+	if n != s.n {
+		s.assign(n)
+	}
+
+	id, ok := s.byKey[q.key]
+	if !ok {
+		id = s.leastLoaded()
+		s.byKey[q.key] = id
+		s.loads[id]++
+	}
+	return id
+}
+
+// assign assigns the keys to n workers, longest processing time first
+func (s *balancedScheduler) assign(n int) {
+	s.n = n
+	s.byKey = make(map[string]int, len(s.counts))
+	s.loads = make([]int64, n)
+
+	keys := make([]string, 0, len(s.counts))
+	for key := range s.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if s.counts[keys[i]] != s.counts[keys[j]] {
+			return s.counts[keys[i]] > s.counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, key := range keys {
+		id := s.leastLoaded()
+		s.byKey[key] = id
+		s.loads[id] += s.counts[key]
+	}
+}
+
+// leastLoaded returns the worker with the fewest queries assigned, the first one of those tied
+func (s *balancedScheduler) leastLoaded() int {
+	id := 0
+	for i, load := range s.loads {
+		if load < s.loads[id] {
+			id = i
+		}
+	}
+	return id
+}
+
+// CountKeys reads every query of the generator and counts the queries of every key, for NewBalancedScheduler
+func CountKeys(gen QueryGenerator) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	for {
+		q, err := gen.Next()
+		if err == io.EOF {
+			return counts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		counts[q.key]++
+	}
+}
@@ -0,0 +1,49 @@
+package dbperf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyScheduler(t *testing.T) {
+	s := NewKeyScheduler()
+	var ids []int
+	for _, key := range []string{"a", "b", "a", "c", "d", "b"} {
+		ids = append(ids, s.Worker(&Query{key: key}, 3))
+	}
+	assert.Equal(t, []int{0, 1, 0, 2, 0, 1}, ids)
+}
+
+func TestRoundRobinScheduler(t *testing.T) {
+	s := NewRoundRobinScheduler()
+	var ids []int
+	for _, key := range []string{"a", "a", "a", "a"} {
+		ids = append(ids, s.Worker(&Query{key: key}, 3))
+	}
+	assert.Equal(t, []int{0, 1, 2, 0}, ids)
+}
+
+func TestBalancedScheduler(t *testing.T) {
+	s := NewBalancedScheduler(map[string]int64{"a": 1, "b": 4, "c": 2, "d": 2})
+	var ids []int
+	for _, key := range []string{"a", "b", "c", "d", "e", "a"} {
+		ids = append(ids, s.Worker(&Query{key: key}, 2))
+	}
+	// b (4) -> 0, c (2) -> 1, d (2) -> 1, a (1) -> 0, then e, unknown, -> 1
+	assert.Equal(t, []int{0, 0, 1, 1, 1, 0}, ids)
+}
+
+func TestCountKeys(t *testing.T) {
+	counts, err := CountKeys(NewCPUTestGenerator(strings.NewReader(testQueries)))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{
+		"host_000000": 1, "host_000001": 1, "host_000002": 2, "host_000003": 1,
+		"host_000005": 1, "host_000006": 1, "host_000008": 3,
+	}, counts)
+
+	_, err = CountKeys(NewCPUTestGenerator(strings.NewReader("hostname,start_time,end_time\nhost_000001,bad,bad\n")))
+	assert.Error(t, err)
+}
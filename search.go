@@ -0,0 +1,202 @@
+package dbperf
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// maxSearchSteps bounds the number of steps a throughput search will run
+const maxSearchSteps = 32
+
+// minSearchRate is the lowest rate (queries per second) a throughput search will try
+const minSearchRate = 1
+
+// SearchConfig configures a search for the maximum sustainable throughput
+type SearchConfig struct {
+	PoolSize   int           // worker pool size for every step
+	StartRate  float64       // first rate to try (queries per second)
+	MaxRate    float64       // upper bound on the search (queries per second)
+	Step       time.Duration // how long to run each step
+	Percentile float64       // latency percentile the SLO applies to: 50, 95, 99 or 100
+	Target     time.Duration // the SLO latency, the percentile must not exceed this
+	Precision  float64       // stop once the search range is within this fraction of the rate (e.g. 0.05)
+
+	// Configure is called with the controller of every step before it runs, if set
+	Configure func(c *Controller)
+
+	// OnStep is called with the outcome of every step as the search progresses, if set
+	OnStep func(step SearchStep)
+}
+
+// SearchStep is the outcome of running a single rate during a throughput search
+type SearchStep struct {
+	Rate       float64       // target rate
+	Throughput float64       // achieved rate
+	Latency    time.Duration // latency at the SLO percentile
+	OK         bool          // rate was sustained within the SLO
+	Stats      *QueryStats
+}
+
+// SearchResult is the outcome of a throughput search
+type SearchResult struct {
+	MaxRate float64      // highest rate sustained within the SLO, 0 if none was
+	Knee    float64      // rate at the knee of the latency curve, 0 if there aren't enough steps to tell
+	Steps   []SearchStep // every step run, in order
+}
+
+// sustainedRatio is the fraction of the target rate that must be achieved for a step to be sustained
+const sustainedRatio = 0.95
+
+// SearchMaxThroughput searches for the highest rate that can be sustained while meeting the latency SLO. The rate is
+// doubled from the start rate until the SLO is violated (or the max rate is reached) and then the range between the
+// last passing and first failing rates is bisected until it is within the configured precision.
+//
+// Every step runs with a fresh controller and a fresh generator obtained from newGenerator.
+func SearchMaxThroughput(ctx context.Context, db Queryable, newGenerator func() (QueryGenerator, error), cfg SearchConfig) (*SearchResult, error) {
+	if _, err := sloLatency(&QueryStats{}, cfg.Percentile); err != nil {
+		return nil, err
+	}
+	if cfg.StartRate < minSearchRate {
+		cfg.StartRate = minSearchRate
+	}
+	if cfg.MaxRate < cfg.StartRate {
+		cfg.MaxRate = cfg.StartRate
+	}
+	if cfg.Precision <= 0 {
+		cfg.Precision = 0.05
+	}
+
+	res := &SearchResult{}
+
+	run := func(rate float64) (bool, error) {
+		c := NewController(WithPoolSize(cfg.PoolSize))
+		if cfg.Configure != nil {
+			cfg.Configure(c)
+		}
+		c.SetRateLimit(rate)
+		c.SetDuration(cfg.Step)
+
+		g, err := newGenerator()
+		if err != nil {
+			return false, err
+		}
+
+		report, err := c.RunTest(ctx, db, g)
+		if err != nil {
+			return false, err
+		}
+		stats := report.QueryStats
+
+		latency, _ := sloLatency(stats, cfg.Percentile)
+		step := SearchStep{
+			Rate:       rate,
+			Throughput: stats.Throughput(),
+			Latency:    latency,
+			Stats:      stats,
+		}
+		step.OK = stats.Processed > 0 && latency <= cfg.Target && step.Throughput >= rate*sustainedRatio
+
+		res.Steps = append(res.Steps, step)
+		if cfg.OnStep != nil {
+			cfg.OnStep(step)
+		}
+
+		return step.OK, nil
+	}
+
+	// probe: double until the SLO is violated
+	var lo, hi float64
+	for rate := cfg.StartRate; ; rate *= 2 {
+		if rate > cfg.MaxRate {
+			rate = cfg.MaxRate
+		}
+
+		ok, err := run(rate)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			hi = rate
+			break
+		}
+
+		lo = rate
+		if rate >= cfg.MaxRate {
+			break
+		}
+	}
+
+	// bisect between the last passing and first failing rate
+	for hi > 0 && len(res.Steps) < maxSearchSteps && hi-lo > cfg.Precision*hi && hi > minSearchRate {
+		rate := (lo + hi) / 2
+
+		ok, err := run(rate)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			lo = rate
+		} else {
+			hi = rate
+		}
+	}
+
+	res.MaxRate = lo
+	res.Knee = kneeRate(res.Steps)
+
+	return res, nil
+}
+
+// sloLatency returns the latency at the given percentile of the stats
+func sloLatency(stats *QueryStats, p float64) (time.Duration, error) {
+	switch p {
+	case 50:
+		return stats.Median, nil
+	case 95:
+		return stats.P95, nil
+	case 99:
+		return stats.P99, nil
+	case 100:
+		return stats.Max, nil
+	}
+
+	return 0, fmt.Errorf("unsupported SLO percentile: %v", p)
+}
+
+// kneeRate finds the knee of the rate vs. latency curve, i.e. the rate beyond which latency starts to climb
+// sharply. The curve is normalized and the knee is the point that is furthest below the straight line connecting
+// the lowest and highest rate.
+func kneeRate(steps []SearchStep) float64 {
+	if len(steps) < 3 {
+		return 0
+	}
+
+	sorted := make([]SearchStep, len(steps))
+	copy(sorted, steps)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Rate < sorted[j].Rate
+	})
+
+	first, last := sorted[0], sorted[len(sorted)-1]
+	dx := last.Rate - first.Rate
+	dy := float64(last.Latency - first.Latency)
+	if dx <= 0 || dy <= 0 {
+		return 0
+	}
+
+	var knee, best float64
+	for _, s := range sorted[1 : len(sorted)-1] {
+		x := (s.Rate - first.Rate) / dx
+		y := float64(s.Latency-first.Latency) / dy
+		if d := x - y; d > best {
+			best = d
+			knee = s.Rate
+		}
+	}
+
+	return knee
+}
@@ -0,0 +1,58 @@
+package dbperf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKneeRate(t *testing.T) {
+	t.Run("too few steps", func(t *testing.T) {
+		steps := []SearchStep{
+			{Rate: 100, Latency: time.Millisecond},
+			{Rate: 200, Latency: time.Millisecond * 100},
+		}
+		assert.Equal(t, float64(0), kneeRate(steps))
+	})
+
+	t.Run("flat", func(t *testing.T) {
+		steps := []SearchStep{
+			{Rate: 100, Latency: time.Millisecond},
+			{Rate: 200, Latency: time.Millisecond},
+			{Rate: 400, Latency: time.Millisecond},
+		}
+		assert.Equal(t, float64(0), kneeRate(steps))
+	})
+
+	t.Run("hockey stick", func(t *testing.T) {
+		// steps are unordered as they would be after bisecting
+		steps := []SearchStep{
+			{Rate: 100, Latency: time.Millisecond * 10},
+			{Rate: 200, Latency: time.Millisecond * 11},
+			{Rate: 400, Latency: time.Millisecond * 12},
+			{Rate: 800, Latency: time.Millisecond * 200},
+			{Rate: 600, Latency: time.Millisecond * 20},
+			{Rate: 700, Latency: time.Millisecond * 60},
+		}
+		assert.Equal(t, float64(600), kneeRate(steps))
+	})
+}
+
+func TestSLOLatency(t *testing.T) {
+	stats := &QueryStats{
+		Median: time.Millisecond,
+		P95:    time.Millisecond * 2,
+		P99:    time.Millisecond * 3,
+		Max:    time.Millisecond * 4,
+	}
+
+	for p, expected := range map[float64]time.Duration{50: stats.Median, 95: stats.P95, 99: stats.P99, 100: stats.Max} {
+		actual, err := sloLatency(stats, p)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	_, err := sloLatency(stats, 90)
+	assert.Error(t, err)
+}
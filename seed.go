@@ -0,0 +1,95 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// cpuUsageSchema creates the cpu usage hypertable the built-in query templates run against
+var cpuUsageSchema = []string{
+	`CREATE EXTENSION IF NOT EXISTS timescaledb;`,
+	`CREATE TABLE IF NOT EXISTS cpu_usage(
+		ts    TIMESTAMPTZ,
+		host  TEXT,
+		usage DOUBLE PRECISION
+	);`,
+	`SELECT create_hypertable('cpu_usage', 'ts', if_not_exists => TRUE);`,
+}
+
+// CreateCPUUsageTable creates the cpu_usage hypertable if it doesn't exist yet, dropping any existing one first if
+// drop is set
+func CreateCPUUsageTable(ctx context.Context, db Queryable, drop bool) error {
+	if drop {
+		if _, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS cpu_usage;`); err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range cpuUsageSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadCPUUsage bulk loads cpu usage readings from a CSV source with a header and ts,host,usage columns into the
+// cpu_usage table in a single transaction and returns the # of rows loaded
+func LoadCPUUsage(ctx context.Context, db *sql.DB, r io.Reader) (int64, error) {
+	reader := csv.NewReader(r)
+	if _, err := reader.Read(); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("cpu_usage", "ts", "host", "usage"))
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for {
+		records, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+
+		if len(records) != 3 || !isValidDateTime(records[0]) {
+			return n, fmt.Errorf("invalid cpu usage row %d: %v", n+1, records)
+		}
+		usage, err := strconv.ParseFloat(records[2], 64)
+		if err != nil {
+			return n, fmt.Errorf("invalid cpu usage row %d: %v", n+1, records)
+		}
+
+		if _, err := stmt.ExecContext(ctx, records[0], records[1], usage); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	// flush the copy
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return n, err
+	}
+	if err := stmt.Close(); err != nil {
+		return n, err
+	}
+
+	return n, tx.Commit()
+}
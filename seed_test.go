@@ -0,0 +1,38 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateCPUUsageTable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	calls := []*gomock.Call{
+		mdb.EXPECT().ExecContext(gomock.Any(), `DROP TABLE IF EXISTS cpu_usage;`).Return(driver.RowsAffected(0), nil),
+	}
+	for _, stmt := range cpuUsageSchema {
+		calls = append(calls, mdb.EXPECT().ExecContext(gomock.Any(), stmt).Return(driver.RowsAffected(0), nil))
+	}
+	gomock.InOrder(calls...)
+
+	assert.NoError(t, CreateCPUUsageTable(context.Background(), mdb, true))
+}
+
+func TestCreateCPUUsageTableError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mdb := mock_dbperf.NewMockQueryable(ctrl)
+	mdb.EXPECT().ExecContext(gomock.Any(), cpuUsageSchema[0]).Return(nil, errors.New("extension not available"))
+
+	assert.EqualError(t, CreateCPUUsageTable(context.Background(), mdb, false), "extension not available")
+}
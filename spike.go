@@ -0,0 +1,97 @@
+package dbperf
+
+import (
+	"time"
+)
+
+// SpikeConfig configures the spikes injected during a spike test
+type SpikeConfig struct {
+	Every     time.Duration // inject a spike this often, the first after one interval of baseline load
+	Size      int           // # queries dispatched at once for every spike
+	Window    time.Duration // p99 is tracked over windows of this length to detect recovery (default 1s)
+	Tolerance float64       // p99 has recovered once within this fraction above the baseline (default 0.1)
+}
+
+func (cfg *SpikeConfig) window() time.Duration {
+	if cfg.Window <= 0 {
+		return time.Second
+	}
+	return cfg.Window
+}
+
+func (cfg *SpikeConfig) tolerance() float64 {
+	if cfg.Tolerance <= 0 {
+		return 0.1
+	}
+	return cfg.Tolerance
+}
+
+// SpikeStats reports how the latency reacted to the spikes injected during a run
+type SpikeStats struct {
+	BaselineP99 time.Duration // p99 before the first spike
+	Spikes      []Spike
+}
+
+// Spike is the outcome of a single spike
+type Spike struct {
+	At        time.Duration // when the spike was injected relative to the start of the run
+	Size      int           // # queries injected
+	PeakP99   time.Duration // highest windowed p99 before recovery
+	Recovery  time.Duration // how long until p99 returned to the baseline
+	Recovered bool          // false if p99 didn't return to the baseline before the next spike or end of the run
+}
+
+// analyzeSpikes determines the baseline p99 and the recovery time of every spike from the latencies of the run
+// bucketed into windows by completion time, summarized in histograms when streaming. A spike has recovered at the
+// start of the first window starting at or after the spike whose p99 is within the tolerance of the baseline.
+func analyzeSpikes(cfg SpikeConfig, timeline []*samples, spikes []Spike, streaming bool) *SpikeStats {
+	window := cfg.window()
+	stats := &SpikeStats{}
+
+	// windowP99 returns the p99 of window i, false if it has no results
+	windowP99 := func(i int) (time.Duration, bool) {
+		if i >= len(timeline) || timeline[i].count() == 0 {
+			return 0, false
+		}
+		return timeline[i].stats().P99, true
+	}
+
+	// baseline is everything completed before the first spike
+	baseline := newSamples(streaming)
+	for i := 0; i < len(timeline) && time.Duration(i+1)*window <= cfg.Every; i++ {
+		baseline.merge(timeline[i])
+	}
+	stats.BaselineP99 = baseline.stats().P99
+
+	threshold := time.Duration(float64(stats.BaselineP99) * (1 + cfg.tolerance()))
+
+	for n, spike := range spikes {
+		// recovery has to happen before the next spike
+		limit := len(timeline)
+		if n+1 < len(spikes) {
+			limit = int(spikes[n+1].At / window)
+		}
+
+		first := int((spike.At + window - 1) / window)
+		for i := first; i < limit; i++ {
+			p99, ok := windowP99(i)
+			if !ok {
+				continue
+			}
+
+			if p99 <= threshold {
+				spike.Recovered = true
+				spike.Recovery = time.Duration(i)*window - spike.At
+				break
+			}
+
+			if p99 > spike.PeakP99 {
+				spike.PeakP99 = p99
+			}
+		}
+
+		stats.Spikes = append(stats.Spikes, spike)
+	}
+
+	return stats
+}
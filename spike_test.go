@@ -0,0 +1,76 @@
+package dbperf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// windows summarizes the latencies of every window like the collector does
+func windows(latencies [][]time.Duration, streaming bool) []*samples {
+	timeline := make([]*samples, len(latencies))
+	for i, l := range latencies {
+		timeline[i] = newSamples(streaming)
+		for _, d := range l {
+			timeline[i].record(d)
+		}
+	}
+	return timeline
+}
+
+func TestAnalyzeSpikes(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("streaming=%t", streaming), func(t *testing.T) {
+			ms := time.Millisecond
+			cfg := SpikeConfig{Every: time.Second * 3, Size: 50}
+
+			timeline := windows([][]time.Duration{
+				{10 * ms, 12 * ms}, // baseline
+				{11 * ms, 10 * ms},
+				{9 * ms, 10 * ms},
+				{80 * ms, 12 * ms}, // spike 1 @ 3s
+				{40 * ms},
+				{10 * ms, 11 * ms}, // recovered @ 5s
+				{90 * ms},          // spike 2 @ 6s
+				{},
+				{60 * ms}, // never recovers before the end of the run
+			}, streaming)
+
+			spikes := []Spike{
+				{At: time.Second * 3, Size: 50},
+				{At: time.Second * 6, Size: 50},
+			}
+
+			stats := analyzeSpikes(cfg, timeline, spikes, streaming)
+			assert.InEpsilon(t, float64(12*ms), float64(stats.BaselineP99), 0.02)
+
+			require.Len(t, stats.Spikes, 2)
+			assert.InEpsilon(t, float64(80*ms), float64(stats.Spikes[0].PeakP99), 0.02)
+			assert.InEpsilon(t, float64(90*ms), float64(stats.Spikes[1].PeakP99), 0.02)
+			stats.Spikes[0].PeakP99, stats.Spikes[1].PeakP99 = 0, 0
+			expected := []Spike{
+				{At: time.Second * 3, Size: 50, Recovery: time.Second * 2, Recovered: true},
+				{At: time.Second * 6, Size: 50},
+			}
+			assert.Equal(t, expected, stats.Spikes)
+		})
+	}
+}
+
+func TestAnalyzeSpikesNoImpact(t *testing.T) {
+	ms := time.Millisecond
+	cfg := SpikeConfig{Every: time.Second, Window: time.Millisecond * 500}
+
+	timeline := windows([][]time.Duration{
+		{10 * ms},
+		{10 * ms},
+		{10 * ms}, // spike @ 1s
+		{10 * ms},
+	}, false)
+
+	stats := analyzeSpikes(cfg, timeline, []Spike{{At: time.Second, Size: 10}}, false)
+	assert.Equal(t, []Spike{{At: time.Second, Size: 10, Recovered: true}}, stats.Spikes)
+}
@@ -0,0 +1,115 @@
+package dbperf
+
+import (
+	"context"
+	"sort"
+)
+
+// tableSizeQuery returns the size of every user table, of its heap, its indexes and in total with TOAST, and its #
+// of live and dead rows as estimated by the statistics
+const tableSizeQuery = `SELECT format('%I.%I', schemaname, relname), pg_relation_size(relid), pg_indexes_size(relid),
+	pg_total_relation_size(relid), n_live_tup, n_dead_tup
+	FROM pg_stat_user_tables;`
+
+// TableSize is the size of a table at a point in time, with those of its chunks if it's a hypertable
+type TableSize struct {
+	HeapBytes  int64 `json:"heap_bytes"`
+	IndexBytes int64 `json:"index_bytes"`
+	TotalBytes int64 `json:"total_bytes"` // heap, indexes and TOAST
+	LiveRows   int64 `json:"live_rows"`   // estimated
+	DeadRows   int64 `json:"dead_rows"`   // estimated, not yet vacuumed
+}
+
+// Bloat estimates the bytes of the heap taken by dead rows, assuming dead rows are as large as live ones on average
+func (s TableSize) Bloat() int64 {
+	rows := s.LiveRows + s.DeadRows
+	if rows == 0 {
+		return 0
+	}
+	return int64(float64(s.HeapBytes) * float64(s.DeadRows) / float64(rows))
+}
+
+// SizeSnapshot is a snapshot of the sizes of the user tables of a database by schema qualified name. The chunks of
+// hypertables are rolled up into the hypertable.
+type SizeSnapshot map[string]*TableSize
+
+// TakeSizeSnapshot takes a snapshot of the sizes of the user tables of the database
+func TakeSizeSnapshot(ctx context.Context, db Queryable) (SizeSnapshot, error) {
+	chunks, err := readChunkParents(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, tableSizeQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s := make(SizeSnapshot)
+	for rows.Next() {
+		var table string
+		var ts TableSize
+		if err := rows.Scan(&table, &ts.HeapBytes, &ts.IndexBytes, &ts.TotalBytes, &ts.LiveRows, &ts.DeadRows); err != nil {
+			return nil, err
+		}
+		if c, ok := chunks[table]; ok {
+			table = c.hypertable
+		}
+		t := s[table]
+		if t == nil {
+			t = &TableSize{}
+			s[table] = t
+		}
+		t.HeapBytes += ts.HeapBytes
+		t.IndexBytes += ts.IndexBytes
+		t.TotalBytes += ts.TotalBytes
+		t.LiveRows += ts.LiveRows
+		t.DeadRows += ts.DeadRows
+	}
+	return s, rows.Err()
+}
+
+// SizeChange is how the size of a table changed during a run
+type SizeChange struct {
+	Table  string    `json:"table"`
+	Before TableSize `json:"before"`
+	After  TableSize `json:"after"`
+}
+
+// Growth returns the # of bytes the table grew by in total
+func (c SizeChange) Growth() int64 {
+	return c.After.TotalBytes - c.Before.TotalBytes
+}
+
+// BytesPerRow returns the growth of the table per row it gained, which measures the storage amplification of writes
+// (indexes, TOAST and bloat included), 0 if it gained none
+func (c SizeChange) BytesPerRow() float64 {
+	rows := c.After.LiveRows - c.Before.LiveRows
+	if rows <= 0 {
+		return 0
+	}
+	return float64(c.Growth()) / float64(rows)
+}
+
+// SizeDelta returns the changes of the tables whose size or # of rows changed between the snapshots, the most grown
+// first. Tables created in between count from zero.
+func SizeDelta(before, after SizeSnapshot) []SizeChange {
+	var changes []SizeChange
+	for table, a := range after {
+		c := SizeChange{Table: table, After: *a}
+		if b := before[table]; b != nil {
+			c.Before = *b
+		}
+		if c.Before != c.After {
+			changes = append(changes, c)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Growth() != changes[j].Growth() {
+			return changes[i].Growth() > changes[j].Growth()
+		}
+		return changes[i].Table < changes[j].Table
+	})
+	return changes
+}
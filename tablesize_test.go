@@ -0,0 +1,53 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeDelta(t *testing.T) {
+	// rows inserted into the second chunk by the run
+	var inserted int64
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		switch {
+		case strings.Contains(query, "to_regclass"):
+			return []string{"exists"}, [][]driver.Value{{true}}
+		case strings.Contains(query, "timescaledb_information.chunks"):
+			return []string{"chunk", "chunk_name", "hypertable"}, [][]driver.Value{
+				{"_timescaledb_internal._hyper_1_1_chunk", "_hyper_1_1_chunk", "public.cpu_usage"},
+				{"_timescaledb_internal._hyper_1_2_chunk", "_hyper_1_2_chunk", "public.cpu_usage"},
+			}
+		default:
+			return []string{"table", "heap", "indexes", "total", "live", "dead"}, [][]driver.Value{
+				{"public.cpu_usage", int64(0), int64(8192), int64(8192), int64(0), int64(0)},
+				{"_timescaledb_internal._hyper_1_1_chunk", int64(1000), int64(500), int64(1500), int64(10), int64(0)},
+				{"_timescaledb_internal._hyper_1_2_chunk", 100 * inserted, 50 * inserted, 150 * inserted, inserted, inserted / 4},
+				{"public.tags", int64(100), int64(100), int64(200), int64(1), int64(0)},
+			}
+		}
+	})
+	defer db.Close()
+
+	before, err := TakeSizeSnapshot(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, &TableSize{HeapBytes: 1000, IndexBytes: 8692, TotalBytes: 9692, LiveRows: 10}, before["public.cpu_usage"])
+
+	inserted = 100
+	after, err := TakeSizeSnapshot(context.Background(), db)
+	require.NoError(t, err)
+
+	changes := SizeDelta(before, after)
+	require.Len(t, changes, 1)
+	c := changes[0]
+	assert.Equal(t, "public.cpu_usage", c.Table)
+	assert.Equal(t, int64(15000), c.Growth())
+	assert.Equal(t, 150.0, c.BytesPerRow())
+	// 25 of the 135 rows are dead
+	assert.Equal(t, int64(11000*25/135), c.After.Bloat())
+	assert.Equal(t, int64(0), c.Before.Bloat())
+}
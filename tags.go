@@ -0,0 +1,63 @@
+package dbperf
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NewRunID returns a unique id for a run, ids sort by the time they were created
+func NewRunID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
+
+// Tags are user defined key=value labels of a run, e.g. the branch or instance type tested, to group and filter runs
+// by later. Tags implements flag.Value so it can be set by a repeated flag.
+type Tags map[string]string
+
+// String returns the tags as a comma separated list of key=value pairs ordered by key
+func (t Tags) String() string {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + t[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set adds the tags of a comma separated list of key=value pairs, replacing earlier values of the same keys
+func (t *Tags) Set(s string) error {
+	if *t == nil {
+		*t = make(Tags)
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return fmt.Errorf("invalid tag %q, expected key=value", pair)
+		}
+		(*t)[strings.TrimSpace(pair[:i])] = strings.TrimSpace(pair[i+1:])
+	}
+
+	return nil
+}
+
+// Match reports whether the tags include every one of filter with the same value
+func (t Tags) Match(filter Tags) bool {
+	for k, v := range filter {
+		if tv, ok := t[k]; !ok || tv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package dbperf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRunID(t *testing.T) {
+	a, b := NewRunID(), NewRunID()
+	assert.NotEqual(t, a, b)
+	assert.Len(t, a, len("20190101T000000-01234567"))
+}
+
+func TestTagsSet(t *testing.T) {
+	var tags Tags
+	require.NoError(t, tags.Set("branch=main"))
+	require.NoError(t, tags.Set("instance=m5.large, branch=feature"))
+	assert.Equal(t, Tags{"branch": "feature", "instance": "m5.large"}, tags)
+	assert.Equal(t, "branch=feature,instance=m5.large", tags.String())
+
+	// values may contain =
+	require.NoError(t, tags.Set("opts=a=b"))
+	assert.Equal(t, "a=b", tags["opts"])
+
+	assert.Error(t, tags.Set("branch"))
+	assert.Error(t, tags.Set("=main"))
+}
+
+func TestTagsMatch(t *testing.T) {
+	tags := Tags{"branch": "main", "instance": "m5.large"}
+	assert.True(t, tags.Match(nil))
+	assert.True(t, tags.Match(Tags{"branch": "main"}))
+	assert.False(t, tags.Match(Tags{"branch": "feature"}))
+	assert.False(t, tags.Match(Tags{"region": "us-east-1"}))
+}
@@ -0,0 +1,87 @@
+package dbperf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeQuery returns the template of the query text, like pg_stat_statements does: string and numeric literals are
+// replaced by ?, lists of them collapsed to a single ?, comments are removed and whitespace collapsed to a single
+// space, so queries of the same shape with different literals share a template. Placeholders ($1) are kept.
+func NormalizeQuery(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	space := false
+	rs := []rune(query)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '-':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+			space = true
+			continue
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			for i += 2; i < len(rs) && !(rs[i] == '*' && i+1 < len(rs) && rs[i+1] == '/'); i++ {
+			}
+			i++
+			space = true
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+
+		switch {
+		case r == '\'':
+			// a string, '' escapes a quote
+			for i++; i < len(rs); i++ {
+				if rs[i] == '\'' {
+					if i+1 < len(rs) && rs[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b.WriteByte('?')
+		case unicode.IsDigit(r) && !identifierChar(prev(rs, i)):
+			for i+1 < len(rs) && (unicode.IsDigit(rs[i+1]) || rs[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	// lists of literals, e.g. of IN (...), have the same template whatever their length
+	template := b.String()
+	for {
+		collapsed := strings.Replace(strings.Replace(template, "?, ?", "?", -1), "?,?", "?", -1)
+		if collapsed == template {
+			return template
+		}
+		template = collapsed
+	}
+}
+
+// prev returns the rune before i, 0 at the start
+func prev(rs []rune, i int) rune {
+	if i == 0 {
+		return 0
+	}
+	return rs[i-1]
+}
+
+// identifierChar reports whether r may be part of an identifier or placeholder, a digit following it isn't a literal
+func identifierChar(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
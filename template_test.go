@@ -0,0 +1,26 @@
+package dbperf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT 1", "SELECT ?"},
+		{"select *\n  from cpu_usage\twhere host = $1", "select * from cpu_usage where host = $1"},
+		{"SELECT * FROM t WHERE name = 'it''s' AND n > 42.5", "SELECT * FROM t WHERE name = ? AND n > ?"},
+		{"SELECT * FROM t WHERE id IN (1, 2, 3)", "SELECT * FROM t WHERE id IN (?)"},
+		{"SELECT * FROM t WHERE id IN (4,5)", "SELECT * FROM t WHERE id IN (?)"},
+		{"SELECT col1 FROM t2 -- comment 3\nLIMIT 10", "SELECT col1 FROM t2 LIMIT ?"},
+		{"/* dbperf */ SELECT max(usage) FROM cpu_usage", "SELECT max(usage) FROM cpu_usage"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, NormalizeQuery(tt.query), tt.query)
+	}
+}
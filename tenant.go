@@ -0,0 +1,131 @@
+package dbperf
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Tenant assigns a database role to a number of workers to simulate a multi-tenant workload
+type Tenant struct {
+	Role     string // role the workers run as
+	Workers  int    // # workers assigned the role
+	Login    bool   // log in as the role rather than switching to it with SET ROLE
+	Password string // password to log in with, empty to use the default
+}
+
+// Tenants assigns consecutive ranges of workers to each tenant in order
+type Tenants []Tenant
+
+// Workers returns the total number of workers of all tenants
+func (ts Tenants) Workers() int {
+	n := 0
+	for _, t := range ts {
+		n += t.Workers
+	}
+	return n
+}
+
+// Assign returns the tenant the worker with the given id belongs to, nil if it's beyond the workers of all tenants
+func (ts Tenants) Assign(id int) *Tenant {
+	for i := range ts {
+		if id < ts[i].Workers {
+			return &ts[i]
+		}
+		id -= ts[i].Workers
+	}
+	return nil
+}
+
+// ParseTenants reads a tenant file with one tenant per line in the form:
+//
+//	ROLE WORKERS [login] [password=PASSWORD]
+//
+// Workers switch to the role with SET ROLE on a connection of the default user unless login is given in which case
+// they log in as the role. Blank lines and lines starting with # are ignored, e.g.
+//
+//	# role     workers
+//	tenant_a   4
+//	tenant_b   2        login password=secret
+func ParseTenants(r io.Reader) (Tenants, error) {
+	var ts Tenants
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("tenant line %d: expected ROLE WORKERS [login] [password=PASSWORD]: %s", line, text)
+		}
+
+		t := Tenant{Role: fields[0]}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("tenant line %d: invalid worker count: %s", line, fields[1])
+		}
+		t.Workers = n
+
+		for _, opt := range fields[2:] {
+			switch {
+			case opt == "login":
+				t.Login = true
+			case strings.HasPrefix(opt, "password="):
+				t.Password = strings.TrimPrefix(opt, "password=")
+			default:
+				return nil, fmt.Errorf("tenant line %d: unknown option: %s", line, opt)
+			}
+		}
+
+		ts = append(ts, t)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ts) == 0 {
+		return nil, fmt.Errorf("no tenants")
+	}
+
+	return ts, nil
+}
+
+// WithRole returns a ConnectFunc that switches every connection opened by connect to the given role with SET ROLE.
+// The role is reset when the connection is closed so it doesn't leak to other users of a connection pool.
+func WithRole(connect ConnectFunc, role string) ConnectFunc {
+	return func(ctx context.Context) (Conn, error) {
+		conn, err := connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := conn.ExecContext(ctx, "SET ROLE "+pq.QuoteIdentifier(role)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		return &roleConn{conn}, nil
+	}
+}
+
+// roleConn resets the role of the connection when closed
+type roleConn struct {
+	Conn
+}
+
+func (c *roleConn) Close() error {
+	_, err := c.ExecContext(context.Background(), "RESET ROLE")
+	if cerr := c.Conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
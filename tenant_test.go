@@ -0,0 +1,74 @@
+package dbperf
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTenants(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		input := `# role     workers
+tenant_a   4
+
+tenant_b   2        login password=secret`
+
+		ts, err := ParseTenants(strings.NewReader(input))
+		require.NoError(t, err)
+
+		expected := Tenants{
+			{Role: "tenant_a", Workers: 4},
+			{Role: "tenant_b", Workers: 2, Login: true, Password: "secret"},
+		}
+		assert.Equal(t, expected, ts)
+		assert.Equal(t, 6, ts.Workers())
+
+		assert.Equal(t, "tenant_a", ts.Assign(0).Role)
+		assert.Equal(t, "tenant_a", ts.Assign(3).Role)
+		assert.Equal(t, "tenant_b", ts.Assign(4).Role)
+		assert.Equal(t, "tenant_b", ts.Assign(5).Role)
+		assert.Nil(t, ts.Assign(6))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		inputs := []string{
+			"",
+			"tenant_a",
+			"tenant_a many",
+			"tenant_a 0",
+			"tenant_a 2 sudo",
+		}
+
+		for _, input := range inputs {
+			_, err := ParseTenants(strings.NewReader(input))
+			assert.Error(t, err, input)
+		}
+	})
+}
+
+func TestWithRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mconn := mock_dbperf.NewMockQueryable(ctrl)
+	gomock.InOrder(
+		mconn.EXPECT().ExecContext(gomock.Any(), `SET ROLE "tenant_a"`).Return(nil, nil),
+		mconn.EXPECT().ExecContext(gomock.Any(), "RESET ROLE").Return(nil, nil),
+	)
+
+	closed := 0
+	connect := WithRole(func(ctx context.Context) (Conn, error) {
+		return fakeConn{mconn, &closed}, nil
+	}, "tenant_a")
+
+	conn, err := connect(context.Background())
+	require.NoError(t, err)
+
+	assert.NoError(t, conn.Close())
+	assert.Equal(t, 1, closed)
+}
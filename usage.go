@@ -0,0 +1,98 @@
+package dbperf
+
+import (
+	"runtime"
+	"time"
+)
+
+// ClientUsage is the resource usage of the process running the test during the run, to verify the load generator
+// itself wasn't the bottleneck
+type ClientUsage struct {
+	NumCPU        int           // # CPUs the process may use (GOMAXPROCS)
+	CPU           float64       // average # CPUs used, user and system time over the duration of the run
+	PeakCPU       float64       // highest # CPUs used over a second of the run
+	MaxRSS        int64         // peak resident set size of the process in bytes, 0 where unknown
+	MaxGoroutines int           // highest # goroutines sampled
+	MaxHeap       uint64        // highest # bytes of heap in use sampled
+	GCCycles      uint32        // # garbage collections completed during the run
+	GCPause       time.Duration // total time the world was stopped for garbage collection during the run
+	Samples       []UsageSample // usage by second of the run
+}
+
+// UsageSample is the resource usage of the process over a second of the run
+type UsageSample struct {
+	Start      time.Duration // offset of the sample from the start of the run
+	CPU        float64       // # CPUs used
+	Goroutines int
+	Heap       uint64 // bytes of heap in use
+}
+
+// usageSampler samples the resource usage of the process while a run progresses
+type usageSampler struct {
+	start   time.Time
+	prev    time.Time     // when the previous sample was taken
+	prevCPU time.Duration // CPU time used at the previous sample
+	cpu0    time.Duration // CPU time used before the run
+	numGC   uint32        // # garbage collections before the run
+	pause   uint64        // stop the world pause ns before the run
+	usage   ClientUsage
+}
+
+func newUsageSampler(start time.Time) *usageSampler {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	cpu, _ := processUsage()
+
+	return &usageSampler{
+		start:   start,
+		prev:    start,
+		prevCPU: cpu,
+		cpu0:    cpu,
+		numGC:   ms.NumGC,
+		pause:   ms.PauseTotalNs,
+		usage:   ClientUsage{NumCPU: runtime.GOMAXPROCS(0)},
+	}
+}
+
+// sample the usage since the previous sample
+func (u *usageSampler) sample(now time.Time) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	cpu, rss := processUsage()
+
+	s := UsageSample{
+		Start:      u.prev.Sub(u.start),
+		Goroutines: runtime.NumGoroutine(),
+		Heap:       ms.HeapInuse,
+	}
+	if elapsed := now.Sub(u.prev); elapsed > 0 {
+		s.CPU = float64(cpu-u.prevCPU) / float64(elapsed)
+	}
+	u.prev = now
+	u.prevCPU = cpu
+
+	if s.CPU > u.usage.PeakCPU {
+		u.usage.PeakCPU = s.CPU
+	}
+	if s.Goroutines > u.usage.MaxGoroutines {
+		u.usage.MaxGoroutines = s.Goroutines
+	}
+	if s.Heap > u.usage.MaxHeap {
+		u.usage.MaxHeap = s.Heap
+	}
+	u.usage.MaxRSS = rss
+	u.usage.GCCycles = ms.NumGC - u.numGC
+	u.usage.GCPause = time.Duration(ms.PauseTotalNs - u.pause)
+	u.usage.Samples = append(u.usage.Samples, s)
+}
+
+// finish takes the last sample and returns the usage of the whole run
+func (u *usageSampler) finish(now time.Time) *ClientUsage {
+	u.sample(now)
+	if elapsed := now.Sub(u.start); elapsed > 0 {
+		u.usage.CPU = float64(u.prevCPU-u.cpu0) / float64(elapsed)
+	}
+
+	usage := u.usage
+	return &usage
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package dbperf
+
+import "time"
+
+// processUsage isn't supported on this platform, the usage reported is limited to the Go runtime's
+func processUsage() (time.Duration, int64) {
+	return 0, 0
+}
@@ -0,0 +1,34 @@
+package dbperf
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageSampler(t *testing.T) {
+	start := time.Now()
+	u := newUsageSampler(start)
+
+	// burn some CPU
+	x := 0
+	for time.Since(start) < 20*time.Millisecond {
+		x++
+	}
+	u.sample(time.Now())
+	usage := u.finish(time.Now())
+
+	assert.Equal(t, runtime.GOMAXPROCS(0), usage.NumCPU)
+	assert.Len(t, usage.Samples, 2)
+	assert.Equal(t, time.Duration(0), usage.Samples[0].Start)
+	assert.True(t, usage.Samples[1].Start > 0)
+	assert.True(t, usage.MaxGoroutines > 0)
+	assert.True(t, usage.MaxHeap > 0)
+	if runtime.GOOS == "linux" {
+		assert.True(t, usage.CPU > 0, "cpu %f", usage.CPU)
+		assert.True(t, usage.PeakCPU >= usage.Samples[0].CPU)
+		assert.True(t, usage.MaxRSS > 0)
+	}
+}
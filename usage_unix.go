@@ -0,0 +1,26 @@
+//go:build unix
+
+package dbperf
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// processUsage returns the CPU time (user and system) used by the process so far and its peak resident set size in
+// bytes
+func processUsage() (time.Duration, int64) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, 0
+	}
+
+	cpu := time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+	rss := int64(ru.Maxrss)
+	if runtime.GOOS != "darwin" {
+		// kilobytes everywhere else
+		rss *= 1024
+	}
+	return cpu, rss
+}
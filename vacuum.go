@@ -0,0 +1,270 @@
+package dbperf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// autovacuumActiveQuery returns whether an autovacuum worker is vacuuming the table
+const autovacuumActiveQuery = `SELECT count(*) > 0 FROM pg_stat_progress_vacuum p JOIN pg_stat_activity a USING (pid)
+	WHERE p.relid = $1::regclass AND a.backend_type = 'autovacuum worker';`
+
+// reloptionsQuery returns the storage parameters set on the table
+const reloptionsQuery = `SELECT coalesce(reloptions, '{}') FROM pg_class WHERE oid = $1::regclass;`
+
+// aggressiveAutovacuum are the storage parameters making autovacuum process the table as soon as it has any dead
+// tuple, without throttling
+var aggressiveAutovacuum = []string{
+	"autovacuum_vacuum_threshold=0",
+	"autovacuum_vacuum_scale_factor=0",
+	"autovacuum_analyze_threshold=0",
+	"autovacuum_analyze_scale_factor=0",
+	"autovacuum_vacuum_cost_delay=0",
+}
+
+// defaultVacuumInterval is the default pause between manual vacuums and how often autovacuum is sampled by default
+const defaultVacuumInterval = timelineBucket
+
+// VacuumMode is how a table is vacuumed during a run
+type VacuumMode string
+
+const (
+	// VacuumManual vacuums the table with VACUUM over and over
+	VacuumManual VacuumMode = "manual"
+
+	// VacuumAutovacuum makes autovacuum process the table aggressively, as soon as it has any dead tuple and without
+	// throttling, for the duration of the run, the autovacuum launcher still waking up every autovacuum_naptime
+	VacuumAutovacuum VacuumMode = "autovacuum"
+)
+
+// VacuumConfig configures vacuuming a table during a run
+type VacuumConfig struct {
+	Table    string        // the table, may be schema qualified
+	Mode     VacuumMode    // manual if empty
+	Interval time.Duration // pause between manual vacuums, or how often autovacuum is sampled, every second if 0
+}
+
+func (cfg VacuumConfig) interval() time.Duration {
+	if cfg.Interval > 0 {
+		return cfg.Interval
+	}
+	return defaultVacuumInterval
+}
+
+// VacuumSpan is a vacuum of the table in progress during a run
+type VacuumSpan struct {
+	Start time.Duration // offset from the start of the run the vacuum started at, or was first sampled in progress at
+	End   time.Duration // offset it ended at, or was last sampled in progress at
+}
+
+// VacuumStats reports the vacuums of the table during a run (see SetVacuum)
+type VacuumStats struct {
+	Table    string
+	Mode     VacuumMode
+	Vacuums  int64        // # manual vacuums completed, or # autovacuums observed
+	Failures int64        // # manual vacuums or samples that failed
+	Spans    []VacuumSpan // the vacuums in progress
+}
+
+// Time returns the total time the table was being vacuumed
+func (s *VacuumStats) Time() time.Duration {
+	var total time.Duration
+	for _, span := range s.Spans {
+		total += span.End - span.Start
+	}
+	return total
+}
+
+// vacuumer vacuums a table, or samples autovacuum vacuuming it, until stopped
+type vacuumer struct {
+	cfg      VacuumConfig
+	db       Queryable
+	start    time.Time
+	table    string   // quoted table name
+	restore  []string // storage parameters to restore after the run in autovacuum mode
+	stats    VacuumStats
+	vacuumed bool // whether the last sample found autovacuum vacuuming the table
+}
+
+func newVacuumer(cfg VacuumConfig, db Queryable, start time.Time) *vacuumer {
+	if cfg.Mode == "" {
+		cfg.Mode = VacuumManual
+	}
+	return &vacuumer{
+		cfg:   cfg,
+		db:    db,
+		start: start,
+		table: quoteQualified(cfg.Table),
+		stats: VacuumStats{Table: cfg.Table, Mode: cfg.Mode},
+	}
+}
+
+// setup makes autovacuum aggressive on the table in autovacuum mode, remembering the storage parameters it replaces
+func (v *vacuumer) setup(ctx context.Context) error {
+	switch v.cfg.Mode {
+	case VacuumManual:
+		return nil
+	case VacuumAutovacuum:
+	default:
+		return fmt.Errorf("unknown vacuum mode %q", v.cfg.Mode)
+	}
+
+	var options pq.StringArray
+	if err := v.db.QueryRowContext(ctx, reloptionsQuery, v.table).Scan(&options); err != nil {
+		return fmt.Errorf("failed to read the storage parameters of %s: %s", v.cfg.Table, err)
+	}
+	for _, o := range options {
+		for _, a := range aggressiveAutovacuum {
+			if strings.SplitN(o, "=", 2)[0] == strings.SplitN(a, "=", 2)[0] {
+				v.restore = append(v.restore, o)
+			}
+		}
+	}
+
+	if _, err := v.db.ExecContext(ctx, "ALTER TABLE "+v.table+" SET ("+strings.Join(aggressiveAutovacuum, ", ")+");"); err != nil {
+		return fmt.Errorf("failed to make autovacuum aggressive on %s: %s", v.cfg.Table, err)
+	}
+	return nil
+}
+
+// teardown restores the storage parameters of the table setup replaced
+func (v *vacuumer) teardown(ctx context.Context) error {
+	if v.cfg.Mode != VacuumAutovacuum {
+		return nil
+	}
+
+	names := make([]string, len(aggressiveAutovacuum))
+	for i, a := range aggressiveAutovacuum {
+		names[i] = strings.SplitN(a, "=", 2)[0]
+	}
+	stmt := "ALTER TABLE " + v.table + " RESET (" + strings.Join(names, ", ") + ")"
+	if len(v.restore) > 0 {
+		stmt += ", SET (" + strings.Join(v.restore, ", ") + ")"
+	}
+	_, err := v.db.ExecContext(ctx, stmt+";")
+	return err
+}
+
+// run vacuums the table, or samples autovacuum, until stop is closed, cancelling the vacuum in flight, the stats are
+// sent on done when finished
+func (v *vacuumer) run(stop <-chan struct{}, done chan<- VacuumStats) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	if v.cfg.Mode == VacuumManual {
+		v.vacuum(ctx)
+	} else {
+		v.sampleAutovacuum(ctx)
+	}
+
+	if err := v.teardown(context.Background()); err != nil {
+		v.stats.Failures++
+	}
+	done <- v.stats
+}
+
+// vacuum vacuums the table over and over, pausing between vacuums, until ctx is cancelled
+func (v *vacuumer) vacuum(ctx context.Context) {
+	for {
+		start := time.Since(v.start)
+		_, err := v.db.ExecContext(ctx, "VACUUM "+v.table+";")
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			v.stats.Failures++
+		} else {
+			v.stats.Vacuums++
+			v.stats.Spans = append(v.stats.Spans, VacuumSpan{Start: start, End: time.Since(v.start)})
+		}
+
+		select {
+		case <-time.After(v.cfg.interval()):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sampleAutovacuum samples whether autovacuum is vacuuming the table until ctx is cancelled
+func (v *vacuumer) sampleAutovacuum(ctx context.Context) {
+	ticker := time.NewTicker(v.cfg.interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			v.sample(ctx, now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sample checks whether autovacuum is vacuuming the table, extending the current span or starting a new one
+func (v *vacuumer) sample(ctx context.Context, now time.Time) {
+	var active bool
+	if err := v.db.QueryRowContext(ctx, autovacuumActiveQuery, v.table).Scan(&active); err != nil {
+		if ctx.Err() == nil {
+			v.stats.Failures++
+		}
+		return
+	}
+
+	at := now.Sub(v.start)
+	switch {
+	case active && v.vacuumed:
+		v.stats.Spans[len(v.stats.Spans)-1].End = at
+	case active:
+		v.stats.Vacuums++
+		v.stats.Spans = append(v.stats.Spans, VacuumSpan{Start: at, End: at})
+	}
+	v.vacuumed = active
+}
+
+// markVacuums marks the buckets of the timeline the table was being vacuumed during
+func markVacuums(timeline []TimelineBucket, spans []VacuumSpan) {
+	for _, s := range spans {
+		for i := int(s.Start / timelineBucket); i <= int(s.End/timelineBucket) && i < len(timeline); i++ {
+			if i >= 0 {
+				timeline[i].Vacuum = true
+			}
+		}
+	}
+}
+
+// VacuumCorrelation compares the latency of the seconds of a run the table was being vacuumed during to the rest, to
+// quantify the impact of vacuum on the workload
+type VacuumCorrelation struct {
+	Seconds      int           // # seconds of the timeline of the run
+	Vacuuming    int           // # seconds the table was being vacuumed during
+	VacuumingP99 time.Duration // median p99 of the seconds the table was being vacuumed during
+	OtherP99     time.Duration // median p99 of the seconds it wasn't
+	Spikes       int           // # seconds whose p99 was over twice the median p99 of all seconds
+	VacuumSpikes int           // # spikes in seconds the table was being vacuumed during
+}
+
+// VacuumCorrelation correlates the vacuums of the table during the run with its latency timeline, nil if the table
+// wasn't vacuumed
+func (r *Report) VacuumCorrelation() *VacuumCorrelation {
+	if r.Vacuum == nil {
+		return nil
+	}
+
+	c := correlate(r.Timeline, func(i int) bool { return r.Timeline[i].Vacuum })
+	return &VacuumCorrelation{
+		Seconds:      c.seconds,
+		Vacuuming:    c.marked,
+		VacuumingP99: c.markedP99,
+		OtherP99:     c.otherP99,
+		Spikes:       c.spikes,
+		VacuumSpikes: c.markedSpikes,
+	}
+}
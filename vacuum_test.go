@@ -0,0 +1,136 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVacuumAutovacuum(t *testing.T) {
+	active := []bool{false, true, true, false, true}
+	var n int
+	var altered []string
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		switch {
+		case strings.Contains(query, "reloptions"):
+			assert.Equal(t, `"public"."cpu_usage"`, args[0])
+			return []string{"reloptions"}, [][]driver.Value{{"{fillfactor=90,autovacuum_vacuum_cost_delay=10}"}}
+		case strings.Contains(query, "pg_stat_progress_vacuum"):
+			rows := [][]driver.Value{{active[n]}}
+			n++
+			return []string{"active"}, rows
+		case strings.HasPrefix(query, "ALTER TABLE"):
+			altered = append(altered, query)
+		}
+		return nil, nil
+	})
+	defer db.Close()
+
+	start := time.Now()
+	v := newVacuumer(VacuumConfig{Table: "public.cpu_usage", Mode: VacuumAutovacuum}, db, start)
+	require.NoError(t, v.setup(context.Background()))
+	for i := range active {
+		v.sample(context.Background(), start.Add(time.Duration(i)*time.Second))
+	}
+	require.NoError(t, v.teardown(context.Background()))
+
+	assert.Equal(t, []string{
+		`ALTER TABLE "public"."cpu_usage" SET (autovacuum_vacuum_threshold=0, autovacuum_vacuum_scale_factor=0, autovacuum_analyze_threshold=0, autovacuum_analyze_scale_factor=0, autovacuum_vacuum_cost_delay=0);`,
+		`ALTER TABLE "public"."cpu_usage" RESET (autovacuum_vacuum_threshold, autovacuum_vacuum_scale_factor, autovacuum_analyze_threshold, autovacuum_analyze_scale_factor, autovacuum_vacuum_cost_delay), SET (autovacuum_vacuum_cost_delay=10);`,
+	}, altered)
+	assert.Equal(t, int64(2), v.stats.Vacuums)
+	assert.Equal(t, []VacuumSpan{{Start: time.Second, End: 2 * time.Second}, {Start: 4 * time.Second, End: 4 * time.Second}}, v.stats.Spans)
+	assert.Equal(t, time.Second, v.stats.Time())
+
+	timeline := make([]TimelineBucket, 4)
+	markVacuums(timeline, v.stats.Spans)
+	var marked []bool
+	for _, b := range timeline {
+		marked = append(marked, b.Vacuum)
+	}
+	assert.Equal(t, []bool{false, true, true, false}, marked)
+}
+
+func TestVacuumUnknownMode(t *testing.T) {
+	v := newVacuumer(VacuumConfig{Table: "cpu_usage", Mode: "full"}, nil, time.Now())
+	assert.EqualError(t, v.setup(context.Background()), `unknown vacuum mode "full"`)
+}
+
+func TestRunTestVacuumSetupFails(t *testing.T) {
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		return nil, nil
+	})
+	defer db.Close()
+
+	c := NewController(WithPoolSize(2))
+	c.SetInterference(InterferenceConfig{Queries: []string{"SELECT 1"}})
+	c.SetVacuum(VacuumConfig{Table: "cpu_usage", Mode: "full"})
+	_, err := c.RunTest(context.Background(), db, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	assert.EqualError(t, err, `unknown vacuum mode "full"`)
+
+	// nothing of the failed run is left running to keep the controller from running again
+	c.vacuum = nil
+	stats, err := c.RunTest(context.Background(), db, NewCPUTestGenerator(strings.NewReader(testQueries)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), stats.Processed)
+	assert.NotNil(t, stats.Interference)
+}
+
+func TestVacuumCorrelation(t *testing.T) {
+	r := &Report{QueryStats: &QueryStats{}}
+	assert.Nil(t, r.VacuumCorrelation())
+
+	ms := time.Millisecond
+	r.Vacuum = &VacuumStats{}
+	r.Timeline = []TimelineBucket{
+		{Processed: 10, P99: 10 * ms},
+		{Processed: 10, P99: 50 * ms, Vacuum: true},
+		{Processed: 10, P99: 14 * ms, Vacuum: true},
+		{Processed: 10, P99: 11 * ms},
+	}
+
+	assert.Equal(t, &VacuumCorrelation{
+		Seconds:      4,
+		Vacuuming:    2,
+		VacuumingP99: 14 * ms,
+		OtherP99:     10 * ms,
+		Spikes:       1,
+		VacuumSpikes: 1,
+	}, r.VacuumCorrelation())
+}
+
+func TestRunTestVacuum(t *testing.T) {
+	var mu sync.Mutex
+	vacuums := 0
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		if strings.HasPrefix(query, "VACUUM") {
+			assert.Equal(t, `VACUUM "cpu_usage";`, query)
+			mu.Lock()
+			vacuums++
+			mu.Unlock()
+		}
+		return nil, nil
+	})
+	defer db.Close()
+
+	report := runInBackground(t, db, 50*time.Millisecond, func(c *Controller) {
+		c.SetVacuum(VacuumConfig{Table: "cpu_usage", Interval: 5 * time.Millisecond})
+	})
+	vs := report.Vacuum
+	require.NotNil(t, vs)
+	assert.Equal(t, VacuumManual, vs.Mode)
+	assert.True(t, vs.Vacuums > 1, vs.Vacuums)
+	assert.Len(t, vs.Spans, int(vs.Vacuums))
+	mu.Lock()
+	// the vacuum in flight at the end of the run is cancelled and not counted
+	assert.True(t, vacuums >= int(vs.Vacuums), vacuums)
+	mu.Unlock()
+	require.NotNil(t, report.VacuumCorrelation())
+	assert.True(t, report.Timeline[0].Vacuum)
+}
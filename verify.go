@@ -0,0 +1,192 @@
+package dbperf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMismatchSamples is the # of mismatched results kept in the verification stats of a run
+const maxMismatchSamples = 10
+
+// checksumPrefix prefixes checksums of results to tell them apart from row counts
+const checksumPrefix = "sha256:"
+
+// Expectation is the result a query is expected to return, for the workers to verify the database answers correctly
+// while its performance is measured, e.g. across a migration
+type Expectation struct {
+	Rows     int64  // # rows expected, -1 to not verify it
+	Checksum string // checksum of the rows expected (see ResultSummary), empty to not verify it
+}
+
+// ParseExpectation parses an expectation written as the # rows expected, the checksum of the rows expected
+// (sha256:HEX, see ResultSummary) or both separated by a space. It returns nil for an empty string.
+func ParseExpectation(s string) (*Expectation, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	e := &Expectation{Rows: -1}
+	for _, f := range fields {
+		if strings.HasPrefix(f, checksumPrefix) {
+			if e.Checksum != "" {
+				return nil, fmt.Errorf("invalid expectation %q: more than one checksum", s)
+			}
+			e.Checksum = f
+			continue
+		}
+
+		n, err := strconv.ParseInt(f, 10, 64)
+		if err != nil || n < 0 || e.Rows >= 0 {
+			return nil, fmt.Errorf("invalid expectation %q: expected a row count and/or %sHEX", s, checksumPrefix)
+		}
+		e.Rows = n
+	}
+	return e, nil
+}
+
+// Match reports whether the result of a query meets the expectation
+func (e *Expectation) Match(s ResultSummary) bool {
+	return (e.Rows < 0 || e.Rows == s.Rows) && (e.Checksum == "" || e.Checksum == s.Checksum)
+}
+
+func (e *Expectation) String() string {
+	var fields []string
+	if e.Rows >= 0 {
+		fields = append(fields, strconv.FormatInt(e.Rows, 10))
+	}
+	if e.Checksum != "" {
+		fields = append(fields, e.Checksum)
+	}
+	return strings.Join(fields, " ")
+}
+
+// ResultSummary summarizes the rows returned by a query: their count and a checksum of their values, independent of
+// the order of the rows which queries without an ORDER BY don't guarantee
+type ResultSummary struct {
+	Rows     int64
+	Checksum string
+}
+
+// String formats the summary as an expectation (see ParseExpectation) the result matches
+func (s ResultSummary) String() string {
+	return fmt.Sprintf("%d %s", s.Rows, s.Checksum)
+}
+
+// SummarizeRows reads every row, closing them, and summarizes them
+func SummarizeRows(rows *sql.Rows) (ResultSummary, error) {
+	return summarizeRows(rows, nil)
+}
+
+// summarizeRows reads every row, closing them, and summarizes them, passing the formatted values of every row to fn
+// if set
+func summarizeRows(rows *sql.Rows, fn func(row []string)) (ResultSummary, error) {
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return ResultSummary{}, err
+	}
+
+	values := make([]interface{}, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var hashes [][sha256.Size]byte
+	var buf bytes.Buffer
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return ResultSummary{}, err
+		}
+
+		buf.Reset()
+		var row []string
+		if fn != nil {
+			row = make([]string, len(values))
+		}
+		for i, v := range values {
+			if i > 0 {
+				buf.WriteByte('\t')
+			}
+			s := formatValue(v)
+			buf.WriteString(s)
+			if row != nil {
+				row[i] = s
+			}
+		}
+		if fn != nil {
+			fn(row)
+		}
+		hashes = append(hashes, sha256.Sum256(buf.Bytes()))
+	}
+	if err := rows.Err(); err != nil {
+		return ResultSummary{}, err
+	}
+
+	// the checksum of the rows in a canonical order
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+	h := sha256.New()
+	for _, rh := range hashes {
+		h.Write(rh[:])
+	}
+	return ResultSummary{Rows: int64(len(hashes)), Checksum: checksumPrefix + hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// formatValue formats a value scanned by database/sql the same way whatever the driver returned it as
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Mismatch is a query that returned a different result than expected
+type Mismatch struct {
+	Query    string
+	Args     []interface{}
+	Expected string // expectation of the query
+	Actual   string // summary of the result returned
+}
+
+// VerifyStats reports the results of the queries verified against their expectation (see Query.Expect)
+type VerifyStats struct {
+	Verified   int64      // # queries verified
+	Mismatches int64      // # queries that returned a different result than expected
+	Samples    []Mismatch // the first mismatches
+}
+
+// verifyQuery executes the query and checks its result meets its expectation, returning the # rows read and the
+// mismatch if it doesn't
+func verifyQuery(ctx context.Context, db Queryable, q *Query) (int64, *Mismatch, error) {
+	rows, err := db.QueryContext(ctx, q.Query, q.Args...)
+	if err != nil {
+		return 0, nil, err
+	}
+	s, err := SummarizeRows(rows)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if q.Expect.Match(s) {
+		return s.Rows, nil, nil
+	}
+	return s.Rows, &Mismatch{Query: q.Query, Args: q.Args, Expected: q.Expect.String(), Actual: s.String()}, nil
+}
@@ -0,0 +1,198 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpectation(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected *Expectation
+		err      bool
+	}{
+		{"", nil, false},
+		{"60", &Expectation{Rows: 60}, false},
+		{"sha256:ab12", &Expectation{Rows: -1, Checksum: "sha256:ab12"}, false},
+		{" 60  sha256:ab12 ", &Expectation{Rows: 60, Checksum: "sha256:ab12"}, false},
+		{"-1", nil, true},
+		{"60 61", nil, true},
+		{"md5:ab12", nil, true},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseExpectation(tt.s)
+		if tt.err {
+			assert.Error(t, err, tt.s)
+			continue
+		}
+		assert.NoError(t, err, tt.s)
+		assert.Equal(t, tt.expected, e, tt.s)
+	}
+
+	e := &Expectation{Rows: 2, Checksum: "sha256:ab12"}
+	assert.Equal(t, "2 sha256:ab12", e.String())
+	assert.True(t, e.Match(ResultSummary{Rows: 2, Checksum: "sha256:ab12"}))
+	assert.False(t, e.Match(ResultSummary{Rows: 2, Checksum: "sha256:cd34"}))
+	assert.True(t, (&Expectation{Rows: -1, Checksum: "sha256:ab12"}).Match(ResultSummary{Rows: 5, Checksum: "sha256:ab12"}))
+}
+
+func TestSummarizeRows(t *testing.T) {
+	ts := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	summarize := func(rows ...[]driver.Value) ResultSummary {
+		db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+			return []string{"minute", "min", "max"}, rows
+		})
+		defer db.Close()
+
+		r, err := db.Query("SELECT")
+		require.NoError(t, err)
+		s, err := SummarizeRows(r)
+		require.NoError(t, err)
+		return s
+	}
+
+	a := []driver.Value{ts, 1.5, []byte("host_1")}
+	b := []driver.Value{ts.Add(time.Minute), nil, int64(2)}
+	s := summarize(a, b)
+	assert.Equal(t, int64(2), s.Rows)
+	assert.True(t, strings.HasPrefix(s.Checksum, "sha256:"))
+
+	// independent of the order of the rows but not of their values
+	assert.Equal(t, s, summarize(b, a))
+	assert.NotEqual(t, s.Checksum, summarize(a, a).Checksum)
+	assert.Equal(t, int64(0), summarize().Rows)
+}
+
+func TestRunTestVerify(t *testing.T) {
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		// host_2 lost a row
+		if args[0] == "host_2" {
+			return []string{"n"}, [][]driver.Value{{int64(1)}}
+		}
+		return []string{"n"}, [][]driver.Value{{int64(1)}, {int64(2)}}
+	})
+	defer db.Close()
+
+	queries := []*Query{
+		{Query: "SELECT n", Args: []interface{}{"host_1"}, Expect: &Expectation{Rows: 2}, key: "host_1"},
+		{Query: "SELECT n", Args: []interface{}{"host_2"}, Expect: &Expectation{Rows: 2}, key: "host_2"},
+		{Query: "SELECT n", Args: []interface{}{"host_3"}, key: "host_3"},
+	}
+	logger := &testLogger{}
+	c := NewController(WithPoolSize(1), WithLogger(logger))
+	report, err := c.RunTest(context.Background(), db, &replayGenerator{queries: queries, n: 6})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(6), report.Processed)
+	require.NotNil(t, report.Verification)
+	assert.Equal(t, int64(4), report.Verification.Verified)
+	assert.Equal(t, int64(2), report.Verification.Mismatches)
+	require.Len(t, report.Verification.Samples, 2)
+	m := report.Verification.Samples[0]
+	assert.Equal(t, []interface{}{"host_2"}, m.Args)
+	assert.Equal(t, "2", m.Expected)
+	assert.True(t, strings.HasPrefix(m.Actual, "1 sha256:"), m.Actual)
+	assert.Contains(t, logger.messages, "WARN result mismatch")
+}
+
+// fakeQueryFunc returns the columns and rows of a query executed on a fake database
+type fakeQueryFunc func(query string, args []driver.Value) ([]string, [][]driver.Value)
+
+// fakeDBs are the fake databases open by name
+var fakeDBs = struct {
+	sync.Mutex
+	funcs map[string]fakeQueryFunc
+}{funcs: make(map[string]fakeQueryFunc)}
+
+func init() {
+	sql.Register("dbperf-fake", fakeDriver{})
+}
+
+// openFakeDB opens a database returning the rows of fn to every query, for the tests of queries reading rows
+func openFakeDB(t *testing.T, fn fakeQueryFunc) *sql.DB {
+	fakeDBs.Lock()
+	fakeDBs.funcs[t.Name()] = fn
+	fakeDBs.Unlock()
+
+	db, err := sql.Open("dbperf-fake", t.Name())
+	require.NoError(t, err)
+	return db
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBs.Lock()
+	defer fakeDBs.Unlock()
+	return &fakeDriverConn{fn: fakeDBs.funcs[name]}, nil
+}
+
+type fakeDriverConn struct {
+	fn fakeQueryFunc
+}
+
+func (c *fakeDriverConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c: c, query: query}, nil
+}
+func (c *fakeDriverConn) Close() error              { return nil }
+func (c *fakeDriverConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeStmt struct {
+	c     *fakeDriverConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+// Exec reports the rows of the query as the rows it affected
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, rows := s.c.fn(s.query, args)
+	return driver.RowsAffected(len(rows)), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	cols, rows := s.c.fn(s.query, args)
+	return &fakeRows{cols: cols, rows: rows}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+// ColumnTypeScanType returns the type of the values of the column in the first row
+func (r *fakeRows) ColumnTypeScanType(i int) reflect.Type {
+	if len(r.rows) == 0 || r.rows[0][i] == nil {
+		return reflect.TypeOf(new(interface{})).Elem()
+	}
+	return reflect.TypeOf(r.rows[0][i])
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,144 @@
+package dbperf
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// waitEventsQuery counts the sessions of the current database busy with a query or transaction by the wait event
+// they are waiting on, CPU when not waiting, only those with the application_name $1 unless it's empty. The session
+// sampling is excluded.
+const waitEventsQuery = `SELECT COALESCE(wait_event_type, 'CPU'), COALESCE(wait_event, 'CPU'), count(*)
+	FROM pg_stat_activity WHERE state <> 'idle' AND pid <> pg_backend_pid() AND datname = current_database()
+	AND ($1 = '' OR application_name = $1)
+	GROUP BY 1, 2;`
+
+// defaultWaitInterval is how often wait events are sampled by default
+const defaultWaitInterval = 100 * time.Millisecond
+
+// WaitConfig configures the sampling of the wait events of sessions during a run
+type WaitConfig struct {
+	Interval        time.Duration // how often to sample, 100ms if 0
+	ApplicationName string        // only the sessions with this application_name are sampled if set
+}
+
+func (cfg WaitConfig) interval() time.Duration {
+	if cfg.Interval > 0 {
+		return cfg.Interval
+	}
+	return defaultWaitInterval
+}
+
+// WaitStats reports the wait events of the sessions sampled during a run (see SetWaitSampling), each session seen
+// attributing a sample interval of server time to the event it waited on
+type WaitStats struct {
+	Interval time.Duration    // time between samples
+	Samples  int64            // # samples taken
+	Failures int64            // # samples that failed
+	Events   map[string]int64 // # sessions seen by wait event (TYPE:EVENT, e.g. IO:DataFileRead, CPU:CPU when not waiting)
+}
+
+// Time estimates the server time spent on the event, every session seen on it spending a sample interval
+func (s *WaitStats) Time(event string) time.Duration {
+	return time.Duration(s.Events[event]) * s.Interval
+}
+
+// Total estimates the server time spent executing the queries and transactions of the sessions sampled
+func (s *WaitStats) Total() time.Duration {
+	var n int64
+	for _, c := range s.Events {
+		n += c
+	}
+	return time.Duration(n) * s.Interval
+}
+
+// ByType estimates the server time spent on every wait event type (IO, LWLock, Lock, Client, CPU, ...)
+func (s *WaitStats) ByType() map[string]time.Duration {
+	types := make(map[string]time.Duration)
+	for e := range s.Events {
+		t := e
+		if i := strings.IndexByte(e, ':'); i >= 0 {
+			t = e[:i]
+		}
+		types[t] += s.Time(e)
+	}
+	return types
+}
+
+// Top returns the n wait events with the most server time, all of them if n <= 0
+func (s *WaitStats) Top(n int) []string {
+	events := make([]string, 0, len(s.Events))
+	for e := range s.Events {
+		events = append(events, e)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if s.Events[events[i]] != s.Events[events[j]] {
+			return s.Events[events[i]] > s.Events[events[j]]
+		}
+		return events[i] < events[j]
+	})
+	if n > 0 && len(events) > n {
+		events = events[:n]
+	}
+	return events
+}
+
+// waitSampler samples the wait events of the database's sessions until stopped
+type waitSampler struct {
+	cfg   WaitConfig
+	db    Queryable
+	stats WaitStats
+}
+
+func newWaitSampler(cfg WaitConfig, db Queryable) *waitSampler {
+	return &waitSampler{
+		cfg:   cfg,
+		db:    db,
+		stats: WaitStats{Interval: cfg.interval(), Events: make(map[string]int64)},
+	}
+}
+
+// run samples wait events until stop is closed, the stats are sent on done when finished
+func (ws *waitSampler) run(stop <-chan struct{}, done chan<- WaitStats) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ticker := time.NewTicker(ws.stats.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ws.sample(ctx)
+		case <-stop:
+			done <- ws.stats
+			return
+		}
+	}
+}
+
+// sample counts the busy sessions by wait event
+func (ws *waitSampler) sample(ctx context.Context) {
+	ws.stats.Samples++
+	rows, err := ws.db.QueryContext(ctx, waitEventsQuery, ws.cfg.ApplicationName)
+	if err != nil {
+		ws.stats.Failures++
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var waitType, event string
+		var n int64
+		if err := rows.Scan(&waitType, &event, &n); err != nil {
+			ws.stats.Failures++
+			return
+		}
+		ws.stats.Events[waitType+":"+event] += n
+	}
+	if rows.Err() != nil {
+		ws.stats.Failures++
+	}
+}
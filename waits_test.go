@@ -0,0 +1,61 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitSample(t *testing.T) {
+	waits := [][][]driver.Value{
+		{{"IO", "DataFileRead", int64(2)}, {"CPU", "CPU", int64(1)}},
+		nil,
+		{{"IO", "DataFileRead", int64(1)}, {"IO", "WALSync", int64(1)}, {"LWLock", "WALWrite", int64(3)}},
+	}
+	var n int
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		assert.Equal(t, "dbperf", args[0])
+		rows := waits[n]
+		n++
+		return []string{"wait_event_type", "wait_event", "count"}, rows
+	})
+	defer db.Close()
+
+	ws := newWaitSampler(WaitConfig{ApplicationName: "dbperf"}, db)
+	for range waits {
+		ws.sample(context.Background())
+	}
+
+	s := ws.stats
+	ms := time.Millisecond
+	assert.Equal(t, defaultWaitInterval, s.Interval)
+	assert.Equal(t, int64(3), s.Samples)
+	assert.Equal(t, map[string]int64{"IO:DataFileRead": 3, "IO:WALSync": 1, "CPU:CPU": 1, "LWLock:WALWrite": 3}, s.Events)
+	assert.Equal(t, 800*ms, s.Total())
+	assert.Equal(t, 300*ms, s.Time("LWLock:WALWrite"))
+	assert.Equal(t, map[string]time.Duration{"IO": 400 * ms, "LWLock": 300 * ms, "CPU": 100 * ms}, s.ByType())
+	assert.Equal(t, []string{"IO:DataFileRead", "LWLock:WALWrite"}, s.Top(2))
+	assert.Len(t, s.Top(0), 4)
+}
+
+func TestRunTestWaits(t *testing.T) {
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		if strings.Contains(query, "wait_event") {
+			return []string{"wait_event_type", "wait_event", "count"}, [][]driver.Value{{"Client", "ClientRead", int64(1)}}
+		}
+		return nil, nil
+	})
+	defer db.Close()
+
+	report := runInBackground(t, db, 100*time.Millisecond, func(c *Controller) {
+		c.SetWaitSampling(WaitConfig{Interval: 10 * time.Millisecond})
+	})
+	require.NotNil(t, report.Waits)
+	assert.True(t, report.Waits.Samples > 0)
+	assert.Equal(t, report.Waits.Samples-report.Waits.Failures, report.Waits.Events["Client:ClientRead"])
+}
@@ -0,0 +1,90 @@
+package dbperf
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// walLSNQuery returns the current WAL write location, and whether pg_stat_wal (PostgreSQL 14+) exists
+	walLSNQuery = `SELECT pg_current_wal_lsn()::text, to_regclass('pg_catalog.pg_stat_wal') IS NOT NULL;`
+
+	// walStatsQuery returns the WAL records, full page images and bytes generated by the server since its stats were
+	// reset
+	walStatsQuery = `SELECT wal_records, wal_fpi, wal_bytes::bigint FROM pg_stat_wal;`
+)
+
+// WALSnapshot is a snapshot of the WAL generated by a server
+type WALSnapshot struct {
+	LSN     uint64 // current WAL write location
+	Stats   bool   // the server has pg_stat_wal, the counters below are only set if it has
+	Records int64
+	FPI     int64 // full page images
+	Bytes   int64
+}
+
+// TakeWALSnapshot takes a snapshot of the WAL generated by the server db is connected to. WAL is generated by the
+// server as a whole, writes of other clients and databases count as well. It fails on standbys.
+func TakeWALSnapshot(ctx context.Context, db Queryable) (*WALSnapshot, error) {
+	s := &WALSnapshot{}
+	var lsn string
+	if err := db.QueryRowContext(ctx, walLSNQuery).Scan(&lsn, &s.Stats); err != nil {
+		return nil, err
+	}
+	var err error
+	if s.LSN, err = parseLSN(lsn); err != nil {
+		return nil, err
+	}
+
+	if s.Stats {
+		if err := db.QueryRowContext(ctx, walStatsQuery).Scan(&s.Records, &s.FPI, &s.Bytes); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// parseLSN parses a WAL location in its text form, e.g. 16/B374D848
+func parseLSN(s string) (uint64, error) {
+	var hi, lo uint32
+	if _, err := fmt.Sscanf(s, "%X/%X", &hi, &lo); err != nil {
+		return 0, fmt.Errorf("invalid LSN %q: %s", s, err)
+	}
+	return uint64(hi)<<32 | uint64(lo), nil
+}
+
+// WALReport reports the WAL generated during a run, the key efficiency metric of ingest, per query and per row
+// written
+type WALReport struct {
+	Bytes   int64 `json:"bytes"`             // WAL written, by the distance between the WAL locations
+	Records int64 `json:"records,omitempty"` // WAL records, if the server has pg_stat_wal
+	FPI     int64 `json:"fpi,omitempty"`     // full page images, if the server has pg_stat_wal
+	Queries int64 `json:"queries"`           // # queries (transactions) of the run
+	Rows    int64 `json:"rows"`              // # rows the queries affected (see QueryStats.Affected)
+}
+
+// WALDelta reports the WAL generated between the snapshots by the queries of the run with the stats
+func WALDelta(before, after *WALSnapshot, stats *QueryStats) *WALReport {
+	r := &WALReport{Bytes: int64(after.LSN - before.LSN), Queries: stats.Processed, Rows: stats.Affected}
+	if before.Stats && after.Stats {
+		r.Records = after.Records - before.Records
+		r.FPI = after.FPI - before.FPI
+	}
+	return r
+}
+
+// BytesPerQuery returns the WAL bytes generated per query, 0 without queries
+func (r *WALReport) BytesPerQuery() float64 {
+	if r.Queries == 0 {
+		return 0
+	}
+	return float64(r.Bytes) / float64(r.Queries)
+}
+
+// BytesPerRow returns the WAL bytes generated per row affected, 0 if no row was
+func (r *WALReport) BytesPerRow() float64 {
+	if r.Rows == 0 {
+		return 0
+	}
+	return float64(r.Bytes) / float64(r.Rows)
+}
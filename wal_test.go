@@ -0,0 +1,63 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLSN(t *testing.T) {
+	lsn, err := parseLSN("16/B374D848")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x16B374D848), lsn)
+
+	_, err = parseLSN("B374D848")
+	assert.Error(t, err)
+}
+
+func TestWALDelta(t *testing.T) {
+	lsn, bytes := "0/1000", int64(100)
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		if strings.Contains(query, "pg_current_wal_lsn") {
+			return []string{"lsn", "stats"}, [][]driver.Value{{lsn, true}}
+		}
+		return []string{"wal_records", "wal_fpi", "wal_bytes"}, [][]driver.Value{{bytes / 10, bytes / 100, bytes}}
+	})
+	defer db.Close()
+
+	before, err := TakeWALSnapshot(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, &WALSnapshot{LSN: 0x1000, Stats: true, Records: 10, FPI: 1, Bytes: 100}, before)
+
+	lsn, bytes = "1/1000", 100+1<<32
+	after, err := TakeWALSnapshot(context.Background(), db)
+	require.NoError(t, err)
+
+	r := WALDelta(before, after, &QueryStats{Processed: 1 << 10, Affected: 1 << 20})
+	assert.Equal(t, int64(1<<32), r.Bytes)
+	assert.Equal(t, (bytes-100)/10, r.Records)
+	assert.Equal(t, float64(1<<22), r.BytesPerQuery())
+	assert.Equal(t, float64(1<<12), r.BytesPerRow())
+
+	// the counters of pg_stat_wal are left out unless both snapshots have them
+	before.Stats = false
+	assert.Equal(t, int64(0), WALDelta(before, after, &QueryStats{}).Records)
+	assert.Equal(t, 0.0, WALDelta(before, after, &QueryStats{}).BytesPerRow())
+}
+
+func TestRunTestAffected(t *testing.T) {
+	db := openFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value) {
+		return nil, [][]driver.Value{{1}, {2}}
+	})
+	defer db.Close()
+
+	c := NewController(WithPoolSize(2))
+	report, err := c.RunTest(context.Background(), db, &replayGenerator{queries: []*Query{{Query: "INSERT", key: "a"}}, n: 3})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), report.Processed)
+	assert.Equal(t, int64(6), report.Affected)
+}
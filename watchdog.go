@@ -0,0 +1,96 @@
+package dbperf
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrStalled is returned by RunTest when the run stalled and the watchdog is configured to abort it, see SetWatchdog
+var ErrStalled = errors.New("run stalled: no queries completed while queries were in flight")
+
+// sessionsTimeout bounds listing the sessions of a stalled run, the connection pool may be exhausted by the run itself
+const sessionsTimeout = 5 * time.Second
+
+const sessionsQuery = `SELECT pid, coalesce(state, ''), coalesce(wait_event_type || ':' || wait_event, ''),
+	coalesce(extract(epoch FROM now() - query_start), 0), coalesce(query, '') FROM pg_stat_activity
+	WHERE application_name = $1 AND datname = current_database() AND pid <> pg_backend_pid() ORDER BY pid;`
+
+// WatchdogConfig configures the detection of stalled runs
+type WatchdogConfig struct {
+	Stall           time.Duration // a run has stalled when no query completed for this long while queries are in flight
+	Abort           bool          // abort a stalled run with ErrStalled instead of only logging its state
+	ApplicationName string        // the sessions with this application_name, i.e. the benchmark's own, are logged if set
+}
+
+// Session is a server session as listed by pg_stat_activity
+type Session struct {
+	PID       int
+	State     string        // e.g. active or idle
+	WaitEvent string        // TYPE:EVENT the session is waiting on, empty if it isn't
+	Running   time.Duration // time since the current (or, when idle, the last) query started
+	Query     string        // current or last query
+}
+
+// Sessions lists the sessions of the current database with the given application name other than the one listing
+// them, e.g. to find the backends of queries that hang
+func Sessions(ctx context.Context, db Queryable, applicationName string) ([]Session, error) {
+	rows, err := db.QueryContext(ctx, sessionsQuery, applicationName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var s Session
+		var running float64
+		if err := rows.Scan(&s.PID, &s.State, &s.WaitEvent, &running, &s.Query); err != nil {
+			return nil, err
+		}
+		s.Running = time.Duration(running * float64(time.Second))
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// checkInterval is how often the run is checked for a stall
+func (cfg WatchdogConfig) checkInterval() time.Duration {
+	if d := cfg.Stall / 4; d > 10*time.Millisecond {
+		return d
+	}
+	return 10 * time.Millisecond
+}
+
+// logStall logs the state of a stalled run: the query every busy worker is stuck on and, if configured, the
+// benchmark's sessions on the server
+func (c *Controller) logStall(ctx context.Context, db Queryable, stalled time.Duration) {
+	c.logger.Warn("run stalled", "no_completions_for", stalled, "inflight", c.inflight)
+
+	c.poolMu.Lock()
+	workers := c.workers
+	c.poolMu.Unlock()
+	now := time.Now()
+	for _, w := range workers {
+		q, since := w.status()
+		if q == nil {
+			continue
+		}
+		c.logger.Warn("stalled worker", "worker", w.id, "running", now.Sub(since), "queued", len(w.jobs), "query", q.Query, "args", q.Args)
+	}
+
+	if c.watchdog.ApplicationName == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sessionsTimeout)
+	defer cancel()
+	sessions, err := Sessions(ctx, db, c.watchdog.ApplicationName)
+	if err != nil {
+		c.logger.Warn("failed to list sessions of the stalled run", "err", err)
+		return
+	}
+	for _, s := range sessions {
+		c.logger.Warn("stalled run session", "pid", s.PID, "state", s.State, "wait_event", s.WaitEvent, "running", s.Running, "query", s.Query)
+	}
+}
@@ -0,0 +1,69 @@
+package dbperf
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+	"timescale/dbperf/test/mocks/mock_dbperf"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunTestWatchdog(t *testing.T) {
+	t.Run("log", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mdb := mock_dbperf.NewMockQueryable(ctrl)
+		gomock.InOrder(
+			mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+					time.Sleep(200 * time.Millisecond)
+					return nil, nil
+				}),
+			mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(9),
+		)
+
+		logger := &testLogger{}
+		c := NewController(WithLogger(logger), WithWatchdog(WatchdogConfig{Stall: 50 * time.Millisecond}))
+		report, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), report.Processed)
+
+		// logged once while the query was stuck
+		assert.Equal(t, []string{
+			"DEBUG run started",
+			"WARN run stalled",
+			"WARN stalled worker",
+			"DEBUG run finished",
+		}, logger.messages)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mdb := mock_dbperf.NewMockQueryable(ctrl)
+		gomock.InOrder(
+			mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+					// hangs until cancelled
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}),
+			mdb.EXPECT().ExecContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil).Times(10),
+		)
+
+		c := NewController(WithWatchdog(WatchdogConfig{Stall: 50 * time.Millisecond, Abort: true}))
+		_, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+		assert.Equal(t, ErrStalled, err)
+
+		// the stuck query was cancelled so the controller can run again
+		report, err := c.RunTest(context.Background(), mdb, NewCPUTestGenerator(strings.NewReader(testQueries)))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), report.Processed)
+	})
+}
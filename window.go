@@ -0,0 +1,98 @@
+package dbperf
+
+import (
+	"sync"
+	"time"
+)
+
+// recentSlots is the # of seconds of results kept for RecentStats, the longest window it summarizes
+const recentSlots = 60
+
+// recentSlot is the results of one second of the run
+type recentSlot struct {
+	sec       int64 // unix time of the second
+	latencies *Histogram
+	errors    int64
+}
+
+// recentWindow keeps the results of the last seconds of a run by second to summarize them over a sliding window. It's
+// written by the collector and read by whoever follows the run live.
+type recentWindow struct {
+	mu    sync.Mutex
+	start time.Time // when the run started
+	slots [recentSlots]recentSlot
+}
+
+// reset clears the results of a previous run
+func (w *recentWindow) reset(start time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.start = start
+	w.slots = [recentSlots]recentSlot{}
+}
+
+// slot returns the slot of the second of now, recycling that of an older second
+func (w *recentWindow) slot(now time.Time) *recentSlot {
+	sec := now.Unix()
+	s := &w.slots[sec%recentSlots]
+	if s.sec != sec || s.latencies == nil {
+		*s = recentSlot{sec: sec, latencies: NewHistogram(nil)}
+	}
+	return s
+}
+
+// record a query completed at now
+func (w *recentWindow) record(now time.Time, d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.slot(now).latencies.Record(d)
+}
+
+// recordError records a query that failed at now
+func (w *recentWindow) recordError(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.slot(now).errors++
+}
+
+// stats summarizes the results of the seconds within window of now, the current one included
+func (w *recentWindow) stats(now time.Time, window time.Duration) *QueryStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	secs := int64((window + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	} else if secs > recentSlots {
+		secs = recentSlots
+	}
+
+	cur := now.Unix()
+	h := NewHistogram(nil)
+	var errors int64
+	for _, s := range w.slots {
+		if s.latencies != nil && s.sec > cur-secs && s.sec <= cur {
+			h.Merge(s.latencies)
+			errors += s.errors
+		}
+	}
+
+	stats := histogramStats(h)
+	stats.Errors = errors
+	from := time.Unix(cur-secs+1, 0)
+	if from.Before(w.start) {
+		from = w.start
+	}
+	stats.Duration = now.Sub(from)
+	return stats
+}
+
+// RecentStats summarizes the queries of the current run completed within the last window (rounded up to whole
+// seconds, up to a minute) so degradation mid-run is visible as it happens rather than only in the final stats. It's
+// safe to call while the run progresses, the percentiles are within the histogram precision.
+func (c *Controller) RecentStats(window time.Duration) *QueryStats {
+	return c.recent.stats(time.Now(), window)
+}
@@ -0,0 +1,50 @@
+package dbperf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentWindow(t *testing.T) {
+	ms := time.Millisecond
+	start := time.Unix(1000, 0)
+	var w recentWindow
+	w.reset(start)
+
+	// 10ms queries for a minute, then 100ms ones for the last 10s
+	for sec := 0; sec < 70; sec++ {
+		now := start.Add(time.Duration(sec)*time.Second + 500*ms)
+		latency := 10 * ms
+		if sec >= 60 {
+			latency = 100 * ms
+		}
+		for i := 0; i < 10; i++ {
+			w.record(now, latency)
+		}
+		if sec == 65 {
+			w.recordError(now)
+		}
+	}
+	now := start.Add(69*time.Second + 500*ms)
+
+	recent := w.stats(now, 10*time.Second)
+	assert.Equal(t, int64(100), recent.Processed)
+	assert.Equal(t, int64(1), recent.Errors)
+	assert.InEpsilon(t, float64(100*ms), float64(recent.P99), 0.02)
+	assert.Equal(t, 9500*ms, recent.Duration)
+	assert.InEpsilon(t, 10.5, recent.Throughput(), 0.01)
+
+	// the degradation only shows in part of a longer window
+	longer := w.stats(now, 20*time.Second)
+	assert.Equal(t, int64(200), longer.Processed)
+	assert.InEpsilon(t, float64(10*ms), float64(longer.Min), 0.02)
+
+	// windows are capped at the seconds kept
+	assert.Equal(t, int64(600), w.stats(now, time.Hour).Processed)
+
+	// the seconds of a previous run are forgotten
+	w.reset(now)
+	assert.Equal(t, int64(0), w.stats(now, 10*time.Second).Processed)
+}